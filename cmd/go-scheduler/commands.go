@@ -0,0 +1,312 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/admin"
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// DefaultAddr is the address of the admin API when neither -addr nor GO_SCHEDULER_ADDR is set
+const DefaultAddr = "http://127.0.0.1:8080"
+
+func runValidate(e env, args []string) error {
+	fs := newFlagSet(e, "validate", "<directory>")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected a directory")
+	}
+
+	handlers, err := newHandlerRepository()
+	if err != nil {
+		return err
+	}
+	definitions, err := loadDefinitions(fs.Arg(0), task.DefaultRegistry, handlers)
+	if err != nil {
+		return err
+	}
+
+	type result struct {
+		File   string   `json:"file"`
+		Jobs   []string `json:"jobs,omitempty"`
+		Valid  bool     `json:"valid"`
+		Errors []string `json:"errors,omitempty"`
+	}
+	results := make([]result, 0, len(definitions))
+	invalid := 0
+	for _, d := range definitions {
+		r := result{File: d.File, Valid: d.Err == nil}
+		if d.Err != nil {
+			r.Errors = strings.Split(d.Err.Error(), "\n")
+			invalid++
+		}
+		for _, j := range d.Jobs {
+			r.Jobs = append(r.Jobs, j.QualifiedName())
+		}
+		results = append(results, r)
+	}
+
+	if *asJSON {
+		if err = printJSON(e.stdout, results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Valid {
+				fmt.Fprintf(e.stdout, "ok      %s (%s)\n", r.File, strings.Join(r.Jobs, ", "))
+			}
+			for _, msg := range r.Errors {
+				fmt.Fprintf(e.stdout, "invalid %s\n", msg)
+			}
+		}
+	}
+
+	if invalid > 0 {
+		return exitCodeError{code: exitInvalid, err: fmt.Errorf("%d of %d definition files are invalid", invalid, len(definitions))}
+	}
+	return nil
+}
+
+func runNext(e env, args []string) error {
+	fs := newFlagSet(e, "next", "<expression>")
+	count := fs.Int("n", 5, "the number of firing times")
+	from := fs.String("from", "", "print the firing times after this RFC 3339 time instead of now")
+	asJSON := fs.Bool("json", false, "print the firing times as a JSON array")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected a schedule expression, quote expressions with spaces")
+	}
+	if *count < 1 {
+		return usageError("-n must be at least 1")
+	}
+
+	t := time.Now()
+	if *from != "" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, *from); err != nil {
+			return usageError("invalid -from: %s", err.Error())
+		}
+	}
+	schedule, err := cron.NewSchedule(fs.Arg(0))
+	if err != nil {
+		return exitCodeError{code: exitInvalid, err: fmt.Errorf("invalid schedule %q: %w", fs.Arg(0), err)}
+	}
+
+	times := make([]time.Time, 0, *count)
+	for len(times) < *count {
+		if t = schedule.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, times)
+	}
+	for _, t := range times {
+		fmt.Fprintln(e.stdout, t.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runList(e env, args []string) error {
+	fs := newFlagSet(e, "list", "")
+	client := clientFlags(e, fs)
+	namespace := fs.String("namespace", "", "only list jobs in the namespace")
+	selector := fs.String("selector", "", "only list jobs with labels matching the selector, such as team=ops")
+	status := fs.String("status", "", "only list jobs with one of the comma separated statuses")
+	asJSON := fs.Bool("json", false, "print the jobs as JSON")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"namespace": *namespace, "selector": *selector, "status": *status} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	jobs, err := listJobs(e.ctx, client(), query)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, jobs)
+	}
+	rows := [][]string{{"ID", "NAME", "STATUS", "ENABLED", "RUNS", "NEXT RUN"}}
+	for _, j := range jobs {
+		next := "-"
+		if j.NextRun != nil {
+			next = j.NextRun.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{j.Id.String(), job.QualifiedName(j.Namespace, j.Name), j.Status, strconv.FormatBool(j.Enabled), strconv.Itoa(j.Runs), next})
+	}
+	return printTable(e.stdout, rows)
+}
+
+func runExport(e env, args []string) error {
+	fs := newFlagSet(e, "export", "")
+	client := clientFlags(e, fs)
+	namespace := fs.String("namespace", "", "only export jobs in the namespace")
+	selector := fs.String("selector", "", "only export jobs with labels matching the selector, such as team=ops")
+	format := fs.String("format", "yaml", "the format of the definitions: json, yaml or toml")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("unexpected arguments %v", fs.Args())
+	}
+	definitionFormat, err := job.ParseDefinitionFormat(*format)
+	if err != nil {
+		return usageError("invalid -format %q", *format)
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"namespace": *namespace, "selector": *selector} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	jobs, err := listJobs(e.ctx, client(), query)
+	if err != nil {
+		return err
+	}
+
+	registry := task.DefaultRegistry
+	definitions := make([]job.Definition, 0, len(jobs))
+	for _, j := range jobs {
+		d, err := exportDefinition(j, registry)
+		if err != nil {
+			return err
+		}
+		definitions = append(definitions, d)
+	}
+	return job.WriteDefinitions(e.stdout, definitionFormat, definitions)
+}
+
+// listJobs returns all jobs matching query, following the cursor of every page
+func listJobs(ctx context.Context, c *admin.Client, query url.Values) ([]admin.Job, error) {
+	jobs := make([]admin.Job, 0)
+	for {
+		list, err := c.ListJobs(ctx, query)
+		if err != nil {
+			return nil, apiError(err)
+		}
+		jobs = append(jobs, list.Jobs...)
+		if list.Cursor == "" {
+			return jobs, nil
+		}
+		query.Set("cursor", list.Cursor)
+	}
+}
+
+func runTrigger(e env, args []string) error {
+	return runJobAction(e, "trigger", "triggered", args, (*admin.Client).TriggerJob)
+}
+
+func runEnable(e env, args []string) error {
+	return runJobAction(e, "enable", "enabled", args, (*admin.Client).EnableJob)
+}
+
+func runDisable(e env, args []string) error {
+	return runJobAction(e, "disable", "disabled", args, (*admin.Client).DisableJob)
+}
+
+// runJobAction applies action to every job in args, a job is referred to by its id or by namespace/name
+func runJobAction(e env, name string, done string, args []string, action func(c *admin.Client, ctx context.Context, jobId uuid.UUID) (admin.Job, error)) error {
+	fs := newFlagSet(e, name, "<job>...")
+	client := clientFlags(e, fs)
+	asJSON := fs.Bool("json", false, "print the jobs as JSON")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usageError("expected the id or namespace/name of at least one job")
+	}
+
+	c := client()
+	jobs := make([]admin.Job, 0, fs.NArg())
+	for _, ref := range fs.Args() {
+		j, err := c.FindJob(e.ctx, ref)
+		if err == nil {
+			j, err = action(c, e.ctx, j.Id)
+		}
+		if err != nil {
+			return apiError(fmt.Errorf("%s: %w", ref, err))
+		}
+		jobs = append(jobs, j)
+	}
+
+	if *asJSON {
+		return printJSON(e.stdout, jobs)
+	}
+	for _, j := range jobs {
+		fmt.Fprintf(e.stdout, "%s %s (%s)\n", done, job.QualifiedName(j.Namespace, j.Name), j.Id)
+	}
+	return nil
+}
+
+// clientFlags adds the flags of the admin API to fs, the returned function creates the client after parsing
+func clientFlags(e env, fs *flag.FlagSet) func() *admin.Client {
+	addr := fs.String("addr", firstOf(e.getenv("GO_SCHEDULER_ADDR"), DefaultAddr), "the address of the admin API, defaults to $GO_SCHEDULER_ADDR")
+	token := fs.String("token", e.getenv("GO_SCHEDULER_TOKEN"), "the bearer token of the admin API, defaults to $GO_SCHEDULER_TOKEN")
+	return func() *admin.Client {
+		return admin.NewClient(*addr, *token, &http.Client{Timeout: 30 * time.Second})
+	}
+}
+
+// apiError converts an error of the admin API to an error with the matching exit code
+func apiError(err error) error {
+	var re admin.ResponseError
+	if errors.As(err, &re) && re.StatusCode == http.StatusNotFound {
+		return exitCodeError{code: exitNotFound, err: err}
+	}
+	return err
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
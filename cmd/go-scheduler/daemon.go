@@ -0,0 +1,147 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/admin"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// definitionSource identifies the jobs managed by the definitions directory of the daemon
+const definitionSource = "files"
+
+// DefaultListen is the address at which the daemon serves the admin API when -listen is not set
+const DefaultListen = "127.0.0.1:8080"
+
+// runDaemon runs an orchestrator with the jobs defined in a directory and serves the admin API until the context of
+// e is done, running jobs are given -shutdown-timeout to finish. The directory is polled every -watch, so jobs follow
+// the definitions which are added, changed and removed while the daemon runs.
+func runDaemon(e env, args []string) error {
+	fs := newFlagSet(e, "run", "<directory>")
+	listen := fs.String("listen", DefaultListen, "the address at which the admin API is served")
+	token := fs.String("token", e.getenv("GO_SCHEDULER_TOKEN"), "the bearer token required by the admin API, defaults to $GO_SCHEDULER_TOKEN")
+	maxJobs := fs.Int("max-jobs", 10, "the maximum number of jobs which run at the same time")
+	interval := fs.Duration("interval", time.Second, "the interval at which the orchestrator schedules jobs")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "the time running jobs are given to finish on shutdown")
+	logLevel := fs.String("log-level", "info", "the minimum level of logs: debug, info, warn or error")
+	asJSON := fs.Bool("json", false, "write logs as JSON instead of text")
+	watch := fs.Duration("watch", job.DefaultWatchInterval, "the interval at which the directory is polled for changed definitions, 0 disables watching")
+	removal := fs.String("removal", job.RemoveDelete.String(), "what happens to the jobs of removed definitions: delete or disable")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError("expected a directory")
+	}
+	if *maxJobs < 1 {
+		return usageError("-max-jobs must be at least 1")
+	}
+
+	var removalPolicy job.RemovalPolicy
+	switch *removal {
+	case job.RemoveDelete.String():
+	case job.RemoveDisable.String():
+		removalPolicy = job.RemoveDisable
+	default:
+		return usageError("invalid -removal %q", *removal)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return usageError("invalid -log-level %q", *logLevel)
+	}
+	options := &slog.HandlerOptions{Level: level}
+	logger := slog.New(slog.NewTextHandler(e.stderr, options))
+	if *asJSON {
+		logger = slog.New(slog.NewJSONHandler(e.stderr, options))
+	}
+
+	handlers, err := newHandlerRepository()
+	if err != nil {
+		return err
+	}
+	registry := task.DefaultRegistry
+	definitions, err := loadDefinitions(fs.Arg(0), registry, handlers)
+	if err != nil {
+		return err
+	}
+
+	catalog := job.NewMemoryCatalog()
+	o := job.NewOrchestrator(catalog, handlers, job.OrchestratorConfig{
+		MaxJobs:          *maxJobs,
+		ScheduleInterval: *interval,
+		Logger:           logger,
+	})
+	for _, d := range definitions {
+		if d.Err != nil {
+			return exitCodeError{code: exitInvalid, err: d.Err}
+		}
+	}
+	reconciler := job.NewReconciler(o, job.ReconcilerConfig{Source: definitionSource, Removal: removalPolicy})
+	watcher := job.NewDefinitionWatcher(fs.Arg(0), registry, reconciler, job.DefinitionWatcherConfig{Interval: *watch, Logger: logger})
+	result, err := watcher.Sync()
+	if err != nil {
+		return exitCodeError{code: exitInvalid, err: err}
+	}
+	logger.Info("loaded job definitions", slog.String("directory", fs.Arg(0)), slog.Int("jobs", result.Added))
+
+	config := admin.Config{}
+	if *token != "" {
+		config.Authenticate = admin.StaticToken(*token)
+	}
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: admin.NewServer(o, catalog, config), ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	logger.Info("serving admin API", slog.String("address", listener.Addr().String()))
+
+	if err = o.Start(e.ctx); err != nil {
+		_ = server.Close()
+		return err
+	}
+	if *watch > 0 {
+		go watcher.Run(e.ctx)
+	}
+
+	select {
+	case <-e.ctx.Done():
+	case err = <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			_ = o.Stop(context.Background())
+			return err
+		}
+	}
+
+	logger.Info("shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	errs := []error{o.Stop(ctx), server.Shutdown(ctx)}
+	return errors.Join(errs...)
+}
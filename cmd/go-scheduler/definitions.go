@@ -0,0 +1,126 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/corelayer/go-scheduler/pkg/admin"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// definition is a file in the definitions directory with the jobs it defines, Err is set if the file does not define
+// valid jobs
+type definition struct {
+	File string
+	Jobs []job.Job
+	Err  error
+}
+
+// newHandlerRepository returns a repository with a handler pool for every built-in task type
+func newHandlerRepository() (*task.HandlerRepository, error) {
+	r := task.NewHandlerRepository()
+	err := r.RegisterHandlerPools([]*task.HandlerPool{
+		task.NewHandlerPool(task.NewDefaultEmptyTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultIntercomMessageTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultPrintTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultSleepTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultTimeLogTaskHandler()),
+	})
+	return r, err
+}
+
+// loadDefinitions reads the job definitions in dir, every *.json, *.yaml, *.yml and *.toml file holds one or more
+// definitions. Definitions are sorted by file, a file which is not valid is returned with its error, as is a file
+// which defines a job that is already defined by a previous file.
+func loadDefinitions(dir string, registry *task.Registry, handlers *task.HandlerRepository) ([]definition, error) {
+	files, err := job.DefinitionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]definition, 0, len(files))
+	defined := make(map[string]string)
+	for _, file := range files {
+		d := definition{File: file}
+		d.Jobs, d.Err = loadDefinition(file, registry, handlers)
+		for _, j := range d.Jobs {
+			if previous, found := defined[j.QualifiedName()]; found && d.Err == nil {
+				d.Err = fmt.Errorf("%s: job %s is already defined in %s", file, j.QualifiedName(), previous)
+			}
+		}
+		if d.Err == nil {
+			for _, j := range d.Jobs {
+				defined[j.QualifiedName()] = file
+			}
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, nil
+}
+
+func loadDefinition(file string, registry *task.Registry, handlers *task.HandlerRepository) ([]job.Job, error) {
+	definitions, err := job.LoadDefinitions(file, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]job.Job, 0, len(definitions))
+	for _, d := range definitions {
+		j, err := d.Job(registry)
+		if err != nil {
+			return nil, err
+		}
+		if err = handlers.Validate(j.Tasks.All()); err != nil {
+			return nil, job.DefinitionError{File: d.File, Line: d.Line, Err: err}
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// exportDefinition returns the definition of a job returned by the admin API
+func exportDefinition(j admin.Job, registry *task.Registry) (job.Definition, error) {
+	d := job.Definition{
+		Namespace:   j.Namespace,
+		Name:        j.Name,
+		Labels:      j.Labels,
+		Annotations: j.Annotations,
+		Schedule:    j.Schedule,
+		MaxRuns:     j.MaxRuns,
+		Priority:    j.Priority,
+		Concurrency: j.Concurrency,
+		Tasks:       make([]job.TaskDefinition, 0, len(j.Tasks)),
+	}
+	if j.Enabled {
+		d.Enabled = &j.Enabled
+	}
+
+	for i, t := range j.Tasks {
+		decoded, err := registry.Decode(task.EncodedTask{Type: t.Type, Params: t.Data})
+		if err != nil {
+			return job.Definition{}, fmt.Errorf("job %s: task %d: %w", job.QualifiedName(j.Namespace, j.Name), i, err)
+		}
+		td, err := job.NewTaskDefinition(decoded, registry)
+		if err != nil {
+			return job.Definition{}, fmt.Errorf("job %s: task %d: %w", job.QualifiedName(j.Namespace, j.Name), i, err)
+		}
+		d.Tasks = append(d.Tasks, td)
+	}
+	return d, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Command go-scheduler runs a standalone scheduler daemon and manages its jobs over the admin API.
+//
+// Usage:
+//
+//	go-scheduler <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	run       run the scheduler daemon with the jobs defined in a directory
+//	validate  check the job definitions in a directory
+//	next      print the next firing times of a schedule expression
+//	list      list the jobs of a running daemon
+//	export    print the definitions of the jobs of a running daemon
+//	trigger   run jobs of a running daemon as soon as possible
+//	enable    enable jobs of a running daemon
+//	disable   disable jobs of a running daemon
+//
+// Commands which talk to a running daemon use the admin API at -addr, which defaults to $GO_SCHEDULER_ADDR, and send
+// -token, which defaults to $GO_SCHEDULER_TOKEN, as bearer token. Commands print JSON instead of text with -json, export
+// prints job definitions in the format of -format.
+//
+// Job definitions are read from *.json, *.yaml, *.yml and *.toml files, a file defines a single job or a list of jobs
+// under the key jobs. Errors in definitions are reported with their file and line. The run command polls the directory
+// every -watch: added files create jobs, changed files update them and the jobs of removed files are deleted or
+// disabled, as chosen with -removal. A file which becomes invalid keeps its jobs until it is fixed.
+//
+// The exit code is 0 on success, 1 if the command failed, 2 for invalid usage, 3 if job definitions are invalid and
+// 4 if a job does not exist.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitInvalid
+	exitNotFound
+)
+
+// exitCodeError is returned by a command to exit with a code other than exitError
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e exitCodeError) Unwrap() error {
+	return e.err
+}
+
+func usageError(format string, args ...interface{}) error {
+	return exitCodeError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// env holds what a command needs from the process, so commands can be run by tests
+type env struct {
+	ctx    context.Context
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+type command struct {
+	name    string
+	summary string
+	run     func(e env, args []string) error
+}
+
+var commands = []command{
+	{"run", "run the scheduler daemon with the jobs defined in a directory", runDaemon},
+	{"validate", "check the job definitions in a directory", runValidate},
+	{"next", "print the next firing times of a schedule expression", runNext},
+	{"list", "list the jobs of a running daemon", runList},
+	{"export", "print the definitions of the jobs of a running daemon", runExport},
+	{"trigger", "run jobs of a running daemon as soon as possible", runTrigger},
+	{"enable", "enable jobs of a running daemon", runEnable},
+	{"disable", "disable jobs of a running daemon", runDisable},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(env{ctx: ctx, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command in args and returns the exit code
+func run(e env, args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(e.stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		err := c.run(e, args[1:])
+		if err == nil || errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintf(e.stderr, "go-scheduler %s: %s\n", c.name, err.Error())
+
+		var ec exitCodeError
+		if errors.As(err, &ec) {
+			return ec.code
+		}
+		return exitError
+	}
+
+	fmt.Fprintf(e.stderr, "go-scheduler: unknown command %q\n", args[0])
+	usage(e.stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: go-scheduler <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s%s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "go-scheduler <command> -h" for the flags of a command.`)
+}
+
+// newFlagSet returns the flag set of a command, which reports errors instead of exiting
+func newFlagSet(e env, name string, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: go-scheduler %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a command, errors are usage errors
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return exitCodeError{code: exitUsage, err: err}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/admin"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// execute runs the command line args with the environment variables in vars and returns the exit code and output
+func execute(t *testing.T, ctx context.Context, vars map[string]string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	e := env{ctx: ctx, stdout: &stdout, stderr: &stderr, getenv: func(key string) string { return vars[key] }}
+	code := run(e, args)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeDefinitions(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const testDefinition = `{"namespace":"ops","name":"backup","schedule":"@hourly","tasks":[{"type":"sleep","params":{"milliseconds":1}}]}`
+
+func TestRun_Usage(t *testing.T) {
+	for _, args := range [][]string{nil, {"deploy"}, {"next"}, {"next", "-x", "@hourly"}, {"trigger"}} {
+		if code, _, stderr := execute(t, context.Background(), nil, args...); code != exitUsage || stderr == "" {
+			t.Errorf("%v: got exit code %d, expected %d with usage", args, code, exitUsage)
+		}
+	}
+	if code, _, _ := execute(t, context.Background(), nil, "next", "-h"); code != exitOK {
+		t.Errorf("got exit code %d for help, expected %d", code, exitOK)
+	}
+}
+
+func TestRun_Next(t *testing.T) {
+	code, stdout, _ := execute(t, context.Background(), nil, "next", "-n", "3", "-from", "2024-05-01T10:30:00Z", "@hourly")
+	wanted := "2024-05-01T11:00:00Z\n2024-05-01T12:00:00Z\n2024-05-01T13:00:00Z\n"
+	if code != exitOK || stdout != wanted {
+		t.Errorf("got exit code %d and output %q, expected %q", code, stdout, wanted)
+	}
+
+	code, stdout, _ = execute(t, context.Background(), nil, "next", "-n", "1", "-json", "-from", "2024-05-01T10:30:00Z", "*/15 * * * *")
+	var times []time.Time
+	if err := json.Unmarshal([]byte(stdout), &times); err != nil || code != exitOK || len(times) != 1 || times[0].Minute() != 45 {
+		t.Errorf("got exit code %d and output %q, expected one firing time at minute 45", code, stdout)
+	}
+
+	if code, _, _ = execute(t, context.Background(), nil, "next", "every day"); code != exitInvalid {
+		t.Errorf("got exit code %d for an invalid expression, expected %d", code, exitInvalid)
+	}
+}
+
+func TestRun_Validate(t *testing.T) {
+	dir := writeDefinitions(t, map[string]string{
+		"backup.json":   testDefinition,
+		"copy.yaml":     "namespace: ops\nname: backup\nschedule: \"@daily\"\ntasks: []\n",
+		"schedule.toml": "name = \"report\"\nschedule = \"every day\"\n",
+		"task.yaml":     "jobs:\n  - name: cleanup\n    schedule: \"@daily\"\n    tasks:\n      - type: foo\n",
+		"notes.txt":     "not a definition",
+	})
+
+	code, stdout, _ := execute(t, context.Background(), nil, "validate", dir)
+	if code != exitInvalid {
+		t.Errorf("got exit code %d, expected %d", code, exitInvalid)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	wanted := []string{
+		"ok      " + filepath.Join(dir, "backup.json") + " (ops/backup)",
+		"invalid " + filepath.Join(dir, "copy.yaml") + ": job ops/backup is already defined in " + filepath.Join(dir, "backup.json"),
+		"invalid " + filepath.Join(dir, "schedule.toml") + ":2: invalid schedule",
+		"invalid " + filepath.Join(dir, "task.yaml") + ":5: task foo: unknown type",
+	}
+	if len(lines) != len(wanted) {
+		t.Fatalf("got output %q, expected a line per definition file", stdout)
+	}
+	for i := range wanted {
+		if !strings.HasPrefix(lines[i], wanted[i]) {
+			t.Errorf("got line %q, expected %q", lines[i], wanted[i])
+		}
+	}
+
+	code, stdout, _ = execute(t, context.Background(), nil, "validate", "-json", writeDefinitions(t, map[string]string{"backup.json": testDefinition}))
+	var results []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil || code != exitOK || len(results) != 1 || results[0]["valid"] != true {
+		t.Errorf("got exit code %d and output %q, expected a valid definition", code, stdout)
+	}
+}
+
+func TestRun_JobCommands(t *testing.T) {
+	handlers, err := newHandlerRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	definitions, err := loadDefinitions(writeDefinitions(t, map[string]string{"backup.json": testDefinition}), task.DefaultRegistry, handlers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := job.NewMemoryCatalog()
+	o := job.NewOrchestrator(c, handlers, job.OrchestratorConfig{MaxJobs: 1, ScheduleInterval: time.Second})
+	if err = o.Add(definitions[0].Jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(admin.NewServer(o, c, admin.Config{Authenticate: admin.StaticToken("secret")}))
+	defer server.Close()
+
+	vars := map[string]string{"GO_SCHEDULER_ADDR": server.URL, "GO_SCHEDULER_TOKEN": "secret"}
+	jobId := definitions[0].Jobs[0].Uuid.String()
+
+	code, stdout, _ := execute(t, context.Background(), vars, "list")
+	if code != exitOK || !strings.HasPrefix(stdout, "ID") || !strings.Contains(stdout, jobId) || !strings.Contains(stdout, "ops/backup") {
+		t.Errorf("got exit code %d and output %q, expected a table with the job", code, stdout)
+	}
+
+	code, stdout, _ = execute(t, context.Background(), vars, "trigger", "-json", "ops/backup")
+	var jobs []admin.Job
+	if err = json.Unmarshal([]byte(stdout), &jobs); err != nil || code != exitOK || len(jobs) != 1 || !jobs[0].Triggered {
+		t.Errorf("got exit code %d and output %q, expected the triggered job", code, stdout)
+	}
+
+	code, stdout, _ = execute(t, context.Background(), vars, "disable", jobId)
+	if current, _ := c.Get(definitions[0].Jobs[0].Uuid); code != exitOK || current.IsEnabled() || stdout != "disabled ops/backup ("+jobId+")\n" {
+		t.Errorf("got exit code %d and output %q, expected the job to be disabled", code, stdout)
+	}
+
+	code, stdout, _ = execute(t, context.Background(), vars, "export", "-format", "toml")
+	exported, err := job.ParseDefinitions("export.toml", []byte(stdout), job.FormatTOML, task.DefaultRegistry)
+	if err != nil || code != exitOK || len(exported) != 1 || exported[0].QualifiedName() != "ops/backup" || exported[0].Tasks[0].Params["milliseconds"] != int64(1) {
+		t.Errorf("got exit code %d, error %v and output %q, expected the definition of the job", code, err, stdout)
+	}
+
+	if code, _, _ = execute(t, context.Background(), vars, "enable", "ops/report"); code != exitNotFound {
+		t.Errorf("got exit code %d for an unknown job, expected %d", code, exitNotFound)
+	}
+	if code, _, _ = execute(t, context.Background(), vars, "list", "-token", "wrong"); code != exitError {
+		t.Errorf("got exit code %d with a wrong token, expected %d", code, exitError)
+	}
+}
+
+func TestRun_Daemon(t *testing.T) {
+	dir := writeDefinitions(t, map[string]string{"backup.json": testDefinition})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan int)
+	var stderr string
+	go func() {
+		var code int
+		code, _, stderr = execute(t, ctx, nil, "run", "-listen", "127.0.0.1:0", "-interval", "10ms", "-watch", "10ms", dir)
+		done <- code
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "report.yaml"), []byte("name: report\nschedule: \"@daily\"\ntasks: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	select {
+	case code := <-done:
+		if code != exitOK || !strings.Contains(stderr, "serving admin API") || !strings.Contains(stderr, "jobs=1") ||
+			!strings.Contains(stderr, "reconciled job definitions") || !strings.Contains(stderr, "added=1") {
+			t.Errorf("got exit code %d and logs %q", code, stderr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not stop")
+	}
+
+	if code, _, _ := execute(t, context.Background(), nil, "run", writeDefinitions(t, map[string]string{"bad.json": `{"name":"x"}`})); code != exitInvalid {
+		t.Errorf("got exit code %d for an invalid definition, expected %d", code, exitInvalid)
+	}
+}
@@ -74,12 +74,14 @@ func main() {
 
 	for i < 10000 {
 		i++
-		if err = c.Add(createJob(i)); err != nil {
+		if err = o.Add(createJob(i)); err != nil {
 			panic(err)
 		}
 	}
 
-	o.Start(ctx)
+	if err = o.Start(ctx); err != nil {
+		panic(err)
+	}
 
 	exiting := false
 	for {
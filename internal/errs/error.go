@@ -0,0 +1,68 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package errs implements the base of the error types of the packages of the scheduler. Every package defines its own
+// error kinds and an error type which adds the subject the error applies to, such as a job or a task type.
+package errs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Kind is the kind of an error, errors of the same kind are equal when matched by errors.Is
+type Kind interface {
+	~int
+	String() string
+}
+
+// Error is an error of a kind which wraps an optional cause
+type Error[K Kind] struct {
+	Kind  K
+	Cause error
+}
+
+// Message returns the name of the kind followed by the message of the cause, if any
+func (e Error[K]) Message() string {
+	if e.Cause == nil {
+		return e.Kind.String()
+	}
+	return e.Kind.String() + ": " + e.Cause.Error()
+}
+
+// LogValue returns the kind, the attributes of the subject and the cause of the error as a group
+func (e Error[K]) LogValue(subject ...slog.Attr) slog.Value {
+	attrs := append([]slog.Attr{slog.String("kind", e.Kind.String())}, subject...)
+	if e.Cause != nil {
+		attrs = append(attrs, slog.String("cause", e.Cause.Error()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// FromContext returns timeout if err is the error of an expired context and canceled if it is the error of a canceled
+// context, it reports false for any other error
+func FromContext[E any](err error, timeout E, canceled E) (E, bool) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return timeout, true
+	case errors.Is(err, context.Canceled):
+		return canceled, true
+	default:
+		var zero E
+		return zero, false
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+type testKind int
+
+func (k testKind) String() string {
+	return "kind " + fmt.Sprint(int(k))
+}
+
+func TestError_Message(t *testing.T) {
+	if msg := (Error[testKind]{Kind: 1}).Message(); msg != "kind 1" {
+		t.Errorf("got %q, expected %q", msg, "kind 1")
+	}
+	if msg := (Error[testKind]{Kind: 2, Cause: errors.New("failed")}).Message(); msg != "kind 2: failed" {
+		t.Errorf("got %q, expected %q", msg, "kind 2: failed")
+	}
+}
+
+func TestError_LogValue(t *testing.T) {
+	value := Error[testKind]{Kind: 1, Cause: errors.New("failed")}.LogValue(slog.String("job", "backup"))
+
+	var keys []string
+	for _, a := range value.Group() {
+		keys = append(keys, a.Key)
+	}
+	if fmt.Sprint(keys) != "[kind job cause]" {
+		t.Errorf("got attributes %v, expected kind, job and cause", keys)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	tests := []struct {
+		err    error
+		wanted string
+		ok     bool
+	}{
+		{context.DeadlineExceeded, "timeout", true},
+		{fmt.Errorf("wait: %w", context.Canceled), "canceled", true},
+		{errors.New("failed"), "", false},
+	}
+
+	for _, test := range tests {
+		if got, ok := FromContext(test.err, "timeout", "canceled"); got != test.wanted || ok != test.ok {
+			t.Errorf("got %q and %t for %v, expected %q and %t", got, ok, test.err, test.wanted, test.ok)
+		}
+	}
+}
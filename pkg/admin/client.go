@@ -0,0 +1,166 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// NewClient returns a client for the admin API served at baseURL, token is sent as bearer token if it is not empty.
+// A nil httpClient defaults to http.DefaultClient.
+func NewClient(baseURL string, token string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// ResponseError is returned by the client for every response which is not successful
+type ResponseError struct {
+	StatusCode int
+	Body       Error
+}
+
+func (e ResponseError) Error() string {
+	if e.Body.Error == "" {
+		return http.StatusText(e.StatusCode)
+	}
+	return e.Body.Error
+}
+
+// ListJobs returns the jobs selected by query, which holds the parameters of GET /jobs
+func (c *Client) ListJobs(ctx context.Context, query url.Values) (JobList, error) {
+	var list JobList
+	err := c.do(ctx, http.MethodGet, "/jobs?"+query.Encode(), nil, &list)
+	return list, err
+}
+
+// FindJob returns the job with the id or the qualified name namespace/name in ref
+func (c *Client) FindJob(ctx context.Context, ref string) (Job, error) {
+	if jobId, err := uuid.Parse(ref); err == nil {
+		return c.Job(ctx, jobId)
+	}
+
+	namespace, name, found := strings.Cut(ref, "/")
+	if !found {
+		namespace, name = "", ref
+	}
+	list, err := c.ListJobs(ctx, url.Values{"namespace": {namespace}, "name": {escapePattern(name)}})
+	if err != nil {
+		return Job{}, err
+	}
+	for _, j := range list.Jobs {
+		if j.Namespace == namespace && j.Name == name {
+			return j, nil
+		}
+	}
+	return Job{}, ResponseError{StatusCode: http.StatusNotFound, Body: Error{Error: fmt.Sprintf("job %s not found", ref), Kind: "not found"}}
+}
+
+func (c *Client) Job(ctx context.Context, jobId uuid.UUID) (Job, error) {
+	var j Job
+	err := c.do(ctx, http.MethodGet, "/jobs/"+jobId.String(), nil, &j)
+	return j, err
+}
+
+func (c *Client) EnableJob(ctx context.Context, jobId uuid.UUID) (Job, error) {
+	return c.jobAction(ctx, jobId, "enable")
+}
+
+func (c *Client) DisableJob(ctx context.Context, jobId uuid.UUID) (Job, error) {
+	return c.jobAction(ctx, jobId, "disable")
+}
+
+func (c *Client) TriggerJob(ctx context.Context, jobId uuid.UUID) (Job, error) {
+	return c.jobAction(ctx, jobId, "trigger")
+}
+
+func (c *Client) jobAction(ctx context.Context, jobId uuid.UUID, action string) (Job, error) {
+	var j Job
+	err := c.do(ctx, http.MethodPost, "/jobs/"+jobId.String()+"/"+action, nil, &j)
+	return j, err
+}
+
+// do sends a request with body encoded as JSON and decodes the response into v
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, v interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		e := ResponseError{StatusCode: res.StatusCode}
+		_ = json.NewDecoder(res.Body).Decode(&e.Body)
+		return e
+	}
+	if v == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// escapePattern escapes the characters of name which have a meaning in the name patterns of GET /jobs
+func escapePattern(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	server, _, _ := newTestServer(t, Config{Authenticate: StaticToken("secret")})
+	if res := call(t, server, http.MethodPost, "/jobs", testSpec, http.Header{"Authorization": {"Bearer secret"}}, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, expected %d", res.StatusCode, http.StatusCreated)
+	}
+
+	c := NewClient(server.URL+"/", "secret", server.Client())
+	j, err := c.FindJob(context.Background(), "ops/backup")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if j, err = c.TriggerJob(context.Background(), j.Id); err != nil || !j.Triggered {
+		t.Errorf("got job %+v and error %v, expected the triggered job", j, err)
+	}
+	if found, err := c.FindJob(context.Background(), j.Id.String()); err != nil || found.Id != j.Id {
+		t.Errorf("got job %+v and error %v, expected the job by id", found, err)
+	}
+
+	var re ResponseError
+	if _, err = c.FindJob(context.Background(), "ops/report"); !errors.As(err, &re) || re.StatusCode != http.StatusNotFound {
+		t.Errorf("got %v, expected not found", err)
+	}
+	if _, err = c.ListJobs(context.Background(), url.Values{"status": {"done"}}); !errors.As(err, &re) || re.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %v, expected a bad request", err)
+	}
+	if _, err = NewClient(server.URL, "", nil).ListJobs(context.Background(), nil); !errors.As(err, &re) || re.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %v, expected unauthorized", err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// requestError is returned for requests which cannot be handled as sent
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e requestError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(err error) error {
+	return requestError{status: http.StatusBadRequest, err: err}
+}
+
+// statusCode returns the HTTP status of the response to a request which failed with err
+func statusCode(err error) int {
+	var re requestError
+	if errors.As(err, &re) {
+		return re.status
+	}
+
+	var je job.Error
+	if !errors.As(err, &je) {
+		return http.StatusInternalServerError
+	}
+	switch je.Kind() {
+	case job.ErrKindNotFound:
+		return http.StatusNotFound
+	case job.ErrKindExist, job.ErrKindNameExist, job.ErrKindConflict, job.ErrKindStarted:
+		return http.StatusConflict
+	case job.ErrKindInvalidName, job.ErrKindInvalidConcurrency, job.ErrKindHandlerMissing, job.ErrKindInvalidTransition:
+		return http.StatusUnprocessableEntity
+	case job.ErrKindNotSupported:
+		return http.StatusNotImplemented
+	case job.ErrKindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	body := Error{Error: err.Error()}
+	var je job.Error
+	if errors.As(err, &je) {
+		body.Kind = je.Kind().String()
+	}
+	writeJSON(w, statusCode(err), body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
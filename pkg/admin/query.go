@@ -0,0 +1,135 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// parseQuery builds a catalog query from the parameters of a request to list jobs:
+// namespace, name, selector, status (repeated or comma separated), enabled, sort, desc, cursor and limit
+func parseQuery(r *http.Request) (job.Query, error) {
+	var (
+		err    error
+		values = r.URL.Query()
+		query  = job.Query{
+			Namespace: values.Get("namespace"),
+			Name:      values.Get("name"),
+			Cursor:    values.Get("cursor"),
+			Now:       time.Now(),
+		}
+	)
+
+	if _, err = path.Match(query.Name, ""); err != nil {
+		return job.Query{}, fmt.Errorf("invalid name pattern %q", query.Name)
+	}
+	if query.Selector, err = job.ParseSelector(values.Get("selector")); err != nil {
+		return job.Query{}, err
+	}
+	for _, value := range values["status"] {
+		for _, name := range strings.Split(value, ",") {
+			var s job.Status
+			if s, err = parseStatus(name); err != nil {
+				return job.Query{}, err
+			}
+			query.Status = append(query.Status, s)
+		}
+	}
+	if value := values.Get("enabled"); value != "" {
+		var enabled bool
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return job.Query{}, fmt.Errorf("invalid enabled %q", value)
+		}
+		query.Enabled = &enabled
+	}
+	if value := values.Get("sort"); value != "" {
+		if query.Sort, err = parseSortField(value); err != nil {
+			return job.Query{}, err
+		}
+	}
+	if value := values.Get("desc"); value != "" {
+		if query.Descending, err = strconv.ParseBool(value); err != nil {
+			return job.Query{}, fmt.Errorf("invalid desc %q", value)
+		}
+	}
+	if query.Limit, err = intParam(values.Get("limit")); err != nil {
+		return job.Query{}, err
+	}
+	return query, nil
+}
+
+// parseHistoryQuery builds a history query for the runs of the job with jobId from the parameters status, limit and
+// offset of a request
+func parseHistoryQuery(r *http.Request, jobId uuid.UUID) (job.HistoryQuery, error) {
+	var (
+		err    error
+		values = r.URL.Query()
+		query  = job.HistoryQuery{JobId: jobId}
+	)
+
+	if value := values.Get("status"); value != "" {
+		if query.Status, err = parseStatus(value); err != nil {
+			return job.HistoryQuery{}, err
+		}
+	}
+	if query.Limit, err = intParam(values.Get("limit")); err != nil {
+		return job.HistoryQuery{}, err
+	}
+	if query.Offset, err = intParam(values.Get("offset")); err != nil {
+		return job.HistoryQuery{}, err
+	}
+	return query, nil
+}
+
+func parseStatus(name string) (job.Status, error) {
+	for s := job.StatusInactive; s <= job.StatusError; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return job.StatusNone, fmt.Errorf("invalid status %q", name)
+}
+
+func parseSortField(name string) (job.SortField, error) {
+	for f := job.SortByName; f <= job.SortByLastRun; f++ {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return job.SortByName, fmt.Errorf("invalid sort field %q", name)
+}
+
+// intParam parses a parameter which must be a number of zero or more, an empty parameter is zero
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
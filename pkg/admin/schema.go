@@ -0,0 +1,48 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"embed"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// Schemas holds the JSON schemas of the resources of the API by name: job, jobSpec, run and error.
+// They are served at /schemas/{name}.
+var Schemas = loadSchemas()
+
+func loadSchemas() map[string]json.RawMessage {
+	entries, err := schemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(err)
+	}
+
+	schemas := make(map[string]json.RawMessage, len(entries))
+	for _, entry := range entries {
+		data, err := schemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		schemas[strings.TrimSuffix(entry.Name(), ".json")] = data
+	}
+	return schemas
+}
@@ -0,0 +1,382 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// NewServer returns the admin API of orchestrator, which schedules the jobs of catalog
+func NewServer(orchestrator *job.Orchestrator, catalog job.Catalog, config Config) *Server {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	s := &Server{
+		orchestrator: orchestrator,
+		catalog:      catalog,
+		config:       config,
+		mux:          http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /jobs", s.handle(s.listJobs))
+	s.mux.HandleFunc("POST /jobs", s.handle(s.createJob))
+	s.mux.HandleFunc("GET /jobs/{id}", s.handle(s.getJob))
+	s.mux.HandleFunc("PUT /jobs/{id}", s.handle(s.updateJob))
+	s.mux.HandleFunc("DELETE /jobs/{id}", s.handle(s.deleteJob))
+	s.mux.HandleFunc("POST /jobs/{id}/enable", s.handle(s.enableJob))
+	s.mux.HandleFunc("POST /jobs/{id}/disable", s.handle(s.disableJob))
+	s.mux.HandleFunc("POST /jobs/{id}/trigger", s.handle(s.triggerJob))
+	s.mux.HandleFunc("GET /jobs/{id}/runs", s.handle(s.listRuns))
+	s.mux.HandleFunc("GET /orchestrator", s.handle(s.getOrchestrator))
+	s.mux.HandleFunc("POST /orchestrator/pause", s.handle(s.pause))
+	s.mux.HandleFunc("POST /orchestrator/resume", s.handle(s.resume))
+	s.mux.HandleFunc("GET /orchestrator/stats", s.handle(s.stats))
+	s.mux.HandleFunc("GET /schemas/{name}", s.handle(s.getSchema))
+	return s
+}
+
+// Server is an http.Handler which serves the admin API. Mount it with http.StripPrefix to serve it below a path.
+//
+//	GET    /jobs                 list jobs, filtered by namespace, name, selector, status and enabled
+//	POST   /jobs                 create a job from a JobSpec
+//	GET    /jobs/{id}            get a job
+//	PUT    /jobs/{id}            replace the definition of a job with a JobSpec, If-Match requires a revision
+//	DELETE /jobs/{id}            delete a job
+//	POST   /jobs/{id}/enable     enable a job
+//	POST   /jobs/{id}/disable    disable a job
+//	POST   /jobs/{id}/trigger    run a job as soon as possible
+//	GET    /jobs/{id}/runs       list the runs of a job with their messages, filtered by status
+//	GET    /orchestrator         get the state of the orchestrator
+//	POST   /orchestrator/pause   stop starting new runs
+//	POST   /orchestrator/resume  start new runs again
+//	GET    /orchestrator/stats   get the statistics of the orchestrator
+//	GET    /schemas/{name}       get the JSON schema of a resource, see Schemas
+type Server struct {
+	orchestrator *job.Orchestrator
+	catalog      job.Catalog
+	config       Config
+	mux          *http.ServeMux
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.config.Authenticate != nil {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if err := s.config.Authenticate(r, strings.TrimSpace(token)); err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-scheduler"`)
+			writeJSON(w, http.StatusUnauthorized, Error{Error: err.Error()})
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle adapts an API handler, which returns the status and body of a successful response, to an http.HandlerFunc
+func (s *Server) handle(h func(r *http.Request) (int, interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, s.config.MaxBodyBytes)
+		}
+
+		status, body, err := h(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if v, ok := body.(Job); ok {
+			w.Header().Set("ETag", etag(v.Revision))
+		}
+		if body == nil {
+			w.WriteHeader(status)
+			return
+		}
+		writeJSON(w, status, body)
+	}
+}
+
+func (s *Server) listJobs(r *http.Request) (int, interface{}, error) {
+	query, err := parseQuery(r)
+	if err != nil {
+		return 0, nil, badRequest(err)
+	}
+
+	page, err := s.catalog.Find(query)
+	if err != nil {
+		return 0, nil, err
+	}
+	list := JobList{Jobs: make([]Job, 0, len(page.Jobs)), Cursor: page.Cursor}
+	for _, j := range page.Jobs {
+		v, err := newJob(j, query.Now)
+		if err != nil {
+			return 0, nil, err
+		}
+		list.Jobs = append(list.Jobs, v)
+	}
+	return http.StatusOK, list, nil
+}
+
+func (s *Server) createJob(r *http.Request) (int, interface{}, error) {
+	var spec JobSpec
+	if err := decode(r, &spec); err != nil {
+		return 0, nil, err
+	}
+
+	j, err := spec.Job()
+	if err != nil {
+		return 0, nil, badRequest(err)
+	}
+	if err := s.orchestrator.Add(j); err != nil {
+		return 0, nil, err
+	}
+	return s.jobResponse(j.Uuid, http.StatusCreated)
+}
+
+func (s *Server) getJob(r *http.Request) (int, interface{}, error) {
+	jobId, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	return s.jobResponse(jobId, http.StatusOK)
+}
+
+// updateJob applies the spec to the latest version of the job, so the run state of the job is kept. When If-Match
+// holds a revision, the update fails with 412 Precondition Failed if the job was changed since that revision.
+func (s *Server) updateJob(r *http.Request) (int, interface{}, error) {
+	jobId, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	var spec JobSpec
+	if err = decode(r, &spec); err != nil {
+		return 0, nil, err
+	}
+	expected, hasRevision, err := ifMatch(r)
+	if err != nil {
+		return 0, nil, badRequest(err)
+	}
+
+	for {
+		var j job.Job
+		if j, err = s.catalog.Get(jobId); err != nil {
+			return 0, nil, err
+		}
+		if hasRevision && j.Revision != expected {
+			return 0, nil, requestError{status: http.StatusPreconditionFailed, err: job.ErrConflict.WithJob(j.Uuid, j.Name)}
+		}
+		if err = spec.apply(&j); err != nil {
+			return 0, nil, badRequest(err)
+		}
+		if err = s.orchestrator.Validate(j); err != nil {
+			return 0, nil, err
+		}
+
+		err = s.catalog.UpdateIf(j, j.Revision)
+		if errors.Is(err, job.ErrConflict) && !hasRevision {
+			continue
+		}
+		if errors.Is(err, job.ErrConflict) {
+			return 0, nil, requestError{status: http.StatusPreconditionFailed, err: err}
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		return s.jobResponse(jobId, http.StatusOK)
+	}
+}
+
+func (s *Server) deleteJob(r *http.Request) (int, interface{}, error) {
+	jobId, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = s.catalog.Delete(jobId); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+func (s *Server) enableJob(r *http.Request) (int, interface{}, error) {
+	return s.jobAction(r, s.catalog.Enable)
+}
+
+func (s *Server) disableJob(r *http.Request) (int, interface{}, error) {
+	return s.jobAction(r, s.catalog.Disable)
+}
+
+func (s *Server) triggerJob(r *http.Request) (int, interface{}, error) {
+	return s.jobAction(r, func(jobId uuid.UUID) error {
+		return job.TriggerJob(s.catalog, jobId)
+	})
+}
+
+// listRuns returns the runs of a job, from the history store if one is configured
+func (s *Server) listRuns(r *http.Request) (int, interface{}, error) {
+	jobId, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	query, err := parseHistoryQuery(r, jobId)
+	if err != nil {
+		return 0, nil, badRequest(err)
+	}
+
+	j, err := s.catalog.Get(jobId)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var records []job.HistoryRecord
+	total := 0
+	if s.config.History != nil {
+		var page job.HistoryPage
+		if page, err = s.config.History.Query(query); err != nil {
+			return 0, nil, err
+		}
+		records, total = page.Records, page.Total
+	} else {
+		records, total = catalogRuns(j, query)
+	}
+
+	list := RunList{Runs: make([]Run, 0, len(records)), Total: total}
+	for _, record := range records {
+		run, err := newRun(record.Run, record.Result)
+		if err != nil {
+			return 0, nil, err
+		}
+		list.Runs = append(list.Runs, run)
+	}
+	return http.StatusOK, list, nil
+}
+
+func (s *Server) getOrchestrator(_ *http.Request) (int, interface{}, error) {
+	return http.StatusOK, OrchestratorState{Started: s.orchestrator.IsStarted(), Paused: s.orchestrator.IsPaused()}, nil
+}
+
+func (s *Server) pause(r *http.Request) (int, interface{}, error) {
+	s.orchestrator.Pause()
+	return s.getOrchestrator(r)
+}
+
+func (s *Server) resume(r *http.Request) (int, interface{}, error) {
+	s.orchestrator.Resume()
+	return s.getOrchestrator(r)
+}
+
+func (s *Server) stats(_ *http.Request) (int, interface{}, error) {
+	return http.StatusOK, s.orchestrator.Statistics(), nil
+}
+
+func (s *Server) getSchema(r *http.Request) (int, interface{}, error) {
+	schema, found := Schemas[strings.TrimSuffix(r.PathValue("name"), ".json")]
+	if !found {
+		return 0, nil, requestError{status: http.StatusNotFound, err: fmt.Errorf("unknown schema %s", r.PathValue("name"))}
+	}
+	return http.StatusOK, schema, nil
+}
+
+// jobAction applies action to the job in the path and returns the job
+func (s *Server) jobAction(r *http.Request, action func(jobId uuid.UUID) error) (int, interface{}, error) {
+	jobId, err := pathId(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err = action(jobId); err != nil {
+		return 0, nil, err
+	}
+	return s.jobResponse(jobId, http.StatusOK)
+}
+
+func (s *Server) jobResponse(jobId uuid.UUID, status int) (int, interface{}, error) {
+	j, err := s.catalog.Get(jobId)
+	if err != nil {
+		return 0, nil, err
+	}
+	v, err := newJob(j, time.Now())
+	if err != nil {
+		return 0, nil, err
+	}
+	return status, v, nil
+}
+
+// catalogRuns selects the runs matching query from the history of j, ordered from newest to oldest
+func catalogRuns(j job.Job, query job.HistoryQuery) ([]job.HistoryRecord, int) {
+	results := j.AllResults()
+	first := j.CountRuns() - len(results) + 1
+
+	var records []job.HistoryRecord
+	for i := len(results) - 1; i >= 0; i-- {
+		if query.Status != job.StatusNone && results[i].Status != query.Status {
+			continue
+		}
+		records = append(records, job.HistoryRecord{JobId: j.Uuid, JobName: j.Name, Run: first + i, Result: results[i]})
+	}
+
+	total := len(records)
+	records = records[min(query.Offset, total):]
+	if query.Limit > 0 && len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+	return records, total
+}
+
+// decode reads a JSON request body into v, unknown fields are rejected
+func decode(r *http.Request, v interface{}) error {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return requestError{status: http.StatusRequestEntityTooLarge, err: err}
+		}
+		return badRequest(fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
+
+func etag(revision uint64) string {
+	return strconv.Quote(strconv.FormatUint(revision, 10))
+}
+
+// ifMatch returns the revision in the If-Match header of r, if any
+func ifMatch(r *http.Request) (uint64, bool, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, false, nil
+	}
+	revision, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid If-Match %q, expected a revision", header)
+	}
+	return revision, true, nil
+}
+
+func pathId(r *http.Request) (uuid.UUID, error) {
+	jobId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return uuid.Nil, requestError{status: http.StatusNotFound, err: fmt.Errorf("invalid job id %q", r.PathValue("id"))}
+	}
+	return jobId, nil
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// DefaultMaxBodyBytes is used when Config.MaxBodyBytes is not set
+const DefaultMaxBodyBytes = 1 << 20
+
+// ErrUnauthorized is returned by StaticToken for a token which does not match
+var ErrUnauthorized = errors.New("unauthorized")
+
+type Config struct {
+	// Authenticate is called with the bearer token of every request, the request is rejected with 401 Unauthorized
+	// if it returns an error. Requests without a bearer token are passed an empty token. All requests are accepted
+	// when it is not set.
+	Authenticate func(r *http.Request, token string) error
+	// History is queried for the runs of a job when set, otherwise the runs are taken from the history in the catalog
+	History      job.HistoryStore
+	MaxBodyBytes int64 // the maximum size of a request body, defaults to DefaultMaxBodyBytes
+}
+
+// StaticToken returns an Authenticate hook which accepts requests carrying token
+func StaticToken(token string) func(r *http.Request, token string) error {
+	return func(_ *http.Request, t string) error {
+		if token == "" || subtle.ConstantTimeCompare([]byte(t), []byte(token)) != 1 {
+			return ErrUnauthorized
+		}
+		return nil
+	}
+}
@@ -0,0 +1,320 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func newTestServer(t *testing.T, config Config) (*httptest.Server, *job.Orchestrator, *job.MemoryCatalog) {
+	t.Helper()
+
+	r := task.NewHandlerRepository()
+	err := r.RegisterHandlerPools([]*task.HandlerPool{
+		task.NewHandlerPool(task.NewDefaultEmptyTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultSleepTaskHandler()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := job.NewMemoryCatalog()
+	o := job.NewOrchestrator(c, r, job.OrchestratorConfig{MaxJobs: 1, ScheduleInterval: 10 * time.Millisecond})
+	server := httptest.NewServer(NewServer(o, c, config))
+	t.Cleanup(server.Close)
+	return server, o, c
+}
+
+// call sends a request with a JSON body to the server and decodes the JSON response into v, if v is not nil
+func call(t *testing.T, server *httptest.Server, method string, path string, body string, header http.Header, v interface{}) *http.Response {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if v != nil {
+		if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: got error decoding response: %s", method, path, err.Error())
+		}
+	}
+	return res
+}
+
+func expectStatus(t *testing.T, res *http.Response, status int) {
+	t.Helper()
+
+	if res.StatusCode != status {
+		t.Errorf("%s %s: got status %d, expected %d", res.Request.Method, res.Request.URL.Path, res.StatusCode, status)
+	}
+}
+
+const testSpec = `{"namespace":"ops","name":"backup","labels":{"team":"ops"},"schedule":"@hourly","maxRuns":3,"tasks":[{"type":"sleep","data":{"milliseconds":5}},{"type":"empty"}]}`
+
+func TestServer_Jobs(t *testing.T) {
+	server, _, _ := newTestServer(t, Config{})
+
+	var created Job
+	res := call(t, server, http.MethodPost, "/jobs", testSpec, nil, &created)
+	expectStatus(t, res, http.StatusCreated)
+	if created.Namespace != "ops" || created.Name != "backup" || !created.Enabled || created.Status != job.StatusInactive.String() || created.MaxRuns != 3 ||
+		created.Schedule != "0 * * * *" || created.NextRun == nil || created.Revision != 1 {
+		t.Errorf("got created job %+v", created)
+	}
+	if len(created.Tasks) != 2 || created.Tasks[0].Type != (task.SleepTask{}).Type() || string(created.Tasks[0].Data) != `{"Milliseconds":5}` {
+		t.Errorf("got tasks %+v, expected the tasks of the spec", created.Tasks)
+	}
+	if res.Header.Get("ETag") != `"1"` {
+		t.Errorf("got ETag %s, expected the revision", res.Header.Get("ETag"))
+	}
+
+	path := "/jobs/" + created.Id.String()
+	var got Job
+	expectStatus(t, call(t, server, http.MethodGet, path, "", nil, &got), http.StatusOK)
+	if got.Id != created.Id || got.Name != created.Name {
+		t.Errorf("got job %+v, expected %+v", got, created)
+	}
+
+	var list JobList
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs?selector=team%3Dops&status=inactive,available", "", nil, &list), http.StatusOK)
+	if len(list.Jobs) != 1 || list.Jobs[0].Id != created.Id {
+		t.Errorf("got jobs %+v, expected the created job", list.Jobs)
+	}
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs?selector=team%3Ddev", "", nil, &list), http.StatusOK)
+	if len(list.Jobs) != 0 {
+		t.Errorf("got jobs %+v, expected none", list.Jobs)
+	}
+
+	// An update with a stale revision is rejected, the definition is replaced while the run state is kept
+	updated := strings.Replace(testSpec, `"maxRuns":3`, `"maxRuns":5,"enabled":false`, 1)
+	var apiErr Error
+	expectStatus(t, call(t, server, http.MethodPut, path, updated, http.Header{"If-Match": {`"7"`}}, &apiErr), http.StatusPreconditionFailed)
+	if apiErr.Kind != job.ErrKindConflict.String() {
+		t.Errorf("got error %+v, expected a conflict", apiErr)
+	}
+	expectStatus(t, call(t, server, http.MethodPut, path, updated, http.Header{"If-Match": {`"1"`}}, &got), http.StatusOK)
+	if got.MaxRuns != 5 || got.Enabled || got.Revision != 2 || got.Status != created.Status {
+		t.Errorf("got updated job %+v", got)
+	}
+
+	expectStatus(t, call(t, server, http.MethodPost, path+"/enable", "", nil, &got), http.StatusOK)
+	if !got.Enabled {
+		t.Errorf("job was not enabled")
+	}
+	expectStatus(t, call(t, server, http.MethodPost, path+"/trigger", "", nil, &got), http.StatusOK)
+	if !got.Triggered {
+		t.Errorf("job was not triggered")
+	}
+	expectStatus(t, call(t, server, http.MethodPost, path+"/disable", "", nil, &got), http.StatusOK)
+	if got.Enabled {
+		t.Errorf("job was not disabled")
+	}
+
+	expectStatus(t, call(t, server, http.MethodDelete, path, "", nil, nil), http.StatusNoContent)
+	expectStatus(t, call(t, server, http.MethodGet, path, "", nil, &apiErr), http.StatusNotFound)
+	if apiErr.Kind != job.ErrKindNotFound.String() {
+		t.Errorf("got error %+v, expected not found", apiErr)
+	}
+}
+
+func TestServer_CreateInvalid(t *testing.T) {
+	server, _, _ := newTestServer(t, Config{})
+
+	if res := call(t, server, http.MethodPost, "/jobs", testSpec, nil, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, expected %d", res.StatusCode, http.StatusCreated)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		kind   string
+	}{
+		{"schedule", `{"name":"a","schedule":"every day"}`, http.StatusBadRequest, ""},
+		{"unknown field", `{"name":"a","schedule":"@hourly","retries":3}`, http.StatusBadRequest, ""},
+		{"unknown task type", `{"name":"a","schedule":"@hourly","tasks":[{"type":"foo"}]}`, http.StatusBadRequest, ""},
+		{"handler missing", `{"name":"a","schedule":"@hourly","tasks":[{"type":"print","data":{"message":"hi"}}]}`, http.StatusUnprocessableEntity, job.ErrKindHandlerMissing.String()},
+		{"name exists", testSpec, http.StatusConflict, job.ErrKindNameExist.String()},
+		{"invalid name", `{"namespace":"ops","name":"a/b","schedule":"@hourly"}`, http.StatusUnprocessableEntity, job.ErrKindInvalidName.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiErr Error
+			expectStatus(t, call(t, server, http.MethodPost, "/jobs", tt.body, nil, &apiErr), tt.status)
+			if apiErr.Error == "" || apiErr.Kind != tt.kind {
+				t.Errorf("got error %+v, expected kind %q", apiErr, tt.kind)
+			}
+		})
+	}
+}
+
+func TestServer_Runs(t *testing.T) {
+	server, _, c := newTestServer(t, Config{})
+
+	s, err := cron.NewSchedule("@hourly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := job.NewJob("backup", s, 0, job.NewSequence([]task.Task{task.EmptyTask{}}))
+	start := time.Now().Add(-time.Hour)
+	for i, status := range []job.Status{job.StatusCompleted, job.StatusError, job.StatusCompleted} {
+		r := job.Result{Start: start.Add(time.Duration(i) * time.Minute), Finish: start.Add(time.Duration(i)*time.Minute + time.Second), Status: status}
+		if status == job.StatusError {
+			r.Messages = []task.Message{task.NewErrorMessage("failed", task.EmptyTask{}, errors.New("disk full"))}
+		}
+		j.AddResult(r)
+	}
+	if err = c.Add(j); err != nil {
+		t.Fatal(err)
+	}
+
+	var list RunList
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs/"+j.Uuid.String()+"/runs?limit=2", "", nil, &list), http.StatusOK)
+	if list.Total != 3 || len(list.Runs) != 2 || list.Runs[0].Run != 3 || list.Runs[1].Run != 2 {
+		t.Fatalf("got %+v, expected runs 3 and 2 of 3", list)
+	}
+
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs/"+j.Uuid.String()+"/runs?status=error", "", nil, &list), http.StatusOK)
+	if list.Total != 1 || len(list.Runs) != 1 || list.Runs[0].Run != 2 {
+		t.Fatalf("got %+v, expected run 2", list)
+	}
+	if messages := list.Runs[0].Messages; len(messages) != 1 || messages[0].Type != task.ErrorMessage.String() || messages[0].Error != "disk full" {
+		t.Errorf("got messages %+v, expected the error of the run", messages)
+	}
+
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs/"+j.Uuid.String()+"/runs?status=done", "", nil, nil), http.StatusBadRequest)
+}
+
+func TestServer_EnableFinishedJob(t *testing.T) {
+	server, o, c := newTestServer(t, Config{})
+
+	// The job finished its last run while it was disabled
+	s, err := cron.NewSchedule("@hourly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := job.NewJob("backup", s, 0, job.NewSequence([]task.Task{task.EmptyTask{}}))
+	j.AddResult(job.Result{Start: time.Now().Add(-time.Minute), Finish: time.Now(), Status: job.StatusCompleted})
+	j.SetStatus(job.StatusCompleted)
+	j.Disable()
+	if err = c.Add(j); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := o.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	path := "/jobs/" + j.Uuid.String()
+	var got Job
+	expectStatus(t, call(t, server, http.MethodPost, path+"/trigger", "", nil, &got), http.StatusOK)
+	if !got.Triggered || got.Status != job.StatusInactive.String() {
+		t.Errorf("got triggered %t with status %s, expected triggered job with status %s", got.Triggered, got.Status, job.StatusInactive)
+	}
+	expectStatus(t, call(t, server, http.MethodPost, path+"/enable", "", nil, &got), http.StatusOK)
+
+	// The triggered job runs again once it is enabled
+	deadline := time.Now().Add(5 * time.Second)
+	for got.Runs < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d runs with status %s, expected the job to run again", got.Runs, got.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		expectStatus(t, call(t, server, http.MethodGet, path, "", nil, &got), http.StatusOK)
+	}
+}
+
+func TestServer_Orchestrator(t *testing.T) {
+	server, o, _ := newTestServer(t, Config{})
+
+	var state OrchestratorState
+	expectStatus(t, call(t, server, http.MethodPost, "/orchestrator/pause", "", nil, &state), http.StatusOK)
+	if !state.Paused || !o.IsPaused() {
+		t.Errorf("orchestrator was not paused")
+	}
+	expectStatus(t, call(t, server, http.MethodPost, "/orchestrator/resume", "", nil, &state), http.StatusOK)
+	if state.Paused || o.IsPaused() {
+		t.Errorf("orchestrator was not resumed")
+	}
+
+	if res := call(t, server, http.MethodPost, "/jobs", testSpec, nil, nil); res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, expected %d", res.StatusCode, http.StatusCreated)
+	}
+	var stats job.OrchestratorStats
+	expectStatus(t, call(t, server, http.MethodGet, "/orchestrator/stats", "", nil, &stats), http.StatusOK)
+	if stats.Job.ConfiguredJobs != 1 || stats.Job.EnabledJobs != 1 {
+		t.Errorf("got stats %+v, expected one enabled job", stats.Job)
+	}
+}
+
+func TestServer_Authenticate(t *testing.T) {
+	server, _, _ := newTestServer(t, Config{Authenticate: StaticToken("secret")})
+
+	for _, header := range []http.Header{nil, {"Authorization": {"Bearer wrong"}}} {
+		var apiErr Error
+		res := call(t, server, http.MethodGet, "/jobs", "", header, &apiErr)
+		expectStatus(t, res, http.StatusUnauthorized)
+		if res.Header.Get("WWW-Authenticate") == "" || apiErr.Error != ErrUnauthorized.Error() {
+			t.Errorf("got error %+v and challenge %q", apiErr, res.Header.Get("WWW-Authenticate"))
+		}
+	}
+
+	expectStatus(t, call(t, server, http.MethodGet, "/jobs", "", http.Header{"Authorization": {"Bearer secret"}}, &JobList{}), http.StatusOK)
+}
+
+func TestServer_Schemas(t *testing.T) {
+	server, _, _ := newTestServer(t, Config{})
+
+	for _, name := range []string{"job", "jobSpec", "run", "error"} {
+		var schema map[string]interface{}
+		expectStatus(t, call(t, server, http.MethodGet, "/schemas/"+name+".json", "", nil, &schema), http.StatusOK)
+		if schema["$id"] != name {
+			t.Errorf("got schema %v for %s", schema["$id"], name)
+		}
+	}
+	expectStatus(t, call(t, server, http.MethodGet, "/schemas/task", "", nil, nil), http.StatusNotFound)
+}
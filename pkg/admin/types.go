@@ -0,0 +1,234 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// Job is the representation of a job returned by the API, see the job schema
+type Job struct {
+	Id          uuid.UUID         `json:"id"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Schedule    string            `json:"schedule"`
+	MaxRuns     int               `json:"maxRuns"`
+	Priority    int               `json:"priority,omitempty"`
+	Concurrency map[string]int    `json:"concurrency,omitempty"`
+	Triggered   bool              `json:"triggered,omitempty"`
+	Status      string            `json:"status"`
+	Tasks       []Task            `json:"tasks"`
+	Runs        int               `json:"runs"`
+	NextRun     *time.Time        `json:"nextRun,omitempty"`
+	Revision    uint64            `json:"revision"`
+}
+
+// JobSpec is the definition of a job which is sent to create or update a job, see the jobSpec schema.
+// A job is enabled when it is created unless Enabled is false, an update without Enabled keeps the job as it is.
+type JobSpec struct {
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Enabled     *bool             `json:"enabled,omitempty"`
+	Schedule    string            `json:"schedule"`
+	MaxRuns     int               `json:"maxRuns"`
+	Priority    int               `json:"priority,omitempty"`
+	Concurrency map[string]int    `json:"concurrency,omitempty"`
+	Tasks       []Task            `json:"tasks"`
+}
+
+// Task is a task of a job, Type is the name of a type registered with task.DefaultRegistry and Data holds the
+// parameters of the task as defined by its type
+type Task struct {
+	Type   string          `json:"type"`
+	Status string          `json:"status,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// Run is a finished or active run of a job, see the run schema
+type Run struct {
+	Run      int       `json:"run"`
+	Start    time.Time `json:"start"`
+	Finish   time.Time `json:"finish"`
+	Status   string    `json:"status"`
+	Tasks    []Task    `json:"tasks"`
+	Messages []Message `json:"messages"`
+}
+
+// Message is a message sent by a task during a run, errors only keep their message
+type Message struct {
+	Message string      `json:"message"`
+	Task    string      `json:"task,omitempty"`
+	Type    string      `json:"type"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type JobList struct {
+	Jobs   []Job  `json:"jobs"`
+	Cursor string `json:"cursor,omitempty"` // continues the listing on the next page, empty on the last page
+}
+
+type RunList struct {
+	Runs  []Run `json:"runs"`  // ordered from newest to oldest
+	Total int   `json:"total"` // the number of matching runs, regardless of pagination
+}
+
+// OrchestratorState reports whether the orchestrator is started and paused
+type OrchestratorState struct {
+	Started bool `json:"started"`
+	Paused  bool `json:"paused"`
+}
+
+// Error is returned with every response which is not successful, Kind is the kind of a job.Error
+type Error struct {
+	Error string `json:"error"`
+	Kind  string `json:"kind,omitempty"`
+}
+
+func newJob(j job.Job, now time.Time) (Job, error) {
+	tasks, err := newTasks(j.Tasks.All())
+	if err != nil {
+		return Job{}, err
+	}
+
+	v := Job{
+		Id:          j.Uuid,
+		Namespace:   j.Namespace,
+		Name:        j.Name,
+		Labels:      j.Labels,
+		Annotations: j.Annotations,
+		Enabled:     j.IsEnabled(),
+		Schedule:    j.Schedule.String(),
+		MaxRuns:     j.MaxRuns,
+		Priority:    j.Priority,
+		Concurrency: j.Concurrency,
+		Triggered:   j.IsTriggered(),
+		Status:      j.Status.String(),
+		Tasks:       tasks,
+		Runs:        j.CountRuns(),
+		Revision:    j.Revision,
+	}
+	if j.IsEnabled() && j.IsEligible() {
+		if next := j.NextRun(now); !next.IsZero() {
+			v.NextRun = &next
+		}
+	}
+	return v, nil
+}
+
+func newRun(run int, r job.Result) (Run, error) {
+	tasks, err := newTasks(r.Tasks)
+	if err != nil {
+		return Run{}, err
+	}
+
+	v := Run{
+		Run:      run,
+		Start:    r.Start,
+		Finish:   r.Finish,
+		Status:   r.Status.String(),
+		Tasks:    tasks,
+		Messages: make([]Message, 0, len(r.Messages)),
+	}
+	for _, m := range r.Messages {
+		message := Message{
+			Message: m.Message,
+			Task:    m.Task,
+			Type:    m.Type.String(),
+		}
+		if err, ok := m.Data.(error); ok {
+			message.Error = err.Error()
+		} else {
+			message.Data = m.Data
+		}
+		v.Messages = append(v.Messages, message)
+	}
+	return v, nil
+}
+
+func newTasks(tasks []task.Task) ([]Task, error) {
+	v := make([]Task, 0, len(tasks))
+	for _, t := range tasks {
+		e, err := task.DefaultRegistry.Encode(t)
+		if err != nil {
+			return nil, err
+		}
+		v = append(v, Task{
+			Type:   e.Type,
+			Status: e.Status.String(),
+			Data:   e.Params,
+		})
+	}
+	return v, nil
+}
+
+// Job returns a new job defined by the spec, errors in the spec are returned as is
+func (s JobSpec) Job() (job.Job, error) {
+	j := job.NewJob("", cron.Schedule{}, 0, job.NewSequence(nil))
+	if err := s.apply(&j); err != nil {
+		return job.Job{}, err
+	}
+	return j, nil
+}
+
+// apply sets the definition of j to the spec, the run state of j is kept
+func (s JobSpec) apply(j *job.Job) error {
+	schedule, err := cron.NewSchedule(s.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", s.Schedule, err)
+	}
+	if s.MaxRuns < 0 {
+		return errors.New("maxRuns must not be negative")
+	}
+
+	tasks := make([]task.Task, 0, len(s.Tasks))
+	for i, t := range s.Tasks {
+		decoded, err := task.DefaultRegistry.Decode(task.EncodedTask{Type: t.Type, Params: t.Data})
+		if err != nil {
+			return fmt.Errorf("task %d: %w", i, err)
+		}
+		tasks = append(tasks, decoded)
+	}
+
+	j.Namespace = s.Namespace
+	j.Name = s.Name
+	j.Labels = s.Labels
+	j.Annotations = s.Annotations
+	j.Schedule = schedule
+	j.MaxRuns = s.MaxRuns
+	j.Priority = s.Priority
+	j.Concurrency = s.Concurrency
+	j.Tasks = job.NewSequence(tasks)
+	if s.Enabled != nil {
+		j.Enabled = *s.Enabled
+	}
+	return nil
+}
@@ -25,6 +25,9 @@ import (
 
 var reSpace = regexp.MustCompile(`\s+`)
 
+// maxNextYears limits the search for the next time a schedule is due
+const maxNextYears = 10
+
 // var reYear = regexp.MustCompile(`\d{4}`)
 
 var cronWeekdayLiterals = strings.NewReplacer(
@@ -155,6 +158,45 @@ func (s *Schedule) IsDue(t time.Time) bool {
 	return o
 }
 
+// Next returns the first time after t at which the schedule is due, with a resolution of one second.
+// The zero time is returned if the schedule is not due within maxNextYears after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	if len(s.elements) == 0 {
+		return time.Time{}
+	}
+
+	next := t.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + maxNextYears
+	for next.Year() <= limit {
+		y, m, d := next.Date()
+		switch {
+		case !s.trigger(positionYear, next):
+			next = time.Date(y+1, time.January, 1, 0, 0, 0, 0, next.Location())
+		case !s.trigger(positionMonth, next):
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.trigger(positionDay, next) || !s.trigger(positionWeekday, next):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, next.Location())
+		case !s.trigger(positionHour, next):
+			next = time.Date(y, m, d, next.Hour()+1, 0, 0, 0, next.Location())
+		case !s.trigger(positionMinute, next):
+			next = next.Truncate(time.Minute).Add(time.Minute)
+		case !s.trigger(positionSecond, next):
+			next = next.Add(time.Second)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
 func (s *Schedule) String() string {
 	return s.expression
 }
+
+// trigger reports whether the element at position p is due at t, a schedule without an element at p is always due
+func (s *Schedule) trigger(p position, t time.Time) bool {
+	if int(p) >= len(s.elements) {
+		return true
+	}
+	return s.elements[p].Trigger(t)
+}
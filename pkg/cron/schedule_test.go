@@ -93,6 +93,43 @@ func TestSchedule_IsDueValid(t *testing.T) {
 	}
 }
 
+func TestSchedule_Next(t *testing.T) {
+	var tests = []struct {
+		expression string
+		time       string
+		wanted     string
+	}{
+		{"* * * * * *", "20060102150405", "20060102150406"},
+		{"@always", "20060102150405", "20060102150500"},
+		{"@hourly", "20060102150405", "20060102160000"},
+		{"@daily", "20060102150405", "20060103000000"},
+		{"@weekly", "20060102150405", "20060108000000"},
+		{"@monthly", "20060102150405", "20060201000000"},
+		{"@yearly", "20060102150405", "20070101000000"},
+		{"@15minutes", "20060102150405", "20060102151500"},
+		{"30 4 * * * *", "20060102150405", "20060102150430"},
+		{"0 4 * * * *", "20060102150405", "20060102160400"},
+		{"0 12 29 2 *", "20060102150405", "20080229120000"},
+		{"0 0 * * 5", "20060102150405", "20060106000000"},
+		{"0 0 0 1 1 * 2010", "20060102150405", "20100101000000"},
+		{"0 0 0 1 1 * 2000", "20060102150405", "00010101000000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			i, _ := time.Parse("20060102150405", tt.time)
+			wanted, _ := time.Parse("20060102150405", tt.wanted)
+			s, err := NewSchedule(tt.expression)
+			if err != nil {
+				t.Fatalf("invalid expression %s", tt.expression)
+			}
+			if next := s.Next(i); !next.Equal(wanted) {
+				t.Errorf("got %s, expected %s for %s", next, wanted, tt.expression)
+			}
+		})
+	}
+}
+
 func TestSchedule_ReplaceTemplates(t *testing.T) {
 	var tests = []struct {
 		expression string
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// DeleteSelected deletes all jobs with labels matching selector and returns the number of deleted jobs
+func DeleteSelected(c Catalog, selector Selector) (int, error) {
+	return forSelected(c, selector, c.Delete)
+}
+
+// DisableSelected disables all jobs with labels matching selector and returns the number of disabled jobs
+func DisableSelected(c Catalog, selector Selector) (int, error) {
+	return forSelected(c, selector, c.Disable)
+}
+
+// EnableSelected enables all jobs with labels matching selector and returns the number of enabled jobs
+func EnableSelected(c Catalog, selector Selector) (int, error) {
+	return forSelected(c, selector, c.Enable)
+}
+
+// TriggerJob requests a run of the job as soon as possible, regardless of its schedule.
+// A job which is triggered while it is running, runs again after the active run.
+func TriggerJob(c Catalog, jobId uuid.UUID) error {
+	for {
+		job, err := c.Get(jobId)
+		if err != nil {
+			return err
+		}
+
+		job.Trigger()
+		err = c.UpdateIf(job, job.Revision)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+}
+
+// TriggerSelected triggers all jobs with labels matching selector and returns the number of triggered jobs
+func TriggerSelected(c Catalog, selector Selector) (int, error) {
+	return forSelected(c, selector, func(jobId uuid.UUID) error {
+		return TriggerJob(c, jobId)
+	})
+}
+
+// forSelected calls f for every job with labels matching selector, jobs which are deleted concurrently are skipped
+func forSelected(c Catalog, selector Selector, f func(jobId uuid.UUID) error) (int, error) {
+	page, err := c.Find(Query{Selector: selector})
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		count int
+		errs  []error
+	)
+	for _, job := range page.Jobs {
+		err = f(job.Uuid)
+		switch {
+		case errors.Is(err, ErrNotFound):
+		case err != nil:
+			errs = append(errs, err)
+		default:
+			count++
+		}
+	}
+	return count, errors.Join(errs...)
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestBulkCatalog(t *testing.T) (*MemoryCatalog, Selector) {
+	t.Helper()
+
+	c := NewMemoryCatalog()
+	for _, labels := range []map[string]string{
+		{"team": "payments", "env": "prod"},
+		{"team": "payments", "env": "stage"},
+		{"team": "payments", "env": "prod", "deprecated": "true"},
+		{"team": "billing", "env": "prod"},
+	} {
+		j := newTestJob(t, "test")
+		j.Labels = labels
+		if err := c.Add(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := ParseSelector("team=payments,env in (prod,stage),!deprecated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, s
+}
+
+func TestDisableSelected(t *testing.T) {
+	c, s := newTestBulkCatalog(t)
+
+	count, err := DisableSelected(c, s)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got %d disabled jobs, expected %d", count, 2)
+	}
+	for _, j := range c.All() {
+		if j.IsEnabled() == s.Matches(j.Labels) {
+			t.Errorf("got enabled %t for job with labels %v, expected only selected jobs to be disabled", j.IsEnabled(), j.Labels)
+		}
+	}
+
+	if count, err = EnableSelected(c, s); err != nil || count != 2 {
+		t.Errorf("got %d enabled jobs and error %v, expected %d", count, err, 2)
+	}
+	for _, j := range c.All() {
+		if !j.IsEnabled() {
+			t.Errorf("job with labels %v is disabled, expected enabled", j.Labels)
+		}
+	}
+}
+
+func TestDeleteSelected(t *testing.T) {
+	c, s := newTestBulkCatalog(t)
+
+	count, err := DeleteSelected(c, s)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if count != 2 || c.Count() != 2 {
+		t.Errorf("got %d deleted and %d remaining jobs, expected 2 and 2", count, c.Count())
+	}
+	for _, j := range c.All() {
+		if s.Matches(j.Labels) {
+			t.Errorf("job with labels %v was not deleted", j.Labels)
+		}
+	}
+}
+
+func TestTriggerSelected(t *testing.T) {
+	c, s := newTestBulkCatalog(t)
+
+	count, err := TriggerSelected(c, s)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got %d triggered jobs, expected %d", count, 2)
+	}
+	for _, j := range c.All() {
+		if j.IsTriggered() != s.Matches(j.Labels) {
+			t.Errorf("got triggered %t for job with labels %v, expected only selected jobs to be triggered", j.IsTriggered(), j.Labels)
+		}
+	}
+}
+
+func TestTriggerJob_NotFound(t *testing.T) {
+	c := NewMemoryCatalog()
+	if err := TriggerJob(c, newTestJob(t, "test").Uuid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, expected %v", err, ErrNotFound)
+	}
+}
@@ -16,19 +16,71 @@
 
 package job
 
-import "github.com/google/uuid"
+import (
+	"time"
 
+	"github.com/google/uuid"
+)
+
+// Catalog stores jobs for an Orchestrator.
+// Jobs returned by a catalog are copies, changes to them are only stored by passing them to Update or UpdateIf.
+// Every stored change increments the revision of a job, a new job is stored with revision 1.
+// Disabled jobs remain part of the catalog and are returned by All and the lists by status.
+// The lists by status are used for scheduling, the jobs they return may hold only the last result of their history.
+// Methods which operate on a single job return ErrNotFound if the job does not exist.
+// Jobs with a namespace have a unique name within their namespace, storing a job with the name of another job in the
+// same namespace fails with ErrNameExist. Names are only looked up within a namespace.
+// Orchestrators sharing a catalog can divide its jobs between them, they register as members with Join and claim the
+// jobs they own. Membership expires when a member does not join again in time, the jobs of an expired member can be
+// claimed by other members.
+// The package catalogtest contains a conformance test suite for implementations.
 type Catalog interface {
+	// Add stores a new job, it returns ErrExist if a job with the same Uuid exists
 	Add(job Job) error
 	All() []Job
 	AvailableJobs() []Job
+	// Claim makes instance the owner of a job, it fails with ErrOwned if the job is owned by another member
+	Claim(jobId uuid.UUID, instance string) error
 	Delete(jobId uuid.UUID) error
+	DeleteByName(namespace string, name string) error
 	Disable(jobId uuid.UUID) error
+	// Enable enables a job, a job which finished its last run while it was disabled is made inactive, so it is
+	// scheduled again
 	Enable(jobId uuid.UUID) error
+	// Find returns the jobs selected by query, sorted and paginated as requested by query
+	Find(query Query) (JobPage, error)
+	Get(jobId uuid.UUID) (Job, error)
+	GetByName(namespace string, name string) (Job, error)
+	// HasEnabledJobs reports whether at least one job is enabled, regardless of its status
 	HasEnabledJobs() bool
 	InactiveJobs() []Job
+	// Join registers instance as a member of the catalog until ttl has passed, joining again extends the membership
+	Join(instance string, ttl time.Duration) error
+	// Leave removes instance from the members and releases all jobs it owns
+	Leave(instance string) error
+	// Members returns the current members, sorted by instance
+	Members() ([]string, error)
+	// Overview returns all jobs like All, the jobs may hold only the last result of their history like the lists by
+	// status. It serves statistics, which do not need the complete history.
+	Overview() []Job
+	// Owners returns the owner of every job which is owned by a current member
+	Owners() (map[uuid.UUID]string, error)
 	PendingJobs() []Job
+	// PruneHistory removes the results of a job which are not kept by policy at time now, a zero policy does not change
+	// the job. Catalogs which store results apart from their job may keep results which are missing from the history
+	// of a job passed to Update or UpdateIf, PruneHistory is the way to remove them.
+	PruneHistory(jobId uuid.UUID, policy RetentionPolicy, now time.Time) error
+	// Release gives up the ownership of a job by instance, it fails with ErrOwned if the job is owned by another member
+	Release(jobId uuid.UUID, instance string) error
+	// Rename changes the namespace and name of a job
+	Rename(jobId uuid.UUID, namespace string, name string) error
 	RunnableJobs() []Job
 	SchedulableJobs() []Job
+	// Transition atomically changes the status of a job, it fails with ErrConflict if the job is no longer in the expected status
+	// and with ErrInvalidTransition if the job cannot move between the statuses
+	Transition(jobId uuid.UUID, from Status, to Status) error
+	// Update replaces a stored job with job, regardless of its revision
 	Update(job Job) error
+	// UpdateIf replaces a stored job with job if the stored revision equals expectedRevision, otherwise it fails with ErrConflict
+	UpdateIf(job Job, expectedRevision uint64) error
 }
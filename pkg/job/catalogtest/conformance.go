@@ -0,0 +1,993 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package catalogtest provides a conformance test suite for implementations of job.Catalog.
+package catalogtest
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// Factory returns a new, empty catalog for every test. Resources can be released with t.Cleanup.
+type Factory func(t *testing.T) job.Catalog
+
+// RunConformance runs the conformance test suite against the catalogs returned by newCatalog.
+// Run the tests with -race to detect data races in the implementation.
+func RunConformance(t *testing.T, newCatalog Factory) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, c job.Catalog)
+	}{
+		{"Add", testAdd},
+		{"AddExisting", testAddExisting},
+		{"AddCopy", testAddCopy},
+		{"AddAfterDelete", testAddAfterDelete},
+		{"AddMetadata", testAddMetadata},
+		{"AddNameExist", testAddNameExist},
+		{"AddInvalidName", testAddInvalidName},
+		{"All", testAll},
+		{"AllCopy", testAllCopy},
+		{"Overview", testOverview},
+		{"Delete", testDelete},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"DeleteByName", testDeleteByName},
+		{"EnableDisable", testEnableDisable},
+		{"EnableDisableNotFound", testEnableDisableNotFound},
+		{"EnableDisablePreservesStatus", testEnableDisablePreservesStatus},
+		{"EnableFinishedJob", testEnableFinishedJob},
+		{"Find", testFind},
+		{"FindSelector", testFindSelector},
+		{"FindNamespace", testFindNamespace},
+		{"FindSort", testFindSort},
+		{"FindPagination", testFindPagination},
+		{"FindInvalid", testFindInvalid},
+		{"FindAfterUpdate", testFindAfterUpdate},
+		{"Get", testGet},
+		{"GetNotFound", testGetNotFound},
+		{"GetByName", testGetByName},
+		{"Rename", testRename},
+		{"HasEnabledJobs", testHasEnabledJobs},
+		{"JobsByStatus", testJobsByStatus},
+		{"JobsByStatusIncludesDisabled", testJobsByStatusIncludesDisabled},
+		{"Transition", testTransition},
+		{"TransitionConflict", testTransitionConflict},
+		{"TransitionNotFound", testTransitionNotFound},
+		{"TransitionInvalid", testTransitionInvalid},
+		{"TransitionPreservesJob", testTransitionPreservesJob},
+		{"Update", testUpdate},
+		{"UpdateHistory", testUpdateHistory},
+		{"UpdateStatusListJob", testUpdateStatusListJob},
+		{"PruneHistory", testPruneHistory},
+		{"PruneHistoryNotFound", testPruneHistoryNotFound},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"UpdateNameExist", testUpdateNameExist},
+		{"UpdateIf", testUpdateIf},
+		{"UpdateIfConflict", testUpdateIfConflict},
+		{"UpdateIfNotFound", testUpdateIfNotFound},
+		{"Revision", testRevision},
+		{"Claim", testClaim},
+		{"ClaimExpired", testClaimExpired},
+		{"ClaimNotFound", testClaimNotFound},
+		{"ClaimAfterDelete", testClaimAfterDelete},
+		{"Leave", testLeave},
+		{"ConcurrentAdd", testConcurrentAdd},
+		{"ConcurrentTransition", testConcurrentTransition},
+		{"ConcurrentAccess", testConcurrentAccess},
+		{"ConcurrentUpdateIf", testConcurrentUpdateIf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.f(t, newCatalog(t))
+		})
+	}
+}
+
+// NewJob returns an enabled, inactive job with two tasks which can be stored in every catalog
+func NewJob(t testing.TB, name string) job.Job {
+	t.Helper()
+
+	s, err := cron.NewSchedule("@everysecond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job.NewJob(name, s, 0, job.NewSequence([]task.Task{task.EmptyTask{}, task.SleepTask{Milliseconds: 10}}))
+}
+
+func add(t *testing.T, c job.Catalog, j job.Job) {
+	t.Helper()
+
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error on add: %s", err.Error())
+	}
+}
+
+func get(t *testing.T, c job.Catalog, id uuid.UUID) job.Job {
+	t.Helper()
+
+	j, found := find(c, id)
+	if !found {
+		t.Fatalf("job %s not found", id)
+	}
+	return j
+}
+
+func find(c job.Catalog, id uuid.UUID) (job.Job, bool) {
+	for _, j := range c.All() {
+		if j.Uuid == id {
+			return j, true
+		}
+	}
+	return job.Job{}, false
+}
+
+func expectErr(t *testing.T, err error, wanted error) {
+	t.Helper()
+
+	if !errors.Is(err, wanted) {
+		t.Errorf("got %v, expected %v", err, wanted)
+	}
+}
+
+func testAdd(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	result := get(t, c, j.Uuid)
+	if result.Name != j.Name || result.Tasks.Count() != j.Tasks.Count() || result.Schedule.String() != j.Schedule.String() {
+		t.Errorf("got job %s with %d tasks, expected %s with %d tasks", result.Name, result.Tasks.Count(), j.Name, j.Tasks.Count())
+	}
+	if !result.IsEnabled() || result.Status != job.StatusInactive || result.MaxRuns != j.MaxRuns {
+		t.Errorf("got enabled %t with status %s, expected enabled job with status %s", result.IsEnabled(), result.Status, job.StatusInactive)
+	}
+}
+
+func testAddExisting(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	expectErr(t, c.Add(j), job.ErrExist)
+	if len(c.All()) != 1 {
+		t.Errorf("got %d jobs, expected 1", len(c.All()))
+	}
+}
+
+func testAddCopy(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	// Changing the job after adding it must not change the stored job
+	j.SetStatus(job.StatusPending)
+	j.AddResult(job.Result{Status: job.StatusActive})
+	j.Disable()
+
+	result := get(t, c, j.Uuid)
+	if result.Status != job.StatusInactive || result.CountRuns() != 0 || !result.IsEnabled() {
+		t.Errorf("stored job changed without update")
+	}
+}
+
+func testAddAfterDelete(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	add(t, c, j)
+}
+
+func testAddMetadata(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.Labels = map[string]string{"team": "payments"}
+	j.Annotations = map[string]string{"runbook": "https://example.com/runbook"}
+	j.Priority = 10
+	j.Concurrency = map[string]int{"db-migrations": 1}
+	j.Trigger()
+	add(t, c, j)
+
+	// Changing the maps of the added job must not change the stored job
+	j.Labels["team"] = "billing"
+	j.Annotations["runbook"] = ""
+
+	result := get(t, c, j.Uuid)
+	if result.Labels["team"] != "payments" || result.Annotations["runbook"] != "https://example.com/runbook" {
+		t.Errorf("got labels %v and annotations %v, expected stored metadata to be unchanged", result.Labels, result.Annotations)
+	}
+	if !result.IsTriggered() {
+		t.Errorf("job is not triggered, expected trigger to be stored")
+	}
+	if result.Priority != 10 || result.Concurrency["db-migrations"] != 1 {
+		t.Errorf("got priority %d and concurrency %v, expected priority 10 and concurrency db-migrations:1", result.Priority, result.Concurrency)
+	}
+}
+
+func testAll(t *testing.T, c job.Catalog) {
+	if len(c.All()) != 0 {
+		t.Fatalf("got %d jobs in an empty catalog, expected 0", len(c.All()))
+	}
+
+	ids := make(map[uuid.UUID]bool)
+	for i := 0; i < 10; i++ {
+		j := NewJob(t, strconv.Itoa(i))
+		ids[j.Uuid] = true
+		add(t, c, j)
+	}
+
+	jobs := c.All()
+	if len(jobs) != 10 {
+		t.Fatalf("got %d jobs, expected %d", len(jobs), 10)
+	}
+	for _, j := range jobs {
+		if !ids[j.Uuid] {
+			t.Errorf("got unexpected job %s", j.Uuid)
+		}
+		delete(ids, j.Uuid)
+	}
+}
+
+func testAllCopy(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.AddResult(job.Result{Status: job.StatusCompleted})
+	add(t, c, j)
+
+	// Changing a returned job must not change the stored job
+	result := get(t, c, j.Uuid)
+	result.SetStatus(job.StatusPending)
+	result.UpdateResult(job.Result{Status: job.StatusError})
+	result.AddResult(job.Result{Status: job.StatusActive})
+	result.Disable()
+
+	result = get(t, c, j.Uuid)
+	if result.Status != job.StatusInactive || !result.IsEnabled() {
+		t.Errorf("got enabled %t with status %s, expected stored job to be unchanged", result.IsEnabled(), result.Status)
+	}
+	if result.CountRuns() != 1 || result.CurrentResult().Status != job.StatusCompleted {
+		t.Errorf("got %d runs with status %s, expected stored history to be unchanged", result.CountRuns(), result.CurrentResult().Status)
+	}
+}
+
+func testOverview(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		j.AddResult(job.Result{Start: start.Add(time.Duration(i) * time.Second), Finish: start.Add(time.Duration(i) * time.Second), Status: job.StatusCompleted})
+	}
+	add(t, c, j)
+	add(t, c, NewJob(t, "other"))
+
+	jobs := c.Overview()
+	if len(jobs) != 2 {
+		t.Fatalf("got %d jobs, expected 2", len(jobs))
+	}
+	for _, o := range jobs {
+		if o.Uuid != j.Uuid {
+			continue
+		}
+		if o.CountRuns() != 3 || !o.CurrentResult().Start.Equal(start.Add(2*time.Second)) {
+			t.Errorf("got %d runs with the last run started at %s, expected 3 runs and the last result", o.CountRuns(), o.CurrentResult().Start)
+		}
+	}
+}
+
+func testDelete(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	other := NewJob(t, "other")
+	add(t, c, j)
+	add(t, c, other)
+
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if _, found := find(c, j.Uuid); found {
+		t.Errorf("job %s still exists after delete", j.Uuid)
+	}
+	if _, found := find(c, other.Uuid); !found {
+		t.Errorf("job %s was deleted, expected only %s to be deleted", other.Uuid, j.Uuid)
+	}
+	expectErr(t, c.Delete(j.Uuid), job.ErrNotFound)
+}
+
+func testDeleteNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.Delete(uuid.New()), job.ErrNotFound)
+}
+
+func testEnableDisable(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result := get(t, c, j.Uuid); result.IsEnabled() {
+		t.Errorf("job is enabled, expected disabled")
+	}
+
+	// Disabling a disabled job is not an error
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	if err := c.Enable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result := get(t, c, j.Uuid); !result.IsEnabled() {
+		t.Errorf("job is disabled, expected enabled")
+	}
+}
+
+func testEnableDisableNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.Enable(uuid.New()), job.ErrNotFound)
+	expectErr(t, c.Disable(uuid.New()), job.ErrNotFound)
+}
+
+func testEnableDisablePreservesStatus(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.SetStatus(job.StatusRunnable)
+	j.AddResult(job.Result{Status: job.StatusCompleted})
+	add(t, c, j)
+
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	result := get(t, c, j.Uuid)
+	if result.Status != job.StatusRunnable || result.CountRuns() != 1 {
+		t.Errorf("got status %s with %d runs, expected %s with 1 run", result.Status, result.CountRuns(), job.StatusRunnable)
+	}
+}
+
+func testEnableFinishedJob(t *testing.T, c job.Catalog) {
+	for _, status := range []job.Status{job.StatusCompleted, job.StatusError} {
+		j := NewJob(t, "test-"+status.String())
+		j.SetStatus(status)
+		j.Disable()
+		add(t, c, j)
+
+		if err := c.Enable(j.Uuid); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		// A job which finished its last run while it was disabled is scheduled again
+		if result := get(t, c, j.Uuid); !result.IsEnabled() || result.Status != job.StatusInactive {
+			t.Errorf("got enabled %t with status %s, expected enabled job with status %s", result.IsEnabled(), result.Status, job.StatusInactive)
+		}
+	}
+}
+
+func testGet(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	result, err := c.Get(j.Uuid)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result.Uuid != j.Uuid || result.Name != j.Name || result.Tasks.Count() != j.Tasks.Count() {
+		t.Errorf("got job %s with %d tasks, expected job %s with %d tasks", result.Name, result.Tasks.Count(), j.Name, j.Tasks.Count())
+	}
+}
+
+func testGetNotFound(t *testing.T, c job.Catalog) {
+	_, err := c.Get(uuid.New())
+	expectErr(t, err, job.ErrNotFound)
+}
+
+func testHasEnabledJobs(t *testing.T, c job.Catalog) {
+	if c.HasEnabledJobs() {
+		t.Errorf("empty catalog has enabled jobs")
+	}
+
+	j := NewJob(t, "test")
+	j.SetStatus(job.StatusCompleted)
+	add(t, c, j)
+	if !c.HasEnabledJobs() {
+		t.Errorf("catalog has no enabled jobs, expected 1")
+	}
+
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if c.HasEnabledJobs() {
+		t.Errorf("catalog has enabled jobs, expected none")
+	}
+}
+
+func lists(c job.Catalog) map[job.Status]func() []job.Job {
+	return map[job.Status]func() []job.Job{
+		job.StatusInactive:    c.InactiveJobs,
+		job.StatusAvailable:   c.AvailableJobs,
+		job.StatusSchedulable: c.SchedulableJobs,
+		job.StatusRunnable:    c.RunnableJobs,
+		job.StatusPending:     c.PendingJobs,
+	}
+}
+
+func testJobsByStatus(t *testing.T, c job.Catalog) {
+	statuses := []job.Status{job.StatusInactive, job.StatusAvailable, job.StatusSchedulable, job.StatusRunnable, job.StatusPending, job.StatusActive, job.StatusCompleted, job.StatusError}
+	for _, status := range statuses {
+		j := NewJob(t, status.String())
+		j.SetStatus(status)
+		add(t, c, j)
+	}
+
+	for status, list := range lists(c) {
+		jobs := list()
+		if len(jobs) != 1 {
+			t.Errorf("got %d %s jobs, expected 1", len(jobs), status)
+			continue
+		}
+		if jobs[0].Status != status || jobs[0].Name != status.String() {
+			t.Errorf("got %s job %s, expected %s", jobs[0].Status, jobs[0].Name, status)
+		}
+	}
+}
+
+func testJobsByStatusIncludesDisabled(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.SetStatus(job.StatusPending)
+	j.Disable()
+	add(t, c, j)
+
+	if len(c.PendingJobs()) != 1 {
+		t.Errorf("got %d pending jobs, expected disabled job to be listed", len(c.PendingJobs()))
+	}
+}
+
+func testTransition(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	flow := []job.Status{job.StatusInactive, job.StatusAvailable, job.StatusSchedulable, job.StatusRunnable, job.StatusPending, job.StatusActive}
+	for i := 1; i < len(flow); i++ {
+		if err := c.Transition(j.Uuid, flow[i-1], flow[i]); err != nil {
+			t.Fatalf("got error on transition from %s to %s: %s", flow[i-1], flow[i], err.Error())
+		}
+		if status := get(t, c, j.Uuid).Status; status != flow[i] {
+			t.Fatalf("got status %s, expected %s", status, flow[i])
+		}
+
+		// A job must only be listed for its current status
+		for status, list := range lists(c) {
+			listed := len(list()) == 1
+			if listed != (status == flow[i]) {
+				t.Errorf("job with status %s listed as %s: %t", flow[i], status, listed)
+			}
+		}
+	}
+}
+
+func testTransitionConflict(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	expectErr(t, c.Transition(j.Uuid, job.StatusPending, job.StatusActive), job.ErrConflict)
+	if status := get(t, c, j.Uuid).Status; status != job.StatusInactive {
+		t.Errorf("got status %s after conflict, expected %s", status, job.StatusInactive)
+	}
+}
+
+func testTransitionNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.Transition(uuid.New(), job.StatusInactive, job.StatusAvailable), job.ErrNotFound)
+}
+
+func testTransitionInvalid(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	expectErr(t, c.Transition(j.Uuid, job.StatusInactive, job.StatusActive), job.ErrInvalidTransition)
+	if status := get(t, c, j.Uuid).Status; status != job.StatusInactive {
+		t.Errorf("got status %s after invalid transition, expected %s", status, job.StatusInactive)
+	}
+}
+
+func testTransitionPreservesJob(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.AddResult(job.Result{Status: job.StatusCompleted})
+	j.Disable()
+	add(t, c, j)
+
+	if err := c.Transition(j.Uuid, job.StatusInactive, job.StatusAvailable); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	result := get(t, c, j.Uuid)
+	if result.IsEnabled() || result.CountRuns() != 1 || result.Name != j.Name || result.Tasks.Count() != j.Tasks.Count() {
+		t.Errorf("transition changed more than the status of the job")
+	}
+}
+
+func testUpdate(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	j.Name = "testUpdated"
+	j.MaxRuns = 5
+	j.SetStatus(job.StatusPending)
+	j.Disable()
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result := get(t, c, j.Uuid)
+	if result.Name != "testUpdated" || result.Status != job.StatusPending || result.MaxRuns != 5 || result.IsEnabled() {
+		t.Errorf("got %s job %s, expected disabled %s job %s", result.Status, result.Name, job.StatusPending, "testUpdated")
+	}
+}
+
+func testUpdateHistory(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	start := time.Now()
+	j.AddResult(job.Result{Start: start, Status: job.StatusActive})
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	j.UpdateResult(job.Result{
+		Start:  start,
+		Finish: start.Add(time.Second),
+		Status: job.StatusCompleted,
+		Tasks:  []task.Task{task.EmptyTask{}.SetStatus(task.StatusCompleted)},
+	})
+	j.AddResult(job.Result{Start: start.Add(2 * time.Second), Status: job.StatusActive})
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result := get(t, c, j.Uuid)
+	history := result.AllResults()
+	if len(history) != 2 {
+		t.Fatalf("got %d results, expected 2", len(history))
+	}
+	if history[0].Status != job.StatusCompleted || history[0].Runtime() != time.Second || len(history[0].Tasks) != 1 {
+		t.Errorf("got first result with status %s and runtime %s, expected %s and %s", history[0].Status, history[0].Runtime(), job.StatusCompleted, time.Second)
+	}
+	if history[0].Tasks[0].Status() != task.StatusCompleted {
+		t.Errorf("got task status %s, expected %s", history[0].Tasks[0].Status(), task.StatusCompleted)
+	}
+	if history[1].Status != job.StatusActive {
+		t.Errorf("got last result with status %s, expected %s", history[1].Status, job.StatusActive)
+	}
+}
+
+// testUpdateStatusListJob updates a job taken from a list by status, which may hold only the last result
+func testUpdateStatusListJob(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		j.AddResult(job.Result{Start: start.Add(time.Duration(i) * time.Second), Finish: start.Add(time.Duration(i) * time.Second), Status: job.StatusCompleted})
+	}
+	add(t, c, j)
+
+	listed := c.InactiveJobs()
+	if len(listed) != 1 {
+		t.Fatalf("got %d inactive jobs, expected 1", len(listed))
+	}
+	listed[0].Labels = map[string]string{"updated": "true"}
+	if err := c.Update(listed[0]); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result := get(t, c, j.Uuid)
+	if result.Labels["updated"] != "true" || result.CountRuns() != 3 || len(result.AllResults()) != 3 {
+		t.Errorf("got %d runs and %d results, expected the update to keep all 3 results", result.CountRuns(), len(result.AllResults()))
+	}
+}
+
+func testPruneHistory(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		j.AddResult(job.Result{Start: start.Add(time.Duration(i) * time.Second), Finish: start.Add(time.Duration(i) * time.Second), Status: job.StatusCompleted})
+		if err := c.Update(j); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+
+	revision := get(t, c, j.Uuid).Revision
+	if err := c.PruneHistory(j.Uuid, job.RetentionPolicy{KeepLast: 1}, start); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	j = get(t, c, j.Uuid)
+	if j.Revision != revision+1 {
+		t.Errorf("got revision %d, expected %d", j.Revision, revision+1)
+	}
+
+	j.AddResult(job.Result{Start: start.Add(3 * time.Second), Status: job.StatusActive})
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result := get(t, c, j.Uuid)
+	history := result.AllResults()
+	if result.CountRuns() != 4 || len(history) != 2 {
+		t.Fatalf("got %d runs and %d results, expected 4 runs and 2 results", result.CountRuns(), len(history))
+	}
+	if !history[0].Start.Equal(start.Add(2*time.Second)) || history[1].Status != job.StatusActive {
+		t.Errorf("got results started at %s and %s, expected the last two runs", history[0].Start, history[1].Start)
+	}
+}
+
+func testPruneHistoryNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.PruneHistory(uuid.New(), job.RetentionPolicy{KeepLast: 1}, time.Now()), job.ErrNotFound)
+}
+
+func testUpdateNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.Update(NewJob(t, "test")), job.ErrNotFound)
+}
+
+func testUpdateIf(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	j = get(t, c, j.Uuid)
+	j.Name = "testUpdated"
+	if err := c.UpdateIf(j, j.Revision); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result := get(t, c, j.Uuid)
+	if result.Name != "testUpdated" {
+		t.Errorf("got name %s, expected %s", result.Name, "testUpdated")
+	}
+	if result.Revision != j.Revision+1 {
+		t.Errorf("got revision %d, expected %d", result.Revision, j.Revision+1)
+	}
+}
+
+func testUpdateIfConflict(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	stale := get(t, c, j.Uuid)
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	stale.Name = "testUpdated"
+	expectErr(t, c.UpdateIf(stale, stale.Revision), job.ErrConflict)
+
+	result := get(t, c, j.Uuid)
+	if result.Name != "test" || result.IsEnabled() {
+		t.Errorf("got enabled %t job %s after conflict, expected disabled job %s", result.IsEnabled(), result.Name, "test")
+	}
+	if result.Revision != stale.Revision+1 {
+		t.Errorf("got revision %d after conflict, expected %d", result.Revision, stale.Revision+1)
+	}
+}
+
+func testUpdateIfNotFound(t *testing.T, c job.Catalog) {
+	expectErr(t, c.UpdateIf(NewJob(t, "test"), 1), job.ErrNotFound)
+}
+
+func testRevision(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	expected := uint64(1)
+	if revision := get(t, c, j.Uuid).Revision; revision != expected {
+		t.Fatalf("got revision %d after add, expected %d", revision, expected)
+	}
+
+	mutations := []struct {
+		name string
+		f    func() error
+	}{
+		{"Update", func() error { return c.Update(get(t, c, j.Uuid)) }},
+		{"Disable", func() error { return c.Disable(j.Uuid) }},
+		{"Enable", func() error { return c.Enable(j.Uuid) }},
+		{"Transition", func() error { return c.Transition(j.Uuid, job.StatusInactive, job.StatusAvailable) }},
+		{"UpdateIf", func() error {
+			current := get(t, c, j.Uuid)
+			return c.UpdateIf(current, current.Revision)
+		}},
+	}
+	for _, m := range mutations {
+		if err := m.f(); err != nil {
+			t.Fatalf("got error on %s: %s", m.name, err.Error())
+		}
+		expected++
+		if revision := get(t, c, j.Uuid).Revision; revision != expected {
+			t.Errorf("got revision %d after %s, expected %d", revision, m.name, expected)
+		}
+	}
+}
+
+func testClaim(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+	join(t, c, "a", time.Minute)
+	join(t, c, "b", time.Minute)
+
+	if err := c.Claim(j.Uuid, "a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := c.Claim(j.Uuid, "a"); err != nil {
+		t.Errorf("got error claiming an owned job again: %s", err.Error())
+	}
+	expectErr(t, c.Claim(j.Uuid, "b"), job.ErrOwned)
+	expectErr(t, c.Release(j.Uuid, "b"), job.ErrOwned)
+	expectOwner(t, c, j.Uuid, "a")
+
+	if err := c.Release(j.Uuid, "a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	expectOwner(t, c, j.Uuid, "")
+	if err := c.Claim(j.Uuid, "b"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	expectOwner(t, c, j.Uuid, "b")
+
+	members, err := c.Members()
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(members) != 2 || members[0] != "a" || members[1] != "b" {
+		t.Errorf("got members %v, expected [a b]", members)
+	}
+}
+
+func testClaimExpired(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+	join(t, c, "a", 50*time.Millisecond)
+	join(t, c, "b", time.Minute)
+
+	if err := c.Claim(j.Uuid, "a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// The jobs of a member which did not join again in time can be claimed by other members
+	time.Sleep(100 * time.Millisecond)
+	members, err := c.Members()
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(members) != 1 || members[0] != "b" {
+		t.Errorf("got members %v, expected [b]", members)
+	}
+	expectOwner(t, c, j.Uuid, "")
+	if err = c.Claim(j.Uuid, "b"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	expectOwner(t, c, j.Uuid, "b")
+}
+
+func testClaimNotFound(t *testing.T, c job.Catalog) {
+	join(t, c, "a", time.Minute)
+	expectErr(t, c.Claim(uuid.New(), "a"), job.ErrNotFound)
+	expectErr(t, c.Release(uuid.New(), "a"), job.ErrNotFound)
+}
+
+func testClaimAfterDelete(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+	join(t, c, "a", time.Minute)
+	join(t, c, "b", time.Minute)
+
+	if err := c.Claim(j.Uuid, "a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// A job which is added again does not inherit the owner of the deleted job
+	add(t, c, j)
+	expectOwner(t, c, j.Uuid, "")
+	if err := c.Claim(j.Uuid, "b"); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+}
+
+func testLeave(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+	join(t, c, "a", time.Minute)
+	join(t, c, "b", time.Minute)
+
+	if err := c.Claim(j.Uuid, "a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := c.Leave("a"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	members, err := c.Members()
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(members) != 1 || members[0] != "b" {
+		t.Errorf("got members %v, expected [b]", members)
+	}
+	expectOwner(t, c, j.Uuid, "")
+
+	// A member which joins again does not own the jobs it released
+	join(t, c, "a", time.Minute)
+	expectOwner(t, c, j.Uuid, "")
+}
+
+func join(t *testing.T, c job.Catalog, instance string, ttl time.Duration) {
+	t.Helper()
+
+	if err := c.Join(instance, ttl); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+}
+
+// expectOwner fails the test if the job with jobId is not owned by owner, an empty owner expects the job to be unowned
+func expectOwner(t *testing.T, c job.Catalog, jobId uuid.UUID, owner string) {
+	t.Helper()
+
+	owners, err := c.Owners()
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if got := owners[jobId]; got != owner {
+		t.Errorf("got owner %q, expected %q", got, owner)
+	}
+}
+
+func testConcurrentAdd(t *testing.T, c job.Catalog) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.Add(NewJob(t, strconv.Itoa(i))); err != nil {
+				t.Errorf("got error: %s", err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(c.All()) != 20 {
+		t.Errorf("got %d jobs, expected 20", len(c.All()))
+	}
+}
+
+func testConcurrentTransition(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.SetStatus(job.StatusPending)
+	add(t, c, j)
+
+	var (
+		wg        sync.WaitGroup
+		mux       sync.Mutex
+		won       int
+		conflicts int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.Transition(j.Uuid, job.StatusPending, job.StatusActive)
+
+			mux.Lock()
+			defer mux.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, job.ErrConflict):
+				conflicts++
+			default:
+				t.Errorf("got error: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if won != 1 || conflicts != 19 {
+		t.Errorf("got %d successful transitions and %d conflicts, expected 1 and 19", won, conflicts)
+	}
+}
+
+func testConcurrentAccess(t *testing.T, c job.Catalog) {
+	jobs := make([]job.Job, 5)
+	for i := range jobs {
+		jobs[i] = NewJob(t, strconv.Itoa(i))
+		add(t, c, jobs[i])
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			j := jobs[i%len(jobs)]
+			for n := 0; n < 10; n++ {
+				var err error
+				switch n % 5 {
+				case 0:
+					err = c.Disable(j.Uuid)
+				case 1:
+					err = c.Enable(j.Uuid)
+				case 2:
+					if current, found := find(c, j.Uuid); found {
+						current.AddResult(job.Result{Status: job.StatusCompleted})
+						err = c.Update(current)
+					}
+				case 3:
+					err = c.Transition(j.Uuid, job.StatusInactive, job.StatusAvailable)
+					if errors.Is(err, job.ErrConflict) {
+						err = c.Transition(j.Uuid, job.StatusAvailable, job.StatusInactive)
+					}
+				case 4:
+					_ = c.InactiveJobs()
+					_ = c.AvailableJobs()
+					_ = c.HasEnabledJobs()
+				}
+				if err != nil && !errors.Is(err, job.ErrConflict) {
+					t.Errorf("got error: %s", err.Error())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(c.All()) != len(jobs) {
+		t.Errorf("got %d jobs, expected %d", len(c.All()), len(jobs))
+	}
+}
+
+func testConcurrentUpdateIf(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	add(t, c, j)
+
+	// Every worker increments MaxRuns in a read-modify-write loop, no increment may be lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 5; k++ {
+				for {
+					current, err := c.Get(j.Uuid)
+					if err != nil {
+						t.Errorf("got error: %s", err.Error())
+						return
+					}
+					current.MaxRuns++
+					err = c.UpdateIf(current, current.Revision)
+					if err == nil {
+						break
+					}
+					if !errors.Is(err, job.ErrConflict) {
+						t.Errorf("got error: %s", err.Error())
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRuns := get(t, c, j.Uuid).MaxRuns; maxRuns != 50 {
+		t.Errorf("got %d increments, expected %d", maxRuns, 50)
+	}
+}
@@ -0,0 +1,261 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package catalogtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// findBase is the reference time of the find tests, so next runs are predictable
+var findBase = time.Date(2024, time.June, 15, 12, 0, 0, 0, time.UTC)
+
+// addFindJobs adds the jobs used by the find tests and returns them by name
+func addFindJobs(t *testing.T, c job.Catalog) map[string]job.Job {
+	t.Helper()
+
+	monthly, err := cron.NewSchedule("@monthly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := map[string]job.Job{
+		"payments-sync":   NewJob(t, "payments-sync"),
+		"payments-report": NewJob(t, "payments-report"),
+		"billing-sync":    NewJob(t, "billing-sync"),
+		"billing-report":  NewJob(t, "billing-report"),
+	}
+
+	j := jobs["payments-sync"]
+	j.Labels = map[string]string{"team": "payments", "env": "prod"}
+	j.AddResult(job.Result{Start: findBase.Add(-time.Hour), Finish: findBase.Add(-time.Hour), Status: job.StatusError})
+	jobs[j.Name] = j
+
+	j = jobs["payments-report"]
+	j.Labels = map[string]string{"team": "payments", "env": "stage"}
+	j.AddResult(job.Result{Start: findBase.Add(-48 * time.Hour), Finish: findBase.Add(-48 * time.Hour), Status: job.StatusError})
+	j.Disable()
+	jobs[j.Name] = j
+
+	j = jobs["billing-sync"]
+	j.Labels = map[string]string{"team": "billing", "env": "prod"}
+	j.AddResult(job.Result{Start: findBase.Add(-2 * time.Hour), Finish: findBase.Add(-2 * time.Hour), Status: job.StatusCompleted})
+	j.SetStatus(job.StatusPending)
+	jobs[j.Name] = j
+
+	j = jobs["billing-report"]
+	j.Schedule = monthly
+	jobs[j.Name] = j
+
+	for _, j = range jobs {
+		add(t, c, j)
+	}
+	return jobs
+}
+
+func names(jobs []job.Job) []string {
+	result := make([]string, len(jobs))
+	for i, j := range jobs {
+		result[i] = j.Name
+	}
+	return result
+}
+
+func expectNames(t *testing.T, jobs []job.Job, wanted []string) {
+	t.Helper()
+
+	got := names(jobs)
+	if len(got) != len(wanted) {
+		t.Fatalf("got jobs %v, expected %v", got, wanted)
+	}
+	for i := range wanted {
+		if got[i] != wanted[i] {
+			t.Fatalf("got jobs %v, expected %v", got, wanted)
+		}
+	}
+}
+
+func testFind(t *testing.T, c job.Catalog) {
+	addFindJobs(t, c)
+	disabled := false
+
+	tests := []struct {
+		name   string
+		query  job.Query
+		wanted []string
+	}{
+		{"All", job.Query{}, []string{"billing-report", "billing-sync", "payments-report", "payments-sync"}},
+		{"Name", job.Query{Name: "payments-*"}, []string{"payments-report", "payments-sync"}},
+		{"Labels", job.Query{Labels: map[string]string{"env": "prod"}}, []string{"billing-sync", "payments-sync"}},
+		{"LabelsAll", job.Query{Labels: map[string]string{"env": "prod", "team": "payments"}}, []string{"payments-sync"}},
+		{"LabelsNone", job.Query{Labels: map[string]string{"team": "unknown"}}, []string{}},
+		{"Enabled", job.Query{Enabled: &disabled}, []string{"payments-report"}},
+		{"Status", job.Query{Status: []job.Status{job.StatusPending, job.StatusActive}}, []string{"billing-sync"}},
+		{"LastResult", job.Query{LastResult: job.StatusError}, []string{"payments-report", "payments-sync"}},
+		{"LastRun", job.Query{LastResult: job.StatusError, LastRunFrom: findBase.Add(-24 * time.Hour)}, []string{"payments-sync"}},
+		{"NextRun", job.Query{Now: findBase, NextRunFrom: findBase, NextRunTo: findBase.Add(time.Minute)}, []string{"billing-sync", "payments-report", "payments-sync"}},
+		{"Combined", job.Query{Name: "*-sync", Labels: map[string]string{"env": "prod"}, Status: []job.Status{job.StatusInactive}}, []string{"payments-sync"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := c.Find(tt.query)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			expectNames(t, page.Jobs, tt.wanted)
+			if page.Cursor != "" {
+				t.Errorf("got cursor %q, expected no more pages", page.Cursor)
+			}
+		})
+	}
+}
+
+func testFindSelector(t *testing.T, c job.Catalog) {
+	addFindJobs(t, c)
+
+	tests := []struct {
+		selector string
+		wanted   []string
+	}{
+		{"team=payments", []string{"payments-report", "payments-sync"}},
+		{"team=payments,env in (prod,stage)", []string{"payments-report", "payments-sync"}},
+		{"env notin (stage)", []string{"billing-report", "billing-sync", "payments-sync"}},
+		{"team!=payments", []string{"billing-report", "billing-sync"}},
+		{"team", []string{"billing-sync", "payments-report", "payments-sync"}},
+		{"!team", []string{"billing-report"}},
+		{"team in (billing,payments),env=prod", []string{"billing-sync", "payments-sync"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := job.ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			page, err := c.Find(job.Query{Selector: s})
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			expectNames(t, page.Jobs, tt.wanted)
+		})
+	}
+}
+
+func testFindSort(t *testing.T, c job.Catalog) {
+	addFindJobs(t, c)
+
+	tests := []struct {
+		name   string
+		query  job.Query
+		wanted []string
+	}{
+		{"NameDescending", job.Query{Descending: true}, []string{"payments-sync", "payments-report", "billing-sync", "billing-report"}},
+		{"NextRun", job.Query{Now: findBase, Sort: job.SortByNextRun, Name: "*-report"}, []string{"payments-report", "billing-report"}},
+		{"LastRun", job.Query{Sort: job.SortByLastRun}, []string{"billing-report", "payments-report", "billing-sync", "payments-sync"}},
+		{"LastRunDescending", job.Query{Sort: job.SortByLastRun, Descending: true}, []string{"payments-sync", "billing-sync", "payments-report", "billing-report"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := c.Find(tt.query)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			expectNames(t, page.Jobs, tt.wanted)
+		})
+	}
+}
+
+func testFindPagination(t *testing.T, c job.Catalog) {
+	addFindJobs(t, c)
+
+	for _, descending := range []bool{false, true} {
+		query := job.Query{Limit: 3, Descending: descending}
+		all, err := c.Find(job.Query{Descending: descending})
+		if err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+
+		var pages [][]job.Job
+		for {
+			page, err := c.Find(query)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			pages = append(pages, page.Jobs)
+			if page.Cursor == "" {
+				break
+			}
+			query.Cursor = page.Cursor
+		}
+
+		if len(pages) != 2 || len(pages[0]) != 3 || len(pages[1]) != 1 {
+			t.Fatalf("got %d pages, expected pages of 3 and 1 jobs", len(pages))
+		}
+		expectNames(t, append(pages[0], pages[1]...), names(all.Jobs))
+	}
+}
+
+func testFindInvalid(t *testing.T, c job.Catalog) {
+	queries := map[string]job.Query{
+		"Name":   {Name: "[a-"},
+		"Cursor": {Cursor: "invalid"},
+		"Sort":   {Sort: job.SortField(42)},
+	}
+	for name, query := range queries {
+		if _, err := c.Find(query); err == nil {
+			t.Errorf("got no error for invalid %s", name)
+		}
+	}
+}
+
+func testFindAfterUpdate(t *testing.T, c job.Catalog) {
+	j := NewJob(t, "test")
+	j.Labels = map[string]string{"team": "payments"}
+	add(t, c, j)
+
+	j.Labels = map[string]string{"team": "billing"}
+	j.SetStatus(job.StatusPending)
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	page, _ := c.Find(job.Query{Labels: map[string]string{"team": "payments"}})
+	expectNames(t, page.Jobs, []string{})
+	page, _ = c.Find(job.Query{Labels: map[string]string{"team": "billing"}, Status: []job.Status{job.StatusPending}})
+	expectNames(t, page.Jobs, []string{"test"})
+
+	if err := c.Transition(j.Uuid, job.StatusPending, job.StatusActive); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	enabled := false
+	page, _ = c.Find(job.Query{Enabled: &enabled, Status: []job.Status{job.StatusActive}})
+	expectNames(t, page.Jobs, []string{"test"})
+
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	page, _ = c.Find(job.Query{Labels: map[string]string{"team": "billing"}})
+	expectNames(t, page.Jobs, []string{})
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package catalogtest
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+)
+
+// newNamedJob returns a job like NewJob with a namespace
+func newNamedJob(t *testing.T, namespace string, name string) job.Job {
+	t.Helper()
+
+	j := NewJob(t, name)
+	j.Namespace = namespace
+	return j
+}
+
+func testAddNameExist(t *testing.T, c job.Catalog) {
+	add(t, c, newNamedJob(t, "exports", "nightly-export"))
+
+	expectErr(t, c.Add(newNamedJob(t, "exports", "nightly-export")), job.ErrNameExist)
+
+	// Names are unique within a namespace, jobs without a namespace can share a name
+	add(t, c, newNamedJob(t, "reports", "nightly-export"))
+	add(t, c, NewJob(t, "nightly-export"))
+	add(t, c, NewJob(t, "nightly-export"))
+}
+
+func testAddInvalidName(t *testing.T, c job.Catalog) {
+	for _, j := range []job.Job{
+		newNamedJob(t, "exports", ""),
+		newNamedJob(t, "exports", "nightly/export"),
+		newNamedJob(t, "exports/nightly", "export"),
+	} {
+		expectErr(t, c.Add(j), job.ErrInvalidName)
+	}
+	if len(c.All()) != 0 {
+		t.Errorf("got %d jobs, expected jobs with invalid names to be rejected", len(c.All()))
+	}
+}
+
+func testGetByName(t *testing.T, c job.Catalog) {
+	j := newNamedJob(t, "exports", "nightly-export")
+	add(t, c, j)
+	add(t, c, newNamedJob(t, "reports", "nightly-export"))
+
+	result, err := c.GetByName("exports", "nightly-export")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result.Uuid != j.Uuid || result.QualifiedName() != "exports/nightly-export" {
+		t.Errorf("got job %s (%s), expected %s (%s)", result.QualifiedName(), result.Uuid, j.QualifiedName(), j.Uuid)
+	}
+
+	_, err = c.GetByName("exports", "weekly-export")
+	expectErr(t, err, job.ErrNotFound)
+	_, err = c.GetByName("", "nightly-export")
+	expectErr(t, err, job.ErrInvalidName)
+}
+
+func testDeleteByName(t *testing.T, c job.Catalog) {
+	j := newNamedJob(t, "exports", "nightly-export")
+	j.AddResult(job.Result{Status: job.StatusCompleted})
+	add(t, c, j)
+	other := newNamedJob(t, "reports", "nightly-export")
+	add(t, c, other)
+
+	if err := c.DeleteByName("exports", "nightly-export"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if _, found := find(c, j.Uuid); found {
+		t.Errorf("job %s was not deleted", j.QualifiedName())
+	}
+	if _, found := find(c, other.Uuid); !found {
+		t.Errorf("job %s was deleted, expected only %s to be deleted", other.QualifiedName(), j.QualifiedName())
+	}
+	expectErr(t, c.DeleteByName("exports", "nightly-export"), job.ErrNotFound)
+
+	// The name can be used again after deleting the job
+	add(t, c, newNamedJob(t, "exports", "nightly-export"))
+}
+
+func testRename(t *testing.T, c job.Catalog) {
+	j := newNamedJob(t, "exports", "nightly-export")
+	add(t, c, j)
+	add(t, c, newNamedJob(t, "exports", "weekly-export"))
+
+	expectErr(t, c.Rename(j.Uuid, "exports", "weekly-export"), job.ErrNameExist)
+	expectErr(t, c.Rename(j.Uuid, "exports", ""), job.ErrInvalidName)
+	expectErr(t, c.Rename(uuid.New(), "exports", "daily-export"), job.ErrNotFound)
+
+	if err := c.Rename(j.Uuid, "archive", "daily-export"); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	result := get(t, c, j.Uuid)
+	if result.QualifiedName() != "archive/daily-export" || result.Tasks.Count() != j.Tasks.Count() {
+		t.Errorf("got job %s with %d tasks, expected %s with %d tasks", result.QualifiedName(), result.Tasks.Count(), "archive/daily-export", j.Tasks.Count())
+	}
+	if result.Revision != 2 {
+		t.Errorf("got revision %d after rename, expected %d", result.Revision, 2)
+	}
+
+	// The old name is released by the rename
+	if _, err := c.GetByName("exports", "nightly-export"); err == nil {
+		t.Errorf("found job by its old name after rename")
+	}
+	add(t, c, newNamedJob(t, "exports", "nightly-export"))
+}
+
+func testUpdateNameExist(t *testing.T, c job.Catalog) {
+	j := newNamedJob(t, "exports", "nightly-export")
+	add(t, c, j)
+	add(t, c, newNamedJob(t, "exports", "weekly-export"))
+
+	j.Name = "weekly-export"
+	expectErr(t, c.Update(j), job.ErrNameExist)
+	current := get(t, c, j.Uuid)
+	expectErr(t, c.UpdateIf(j, current.Revision), job.ErrNameExist)
+
+	if result := get(t, c, j.Uuid); result.Name != "nightly-export" {
+		t.Errorf("got name %s, expected %s", result.Name, "nightly-export")
+	}
+}
+
+func testFindNamespace(t *testing.T, c job.Catalog) {
+	add(t, c, newNamedJob(t, "exports", "nightly-export"))
+	add(t, c, newNamedJob(t, "exports", "weekly-export"))
+	add(t, c, newNamedJob(t, "reports", "nightly-export"))
+
+	page, err := c.Find(job.Query{Namespace: "exports"})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	expectNames(t, page.Jobs, []string{"nightly-export", "weekly-export"})
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseConcurrency parses concurrency keys in the form key:limit, such as db-migrations:1, into a map which can be used
+// as Job.Concurrency. A key without a limit has a limit of 1.
+func ParseConcurrency(keys []string) (map[string]int, error) {
+	concurrency := make(map[string]int, len(keys))
+	for _, k := range keys {
+		key, limit, found := strings.Cut(k, ":")
+		n := 1
+		if found {
+			var err error
+			if n, err = strconv.Atoi(limit); err != nil {
+				return nil, ErrInvalidConcurrency.WithCause(fmt.Errorf("invalid limit in %q", k))
+			}
+		}
+		concurrency[key] = n
+	}
+	if err := validateConcurrency(concurrency); err != nil {
+		return nil, ErrInvalidConcurrency.WithCause(err)
+	}
+	return concurrency, nil
+}
+
+// FormatConcurrency returns the concurrency keys of a job in the form key:limit, sorted by key
+func FormatConcurrency(concurrency map[string]int) []string {
+	keys := make([]string, 0, len(concurrency))
+	for key, limit := range concurrency {
+		keys = append(keys, key+":"+strconv.Itoa(limit))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// validateConcurrency returns an error if a key is empty or has a limit below 1
+func validateConcurrency(concurrency map[string]int) error {
+	for key, limit := range concurrency {
+		if key == "" || strings.Contains(key, ":") {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		if limit < 1 {
+			return fmt.Errorf("limit %d for key %s is below 1", limit, key)
+		}
+	}
+	return nil
+}
+
+// keysAvailable reports whether a slot is free for every key in concurrency, held is the number of running jobs
+// holding each key. A job acquires all of its keys at once or none of them, so jobs never wait while holding a key
+// and cannot deadlock.
+func keysAvailable(concurrency map[string]int, held map[string]int) bool {
+	for key, limit := range concurrency {
+		if held[key] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// concurrencyStats returns the statistics of every key which is held or waited for, ordered by key
+func concurrencyStats(held map[string]int, waiting map[string]int) []ConcurrencyStats {
+	keys := make(map[string]bool, len(held)+len(waiting))
+	for key := range held {
+		keys[key] = true
+	}
+	for key := range waiting {
+		keys[key] = true
+	}
+
+	stats := make([]ConcurrencyStats, 0, len(keys))
+	for key := range keys {
+		stats = append(stats, ConcurrencyStats{Key: key, HeldBy: float64(held[key]), WaitingJobs: float64(waiting[key])})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
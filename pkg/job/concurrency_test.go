@@ -0,0 +1,67 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseConcurrency(t *testing.T) {
+	concurrency, err := ParseConcurrency([]string{"db-migrations:1", "s3-upload:4", "reports"})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if concurrency["db-migrations"] != 1 || concurrency["s3-upload"] != 4 || concurrency["reports"] != 1 {
+		t.Errorf("got %v, expected db-migrations:1, s3-upload:4 and reports:1", concurrency)
+	}
+
+	formatted := FormatConcurrency(concurrency)
+	wanted := []string{"db-migrations:1", "reports:1", "s3-upload:4"}
+	for i := range wanted {
+		if formatted[i] != wanted[i] {
+			t.Errorf("got %s at position %d, expected %s", formatted[i], i, wanted[i])
+		}
+	}
+}
+
+func TestParseConcurrency_Invalid(t *testing.T) {
+	for _, keys := range [][]string{{"db:"}, {"db:x"}, {"db:0"}, {":1"}, {"db:-1"}} {
+		if _, err := ParseConcurrency(keys); !errors.Is(err, ErrInvalidConcurrency) {
+			t.Errorf("got %v for %v, expected %v", err, keys, ErrInvalidConcurrency)
+		}
+	}
+}
+
+func TestKeysAvailable(t *testing.T) {
+	held := map[string]int{"db": 1, "s3": 3}
+
+	tests := []struct {
+		concurrency map[string]int
+		wanted      bool
+	}{
+		{nil, true},
+		{map[string]int{"s3": 4}, true},
+		{map[string]int{"s3": 4, "db": 1}, false},
+		{map[string]int{"db": 2, "reports": 1}, true},
+	}
+	for _, test := range tests {
+		if result := keysAvailable(test.concurrency, held); result != test.wanted {
+			t.Errorf("got %t for %v, expected %t", result, test.concurrency, test.wanted)
+		}
+	}
+}
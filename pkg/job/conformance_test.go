@@ -0,0 +1,68 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/job/catalogtest"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestMemoryCatalog_Conformance(t *testing.T) {
+	catalogtest.RunConformance(t, func(t *testing.T) job.Catalog {
+		return job.NewMemoryCatalog()
+	})
+}
+
+func TestFileCatalog_Conformance(t *testing.T) {
+	catalogtest.RunConformance(t, func(t *testing.T) job.Catalog {
+		c, err := job.NewFileCatalog(job.NewFileCatalogConfig(t.TempDir()))
+		if err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		t.Cleanup(func() {
+			if err = c.Close(); err != nil {
+				t.Errorf("got error on close: %s", err.Error())
+			}
+		})
+		return c
+	})
+}
+
+func TestSQLCatalog_Conformance(t *testing.T) {
+	catalogtest.RunConformance(t, func(t *testing.T) job.Catalog {
+		db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "catalog.db")+"?_pragma=busy_timeout(5000)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		var c *job.SQLCatalog
+		if c, err = job.NewSQLCatalog(db, job.SQLCatalogConfig{Dialect: job.SQLiteDialect{}}); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		return c
+	})
+}
@@ -0,0 +1,666 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// DefinitionFormat is the file format of job definitions
+type DefinitionFormat int
+
+func (f DefinitionFormat) String() string {
+	return [...]string{"json", "yaml", "toml"}[f]
+}
+
+const (
+	FormatJSON DefinitionFormat = iota
+	FormatYAML
+	FormatTOML
+)
+
+// definitionExtensions maps the file extensions of job definitions to their format
+var definitionExtensions = map[string]DefinitionFormat{
+	".json": FormatJSON,
+	".yaml": FormatYAML,
+	".yml":  FormatYAML,
+	".toml": FormatTOML,
+}
+
+// ParseDefinitionFormat returns the format named json, yaml, yml or toml
+func ParseDefinitionFormat(name string) (DefinitionFormat, error) {
+	if f, found := definitionExtensions["."+strings.ToLower(name)]; found {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown definition format %q", name)
+}
+
+// DefinitionFileFormat returns the format of a definition file by its extension
+func DefinitionFileFormat(file string) (DefinitionFormat, error) {
+	if f, found := definitionExtensions[strings.ToLower(filepath.Ext(file))]; found {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown definition format of %s", file)
+}
+
+// Definition is a job as defined in a configuration file. A file holds a single definition, or a list of definitions
+// under the key jobs.
+type Definition struct {
+	Namespace   string            `json:"namespace,omitempty" yaml:"namespace,omitempty" toml:"namespace,omitempty"`
+	Name        string            `json:"name" yaml:"name" toml:"name"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty" toml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty" toml:"annotations,omitempty"`
+	Enabled     *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty" toml:"enabled,omitempty"` // jobs are disabled if not set
+	Schedule    string            `json:"schedule" yaml:"schedule" toml:"schedule"`
+	MaxRuns     int               `json:"maxRuns,omitempty" yaml:"maxRuns,omitempty" toml:"maxRuns,omitempty"`
+	Priority    int               `json:"priority,omitempty" yaml:"priority,omitempty" toml:"priority,omitempty"`
+	Concurrency map[string]int    `json:"concurrency,omitempty" yaml:"concurrency,omitempty" toml:"concurrency,omitempty"`
+	Tasks       []TaskDefinition  `json:"tasks" yaml:"tasks" toml:"tasks"`
+	File        string            `json:"-" yaml:"-" toml:"-"` // the file the job is defined in, if any
+	Line        int               `json:"-" yaml:"-" toml:"-"` // the line at which the job is defined in File
+}
+
+// TaskDefinition is a task of a job definition, Type is the name under which the type of the task is registered
+type TaskDefinition struct {
+	Type   string                 `json:"type" yaml:"type" toml:"type"`
+	Params map[string]interface{} `json:"params,omitempty" yaml:"params,omitempty" toml:"params,omitempty"`
+}
+
+// definitionIds is the namespace of the identifiers of jobs defined in files
+var definitionIds = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/corelayer/go-scheduler/definitions"))
+
+// DefinitionId returns the identifier of the job named namespace/name which is defined in file. The identifier is
+// derived from the base name of the file, so it does not change when the directory of the file is moved.
+func DefinitionId(file string, namespace string, name string) uuid.UUID {
+	return uuid.NewSHA1(definitionIds, []byte(filepath.Base(file)+"\n"+QualifiedName(namespace, name)))
+}
+
+// NewDefinition returns the definition of j, the run state of j is not part of its definition
+func NewDefinition(j Job, registry *task.Registry) (Definition, error) {
+	d := Definition{
+		Namespace:   j.Namespace,
+		Name:        j.Name,
+		Labels:      cloneMap(j.Labels),
+		Annotations: cloneMap(j.Annotations),
+		Schedule:    j.Schedule.String(),
+		MaxRuns:     j.MaxRuns,
+		Priority:    j.Priority,
+		Concurrency: cloneMap(j.Concurrency),
+		Tasks:       make([]TaskDefinition, 0, len(j.Tasks.Tasks)),
+	}
+	if j.Enabled {
+		enabled := true
+		d.Enabled = &enabled
+	}
+
+	for i, t := range j.Tasks.Tasks {
+		td, err := NewTaskDefinition(t, registry)
+		if err != nil {
+			return Definition{}, fmt.Errorf("job %s: task %d: %w", j.QualifiedName(), i, err)
+		}
+		d.Tasks = append(d.Tasks, td)
+	}
+	return d, nil
+}
+
+// QualifiedName returns the name of the defined job in the form namespace/name
+func (d Definition) QualifiedName() string {
+	return QualifiedName(d.Namespace, d.Name)
+}
+
+// Job returns a new job as defined by d, the tasks of the job are created by registry. A job defined in a file has the
+// stable identifier returned by DefinitionId, so it keeps its identity and history when the file is loaded again.
+func (d Definition) Job(registry *task.Registry) (Job, error) {
+	if err := d.validate(); err != nil {
+		return Job{}, d.error(err)
+	}
+	schedule, err := cron.NewSchedule(d.Schedule)
+	if err != nil {
+		return Job{}, d.error(fmt.Errorf("invalid schedule %q: %w", d.Schedule, err))
+	}
+
+	tasks := make([]task.Task, 0, len(d.Tasks))
+	for i, t := range d.Tasks {
+		var created task.Task
+		if created, err = newTask(registry, t.Type, t.Params); err != nil {
+			return Job{}, d.error(fmt.Errorf("task %d: %w", i, err))
+		}
+		tasks = append(tasks, created)
+	}
+
+	j := NewJob(d.Name, schedule, d.MaxRuns, NewSequence(tasks))
+	if d.File != "" {
+		j.Uuid = DefinitionId(d.File, d.Namespace, d.Name)
+	}
+	j.Namespace = d.Namespace
+	j.Labels = cloneMap(d.Labels)
+	j.Annotations = cloneMap(d.Annotations)
+	j.Priority = d.Priority
+	j.Concurrency = cloneMap(d.Concurrency)
+	if d.Enabled != nil {
+		j.Enabled = *d.Enabled
+	}
+	return j, nil
+}
+
+func (d Definition) validate() error {
+	if d.Name == "" {
+		return errors.New("name is required")
+	}
+	if err := validateName(d.Namespace, d.Name); err != nil {
+		return err
+	}
+	if d.MaxRuns < 0 {
+		return errors.New("maxRuns must not be negative")
+	}
+	if err := validateConcurrency(d.Concurrency); err != nil {
+		return ErrInvalidConcurrency.WithCause(err)
+	}
+	return nil
+}
+
+func (d Definition) error(err error) error {
+	return DefinitionError{File: d.File, Line: d.Line, Err: err}
+}
+
+// location returns the file and line at which the job is defined
+func (d Definition) location() string {
+	return DefinitionError{File: d.File, Line: d.Line}.location()
+}
+
+// DefinitionError is an error in a definition file, Line is 0 if the error does not apply to a line
+type DefinitionError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e DefinitionError) Error() string {
+	if location := e.location(); location != "" {
+		return location + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+// location returns the file and line of the error in the form file:line
+func (e DefinitionError) location() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return e.File + ":" + strconv.Itoa(e.Line)
+	case e.Line > 0:
+		return "line " + strconv.Itoa(e.Line)
+	default:
+		return e.File
+	}
+}
+
+func (e DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+// DefinitionFiles returns the sorted definition files in dir, files with an unknown extension are ignored
+func DefinitionFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if _, err = DefinitionFileFormat(entry.Name()); err != nil || entry.IsDir() {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// LoadDefinitionDir loads the definitions of all definition files in dir. The errors of all files are returned at
+// once, a job must not be defined more than once.
+func LoadDefinitionDir(dir string, registry *task.Registry) ([]Definition, error) {
+	files, err := DefinitionFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		definitions []Definition
+		errs        []error
+	)
+	for _, file := range files {
+		d, err := LoadDefinitions(file, registry)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		definitions = append(definitions, d...)
+	}
+	errs = append(errs, duplicateDefinitions(definitions)...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return definitions, nil
+}
+
+// LoadDefinitions loads the definitions in file, the format of the file is derived from its extension
+func LoadDefinitions(file string, registry *task.Registry) ([]Definition, error) {
+	format, err := DefinitionFileFormat(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDefinitions(file, data, format, registry)
+}
+
+// ParseDefinitions parses and validates the definitions in data, file is used in errors only. Every error in data is
+// returned as a DefinitionError, multiple errors are joined in
+// the order of their lines.
+func ParseDefinitions(file string, data []byte, format DefinitionFormat, registry *task.Registry) ([]Definition, error) {
+	root, err := parseNode(data, format)
+	if err != nil {
+		var le lineError
+		if errors.As(err, &le) {
+			return nil, DefinitionError{File: file, Line: le.line, Err: le.err}
+		}
+		return nil, DefinitionError{File: file, Err: err}
+	}
+
+	d := definitionDecoder{file: file, registry: registry}
+	definitions := d.document(root)
+	d.errs = append(d.errs, duplicateDefinitions(definitions)...)
+	if len(d.errs) > 0 {
+		sort.SliceStable(d.errs, func(i, j int) bool {
+			return d.errs[i].(DefinitionError).Line < d.errs[j].(DefinitionError).Line
+		})
+		return nil, errors.Join(d.errs...)
+	}
+	return definitions, nil
+}
+
+// WriteDefinitions writes definitions in format as a list under the key jobs, which can be read by ParseDefinitions
+func WriteDefinitions(w io.Writer, format DefinitionFormat, definitions []Definition) error {
+	if definitions == nil {
+		definitions = []Definition{}
+	}
+	document := struct {
+		Jobs []Definition `json:"jobs" yaml:"jobs" toml:"jobs"`
+	}{Jobs: definitions}
+
+	switch format {
+	case FormatJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(document)
+	case FormatYAML:
+		e := yaml.NewEncoder(w)
+		e.SetIndent(2)
+		if err := e.Encode(document); err != nil {
+			return err
+		}
+		return e.Close()
+	case FormatTOML:
+		return toml.NewEncoder(w).SetIndentTables(true).Encode(document)
+	default:
+		return fmt.Errorf("unsupported format %d", format)
+	}
+}
+
+// duplicateDefinitions returns an error for every job which is defined more than once
+func duplicateDefinitions(definitions []Definition) []error {
+	var (
+		errs []error
+		seen = make(map[string]Definition, len(definitions))
+	)
+	for _, d := range definitions {
+		name := d.QualifiedName()
+		if first, found := seen[name]; found {
+			errs = append(errs, d.error(fmt.Errorf("job %s is already defined at %s", name, first.location())))
+			continue
+		}
+		seen[name] = d
+	}
+	return errs
+}
+
+// definitionDecoder decodes the nodes of a definition document, errors are collected with the line of their node
+type definitionDecoder struct {
+	file     string
+	registry *task.Registry
+	errs     []error
+}
+
+func (d *definitionDecoder) errorf(n *node, format string, args ...interface{}) {
+	d.errs = append(d.errs, DefinitionError{File: d.file, Line: n.line, Err: fmt.Errorf(format, args...)})
+}
+
+// document decodes a single definition, or a list of definitions under the key jobs
+func (d *definitionDecoder) document(root *node) []Definition {
+	if root.kind != mappingNode {
+		d.errorf(root, "expected a mapping, got a %s", root.kind)
+		return nil
+	}
+
+	var jobs *node
+	for _, f := range root.fields {
+		if f.key == "jobs" {
+			jobs = f.value
+		}
+	}
+	if jobs == nil {
+		return []Definition{d.definition(root)}
+	}
+
+	for _, f := range root.fields {
+		if f.key != "jobs" {
+			d.errorf(f.value, "unknown field %q", f.key)
+		}
+	}
+	if jobs.kind != sequenceNode {
+		d.errorf(jobs, "jobs: expected a list, got a %s", jobs.kind)
+		return nil
+	}
+	definitions := make([]Definition, 0, len(jobs.items))
+	for _, item := range jobs.items {
+		definitions = append(definitions, d.definition(item))
+	}
+	return definitions
+}
+
+func (d *definitionDecoder) definition(n *node) Definition {
+	def := Definition{File: d.file, Line: n.line}
+	if n.kind != mappingNode {
+		d.errorf(n, "expected a job definition, got a %s", n.kind)
+		return def
+	}
+
+	var schedule, maxRuns, concurrency *node
+	for _, f := range n.fields {
+		switch f.key {
+		case "namespace":
+			def.Namespace = d.string(f)
+		case "name":
+			def.Name = d.string(f)
+		case "labels":
+			def.Labels = d.stringMap(f)
+		case "annotations":
+			def.Annotations = d.stringMap(f)
+		case "enabled":
+			if b, ok := f.value.value.(bool); ok && f.value.kind == scalarNode {
+				def.Enabled = &b
+			} else {
+				d.errorf(f.value, "%s: expected a boolean", f.key)
+			}
+		case "schedule":
+			def.Schedule, schedule = d.string(f), f.value
+		case "maxRuns":
+			def.MaxRuns, maxRuns = d.int(f.key, f.value), f.value
+		case "priority":
+			def.Priority = d.int(f.key, f.value)
+		case "concurrency":
+			def.Concurrency, concurrency = d.intMap(f), f.value
+		case "tasks":
+			def.Tasks = d.tasks(f)
+		default:
+			d.errorf(f.value, "unknown field %q", f.key)
+		}
+	}
+
+	switch {
+	case def.Name == "":
+		d.errorf(n, "name is required")
+	case validateName(def.Namespace, def.Name) != nil:
+		d.errorf(n, "%s", validateName(def.Namespace, def.Name))
+	}
+	if schedule == nil {
+		d.errorf(n, "schedule is required")
+	} else if _, err := cron.NewSchedule(def.Schedule); err != nil {
+		d.errorf(schedule, "invalid schedule %q: %s", def.Schedule, err)
+	}
+	if def.MaxRuns < 0 {
+		d.errorf(maxRuns, "maxRuns must not be negative")
+	}
+	if err := validateConcurrency(def.Concurrency); err != nil {
+		d.errorf(concurrency, "%s", ErrInvalidConcurrency.WithCause(err))
+	}
+	return def
+}
+
+func (d *definitionDecoder) tasks(f field) []TaskDefinition {
+	if f.value.kind != sequenceNode {
+		d.errorf(f.value, "%s: expected a list, got a %s", f.key, f.value.kind)
+		return nil
+	}
+
+	tasks := make([]TaskDefinition, 0, len(f.value.items))
+	for i, n := range f.value.items {
+		if n.kind != mappingNode {
+			d.errorf(n, "task %d: expected a mapping, got a %s", i, n.kind)
+			continue
+		}
+
+		var (
+			t      TaskDefinition
+			params *node
+		)
+		for _, tf := range n.fields {
+			switch tf.key {
+			case "type":
+				t.Type = d.string(tf)
+			case "params":
+				if tf.value.kind != mappingNode {
+					d.errorf(tf.value, "task %d: params: expected a mapping, got a %s", i, tf.value.kind)
+					continue
+				}
+				t.Params, params = tf.value.interfaceValue().(map[string]interface{}), tf.value
+			default:
+				d.errorf(tf.value, "task %d: unknown field %q", i, tf.key)
+			}
+		}
+		if t.Type == "" {
+			d.errorf(n, "task %d: type is required", i)
+		} else {
+			d.checkTask(n, t, params)
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks
+}
+
+// checkTask checks whether the registry can create the task, an error in params is reported at the line of the
+// parameter which causes it
+func (d *definitionDecoder) checkTask(n *node, t TaskDefinition, params *node) {
+	_, err := newTask(d.registry, t.Type, t.Params)
+	if err == nil {
+		return
+	}
+	if params != nil {
+		for _, p := range params.fields {
+			if _, paramErr := newTask(d.registry, t.Type, map[string]interface{}{p.key: t.Params[p.key]}); paramErr != nil {
+				d.errorf(p.value, "%s", paramErr)
+				return
+			}
+		}
+	}
+	d.errorf(n, "%s", err)
+}
+
+func (d *definitionDecoder) string(f field) string {
+	if s, ok := f.value.value.(string); ok && f.value.kind == scalarNode {
+		return s
+	}
+	d.errorf(f.value, "%s: expected a string", f.key)
+	return ""
+}
+
+func (d *definitionDecoder) int(key string, n *node) int {
+	if n.kind == scalarNode {
+		switch v := n.value.(type) {
+		case int64:
+			if v >= math.MinInt && v <= math.MaxInt {
+				return int(v)
+			}
+		case float64:
+			if v == math.Trunc(v) && v >= math.MinInt && v <= math.MaxInt {
+				return int(v)
+			}
+		}
+	}
+	d.errorf(n, "%s: expected an integer", key)
+	return 0
+}
+
+func (d *definitionDecoder) stringMap(f field) map[string]string {
+	if f.value.kind != mappingNode {
+		d.errorf(f.value, "%s: expected a mapping, got a %s", f.key, f.value.kind)
+		return nil
+	}
+	m := make(map[string]string, len(f.value.fields))
+	for _, entry := range f.value.fields {
+		m[entry.key] = d.string(field{key: f.key + "." + entry.key, value: entry.value})
+	}
+	return m
+}
+
+func (d *definitionDecoder) intMap(f field) map[string]int {
+	if f.value.kind != mappingNode {
+		d.errorf(f.value, "%s: expected a mapping, got a %s", f.key, f.value.kind)
+		return nil
+	}
+	m := make(map[string]int, len(f.value.fields))
+	for _, entry := range f.value.fields {
+		m[entry.key] = d.int(f.key+"."+entry.key, entry.value)
+	}
+	return m
+}
+
+// newTask returns a task of the type registered as name with params
+func newTask(registry *task.Registry, name string, params map[string]interface{}) (task.Task, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if len(params) > 0 {
+		if data, err = json.Marshal(params); err != nil {
+			return nil, err
+		}
+	}
+	return registry.Decode(task.EncodedTask{Type: name, Params: data})
+}
+
+// NewTaskDefinition returns the definition of t, parameters which equal those of a new task of its type are omitted
+func NewTaskDefinition(t task.Task, registry *task.Registry) (TaskDefinition, error) {
+	encoded, err := registry.Encode(t)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	defaultTask, err := registry.New(encoded.Type)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	defaults, err := registry.Encode(defaultTask)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+
+	fields, err := decodeParams(encoded.Params)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+	defaultFields, err := decodeParams(defaults.Params)
+	if err != nil {
+		return TaskDefinition{}, err
+	}
+
+	d := TaskDefinition{Type: encoded.Type}
+	for key, value := range fields {
+		if reflect.DeepEqual(value, defaultFields[key]) {
+			continue
+		}
+		if d.Params == nil {
+			d.Params = make(map[string]interface{}, len(fields))
+		}
+		d.Params[lowerFirst(key)] = value
+	}
+	return d, nil
+}
+
+// decodeParams decodes encoded parameters, integral numbers are decoded as int64 and other numbers as float64
+func decodeParams(data json.RawMessage) (map[string]interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var params map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&params); err != nil {
+		return nil, err
+	}
+	for key, value := range params {
+		params[key] = numbers(value)
+	}
+	return params, nil
+}
+
+// numbers converts the numbers in a decoded JSON value to an int64 if they are integral, otherwise to a float64
+func numbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = numbers(v[key])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = numbers(v[i])
+		}
+	}
+	return value
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
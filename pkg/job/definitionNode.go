@@ -0,0 +1,398 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+type nodeKind int
+
+func (k nodeKind) String() string {
+	return [...]string{"value", "mapping", "list"}[k]
+}
+
+const (
+	scalarNode nodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+// node is a value of a definition document with the line at which it starts, so errors can point at their cause
+type node struct {
+	kind   nodeKind
+	line   int
+	value  interface{} // the value of a scalar: nil, bool, int64, float64 or string
+	fields []field     // the fields of a mapping in document order
+	items  []*node     // the items of a sequence
+}
+
+type field struct {
+	key   string
+	value *node
+}
+
+// interfaceValue returns the value of the node as decoded by encoding/json into an interface{}, with integers as int64
+func (n *node) interfaceValue() interface{} {
+	switch n.kind {
+	case mappingNode:
+		m := make(map[string]interface{}, len(n.fields))
+		for _, f := range n.fields {
+			m[f.key] = f.value.interfaceValue()
+		}
+		return m
+	case sequenceNode:
+		s := make([]interface{}, 0, len(n.items))
+		for _, item := range n.items {
+			s = append(s, item.interfaceValue())
+		}
+		return s
+	default:
+		return n.value
+	}
+}
+
+// parseNode parses a definition document in format
+func parseNode(data []byte, format DefinitionFormat) (*node, error) {
+	switch format {
+	case FormatJSON:
+		return parseJSONNode(data)
+	case FormatYAML:
+		return parseYAMLNode(data)
+	case FormatTOML:
+		return parseTOMLNode(data)
+	default:
+		return nil, fmt.Errorf("unsupported format %d", format)
+	}
+}
+
+// lineError is a syntax error at a line of a document
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e lineError) Error() string {
+	return e.err.Error()
+}
+
+func parseYAMLNode(data []byte) (*node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &node{kind: mappingNode, line: 1}, nil
+	}
+	return yamlNode(doc.Content[0])
+}
+
+func yamlNode(y *yaml.Node) (*node, error) {
+	n := &node{line: y.Line}
+	switch y.Kind {
+	case yaml.AliasNode:
+		return yamlNode(y.Alias)
+	case yaml.MappingNode:
+		n.kind = mappingNode
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			value, err := yamlNode(y.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			n.fields = append(n.fields, field{key: y.Content[i].Value, value: value})
+		}
+	case yaml.SequenceNode:
+		n.kind = sequenceNode
+		for _, c := range y.Content {
+			item, err := yamlNode(c)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	default:
+		var v interface{}
+		if err := y.Decode(&v); err != nil {
+			return nil, lineError{line: y.Line, err: err}
+		}
+		if i, ok := v.(int); ok {
+			v = int64(i)
+		}
+		n.value = v
+	}
+	return n, nil
+}
+
+// parseJSONNode parses a JSON document, the line of every value is derived from the offset of its first token
+func parseJSONNode(data []byte) (*node, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	p := jsonParser{data: data, decoder: d}
+
+	n, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = d.Token(); err != io.EOF {
+		return nil, lineError{line: p.line(), err: errors.New("unexpected data after the document")}
+	}
+	return n, nil
+}
+
+type jsonParser struct {
+	data    []byte
+	decoder *json.Decoder
+}
+
+// line returns the line of the next token
+func (p jsonParser) line() int {
+	offset := int(p.decoder.InputOffset())
+	for offset < len(p.data) && strings.IndexByte(" \t\r\n,:", p.data[offset]) >= 0 {
+		offset++
+	}
+	return bytes.Count(p.data[:offset], []byte("\n")) + 1
+}
+
+func (p jsonParser) value() (*node, error) {
+	line := p.line()
+	token, err := p.decoder.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, lineError{line: line, err: err}
+	}
+
+	n := &node{line: line}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			n.kind = mappingNode
+			for p.decoder.More() {
+				keyLine := p.line()
+				var key json.Token
+				if key, err = p.decoder.Token(); err != nil {
+					return nil, lineError{line: keyLine, err: err}
+				}
+				var value *node
+				if value, err = p.value(); err != nil {
+					return nil, err
+				}
+				n.fields = append(n.fields, field{key: key.(string), value: value})
+			}
+		case '[':
+			n.kind = sequenceNode
+			for p.decoder.More() {
+				var item *node
+				if item, err = p.value(); err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, item)
+			}
+		}
+		// Consume the closing delimiter
+		if _, err = p.decoder.Token(); err != nil {
+			return nil, lineError{line: p.line(), err: err}
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			n.value = i
+		} else if n.value, err = t.Float64(); err != nil {
+			return nil, lineError{line: line, err: err}
+		}
+	default:
+		n.value = t
+	}
+	return n, nil
+}
+
+// parseTOMLNode parses a TOML document. The document is decoded by the toml package, the lines of keys are taken from
+// the expressions of the document.
+func parseTOMLNode(data []byte) (*node, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		var de *toml.DecodeError
+		if errors.As(err, &de) {
+			row, _ := de.Position()
+			return nil, lineError{line: row, err: errors.New(de.Error())}
+		}
+		return nil, err
+	}
+
+	lines, err := tomlLines(data)
+	if err != nil {
+		return nil, err
+	}
+	return tomlNode(doc, "", 1, lines), nil
+}
+
+// tomlLines returns the line of every table, array table element and key of a TOML document by path, such as
+// jobs[0].tasks[1].type
+func tomlLines(data []byte) (map[string]int, error) {
+	var (
+		p       = unstable.Parser{}
+		lines   = make(map[string]int)
+		counts  = make(map[string]int) // the number of elements of every array table
+		current = ""
+	)
+	p.Reset(data)
+
+	// resolve returns the path of a table key, array tables in the key refer to their last element
+	resolve := func(keys []string, append bool) string {
+		path := ""
+		for i, key := range keys {
+			path = joinPath(path, key)
+			last := i == len(keys)-1
+			if last && append {
+				counts[path]++
+			}
+			if count, isArray := counts[path]; isArray && (!last || append) {
+				path += "[" + strconv.Itoa(count-1) + "]"
+			}
+		}
+		return path
+	}
+
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			keys, first := tomlKey(e.Key())
+			current = resolve(keys, e.Kind == unstable.ArrayTable)
+			lines[current] = p.Shape(first.Raw).Start.Line
+		case unstable.KeyValue:
+			keys, _ := tomlKey(e.Key())
+			path := current
+			for _, key := range keys {
+				path = joinPath(path, key)
+			}
+			line := p.Shape(e.Raw).Start.Line
+			lines[path] = line
+			tomlValueLines(&p, e.Value(), path, line, lines)
+		}
+	}
+	return lines, p.Error()
+}
+
+// tomlValueLines records the lines of the elements of arrays and inline tables
+func tomlValueLines(p *unstable.Parser, v *unstable.Node, path string, line int, lines map[string]int) {
+	if v.Raw.Length > 0 {
+		line = p.Shape(v.Raw).Start.Line
+	}
+	lines[path] = line
+
+	switch v.Kind {
+	case unstable.Array:
+		i := 0
+		for it := v.Children(); it.Next(); {
+			if c := it.Node(); c.Kind != unstable.Comment {
+				tomlValueLines(p, c, path+"["+strconv.Itoa(i)+"]", line, lines)
+				i++
+			}
+		}
+	case unstable.InlineTable:
+		for it := v.Children(); it.Next(); {
+			kv := it.Node()
+			if kv.Kind != unstable.KeyValue {
+				continue
+			}
+			keys, first := tomlKey(kv.Key())
+			child := path
+			for _, key := range keys {
+				child = joinPath(child, key)
+			}
+			childLine := line
+			if first != nil && first.Raw.Length > 0 {
+				childLine = p.Shape(first.Raw).Start.Line
+			}
+			lines[child] = childLine
+			tomlValueLines(p, kv.Value(), child, childLine, lines)
+		}
+	}
+}
+
+func tomlKey(it unstable.Iterator) ([]string, *unstable.Node) {
+	var (
+		keys  []string
+		first *unstable.Node
+	)
+	for it.Next() {
+		if first == nil {
+			first = it.Node()
+		}
+		keys = append(keys, string(it.Node().Data))
+	}
+	return keys, first
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// tomlNode converts a value decoded by the toml package to a node, keys are sorted since the decoded tables do not
+// keep the order of the document
+func tomlNode(value interface{}, path string, line int, lines map[string]int) *node {
+	if l, found := lines[path]; found {
+		line = l
+	}
+
+	n := &node{line: line}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		n.kind = mappingNode
+		for _, key := range sortedKeys(v) {
+			n.fields = append(n.fields, field{key: key, value: tomlNode(v[key], joinPath(path, key), line, lines)})
+		}
+	case []interface{}:
+		n.kind = sequenceNode
+		for i, item := range v {
+			n.items = append(n.items, tomlNode(item, path+"["+strconv.Itoa(i)+"]", line, lines))
+		}
+	case []map[string]interface{}:
+		n.kind = sequenceNode
+		for i, item := range v {
+			n.items = append(n.items, tomlNode(item, path+"["+strconv.Itoa(i)+"]", line, lines))
+		}
+	default:
+		n.value = v
+	}
+	return n
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func NewDefinitionWatcher(dir string, registry *task.Registry, reconciler *Reconciler, config DefinitionWatcherConfig) *DefinitionWatcher {
+	if config.Interval <= 0 {
+		config.Interval = DefaultWatchInterval
+	}
+	if config.Logger == nil {
+		config.Logger = discardLogger
+	}
+	return &DefinitionWatcher{
+		dir:        dir,
+		registry:   registry,
+		reconciler: reconciler,
+		config:     config,
+		files:      make(map[string]watchedFile),
+	}
+}
+
+// DefinitionWatcher polls a directory of definition files and reconciles the jobs they define whenever a file is
+// added, changed or removed. Polling works on every platform and file system, including network file systems.
+// A file which becomes invalid keeps the jobs it defined when it was last valid, until it is fixed or removed.
+type DefinitionWatcher struct {
+	dir        string
+	registry   *task.Registry
+	reconciler *Reconciler
+	config     DefinitionWatcherConfig
+	files      map[string]watchedFile // the state of every definition file as of the last poll
+	retry      bool                   // reconcile on the next poll, even if no file changed
+	mux        sync.Mutex
+}
+
+// watchedFile is the state of a definition file
+type watchedFile struct {
+	digest [sha256.Size]byte
+	jobs   []Job // the jobs defined by the file when it was last valid
+	err    error // the error of the file if it is not valid
+}
+
+// Run polls the directory until ctx is canceled, the directory is reconciled immediately
+func (w *DefinitionWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(false)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync loads all definition files and reconciles the jobs they define, regardless of whether files changed.
+// The returned error joins the errors of invalid files and the errors of the reconciliation.
+func (w *DefinitionWatcher) Sync() (ReconcileResult, error) {
+	return w.poll(true)
+}
+
+func (w *DefinitionWatcher) poll(force bool) (ReconcileResult, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	changed, err := w.load()
+	if err != nil {
+		w.config.Logger.Error("reading job definitions failed", slog.String("directory", w.dir), slog.Any("error", err))
+		return ReconcileResult{}, err
+	}
+	if !changed && !w.retry && !force {
+		return ReconcileResult{}, nil
+	}
+
+	var (
+		desired []Job
+		errs    []error
+	)
+	for _, file := range sortedFiles(w.files) {
+		desired = append(desired, w.files[file].jobs...)
+		if w.files[file].err != nil {
+			errs = append(errs, w.files[file].err)
+		}
+	}
+
+	result, err := w.reconciler.Reconcile(desired)
+	w.retry = err != nil || result.Deferred > 0
+	if err != nil {
+		w.config.Logger.Error("reconciling job definitions failed", slog.String("directory", w.dir), slog.Any("error", err))
+	}
+	if result.Changed() {
+		w.config.Logger.Info("reconciled job definitions",
+			slog.String("directory", w.dir),
+			slog.Int("added", result.Added),
+			slog.Int("updated", result.Updated),
+			slog.Int("removed", result.Removed),
+			slog.Int("deferred", result.Deferred),
+			slog.Int("unchanged", result.Unchanged))
+	}
+	return result, errors.Join(append(errs, err)...)
+}
+
+// load reads the definition files which were added or changed since the last poll, it reports whether a file was
+// added, changed or removed
+func (w *DefinitionWatcher) load() (bool, error) {
+	files, err := DefinitionFiles(w.dir)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	present := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // removed since the directory was read
+		}
+		if err != nil {
+			return false, err
+		}
+		present[file] = struct{}{}
+
+		digest := sha256.Sum256(data)
+		previous, found := w.files[file]
+		if found && previous.digest == digest {
+			continue
+		}
+		changed = true
+
+		state := watchedFile{digest: digest, jobs: previous.jobs}
+		var jobs []Job
+		if jobs, state.err = w.parse(file, data); state.err != nil {
+			w.config.Logger.Warn("invalid job definitions", slog.String("file", file), slog.Any("error", state.err))
+		} else {
+			state.jobs = jobs
+		}
+		w.files[file] = state
+	}
+
+	for file := range w.files {
+		if _, found := present[file]; !found {
+			delete(w.files, file)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func (w *DefinitionWatcher) parse(file string, data []byte) ([]Job, error) {
+	format, err := DefinitionFileFormat(file)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := ParseDefinitions(file, data, format, w.registry)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0, len(definitions))
+	for _, d := range definitions {
+		j, err := d.Job(w.registry)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+func sortedFiles(files map[string]watchedFile) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
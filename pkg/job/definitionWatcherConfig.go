@@ -0,0 +1,30 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"log/slog"
+	"time"
+)
+
+// DefaultWatchInterval is used when DefinitionWatcherConfig.Interval is not set
+const DefaultWatchInterval = 5 * time.Second
+
+type DefinitionWatcherConfig struct {
+	Interval time.Duration // the interval at which the directory is polled for changes
+	Logger   *slog.Logger  // receives invalid definitions and the changes made to the catalog, nothing is logged when it is not set
+}
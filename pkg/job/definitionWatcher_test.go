@@ -0,0 +1,114 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func TestDefinitionWatcher(t *testing.T) {
+	o, c, _ := newTestOrchestrator(t, nil)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.yaml")
+	write := func(name string, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := NewDefinitionWatcher(dir, task.NewDefaultRegistry(), NewReconciler(o, ReconcilerConfig{Source: "files"}), DefinitionWatcherConfig{})
+
+	write("backup.yaml", "namespace: ops\nname: backup\nenabled: true\nschedule: \"@hourly\"\ntasks:\n  - type: sleep\n")
+	result, err := w.Sync()
+	if err != nil || result != (ReconcileResult{Added: 1}) {
+		t.Fatalf("got result %+v and error %v, expected an added job", result, err)
+	}
+	jobId := DefinitionId(file, "ops", "backup")
+	if stored, err := c.Get(jobId); err != nil || !stored.IsEnabled() {
+		t.Fatalf("got error %v, expected an enabled job with the identifier of its definition", err)
+	}
+
+	if result, err = w.poll(false); err != nil || result != (ReconcileResult{}) {
+		t.Errorf("got result %+v and error %v, expected no reconciliation without changes", result, err)
+	}
+
+	write("backup.yaml", "namespace: ops\nname: backup\nenabled: true\nschedule: \"@daily\"\ntasks:\n  - type: sleep\n")
+	if result, err = w.poll(false); err != nil || result != (ReconcileResult{Updated: 1}) {
+		t.Errorf("got result %+v and error %v, expected an updated job", result, err)
+	}
+
+	// An invalid file keeps the jobs it defined when it was last valid
+	write("backup.yaml", "namespace: ops\nname: backup\nschedule: \"@never\"\n")
+	result, err = w.poll(false)
+	var de DefinitionError
+	if !errors.As(err, &de) || de.Line != 3 || result != (ReconcileResult{Unchanged: 1}) {
+		t.Errorf("got result %+v and error %v, expected an error at line 3 and an unchanged job", result, err)
+	}
+	if stored, _ := c.Get(jobId); stored.Schedule.String() != "0 0 * * *" {
+		t.Errorf("got schedule %s, expected the last valid definition", stored.Schedule.String())
+	}
+
+	write("cleanup.json", `{"namespace":"ops","name":"cleanup","schedule":"@daily","tasks":[{"type":"empty"}]}`)
+	if err = os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = w.poll(false); err != nil || result != (ReconcileResult{Added: 1, Removed: 1}) {
+		t.Errorf("got result %+v and error %v, expected an added and a removed job", result, err)
+	}
+	if _, err = c.Get(jobId); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, expected the job of the removed file to be deleted", err)
+	}
+}
+
+func TestDefinitionWatcher_Run(t *testing.T) {
+	o, c, _ := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	dir := t.TempDir()
+	w := NewDefinitionWatcher(dir, task.NewDefaultRegistry(), NewReconciler(o, ReconcilerConfig{}), DefinitionWatcherConfig{Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	file := filepath.Join(dir, "report.toml")
+	if err := os.WriteFile(file, []byte("name = \"report\"\nschedule = \"@daily\"\n\n[[tasks]]\ntype = \"empty\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := c.Get(DefinitionId(file, "", "report")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if _, err := c.Get(DefinitionId(file, "", "report")); err != nil {
+		t.Errorf("got error %v, expected the job of the new file", err)
+	}
+}
@@ -0,0 +1,363 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+var testDefinitions = map[DefinitionFormat]string{
+	FormatYAML: `jobs:
+  - namespace: ops
+    name: backup
+    labels:
+      team: storage
+    enabled: true
+    schedule: "@hourly"
+    maxRuns: 3
+    priority: 100
+    concurrency:
+      db: 1
+    tasks:
+      - type: print
+        params:
+          message: starting backup
+      - type: sleep
+        params:
+          milliseconds: 250
+      - type: timelog
+  - name: cleanup
+    schedule: "0 3 * * *"
+    tasks:
+      - type: empty
+`,
+	FormatJSON: `{
+  "jobs": [
+    {
+      "namespace": "ops",
+      "name": "backup",
+      "labels": {"team": "storage"},
+      "enabled": true,
+      "schedule": "@hourly",
+      "maxRuns": 3,
+      "priority": 100,
+      "concurrency": {"db": 1},
+      "tasks": [
+        {"type": "print", "params": {"message": "starting backup"}},
+        {"type": "sleep", "params": {"milliseconds": 250}},
+        {"type": "timelog"}
+      ]
+    },
+    {
+      "name": "cleanup",
+      "schedule": "0 3 * * *",
+      "tasks": [{"type": "empty"}]
+    }
+  ]
+}
+`,
+	FormatTOML: `[[jobs]]
+namespace = "ops"
+name = "backup"
+enabled = true
+schedule = "@hourly"
+maxRuns = 3
+priority = 100
+labels = { team = "storage" }
+concurrency = { db = 1 }
+
+  [[jobs.tasks]]
+  type = "print"
+  params = { message = "starting backup" }
+
+  [[jobs.tasks]]
+  type = "sleep"
+  params.milliseconds = 250
+
+  [[jobs.tasks]]
+  type = "timelog"
+
+[[jobs]]
+name = "cleanup"
+schedule = "0 3 * * *"
+
+  [[jobs.tasks]]
+  type = "empty"
+`,
+}
+
+func TestParseDefinitions(t *testing.T) {
+	registry := task.NewDefaultRegistry()
+
+	for format, data := range testDefinitions {
+		t.Run(format.String(), func(t *testing.T) {
+			definitions, err := ParseDefinitions("jobs."+format.String(), []byte(data), format, registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(definitions) != 2 {
+				t.Fatalf("got %d definitions, expected 2", len(definitions))
+			}
+
+			j, err := definitions[0].Job(registry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j.QualifiedName() != "ops/backup" || !j.Enabled || j.MaxRuns != 3 || j.Priority != 100 {
+				t.Errorf("got job %s, enabled %t, maxRuns %d, priority %d", j.QualifiedName(), j.Enabled, j.MaxRuns, j.Priority)
+			}
+			if j.Labels["team"] != "storage" || j.Concurrency["db"] != 1 {
+				t.Errorf("got labels %v and concurrency %v", j.Labels, j.Concurrency)
+			}
+			if j.Schedule.String() != "0 * * * *" {
+				t.Errorf("got schedule %s, expected 0 * * * *", j.Schedule.String())
+			}
+			expected := []task.Task{task.PrintTask{Message: "starting backup"}, task.SleepTask{Milliseconds: 250}, task.TimeLogTask{}}
+			if !reflect.DeepEqual(j.Tasks.Tasks, expected) {
+				t.Errorf("got tasks %v, expected %v", j.Tasks.Tasks, expected)
+			}
+
+			if definitions[1].Enabled != nil {
+				t.Errorf("got enabled %t for cleanup, expected it not to be set", *definitions[1].Enabled)
+			}
+			if definitions[1].Line <= definitions[0].Line {
+				t.Errorf("got line %d for cleanup, expected it after line %d", definitions[1].Line, definitions[0].Line)
+			}
+		})
+	}
+}
+
+func TestParseDefinitions_Single(t *testing.T) {
+	data := "name: report\nschedule: \"@daily\"\ntasks:\n  - type: print\n    params:\n      message: done\n"
+	definitions, err := ParseDefinitions("report.yaml", []byte(data), FormatYAML, task.NewDefaultRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definitions) != 1 || definitions[0].Name != "report" || definitions[0].Line != 1 {
+		t.Errorf("got %+v, expected a single definition of report at line 1", definitions)
+	}
+}
+
+func TestParseDefinitions_Errors(t *testing.T) {
+	var tests = []struct {
+		name   string
+		format DefinitionFormat
+		data   string
+		errors []string
+	}{
+		{
+			name:   "yaml",
+			format: FormatYAML,
+			data: `jobs:
+  - name: backup
+    schedule: "0 * * *"
+    maxRuns: many
+    tasks:
+      - type: sleep
+        params:
+          milliseconds: 10
+          seconds: 1
+      - type: unknown
+  - schedule: "@daily"
+    owner: ops
+`,
+			errors: []string{
+				`jobs.yaml:3: invalid schedule "0 * * *"`,
+				"jobs.yaml:4: maxRuns: expected an integer",
+				`jobs.yaml:9: task sleep: invalid params: json: unknown field "seconds"`,
+				"jobs.yaml:10: task unknown: unknown type",
+				"jobs.yaml:11: name is required",
+				`jobs.yaml:12: unknown field "owner"`,
+			},
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			data: `{
+  "name": "backup",
+  "schedule": "@hourly",
+  "enabled": "yes",
+  "tasks": [
+    {"type": "print", "params": {"message": 1}}
+  ]
+}`,
+			errors: []string{
+				"jobs.json:4: enabled: expected a boolean",
+				"jobs.json:6: task print: invalid params",
+			},
+		},
+		{
+			name:   "toml",
+			format: FormatTOML,
+			data: `[[jobs]]
+name = "backup"
+schedule = "@hourly"
+
+[[jobs]]
+name = "backup"
+schedule = "@hourly"
+concurrency = { db = 0 }
+
+  [[jobs.tasks]]
+  kind = "print"
+`,
+			errors: []string{
+				"jobs.toml:5: job backup is already defined at jobs.toml:1",
+				"jobs.toml:8: invalid concurrency",
+				"jobs.toml:10: task 0: type is required",
+				`jobs.toml:11: task 0: unknown field "kind"`,
+			},
+		},
+		{
+			name:   "yaml syntax",
+			format: FormatYAML,
+			data:   "name: backup\nschedule: [\n",
+			errors: []string{"jobs.yaml:"},
+		},
+		{
+			name:   "json syntax",
+			format: FormatJSON,
+			data:   "{\n  \"name\": \"backup\",\n  \"schedule\" \"@hourly\"\n}",
+			errors: []string{"jobs.json:3: invalid character"},
+		},
+		{
+			name:   "toml syntax",
+			format: FormatTOML,
+			data:   "name = \"backup\"\nschedule = \n",
+			errors: []string{"jobs.toml:2: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := "jobs." + tt.format.String()
+			_, err := ParseDefinitions(file, []byte(tt.data), tt.format, task.NewDefaultRegistry())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !errors.As(err, &DefinitionError{}) {
+				t.Errorf("got error %T, expected a DefinitionError", err)
+			}
+
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tt.errors) {
+				t.Fatalf("got %d errors, expected %d:\n%v", len(lines), len(tt.errors), err)
+			}
+			for i, expected := range tt.errors {
+				if !strings.HasPrefix(lines[i], expected) {
+					t.Errorf("got error %q, expected %q", lines[i], expected)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteDefinitions(t *testing.T) {
+	registry := task.NewDefaultRegistry()
+	definitions, err := ParseDefinitions("jobs.yaml", []byte(testDefinitions[FormatYAML]), FormatYAML, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jobs []Job
+	for _, d := range definitions {
+		j, err := d.Job(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, j)
+	}
+	jobs[1].Tasks.Tasks = append(jobs[1].Tasks.Tasks, task.TimeLogTask{Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)})
+
+	for _, format := range []DefinitionFormat{FormatJSON, FormatYAML, FormatTOML} {
+		t.Run(format.String(), func(t *testing.T) {
+			exported := make([]Definition, 0, len(jobs))
+			for _, j := range jobs {
+				d, err := NewDefinition(j, registry)
+				if err != nil {
+					t.Fatal(err)
+				}
+				exported = append(exported, d)
+			}
+
+			var b bytes.Buffer
+			if err = WriteDefinitions(&b, format, exported); err != nil {
+				t.Fatal(err)
+			}
+			imported, err := ParseDefinitions("export", b.Bytes(), format, registry)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, b.String())
+			}
+
+			for i, d := range imported {
+				j, err := d.Job(registry)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(j.Tasks.Tasks, jobs[i].Tasks.Tasks) {
+					t.Errorf("got tasks %v, expected %v", j.Tasks.Tasks, jobs[i].Tasks.Tasks)
+				}
+				d.File, d.Line = "", 0
+				if !reflect.DeepEqual(d, exported[i]) {
+					t.Errorf("got definition %+v, expected %+v\n%s", d, exported[i], b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDefinitionDir(t *testing.T) {
+	dir := t.TempDir()
+	for format, data := range testDefinitions {
+		if format == FormatJSON {
+			data = strings.ReplaceAll(strings.ReplaceAll(data, `"ops"`, `"json"`), "cleanup", "json-cleanup")
+		}
+		if format == FormatTOML {
+			data = strings.ReplaceAll(strings.ReplaceAll(data, `"ops"`, `"toml"`), "cleanup", "toml-cleanup")
+		}
+		if err := os.WriteFile(filepath.Join(dir, "jobs."+format.String()), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a definition"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	definitions, err := LoadDefinitionDir(dir, task.NewDefaultRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(definitions) != 6 {
+		t.Errorf("got %d definitions, expected 6", len(definitions))
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "copy.yml"), []byte(testDefinitions[FormatYAML]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadDefinitionDir(dir, task.NewDefaultRegistry()); err == nil || !strings.Contains(err.Error(), "already defined at "+filepath.Join(dir, "copy.yml")) {
+		t.Errorf("got error %v, expected duplicate definitions", err)
+	}
+}
@@ -16,36 +16,162 @@
 
 package job
 
-import "fmt"
+import (
+	"context"
+	"log/slog"
+	"strconv"
 
-func NewError(kind errKind, err error) Error {
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/internal/errs"
+)
+
+// NewError returns an error of kind which wraps err, err may be nil
+func NewError(kind ErrorKind, err error) Error {
 	return Error{
-		kind: kind,
-		err:  err,
+		base: errs.Error[ErrorKind]{Kind: kind, Cause: err},
 	}
 }
 
+// Error is returned by catalogs and the orchestrator. Errors are matched by kind using errors.Is, so
+// errors.Is(err, ErrNotFound) reports whether err is a not found error, regardless of the job it applies to.
 type Error struct {
-	kind errKind
-	err  error
+	JobId uuid.UUID // the job the error applies to, if any
+	Name  string    // the name of the job the error applies to, if known
+	base  errs.Error[ErrorKind]
 }
 
 func (e Error) Error() string {
-	return e.kind.String()
+	msg := e.base.Message()
+	switch {
+	case e.JobId == uuid.Nil:
+		return msg
+	case e.Name == "":
+		return "job " + e.JobId.String() + ": " + msg
+	default:
+		return "job " + e.Name + " (" + e.JobId.String() + "): " + msg
+	}
+}
+
+// Is reports whether target is an Error of the same kind
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.base.Kind == e.base.Kind
+}
+
+// Kind returns the kind of the error
+func (e Error) Kind() ErrorKind {
+	return e.base.Kind
+}
+
+// LogValue implements slog.LogValuer, the error is logged as a group with its kind, job and cause
+func (e Error) LogValue() slog.Value {
+	var subject []slog.Attr
+	if e.JobId != uuid.Nil {
+		subject = append(subject, slog.String("job_id", e.JobId.String()))
+	}
+	if e.Name != "" {
+		subject = append(subject, slog.String("job_name", e.Name))
+	}
+	return e.base.LogValue(subject...)
+}
+
+func (e Error) Unwrap() error {
+	return e.base.Cause
 }
 
-type errKind int
+// WithCause returns a copy of the error which wraps err
+func (e Error) WithCause(err error) Error {
+	e.base.Cause = err
+	return e
+}
+
+// WithJob returns a copy of the error which applies to the job with jobId and name
+func (e Error) WithJob(jobId uuid.UUID, name string) Error {
+	e.JobId = jobId
+	e.Name = name
+	return e
+}
 
-func (k errKind) String() string {
-	return fmt.Sprintf("%d", k)
+// contextError converts an error of an expired context into ErrTimeout or ErrCanceled, which wrap the context error
+func contextError(err error) error {
+	if e, ok := errs.FromContext(err, ErrTimeout, ErrCanceled); ok {
+		return e
+	}
+	return err
+}
+
+type ErrorKind int
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrKindNotFound:
+		return "not found"
+	case ErrKindExist:
+		return "exists"
+	case ErrKindStarted:
+		return "started"
+	case ErrKindConflict:
+		return "conflict"
+	case ErrKindNameExist:
+		return "name exists"
+	case ErrKindInvalidName:
+		return "invalid name"
+	case ErrKindInvalidTransition:
+		return "invalid transition"
+	case ErrKindHandlerMissing:
+		return "handler missing"
+	case ErrKindTimeout:
+		return "timeout"
+	case ErrKindCanceled:
+		return "canceled"
+	case ErrKindInvalidConcurrency:
+		return "invalid concurrency"
+	case ErrKindLocked:
+		return "locked"
+	case ErrKindLockLost:
+		return "lock lost"
+	case ErrKindNotSupported:
+		return "not supported"
+	case ErrKindOwned:
+		return "owned"
+	default:
+		return "kind(" + strconv.Itoa(int(k)) + ")"
+	}
 }
 
 const (
-	ErrKindNotFound errKind = iota
+	ErrKindNotFound ErrorKind = iota
 	ErrKindExist
+	ErrKindStarted
+	ErrKindConflict
+	ErrKindNameExist
+	ErrKindInvalidName
+	ErrKindInvalidTransition
+	ErrKindHandlerMissing
+	ErrKindTimeout
+	ErrKindCanceled
+	ErrKindInvalidConcurrency
+	ErrKindLocked
+	ErrKindLockLost
+	ErrKindNotSupported
+	ErrKindOwned
 )
 
 var (
-	ErrNotFound = Error{kind: ErrKindNotFound}
-	ErrExist    = Error{kind: ErrKindExist}
+	ErrNotFound           = NewError(ErrKindNotFound, nil)
+	ErrExist              = NewError(ErrKindExist, nil)
+	ErrStarted            = NewError(ErrKindStarted, nil)
+	ErrConflict           = NewError(ErrKindConflict, nil)
+	ErrNameExist          = NewError(ErrKindNameExist, nil)
+	ErrInvalidName        = NewError(ErrKindInvalidName, nil)
+	ErrInvalidTransition  = NewError(ErrKindInvalidTransition, nil)
+	ErrHandlerMissing     = NewError(ErrKindHandlerMissing, nil)
+	ErrTimeout            = NewError(ErrKindTimeout, context.DeadlineExceeded)
+	ErrCanceled           = NewError(ErrKindCanceled, context.Canceled)
+	ErrInvalidConcurrency = NewError(ErrKindInvalidConcurrency, nil)
+	ErrLocked             = NewError(ErrKindLocked, nil)
+	ErrLockLost           = NewError(ErrKindLockLost, nil)
+	ErrNotSupported       = NewError(ErrKindNotSupported, nil)
+	ErrOwned              = NewError(ErrKindOwned, nil)
 )
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestError_Error(t *testing.T) {
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	tests := []struct {
+		err    Error
+		wanted string
+	}{
+		{ErrNotFound, "not found"},
+		{ErrNotFound.WithJob(id, ""), "job 6ba7b810-9dad-11d1-80b4-00c04fd430c8: not found"},
+		{ErrConflict.WithJob(id, "backup"), "job backup (6ba7b810-9dad-11d1-80b4-00c04fd430c8): conflict"},
+		{ErrInvalidName.WithCause(errors.New("empty name")), "invalid name: empty name"},
+		{ErrTimeout, "timeout: context deadline exceeded"},
+		{NewError(ErrorKind(99), nil), "kind(99)"},
+	}
+
+	for _, test := range tests {
+		if msg := test.err.Error(); msg != test.wanted {
+			t.Errorf("got %q, expected %q", msg, test.wanted)
+		}
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("update: %w", ErrConflict.WithJob(uuid.New(), "test").WithCause(errors.New("revision 2")))
+
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("%v is not %v", err, ErrConflict)
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("%v is %v", err, ErrNotFound)
+	}
+	if !errors.Is(ErrCanceled, context.Canceled) || !errors.Is(ErrTimeout, context.DeadlineExceeded) {
+		t.Errorf("context errors are not wrapped")
+	}
+}
+
+func TestError_As(t *testing.T) {
+	id := uuid.New()
+	err := fmt.Errorf("get: %w", ErrNotFound.WithJob(id, "test"))
+
+	var e Error
+	if !errors.As(err, &e) {
+		t.Fatalf("%v is not an Error", err)
+	}
+	if e.Kind() != ErrKindNotFound || e.JobId != id || e.Name != "test" {
+		t.Errorf("got kind %s for job %s (%s), expected %s for job test (%s)", e.Kind(), e.Name, e.JobId, ErrKindNotFound, id)
+	}
+}
+
+func TestError_LogValue(t *testing.T) {
+	id := uuid.New()
+	value := ErrConflict.WithJob(id, "test").WithCause(errors.New("revision 2")).LogValue()
+
+	attrs := make(map[string]string)
+	for _, a := range value.Group() {
+		attrs[a.Key] = a.Value.String()
+	}
+	wanted := map[string]string{"kind": "conflict", "job_id": id.String(), "job_name": "test", "cause": "revision 2"}
+	for k, v := range wanted {
+		if attrs[k] != v {
+			t.Errorf("got %s=%q, expected %q", k, attrs[k], v)
+		}
+	}
+	if value.Kind() != slog.KindGroup {
+		t.Errorf("got kind %s, expected %s", value.Kind(), slog.KindGroup)
+	}
+}
+
+func TestContextError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := contextError(ctx.Err()); !errors.Is(err, ErrCanceled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, expected %v", err, ErrCanceled)
+	}
+}
+
+func TestStatus_CanTransition(t *testing.T) {
+	flow := []Status{StatusInactive, StatusAvailable, StatusSchedulable, StatusRunnable, StatusPending, StatusActive, StatusCompleted, StatusInactive}
+	for i := 1; i < len(flow); i++ {
+		if !flow[i-1].CanTransition(flow[i]) {
+			t.Errorf("cannot transition from %s to %s", flow[i-1], flow[i])
+		}
+	}
+	if StatusInactive.CanTransition(StatusActive) {
+		t.Errorf("can transition from %s to %s", StatusInactive, StatusActive)
+	}
+}
@@ -0,0 +1,698 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	fileCatalogSnapshot    = "catalog.snapshot"
+	fileCatalogSnapshotTmp = "catalog.snapshot.tmp"
+	fileCatalogWal         = "catalog.wal"
+	fileCatalogHeaderSize  = 8
+)
+
+type walOperation int
+
+const (
+	walPut walOperation = iota
+	walDelete
+)
+
+type walEntry struct {
+	Sequence  uint64       `json:"sequence"`
+	Operation walOperation `json:"operation"`
+	JobId     uuid.UUID    `json:"jobId"`
+	Job       *jobRecord   `json:"job,omitempty"`
+}
+
+// walFile is the file holding the write-ahead log
+type walFile interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Sync() error
+	Truncate(size int64) error
+}
+
+type snapshot struct {
+	Sequence uint64      `json:"sequence"`
+	Jobs     []jobRecord `json:"jobs"`
+}
+
+// NewFileCatalog opens the catalog stored in config.Directory, the directory is created if it does not exist.
+// The state is recovered from the latest snapshot and the write-ahead log, an incomplete record at the end of the log
+// caused by a crash during a write is discarded. The sync policy SyncInterval requires a positive SyncInterval.
+func NewFileCatalog(config FileCatalogConfig) (*FileCatalog, error) {
+	var err error
+
+	if config.SyncPolicy == SyncInterval && config.SyncInterval <= 0 {
+		return nil, fmt.Errorf("sync policy %s requires a positive sync interval, got %s", config.SyncPolicy, config.SyncInterval)
+	}
+	if err = os.MkdirAll(config.Directory, 0o750); err != nil {
+		return nil, err
+	}
+
+	c := &FileCatalog{
+		config: config,
+		memory: NewMemoryCatalog(),
+		chStop: make(chan struct{}),
+		chDone: make(chan struct{}),
+		mux:    &sync.Mutex{},
+	}
+
+	if err = c.recover(); err != nil {
+		return nil, err
+	}
+
+	go c.maintain()
+	return c, nil
+}
+
+// FileCatalog is a Catalog which keeps its state in memory and persists every change to an append-only
+// write-ahead log in a local directory. The log is compacted into a snapshot periodically.
+type FileCatalog struct {
+	config   FileCatalogConfig
+	memory   *MemoryCatalog
+	wal      walFile
+	sequence uint64
+	entries  int
+	dirty    bool
+	closed   bool
+	chStop   chan struct{}
+	chDone   chan struct{}
+	mux      *sync.Mutex
+}
+
+func (c *FileCatalog) Add(job Job) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.memory.Exists(job.Uuid) {
+		return ErrExist.WithJob(job.Uuid, job.Name)
+	}
+	if err := c.memory.lockedCheckName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
+	job.Revision = 1
+	return c.store(job)
+}
+
+func (c *FileCatalog) All() []Job {
+	return c.memory.All()
+}
+
+func (c *FileCatalog) AvailableJobs() []Job {
+	return c.memory.AvailableJobs()
+}
+
+// Claim makes instance the owner of a job. Membership and ownership are kept in memory only, they expire anyway
+// when the process which holds them is gone.
+func (c *FileCatalog) Claim(jobId uuid.UUID, instance string) error {
+	return c.memory.Claim(jobId, instance)
+}
+
+// Close writes a final snapshot and releases the underlying files, the catalog cannot be used afterward.
+func (c *FileCatalog) Close() error {
+	c.mux.Lock()
+	if c.closed {
+		c.mux.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mux.Unlock()
+
+	close(c.chStop)
+	<-c.chDone
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	err := c.snapshot()
+	if closeErr := c.wal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *FileCatalog) Count() int {
+	return c.memory.Count()
+}
+
+func (c *FileCatalog) Delete(jobId uuid.UUID) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if !c.memory.Exists(jobId) {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	return c.delete(jobId)
+}
+
+func (c *FileCatalog) DeleteByName(namespace string, name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.GetByName(namespace, name)
+	if err != nil {
+		return err
+	}
+	return c.delete(job.Uuid)
+}
+
+// delete removes an existing job
+func (c *FileCatalog) delete(jobId uuid.UUID) error {
+	if err := c.append(walEntry{Operation: walDelete, JobId: jobId}); err != nil {
+		return err
+	}
+	if err := c.memory.Delete(jobId); err != nil {
+		return err
+	}
+	return c.compact()
+}
+
+func (c *FileCatalog) Disable(jobId uuid.UUID) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.Get(jobId)
+	if err != nil {
+		return err
+	}
+	job.Disable()
+	job.Revision++
+	return c.store(job)
+}
+
+func (c *FileCatalog) Enable(jobId uuid.UUID) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.Get(jobId)
+	if err != nil {
+		return err
+	}
+	job.Enable()
+	job.Revision++
+	return c.store(job)
+}
+
+func (c *FileCatalog) Exists(id uuid.UUID) bool {
+	return c.memory.Exists(id)
+}
+
+func (c *FileCatalog) Find(query Query) (JobPage, error) {
+	return c.memory.Find(query)
+}
+
+func (c *FileCatalog) Get(id uuid.UUID) (Job, error) {
+	return c.memory.Get(id)
+}
+
+func (c *FileCatalog) GetByName(namespace string, name string) (Job, error) {
+	return c.memory.GetByName(namespace, name)
+}
+
+func (c *FileCatalog) GetJobsByStatus(status Status) []Job {
+	return c.memory.GetJobsByStatus(status)
+}
+
+func (c *FileCatalog) HasEnabledJobs() bool {
+	return c.memory.HasEnabledJobs()
+}
+
+func (c *FileCatalog) InactiveJobs() []Job {
+	return c.memory.InactiveJobs()
+}
+
+func (c *FileCatalog) Join(instance string, ttl time.Duration) error {
+	return c.memory.Join(instance, ttl)
+}
+
+func (c *FileCatalog) Leave(instance string) error {
+	return c.memory.Leave(instance)
+}
+
+func (c *FileCatalog) Members() ([]string, error) {
+	return c.memory.Members()
+}
+
+func (c *FileCatalog) Overview() []Job {
+	return c.memory.Overview()
+}
+
+func (c *FileCatalog) Owners() (map[uuid.UUID]string, error) {
+	return c.memory.Owners()
+}
+
+func (c *FileCatalog) PendingJobs() []Job {
+	return c.memory.PendingJobs()
+}
+
+func (c *FileCatalog) PruneHistory(jobId uuid.UUID, policy RetentionPolicy, now time.Time) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.Get(jobId)
+	if err != nil || policy.IsZero() {
+		return err
+	}
+	job.PruneHistory(policy, now)
+	job.Revision++
+	return c.store(job)
+}
+
+func (c *FileCatalog) Release(jobId uuid.UUID, instance string) error {
+	return c.memory.Release(jobId, instance)
+}
+
+func (c *FileCatalog) Rename(jobId uuid.UUID, namespace string, name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.Get(jobId)
+	if err != nil {
+		return err
+	}
+	if err = c.memory.lockedCheckName(jobId, namespace, name); err != nil {
+		return err
+	}
+	job.Namespace = namespace
+	job.Name = name
+	job.Revision++
+	return c.store(job)
+}
+
+func (c *FileCatalog) RunnableJobs() []Job {
+	return c.memory.RunnableJobs()
+}
+
+func (c *FileCatalog) SchedulableJobs() []Job {
+	return c.memory.SchedulableJobs()
+}
+
+// Snapshot writes the current state to a snapshot and truncates the write-ahead log.
+func (c *FileCatalog) Snapshot() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.snapshot()
+}
+
+func (c *FileCatalog) Transition(jobId uuid.UUID, from Status, to Status) error {
+	if err := checkTransition(jobId, from, to); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, err := c.memory.Get(jobId)
+	if err != nil {
+		return err
+	}
+	if job.Status != from {
+		return ErrConflict.WithJob(jobId, job.Name)
+	}
+	job.SetStatus(to)
+	job.Revision++
+	return c.store(job)
+}
+
+func (c *FileCatalog) Update(job Job) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	stored, err := c.memory.Get(job.Uuid)
+	if err != nil {
+		return err
+	}
+	if err = c.memory.lockedCheckName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
+	job.Revision = stored.Revision + 1
+	return c.store(job)
+}
+
+func (c *FileCatalog) UpdateIf(job Job, expectedRevision uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	stored, err := c.memory.Get(job.Uuid)
+	if err != nil {
+		return err
+	}
+	if stored.Revision != expectedRevision {
+		return ErrConflict.WithJob(job.Uuid, job.Name)
+	}
+	if err = c.memory.lockedCheckName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
+	job.Revision = expectedRevision + 1
+	return c.store(job)
+}
+
+// append writes an entry to the write-ahead log, the caller must hold the lock
+func (c *FileCatalog) append(e walEntry) error {
+	if c.closed {
+		return os.ErrClosed
+	}
+
+	e.Sequence = c.sequence + 1
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// A failed write or sync must not leave a partial entry in the middle of the log
+	var offset int64
+	if offset, err = c.wal.Seek(0, io.SeekCurrent); err != nil {
+		return err
+	}
+	if _, err = c.wal.Write(frame(payload)); err != nil {
+		return errors.Join(err, c.rewind(offset))
+	}
+	c.dirty = true
+
+	if c.config.SyncPolicy == SyncAlways {
+		if err = c.sync(); err != nil {
+			return errors.Join(err, c.rewind(offset))
+		}
+	}
+	c.sequence = e.Sequence
+	c.entries++
+	return nil
+}
+
+// rewind discards everything in the log after offset, the caller must hold the lock
+func (c *FileCatalog) rewind(offset int64) error {
+	if err := c.wal.Truncate(offset); err != nil {
+		return err
+	}
+	_, err := c.wal.Seek(offset, io.SeekStart)
+	return err
+}
+
+// compact writes a snapshot once the log has grown beyond the configured threshold, the caller must hold the lock
+func (c *FileCatalog) compact() error {
+	if c.config.SnapshotThreshold > 0 && c.entries >= c.config.SnapshotThreshold {
+		return c.snapshot()
+	}
+	return nil
+}
+
+func (c *FileCatalog) maintain() {
+	defer close(c.chDone)
+
+	var chSync, chSnapshot <-chan time.Time
+	if c.config.SyncPolicy == SyncInterval && c.config.SyncInterval > 0 {
+		t := time.NewTicker(c.config.SyncInterval)
+		defer t.Stop()
+		chSync = t.C
+	}
+	if c.config.SnapshotInterval > 0 {
+		t := time.NewTicker(c.config.SnapshotInterval)
+		defer t.Stop()
+		chSnapshot = t.C
+	}
+
+	for {
+		select {
+		case <-c.chStop:
+			return
+		case <-chSync:
+			c.mux.Lock()
+			_ = c.sync()
+			c.mux.Unlock()
+		case <-chSnapshot:
+			c.mux.Lock()
+			if c.entries > 0 {
+				_ = c.snapshot()
+			}
+			c.mux.Unlock()
+		}
+	}
+}
+
+// store writes the full state of a job to the write-ahead log before applying it, the caller must hold the lock
+func (c *FileCatalog) store(job Job) error {
+	r, err := newJobRecord(job)
+	if err != nil {
+		return err
+	}
+	if err = c.append(walEntry{Operation: walPut, JobId: job.Uuid, Job: &r}); err != nil {
+		return err
+	}
+	c.memory.store(job)
+	return c.compact()
+}
+
+func (c *FileCatalog) recover() error {
+	var (
+		err  error
+		snap snapshot
+	)
+
+	if snap, err = c.readSnapshot(); err != nil {
+		return err
+	}
+	for _, r := range snap.Jobs {
+		var job Job
+		if job, err = r.job(); err != nil {
+			return err
+		}
+		c.memory.store(job)
+	}
+	c.sequence = snap.Sequence
+
+	var wal *os.File
+	if wal, err = os.OpenFile(filepath.Join(c.config.Directory, fileCatalogWal), os.O_RDWR|os.O_CREATE, 0o640); err != nil {
+		return err
+	}
+	c.wal = wal
+
+	var valid int64
+	if valid, err = c.replay(); err == nil {
+		// Discard a partially written entry at the end of the log
+		err = c.rewind(valid)
+	}
+	if err != nil {
+		return errors.Join(err, c.wal.Close())
+	}
+	return nil
+}
+
+func (c *FileCatalog) readSnapshot() (snapshot, error) {
+	var snap snapshot
+
+	data, err := os.ReadFile(filepath.Join(c.config.Directory, fileCatalogSnapshot))
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, nil
+	}
+	if err != nil {
+		return snap, err
+	}
+
+	payload, n := unframe(data)
+	if n == 0 {
+		return snap, fmt.Errorf("snapshot %s is corrupt", fileCatalogSnapshot)
+	}
+	err = json.Unmarshal(payload, &snap)
+	return snap, err
+}
+
+// replay applies all valid log entries newer than the snapshot and returns the size of the valid part of the log.
+// An entry which is cut off or fails its checksum at the end of the log was being written during a crash, the log is
+// valid up to that entry. An invalid entry followed by other data means the log is corrupt.
+func (c *FileCatalog) replay() (int64, error) {
+	var (
+		err   error
+		valid int64
+	)
+
+	r := bufio.NewReader(c.wal)
+	for {
+		header := make([]byte, fileCatalogHeaderSize)
+		if _, err = io.ReadFull(r, header); err != nil {
+			return valid, nil
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err = io.ReadFull(r, payload); err != nil {
+			return valid, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			if _, err = r.Peek(1); err != nil {
+				return valid, nil
+			}
+			return valid, fmt.Errorf("log %s is corrupt at offset %d", fileCatalogWal, valid)
+		}
+
+		var e walEntry
+		if err = json.Unmarshal(payload, &e); err != nil {
+			return valid, fmt.Errorf("log %s is corrupt at offset %d: %w", fileCatalogWal, valid, err)
+		}
+		valid += int64(fileCatalogHeaderSize + len(payload))
+
+		if e.Sequence <= c.sequence {
+			continue
+		}
+		if err = c.apply(e); err != nil {
+			return valid, err
+		}
+		c.sequence = e.Sequence
+		c.entries++
+	}
+}
+
+func (c *FileCatalog) apply(e walEntry) error {
+	switch e.Operation {
+	case walPut:
+		if e.Job == nil {
+			return fmt.Errorf("log entry %d has no job", e.Sequence)
+		}
+		job, err := e.Job.job()
+		if err != nil {
+			return err
+		}
+		c.memory.store(job)
+		return nil
+	case walDelete:
+		if !c.memory.Exists(e.JobId) {
+			return nil
+		}
+		return c.memory.Delete(e.JobId)
+	default:
+		return fmt.Errorf("log entry %d has unknown operation %d", e.Sequence, e.Operation)
+	}
+}
+
+// snapshot atomically replaces the snapshot with the current state and empties the log, the caller must hold the lock
+func (c *FileCatalog) snapshot() error {
+	var err error
+
+	snap := snapshot{
+		Sequence: c.sequence,
+		Jobs:     make([]jobRecord, 0),
+	}
+	for _, job := range c.memory.All() {
+		var r jobRecord
+		if r, err = newJobRecord(job); err != nil {
+			return err
+		}
+		snap.Jobs = append(snap.Jobs, r)
+	}
+
+	var payload []byte
+	if payload, err = json.Marshal(snap); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(c.config.Directory, fileCatalogSnapshotTmp)
+	if err = writeFileSync(tmp, frame(payload)); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, filepath.Join(c.config.Directory, fileCatalogSnapshot)); err != nil {
+		return err
+	}
+	if err = syncDirectory(c.config.Directory); err != nil {
+		return err
+	}
+
+	// All entries are part of the snapshot, a crash before truncating the log is handled by the sequence numbers
+	if err = c.rewind(0); err != nil {
+		return err
+	}
+	c.entries = 0
+	return c.sync()
+}
+
+// sync flushes the log to stable storage, the caller must hold the lock
+func (c *FileCatalog) sync() error {
+	if !c.dirty || c.config.SyncPolicy == SyncNever {
+		c.dirty = false
+		return nil
+	}
+	c.dirty = false
+	return c.wal.Sync()
+}
+
+// frame prefixes a payload with its length and checksum
+func frame(payload []byte) []byte {
+	var b bytes.Buffer
+	header := make([]byte, fileCatalogHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload))
+	b.Write(header)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// unframe returns the payload of a framed record and the number of bytes consumed, or 0 if the record is invalid
+func unframe(data []byte) ([]byte, int) {
+	if len(data) < fileCatalogHeaderSize {
+		return nil, 0
+	}
+	size := int(binary.BigEndian.Uint32(data[0:4]))
+	if len(data) < fileCatalogHeaderSize+size {
+		return nil, 0
+	}
+	payload := data[fileCatalogHeaderSize : fileCatalogHeaderSize+size]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, 0
+	}
+	return payload, fileCatalogHeaderSize + size
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDirectory(name string) error {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Not all platforms support syncing a directory, the rename itself has been issued at this point
+	_ = d.Sync()
+	return nil
+}
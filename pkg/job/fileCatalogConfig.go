@@ -0,0 +1,58 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"time"
+)
+
+const (
+	DefaultSnapshotThreshold = 10000
+	DefaultSyncInterval      = time.Second
+)
+
+type SyncPolicy int
+
+func (p SyncPolicy) String() string {
+	return [...]string{"always", "interval", "never"}[p]
+}
+
+const (
+	// SyncAlways flushes the write-ahead log to stable storage after every change
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the write-ahead log every SyncInterval, changes made in between can be lost on a crash
+	SyncInterval
+	// SyncNever leaves flushing the write-ahead log to the operating system
+	SyncNever
+)
+
+func NewFileCatalogConfig(directory string) FileCatalogConfig {
+	return FileCatalogConfig{
+		Directory:         directory,
+		SyncPolicy:        SyncAlways,
+		SyncInterval:      DefaultSyncInterval,
+		SnapshotThreshold: DefaultSnapshotThreshold,
+	}
+}
+
+type FileCatalogConfig struct {
+	Directory         string
+	SyncPolicy        SyncPolicy
+	SyncInterval      time.Duration // flush the log periodically with SyncInterval, it must be positive then
+	SnapshotInterval  time.Duration // write a snapshot periodically, 0 disables periodic snapshots
+	SnapshotThreshold int           // write a snapshot after this many log entries, 0 disables the threshold
+}
@@ -0,0 +1,353 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func newTestFileCatalog(t *testing.T, config FileCatalogConfig) *FileCatalog {
+	t.Helper()
+
+	c, err := NewFileCatalog(config)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	return c
+}
+
+// failingWal fails the next write after writing half of it, or the next sync
+type failingWal struct {
+	walFile
+	failWrite bool
+	failSync  bool
+}
+
+func (w *failingWal) Write(p []byte) (int, error) {
+	if w.failWrite {
+		w.failWrite = false
+		n, _ := w.walFile.Write(p[:len(p)/2])
+		return n, errors.New("write failed")
+	}
+	return w.walFile.Write(p)
+}
+
+func (w *failingWal) Sync() error {
+	if w.failSync {
+		w.failSync = false
+		return errors.New("sync failed")
+	}
+	return w.walFile.Sync()
+}
+
+func TestNewFileCatalog_SyncInterval(t *testing.T) {
+	config := NewFileCatalogConfig(t.TempDir())
+	config.SyncPolicy = SyncInterval
+	config.SyncInterval = 0
+	if _, err := NewFileCatalog(config); err == nil {
+		t.Errorf("got no error, expected a sync interval of 0 to be rejected")
+	}
+
+	config.SyncInterval = NewFileCatalogConfig("").SyncInterval
+	c := newTestFileCatalog(t, config)
+	if err := c.Close(); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+}
+
+func TestFileCatalog_Recover(t *testing.T) {
+	var tests = []struct {
+		name   string
+		config func(dir string) FileCatalogConfig
+	}{
+		{"SyncAlways", func(dir string) FileCatalogConfig {
+			return FileCatalogConfig{Directory: dir, SyncPolicy: SyncAlways}
+		}},
+		{"SyncInterval", func(dir string) FileCatalogConfig {
+			return FileCatalogConfig{Directory: dir, SyncPolicy: SyncInterval, SyncInterval: time.Millisecond}
+		}},
+		{"SyncNever", func(dir string) FileCatalogConfig {
+			return FileCatalogConfig{Directory: dir, SyncPolicy: SyncNever}
+		}},
+		{"SnapshotThreshold", func(dir string) FileCatalogConfig {
+			return FileCatalogConfig{Directory: dir, SyncPolicy: SyncAlways, SnapshotThreshold: 2}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := newTestFileCatalog(t, tt.config(dir))
+
+			kept := newTestJob(t, "kept")
+			deleted := newTestJob(t, "deleted")
+			for _, j := range []Job{kept, deleted} {
+				if err := c.Add(j); err != nil {
+					t.Fatalf("got error: %s", err.Error())
+				}
+			}
+
+			kept.SetStatus(StatusCompleted)
+			kept.AddResult(Result{
+				Start:    time.Now(),
+				Status:   StatusCompleted,
+				Messages: []task.Message{task.NewErrorMessage("failed", task.EmptyTask{}, errors.New("boom"))},
+				Tasks:    []task.Task{task.EmptyTask{}.SetStatus(task.StatusCompleted)},
+			})
+			if err := c.Update(kept); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if err := c.Disable(kept.Uuid); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if err := c.Delete(deleted.Uuid); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+
+			// Simulate a crash by abandoning the catalog without closing it
+			r := newTestFileCatalog(t, tt.config(dir))
+			defer r.Close()
+
+			if r.Exists(deleted.Uuid) {
+				t.Errorf("deleted job exists after recovery")
+			}
+			result, err := r.Get(kept.Uuid)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if result.IsEnabled() || result.Status != StatusCompleted || result.CountRuns() != 1 {
+				t.Errorf("got enabled %t, status %s and %d runs, expected disabled, %s and 1 run", result.IsEnabled(), result.Status, result.CountRuns(), StatusCompleted)
+			}
+
+			current := result.CurrentResult()
+			if len(current.Tasks) != 1 || current.Tasks[0].Status() != task.StatusCompleted {
+				t.Errorf("got tasks %v, expected one completed task", current.Tasks)
+			}
+			if len(current.Messages) != 1 || current.Messages[0].Data.(error).Error() != "boom" {
+				t.Errorf("got messages %v, expected error message", current.Messages)
+			}
+			if result.Tasks.Count() != 2 || result.Tasks.All()[1].(task.SleepTask).Milliseconds != 10 {
+				t.Errorf("got tasks %v, expected task definitions to be restored", result.Tasks.All())
+			}
+		})
+	}
+}
+
+func TestFileCatalog_RecoverTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+
+	j := newTestJob(t, "test")
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Append half of a valid entry, as if the process crashed during a write
+	f, err := os.OpenFile(filepath.Join(dir, fileCatalogWal), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := frame([]byte(`{"sequence":99,"operation":1}`))
+	if _, err = f.Write(entry[:len(entry)/2]); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	r := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+	if !r.Exists(j.Uuid) {
+		t.Fatalf("job does not exist after recovery")
+	}
+
+	// New entries must be appended after the last valid entry
+	other := newTestJob(t, "other")
+	if err = r.Add(other); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	r = newTestFileCatalog(t, NewFileCatalogConfig(dir))
+	defer r.Close()
+	if r.Count() != 2 {
+		t.Errorf("got %d jobs, expected 2", r.Count())
+	}
+}
+
+func TestFileCatalog_RecoverTornChecksum(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+
+	j := newTestJob(t, "test")
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Append a complete entry with a damaged payload, as if the process crashed before the write reached the disk
+	name := filepath.Join(dir, fileCatalogWal)
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := info.Size()
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := frame([]byte(`{"sequence":99,"operation":1}`))
+	entry[len(entry)-2] ^= 0xff
+	if _, err = f.Write(entry); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	r := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+	defer r.Close()
+	if r.Count() != 1 || !r.Exists(j.Uuid) {
+		t.Errorf("got %d jobs, expected the job written before the damaged entry", r.Count())
+	}
+
+	if info, err = os.Stat(name); err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != size {
+		t.Errorf("got log size %d, expected %d", info.Size(), size)
+	}
+}
+
+func TestFileCatalog_RecoverCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+
+	for _, name := range []string{"first", "second"} {
+		if err := c.Add(newTestJob(t, name)); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+
+	// Damage the payload of the first entry, the entry after it cannot be trusted to follow a valid log
+	name := filepath.Join(dir, fileCatalogWal)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[fileCatalogHeaderSize+1] ^= 0xff
+	if err = os.WriteFile(name, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewFileCatalog(NewFileCatalogConfig(dir)); err == nil {
+		t.Fatalf("got no error, expected the corrupt log to be reported")
+	}
+
+	// The log must not have been truncated
+	var info os.FileInfo
+	if info, err = os.Stat(name); err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("got log size %d, expected %d", info.Size(), len(data))
+	}
+}
+
+func TestFileCatalog_AppendFailed(t *testing.T) {
+	var tests = []struct {
+		name string
+		wal  failingWal
+	}{
+		{name: "write", wal: failingWal{failWrite: true}},
+		{name: "sync", wal: failingWal{failSync: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+			defer c.Close()
+			first := newTestJob(t, "first")
+			if err := c.Add(first); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+
+			wal := tt.wal
+			wal.walFile = c.wal
+			c.wal = &wal
+
+			failed := newTestJob(t, "failed")
+			if err := c.Add(failed); err == nil {
+				t.Fatalf("got no error, expected the failed %s to be reported", tt.name)
+			}
+			if c.Exists(failed.Uuid) {
+				t.Errorf("job exists after a failed %s", tt.name)
+			}
+
+			// The entry after the failed one must follow the last valid entry
+			other := newTestJob(t, "other")
+			if err := c.Add(other); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+
+			// Recover from the log, closing the catalog would write a snapshot
+			r := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+			defer r.Close()
+			if r.Exists(failed.Uuid) || !r.Exists(first.Uuid) || !r.Exists(other.Uuid) {
+				t.Errorf("got %d jobs after recovery, expected the jobs added around the failed %s", r.Count(), tt.name)
+			}
+		})
+	}
+}
+
+func TestFileCatalog_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+
+	j := newTestJob(t, "test")
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := c.Snapshot(); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	info, err := os.Stat(filepath.Join(dir, fileCatalogWal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("log has %d bytes after snapshot, expected 0", info.Size())
+	}
+
+	if err = c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err = c.Close(); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	r := newTestFileCatalog(t, NewFileCatalogConfig(dir))
+	defer r.Close()
+	result, err := r.Get(j.Uuid)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result.IsEnabled() {
+		t.Errorf("job is enabled, expected disabled")
+	}
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	fileHistoryLog    = "history.log"
+	fileHistoryLogTmp = "history.log.tmp"
+)
+
+type FileHistoryStoreConfig struct {
+	Directory string
+	Sync      bool // flush every record to stable storage before Append returns
+}
+
+// NewFileHistoryStore returns a history store which keeps its records in memory and appends them to a log in a local
+// directory. The directory can be shared with a FileCatalog.
+func NewFileHistoryStore(config FileHistoryStoreConfig) (*FileHistoryStore, error) {
+	if err := os.MkdirAll(config.Directory, 0o750); err != nil {
+		return nil, err
+	}
+
+	s := &FileHistoryStore{
+		config: config,
+		memory: NewMemoryHistoryStore(),
+		mux:    &sync.Mutex{},
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FileHistoryStore is a HistoryStore which persists every record to an append-only log, the log is rewritten when
+// records are pruned.
+type FileHistoryStore struct {
+	config FileHistoryStoreConfig
+	memory *MemoryHistoryStore
+	log    *os.File
+	closed bool
+	mux    *sync.Mutex
+}
+
+type historyEntry struct {
+	JobId   uuid.UUID    `json:"jobId"`
+	JobName string       `json:"jobName"`
+	Run     int          `json:"run"`
+	Result  resultRecord `json:"result"`
+}
+
+func (s *FileHistoryStore) Append(record HistoryRecord) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return os.ErrClosed
+	}
+
+	payload, err := encodeHistoryRecord(record)
+	if err != nil {
+		return err
+	}
+	if _, err = s.log.Write(frame(payload)); err != nil {
+		return err
+	}
+	if s.config.Sync {
+		if err = s.log.Sync(); err != nil {
+			return err
+		}
+	}
+	return s.memory.Append(record)
+}
+
+// Close releases the log, the store cannot be used afterward
+func (s *FileHistoryStore) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.log.Close()
+}
+
+func (s *FileHistoryStore) Count() int {
+	return s.memory.Count()
+}
+
+// Prune removes the records which are not kept by policy and rewrites the log with the remaining records
+func (s *FileHistoryStore) Prune(policy RetentionPolicy, now time.Time) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+
+	pruned, err := s.memory.Prune(policy, now)
+	if err != nil || pruned == 0 {
+		return pruned, err
+	}
+	return pruned, s.rewrite()
+}
+
+func (s *FileHistoryStore) Query(query HistoryQuery) (HistoryPage, error) {
+	return s.memory.Query(query)
+}
+
+// recover reads the records in the log. A record which is cut off or fails its checksum at the end of the log was
+// being written during a crash and is discarded, an invalid record followed by other data means the log is corrupt.
+func (s *FileHistoryStore) recover() error {
+	name := filepath.Join(s.config.Directory, fileHistoryLog)
+	data, err := os.ReadFile(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	valid := 0
+	for valid < len(data) {
+		payload, n := unframe(data[valid:])
+		if n == 0 {
+			rest := data[valid:]
+			if len(rest) >= fileCatalogHeaderSize && fileCatalogHeaderSize+int(binary.BigEndian.Uint32(rest[0:4])) < len(rest) {
+				return fmt.Errorf("log %s is corrupt at offset %d", fileHistoryLog, valid)
+			}
+			break
+		}
+
+		var record HistoryRecord
+		if record, err = decodeHistoryRecord(payload); err != nil {
+			return fmt.Errorf("log %s is corrupt at offset %d: %w", fileHistoryLog, valid, err)
+		}
+		if err = s.memory.Append(record); err != nil {
+			return err
+		}
+		valid += n
+	}
+
+	if s.log, err = os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0o640); err != nil {
+		return err
+	}
+	// Discard a partially written record at the end of the log
+	if err = s.log.Truncate(int64(valid)); err == nil {
+		_, err = s.log.Seek(int64(valid), io.SeekStart)
+	}
+	if err != nil {
+		return errors.Join(err, s.log.Close())
+	}
+	return nil
+}
+
+// rewrite atomically replaces the log with the records in memory, the caller must hold the lock
+func (s *FileHistoryStore) rewrite() error {
+	page, err := s.memory.Query(HistoryQuery{})
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	for _, record := range page.Records {
+		var payload []byte
+		if payload, err = encodeHistoryRecord(record); err != nil {
+			return err
+		}
+		b.Write(frame(payload))
+	}
+
+	tmp := filepath.Join(s.config.Directory, fileHistoryLogTmp)
+	if err = writeFileSync(tmp, b.Bytes()); err != nil {
+		return err
+	}
+	name := filepath.Join(s.config.Directory, fileHistoryLog)
+	if err = os.Rename(tmp, name); err != nil {
+		return err
+	}
+	if err = syncDirectory(s.config.Directory); err != nil {
+		return err
+	}
+
+	var log *os.File
+	if log, err = os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+		return err
+	}
+	err = s.log.Close()
+	s.log = log
+	return err
+}
+
+func encodeHistoryRecord(record HistoryRecord) ([]byte, error) {
+	rr, err := newResultRecord(record.Result)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(historyEntry{JobId: record.JobId, JobName: record.JobName, Run: record.Run, Result: rr})
+}
+
+func decodeHistoryRecord(payload []byte) (HistoryRecord, error) {
+	var e historyEntry
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return HistoryRecord{}, err
+	}
+
+	result, err := e.Result.result()
+	if err != nil {
+		return HistoryRecord{}, err
+	}
+	return HistoryRecord{JobId: e.JobId, JobName: e.JobName, Run: e.Run, Result: result}, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestFileHistoryStore(t *testing.T, dir string) *FileHistoryStore {
+	t.Helper()
+
+	s, err := NewFileHistoryStore(FileHistoryStoreConfig{Directory: dir, Sync: true})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+func TestFileHistoryStore_Query(t *testing.T) {
+	testHistoryStoreQuery(t, newTestFileHistoryStore(t, t.TempDir()))
+}
+
+func TestFileHistoryStore_Prune(t *testing.T) {
+	testHistoryStorePrune(t, newTestFileHistoryStore(t, t.TempDir()))
+}
+
+func TestFileHistoryStore_Recover(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	first, second := uuid.New(), uuid.New()
+
+	s := newTestFileHistoryStore(t, dir)
+	appendTestHistory(t, s, start, first, second)
+	if _, err := s.Prune(RetentionPolicy{KeepLast: 2}, start.Add(time.Hour)); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := s.Append(HistoryRecord{JobId: first, Run: 6, Result: Result{Start: start.Add(6 * time.Minute), Status: StatusActive}}); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Append half of a record, as if the process crashed during a write
+	name := filepath.Join(dir, fileHistoryLog)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := frame([]byte(`{"run":7}`))
+	if _, err = f.Write(entry[:len(entry)/2]); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	r := newTestFileHistoryStore(t, dir)
+	if r.Count() != 5 {
+		t.Errorf("got %d records, expected %d", r.Count(), 5)
+	}
+	page, err := r.Query(HistoryQuery{JobId: first})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(page.Records) != 3 || page.Records[0].Run != 6 || page.Records[0].Result.Status != StatusActive {
+		t.Errorf("got records %v, expected runs 6, 5 and 4", page.Records)
+	}
+}
+
+func TestFileHistoryStore_RecoverCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	appendTestHistory(t, newTestFileHistoryStore(t, dir), time.Now(), uuid.New())
+
+	// Damage the payload of the first record, the records after it cannot be trusted
+	name := filepath.Join(dir, fileHistoryLog)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[fileCatalogHeaderSize+1] ^= 0xff
+	if err = os.WriteFile(name, data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewFileHistoryStore(FileHistoryStoreConfig{Directory: dir}); err == nil {
+		t.Errorf("got no error, expected the corrupt log to be reported")
+	}
+}
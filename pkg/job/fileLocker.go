@@ -0,0 +1,85 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NewFileLocker returns a locker for instances on a single host, which holds a lock file in directory for every lock.
+// Locks are released by the operating system when the process exits, so they do not need to be renewed.
+func NewFileLocker(directory string) *FileLocker {
+	return &FileLocker{
+		directory: directory,
+	}
+}
+
+type FileLocker struct {
+	directory string
+}
+
+func (l *FileLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(l.directory, strings.ReplaceAll(name, "/", "_")+".lock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err = tryLockFile(f); err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+
+	// The fencing token is kept in the lock file and incremented by every owner of the lock
+	var token uint64
+	if err = binary.Read(f, binary.BigEndian, &token); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Join(err, f.Close())
+	}
+	token++
+	if _, err = f.WriteAt(binary.BigEndian.AppendUint64(nil, token), 0); err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return &fileLock{
+		file:  f,
+		token: token,
+	}, nil
+}
+
+type fileLock struct {
+	file  *os.File
+	token uint64
+}
+
+func (l *fileLock) Token() uint64 {
+	return l.token
+}
+
+func (l *fileLock) Renew(ctx context.Context) error {
+	return nil
+}
+
+func (l *fileLock) Release(ctx context.Context) error {
+	// Closing the file releases the lock
+	return l.file.Close()
+}
@@ -0,0 +1,60 @@
+//go:build unix
+
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFileLocker_TryLock(t *testing.T) {
+	directory := t.TempDir()
+	first, second := NewFileLocker(directory), NewFileLocker(directory)
+
+	lock, err := first.TryLock(context.Background(), "job/test")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if _, err = second.TryLock(context.Background(), "job/test"); !errors.Is(err, ErrLocked) {
+		t.Errorf("got error %v, expected ErrLocked", err)
+	}
+
+	// Locks with another name are independent
+	other, err := second.TryLock(context.Background(), "job/other")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err = other.Release(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	if err = lock.Release(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	next, err := second.TryLock(context.Background(), "job/test")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer next.Release(context.Background())
+
+	if next.Token() <= lock.Token() {
+		t.Errorf("got fencing token %d after %d, expected it to increase", next.Token(), lock.Token())
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HistoryStore keeps the results of finished runs separately from the catalog, so the history in the catalog can be
+// kept short while older results remain available.
+type HistoryStore interface {
+	// Append stores a record, a record for the same run of a job replaces the existing one
+	Append(record HistoryRecord) error
+	// Query returns the records matching query, ordered from newest to oldest
+	Query(query HistoryQuery) (HistoryPage, error)
+	// Prune removes the records of every job which are not kept by policy at time now and returns the number of removed records
+	Prune(policy RetentionPolicy, now time.Time) (int, error)
+}
+
+type HistoryRecord struct {
+	JobId   uuid.UUID
+	JobName string
+	Run     int // sequence number of the run, starting at 1
+	Result  Result
+}
+
+// HistoryQuery selects records from a HistoryStore, zero values do not filter.
+// From and To select records by start of the run, From is inclusive and To is exclusive.
+// Limit is the maximum number of records in a page, Offset the number of matching records to skip.
+type HistoryQuery struct {
+	JobId  uuid.UUID
+	Status Status
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+// matches reports whether record r is selected by the filters of the query
+func (q HistoryQuery) matches(r HistoryRecord) bool {
+	switch {
+	case q.JobId != uuid.Nil && r.JobId != q.JobId:
+		return false
+	case q.Status != StatusNone && r.Result.Status != q.Status:
+		return false
+	case !q.From.IsZero() && r.Result.Start.Before(q.From):
+		return false
+	case !q.To.IsZero() && !r.Result.Start.Before(q.To):
+		return false
+	default:
+		return true
+	}
+}
+
+type HistoryPage struct {
+	Records []HistoryRecord
+	Total   int // number of records matching the query, regardless of pagination
+}
+
+// HasMore reports whether there are matching records after the page returned for query
+func (p HistoryPage) HasMore(query HistoryQuery) bool {
+	return query.Offset+len(p.Records) < p.Total
+}
@@ -40,15 +40,23 @@ func NewJob(name string, s cron.Schedule, maxRuns int, tasks Sequence) Job {
 }
 
 type Job struct {
-	Uuid     uuid.UUID
-	Name     string
-	Enabled  bool
-	Schedule cron.Schedule
-	MaxRuns  int
-	Status   Status
-	Tasks    Sequence
-	History  []Result
-	mux      *sync.Mutex
+	Uuid        uuid.UUID
+	Namespace   string // jobs with a namespace have a unique name within their namespace
+	Name        string
+	Labels      map[string]string // identifying metadata, used by selectors
+	Annotations map[string]string // descriptive metadata, such as owners or runbook links
+	Enabled     bool
+	Schedule    cron.Schedule
+	MaxRuns     int
+	Priority    int            // jobs with a higher priority are dispatched first, see PriorityClass
+	Concurrency map[string]int // the maximum number of jobs holding each concurrency key which run at the same time
+	Triggered   bool           // run the job as soon as possible, regardless of its schedule
+	Status      Status
+	Tasks       Sequence
+	History     []Result
+	Runs        int    // number of runs, including runs which were pruned from History
+	Revision    uint64 // incremented by the catalog on every stored change
+	mux         *sync.Mutex
 }
 
 func (j *Job) AddResult(r Result) {
@@ -56,12 +64,13 @@ func (j *Job) AddResult(r Result) {
 	defer j.mux.Unlock()
 
 	j.History = append(j.History, r)
+	j.Runs++
 }
 func (j *Job) CountRuns() int {
 	j.mux.Lock()
 	defer j.mux.Unlock()
 
-	return len(j.History)
+	return j.Runs
 }
 
 func (j *Job) CurrentResult() Result {
@@ -81,11 +90,21 @@ func (j *Job) Disable() {
 	j.Enabled = false
 }
 
+// Enable enables the job, a job which finished its last run while it was disabled is made inactive, so it is
+// scheduled again
 func (j *Job) Enable() {
 	j.mux.Lock()
 	defer j.mux.Unlock()
 
 	j.Enabled = true
+	if j.Status == StatusCompleted || j.Status == StatusError {
+		j.Status = StatusInactive
+	}
+}
+
+// QualifiedName returns the name of the job in the form namespace/name
+func (j *Job) QualifiedName() string {
+	return QualifiedName(j.Namespace, j.Name)
 }
 
 func (j *Job) IsActive() bool {
@@ -110,12 +129,19 @@ func (j *Job) IsEligible() bool {
 		return j.Enabled
 	}
 
-	if len(j.History) < j.MaxRuns {
+	if j.Runs < j.MaxRuns {
 		return j.Enabled
 	}
 	return false
 }
 
+func (j *Job) IsTriggered() bool {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.Triggered
+}
+
 func (j *Job) IsEnabled() bool {
 	j.mux.Lock()
 	defer j.mux.Unlock()
@@ -149,7 +175,44 @@ func (j *Job) IsSchedulable() bool {
 	j.mux.Lock()
 	defer j.mux.Unlock()
 
-	return j.Status == StatusSchedulable && j.Schedule.IsDue(time.Now())
+	return j.Status == StatusSchedulable && j.isDue(time.Now())
+}
+
+// IsDue reports whether the job is triggered, or whether its schedule has fired at or before now since the job last
+// started a run
+func (j *Job) IsDue(now time.Time) bool {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.isDue(now)
+}
+
+// NextRun returns the first time at or after the start of the current second of now at which the schedule fires.
+// A job is due at most once per second, a job which started a run in the current second is due at the next time the
+// schedule fires. The zero time is returned if the schedule does not fire again.
+func (j *Job) NextRun(now time.Time) time.Time {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.nextRun(now)
+}
+
+// isDue is IsDue for callers which hold j.mux
+func (j *Job) isDue(now time.Time) bool {
+	if j.Triggered {
+		return true
+	}
+	next := j.nextRun(now)
+	return !next.IsZero() && !next.After(now)
+}
+
+// nextRun is NextRun for callers which hold j.mux
+func (j *Job) nextRun(now time.Time) time.Time {
+	from := now.Truncate(time.Second).Add(-time.Nanosecond)
+	if n := len(j.History); n > 0 && j.History[n-1].Start.After(from) {
+		from = j.History[n-1].Start
+	}
+	return j.Schedule.Next(from)
 }
 
 func (j *Job) AllResults() []Result {
@@ -170,9 +233,93 @@ func (j *Job) UpdateResult(r Result) {
 	}
 }
 
+// PruneHistory removes the results which are not kept by policy at time now, the number of runs is not affected
+func (j *Job) PruneHistory(policy RetentionPolicy, now time.Time) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.History = policy.Apply(j.History, now)
+}
+
+// Trigger requests a run of the job as soon as possible, the request is cleared when the run starts.
+// A job which finished its last run while it was disabled is made inactive, so it runs once it is enabled.
+func (j *Job) Trigger() {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.Triggered = true
+	if j.Status == StatusCompleted || j.Status == StatusError {
+		j.Status = StatusInactive
+	}
+}
+
 func (j *Job) SetStatus(s Status) {
 	j.mux.Lock()
 	defer j.mux.Unlock()
 
 	j.Status = s
 }
+
+// clone returns a copy of the job which does not share any mutable state with the original
+func (j *Job) clone() Job {
+	if j.mux != nil {
+		j.mux.Lock()
+		defer j.mux.Unlock()
+	}
+	return j.cloneWith(j.History)
+}
+
+// cloneLast returns a copy of j which holds only the last result of its history
+func (j *Job) cloneLast() Job {
+	if j.mux != nil {
+		j.mux.Lock()
+		defer j.mux.Unlock()
+	}
+	return j.cloneWith(j.History[max(len(j.History)-1, 0):])
+}
+
+// cloneWith returns a copy of j with a copy of history as its history, j.mux must be held
+func (j *Job) cloneWith(history []Result) Job {
+	c := *j
+	c.Labels = cloneMap(j.Labels)
+	c.Annotations = cloneMap(j.Annotations)
+	c.Concurrency = cloneMap(j.Concurrency)
+	c.History = make([]Result, len(history))
+	copy(c.History, history)
+	c.Tasks = j.Tasks.clone()
+	c.mux = &sync.Mutex{}
+	return c
+}
+
+// withRunState returns latest with the state of the active run of j applied to it, the run state consists of the
+// status, the history, the number of runs and the execution state of the task sequence
+func (j *Job) withRunState(latest Job) Job {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	latest.Status = j.Status
+	latest.Runs = j.Runs
+	latest.History = make([]Result, len(j.History))
+	copy(latest.History, j.History)
+
+	if j.Tasks.mux != nil {
+		j.Tasks.mux.Lock()
+		defer j.Tasks.mux.Unlock()
+	}
+	latest.Tasks.executed = j.Tasks.executed
+	latest.Tasks.active = j.Tasks.active
+	latest.Tasks.activeIdx = j.Tasks.activeIdx
+	return latest
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,237 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// jobRecord is the serializable representation of a job used by persistent catalogs
+type jobRecord struct {
+	Uuid        uuid.UUID         `json:"uuid"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Enabled     bool              `json:"enabled"`
+	Schedule    string            `json:"schedule"`
+	MaxRuns     int               `json:"maxRuns"`
+	Priority    int               `json:"priority,omitempty"`
+	Concurrency map[string]int    `json:"concurrency,omitempty"`
+	Triggered   bool              `json:"triggered,omitempty"`
+	Status      Status            `json:"status"`
+	Tasks       []taskRecord      `json:"tasks"`
+	History     []resultRecord    `json:"history"`
+	Runs        int               `json:"runs"`
+	Revision    uint64            `json:"revision"`
+}
+
+type resultRecord struct {
+	Start    time.Time       `json:"start"`
+	Finish   time.Time       `json:"finish"`
+	Status   Status          `json:"status"`
+	Messages []messageRecord `json:"messages"`
+	Tasks    []taskRecord    `json:"tasks"`
+}
+
+type messageRecord struct {
+	Message string           `json:"message"`
+	Task    string           `json:"task"`
+	Type    task.MessageType `json:"type"`
+	Data    interface{}      `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type taskRecord struct {
+	Type   string          `json:"type"`
+	Status task.Status     `json:"status"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func newJobRecord(j Job) (jobRecord, error) {
+	var err error
+	r := jobRecord{
+		Uuid:        j.Uuid,
+		Namespace:   j.Namespace,
+		Name:        j.Name,
+		Labels:      j.Labels,
+		Annotations: j.Annotations,
+		Triggered:   j.IsTriggered(),
+		Enabled:     j.IsEnabled(),
+		Schedule:    j.Schedule.String(),
+		MaxRuns:     j.MaxRuns,
+		Priority:    j.Priority,
+		Concurrency: j.Concurrency,
+		Status:      j.Status,
+		Runs:        j.CountRuns(),
+		Revision:    j.Revision,
+	}
+
+	if r.Tasks, err = newTaskRecords(j.Tasks.Tasks); err != nil {
+		return jobRecord{}, err
+	}
+
+	for _, result := range j.AllResults() {
+		var rr resultRecord
+		if rr, err = newResultRecord(result); err != nil {
+			return jobRecord{}, err
+		}
+		r.History = append(r.History, rr)
+	}
+	return r, nil
+}
+
+func (r jobRecord) job() (Job, error) {
+	var (
+		err error
+		s   cron.Schedule
+	)
+
+	if r.Schedule != "" {
+		if s, err = cron.NewSchedule(r.Schedule); err != nil {
+			return Job{}, err
+		}
+	}
+
+	j := Job{
+		Uuid:        r.Uuid,
+		Namespace:   r.Namespace,
+		Name:        r.Name,
+		Labels:      r.Labels,
+		Annotations: r.Annotations,
+		Triggered:   r.Triggered,
+		Enabled:     r.Enabled,
+		Schedule:    s,
+		MaxRuns:     r.MaxRuns,
+		Priority:    r.Priority,
+		Concurrency: r.Concurrency,
+		Status:      r.Status,
+		History:     make([]Result, 0, len(r.History)),
+		Runs:        max(r.Runs, len(r.History)), // records written before runs were counted only have their history
+		Revision:    r.Revision,
+		mux:         &sync.Mutex{},
+	}
+
+	var tasks []task.Task
+	if tasks, err = taskRecords(r.Tasks); err != nil {
+		return Job{}, err
+	}
+	j.Tasks = NewSequence(tasks)
+
+	for _, rr := range r.History {
+		var result Result
+		if result, err = rr.result(); err != nil {
+			return Job{}, err
+		}
+		j.History = append(j.History, result)
+	}
+	return j, nil
+}
+
+func newResultRecord(r Result) (resultRecord, error) {
+	var err error
+	rr := resultRecord{
+		Start:  r.Start,
+		Finish: r.Finish,
+		Status: r.Status,
+	}
+
+	if rr.Tasks, err = newTaskRecords(r.Tasks); err != nil {
+		return resultRecord{}, err
+	}
+
+	for _, m := range r.Messages {
+		mr := messageRecord{
+			Message: m.Message,
+			Task:    m.Task,
+			Type:    m.Type,
+		}
+		// Errors do not survive serialization, only their message is kept
+		if err, ok := m.Data.(error); ok {
+			mr.Error = err.Error()
+		} else {
+			mr.Data = m.Data
+		}
+		rr.Messages = append(rr.Messages, mr)
+	}
+	return rr, nil
+}
+
+func (r resultRecord) result() (Result, error) {
+	var err error
+	result := Result{
+		Start:  r.Start,
+		Finish: r.Finish,
+		Status: r.Status,
+	}
+
+	if result.Tasks, err = taskRecords(r.Tasks); err != nil {
+		return Result{}, err
+	}
+
+	for _, mr := range r.Messages {
+		m := task.Message{
+			Message: mr.Message,
+			Task:    mr.Task,
+			Type:    mr.Type,
+			Data:    mr.Data,
+		}
+		if mr.Error != "" {
+			m.Data = errors.New(mr.Error)
+		}
+		result.Messages = append(result.Messages, m)
+	}
+	return result, nil
+}
+
+func newTaskRecords(tasks []task.Task) ([]taskRecord, error) {
+	records := make([]taskRecord, 0, len(tasks))
+	for _, t := range tasks {
+		e, err := task.DefaultRegistry.Encode(t)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, taskRecord{
+			Type:   e.Type,
+			Status: e.Status,
+			Data:   e.Params,
+		})
+	}
+	return records, nil
+}
+
+// taskRecords restores the tasks of records with task.DefaultRegistry, records stored before the built-in task types
+// had stable names are restored by the aliases of the built-in types
+func taskRecords(records []taskRecord) ([]task.Task, error) {
+	tasks := make([]task.Task, 0, len(records))
+	for _, r := range records {
+		t, err := task.DefaultRegistry.Decode(task.EncodedTask{Type: r.Type, Status: r.Status, Params: r.Data})
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func TestJobRecord_LegacyTaskTypes(t *testing.T) {
+	data := `{"name":"backup","schedule":"@hourly","status":1,"tasks":[` +
+		`{"type":"task.SleepTask","status":2,"data":{"Milliseconds":5}},` +
+		`{"type":"task.PrintTask","status":0,"data":{"Message":"done"}}]}`
+
+	var r jobRecord
+	if err := json.Unmarshal([]byte(data), &r); err != nil {
+		t.Fatal(err)
+	}
+	j, err := r.job()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []task.Task{task.SleepTask{Milliseconds: 5}.SetStatus(task.StatusCompleted), task.PrintTask{Message: "done"}}
+	if len(j.Tasks.Tasks) != 2 || j.Tasks.Tasks[0] != wanted[0] || j.Tasks.Tasks[1] != wanted[1] {
+		t.Fatalf("got tasks %#v, expected %#v", j.Tasks.Tasks, wanted)
+	}
+
+	// Tasks are stored by their stable names from now on
+	if r, err = newJobRecord(j); err != nil {
+		t.Fatal(err)
+	}
+	if r.Tasks[0].Type != "sleep" || r.Tasks[1].Type != "print" {
+		t.Errorf("got types %s and %s, expected sleep and print", r.Tasks[0].Type, r.Tasks[1].Type)
+	}
+}
@@ -16,6 +16,13 @@
 
 package job
 
+import (
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+)
+
 //
 // func TestJob_IsPending1(t *testing.T) {
 // 	j := Job{
@@ -38,3 +45,79 @@ package job
 // 		t.Errorf("job is %s, expected %s", j.Status, StatusPending)
 // 	}
 // }
+
+func TestJob_NextRun(t *testing.T) {
+	now := time.Date(2024, time.June, 15, 12, 0, 0, 500, time.UTC)
+	hourly, _ := cron.NewSchedule("@hourly")
+
+	tests := []struct {
+		name     string
+		schedule cron.Schedule
+		lastRun  time.Time
+		wanted   time.Time
+	}{
+		{"NotRun", newTestJob(t, "test").Schedule, time.Time{}, now.Truncate(time.Second)},
+		{"RunInCurrentSecond", newTestJob(t, "test").Schedule, now, now.Truncate(time.Second).Add(time.Second)},
+		{"RunBefore", newTestJob(t, "test").Schedule, now.Add(-time.Minute), now.Truncate(time.Second)},
+		{"Hourly", hourly, time.Time{}, now.Truncate(time.Second)},
+		{"HourlyRunInCurrentSecond", hourly, now, now.Truncate(time.Second).Add(time.Hour)},
+		{"NoSchedule", cron.Schedule{}, time.Time{}, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newTestJob(t, "test")
+			j.Schedule = tt.schedule
+			if !tt.lastRun.IsZero() {
+				j.AddResult(Result{Start: tt.lastRun, Status: StatusCompleted})
+			}
+
+			if next := j.NextRun(now); !next.Equal(tt.wanted) {
+				t.Errorf("got next run %s, expected %s", next, tt.wanted)
+			}
+			if due := j.IsDue(now); due != !tt.wanted.IsZero() && !tt.wanted.After(now) {
+				t.Errorf("got due %t, expected next run %s", due, tt.wanted)
+			}
+		})
+	}
+}
+
+func TestJob_IsSchedulable(t *testing.T) {
+	s, err := cron.NewSchedule("0 0 1 1 * 2099")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		status    Status
+		schedule  cron.Schedule
+		triggered bool
+		wanted    bool
+	}{
+		{"Due", StatusSchedulable, newTestJob(t, "test").Schedule, false, true},
+		{"NotDue", StatusSchedulable, s, false, false},
+		{"Triggered", StatusSchedulable, s, true, true},
+		{"NotSchedulable", StatusInactive, newTestJob(t, "test").Schedule, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newTestJob(t, "test")
+			j.Schedule = tt.schedule
+			j.Status = tt.status
+			j.Triggered = tt.triggered
+
+			result := make(chan bool, 1)
+			go func() { result <- j.IsSchedulable() }()
+			select {
+			case got := <-result:
+				if got != tt.wanted {
+					t.Errorf("got schedulable %t, expected %t", got, tt.wanted)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("IsSchedulable did not return")
+			}
+		})
+	}
+}
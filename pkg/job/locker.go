@@ -0,0 +1,117 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// FencingTokenKey is the key of the fencing token of the run lock in the data of the task pipeline. Tasks which write
+// to an external system can pass the token along, so the system can reject writes of a run which lost its lock.
+const FencingTokenKey = "fencingToken"
+
+// DefaultLockRenewInterval is used when OrchestratorConfig.LockRenewInterval is not set
+const DefaultLockRenewInterval = 10 * time.Second
+
+// Lock is a lock held by this instance
+type Lock interface {
+	// Token returns the fencing token of the lock, which is higher every time the lock is acquired
+	Token() uint64
+	// Renew extends the lease of the lock, it returns ErrLockLost if the lock is no longer held by this instance
+	Renew(ctx context.Context) error
+	// Release gives up the lock
+	Release(ctx context.Context) error
+}
+
+// Locker provides named locks which are shared between instances
+type Locker interface {
+	// TryLock acquires the lock with name without waiting, it returns ErrLocked if another instance holds the lock
+	TryLock(ctx context.Context, name string) (Lock, error)
+}
+
+// LeaderElector decides which of the instances sharing a catalog dispatches runs
+type LeaderElector interface {
+	// Leader reports whether this instance is the leader, and if so the fencing token of its term
+	Leader(ctx context.Context) (bool, uint64, error)
+}
+
+// NewLeaderElector returns a leader elector which holds the lock with name of locker while it is the leader.
+// The lock is renewed when Leader is called and renewInterval has passed since the last renewal, so renewInterval must
+// be shorter than the lease of the locker.
+func NewLeaderElector(locker Locker, name string, renewInterval time.Duration) *LockLeaderElector {
+	return &LockLeaderElector{
+		locker:        locker,
+		name:          name,
+		renewInterval: renewInterval,
+		mux:           &sync.Mutex{},
+	}
+}
+
+type LockLeaderElector struct {
+	locker        Locker
+	name          string
+	renewInterval time.Duration
+	lock          Lock
+	renewed       time.Time
+	mux           *sync.Mutex
+}
+
+func (e *LockLeaderElector) Leader(ctx context.Context) (bool, uint64, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.lock == nil {
+		lock, err := e.locker.TryLock(ctx, e.name)
+		switch {
+		case errors.Is(err, ErrLocked):
+			return false, 0, nil
+		case err != nil:
+			return false, 0, err
+		}
+		e.lock = lock
+		e.renewed = time.Now()
+		return true, lock.Token(), nil
+	}
+
+	if time.Since(e.renewed) >= e.renewInterval {
+		if err := e.lock.Renew(ctx); err != nil {
+			e.lock = nil
+			if errors.Is(err, ErrLockLost) {
+				return false, 0, nil
+			}
+			return false, 0, err
+		}
+		e.renewed = time.Now()
+	}
+	return true, e.lock.Token(), nil
+}
+
+// Resign gives up the leadership, if this instance is the leader
+func (e *LockLeaderElector) Resign(ctx context.Context) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.lock == nil {
+		return nil
+	}
+	lock := e.lock
+	e.lock = nil
+	return lock.Release(ctx)
+}
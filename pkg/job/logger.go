@@ -0,0 +1,105 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// discardLogger is used when OrchestratorConfig.Logger is not set, its handler is never enabled
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
+
+// LogJobAttr returns the attributes which identify job in structured logs
+func LogJobAttr(job Job) slog.Attr {
+	return slog.Group(
+		"job",
+		slog.String("id", job.Uuid.String()),
+		slog.String("name", job.Name),
+	)
+}
+
+// LogRunAttrs returns the attributes which identify a run of a job in structured logs. The run is the number of the
+// run of the job, the attempt counts how often the run was dispatched to a runner before it started.
+func LogRunAttrs(job Job, run int, attempt int) []slog.Attr {
+	return []slog.Attr{
+		LogJobAttr(job),
+		slog.Int("run", run),
+		slog.Int("attempt", attempt),
+	}
+}
+
+func (o *Orchestrator) logger() *slog.Logger {
+	if o.config.Logger == nil {
+		return discardLogger
+	}
+	return o.config.Logger
+}
+
+// logTransition logs that job moved from one status to another, reason explains transitions which are not part of the
+// normal job flow
+func (o *Orchestrator) logTransition(job Job, from Status, to Status, reason string) {
+	attrs := []slog.Attr{LogJobAttr(job), slog.String("from", from.String()), slog.String("to", to.String())}
+	if reason != "" {
+		attrs = append(attrs, slog.String("reason", reason))
+	}
+	o.logger().LogAttrs(context.Background(), slog.LevelDebug, "job transition", attrs...)
+}
+
+// logTask logs the outcome of a task of a run
+func (o *Orchestrator) logTask(run []slog.Attr, t task.Task) {
+	level := slog.LevelInfo
+	switch t.Status() {
+	case task.StatusError, task.StatusCanceled:
+		level = slog.LevelWarn
+	}
+	o.logger().LogAttrs(context.Background(), level, "task finished", append(run, task.LogTaskAttr(t))...)
+}
+
+// logRun logs the outcome of a run
+func (o *Orchestrator) logRun(run []slog.Attr, result Result) {
+	level := slog.LevelInfo
+	if result.Status == StatusError {
+		level = slog.LevelError
+	}
+	o.logger().LogAttrs(context.Background(), level, "job run finished",
+		append(run, slog.String("status", result.Status.String()), slog.Duration("duration", result.Finish.Sub(result.Start)))...)
+}
+
+// runLogger returns the logger for the tasks of a run, records are sent to intercom
+func runLogger(intercom *task.Intercom, run []slog.Attr) *slog.Logger {
+	args := make([]any, len(run))
+	for i, a := range run {
+		args[i] = a
+	}
+	return slog.New(task.NewIntercomHandler(intercom, nil)).With(args...)
+}
+
+// attempt counts a dispatch of the job with jobId and returns the number of times the pending run was dispatched
+func (o *Orchestrator) attempt(jobId uuid.UUID) int {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.attempts[jobId]++
+	return o.attempts[jobId]
+}
@@ -17,21 +17,37 @@
 package job
 
 import (
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 func NewMemoryCatalog() *MemoryCatalog {
 	return &MemoryCatalog{
-		jobs: make(map[uuid.UUID]Job, 0),
-		mux:  sync.Mutex{},
+		jobs:      make(map[uuid.UUID]Job, 0),
+		byStatus:  make(map[Status]idSet),
+		byEnabled: make(map[bool]idSet),
+		byLabel:   make(map[string]map[string]idSet),
+		byName:    make(map[string]uuid.UUID),
+		members:   make(map[string]time.Time),
+		owners:    make(map[uuid.UUID]string),
+		mux:       sync.Mutex{},
 	}
 }
 
 type MemoryCatalog struct {
 	jobs map[uuid.UUID]Job
-	mux  sync.Mutex
+	// Secondary indexes used by Find and the lists by status
+	byStatus  map[Status]idSet
+	byEnabled map[bool]idSet
+	byLabel   map[string]map[string]idSet
+	byName    map[string]uuid.UUID // jobs with a namespace by qualified name
+	members   map[string]time.Time // the expiry of the membership of every instance
+	owners    map[uuid.UUID]string // the instance which claimed a job
+	mux       sync.Mutex
 }
 
 func (c *MemoryCatalog) Add(job Job) error {
@@ -40,11 +56,16 @@ func (c *MemoryCatalog) Add(job Job) error {
 
 	for k := range c.jobs {
 		if job.Uuid == k {
-			return ErrExist
+			return ErrExist.WithJob(job.Uuid, job.Name)
 		}
 	}
+	if err := c.checkName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
 
-	c.jobs[job.Uuid] = job
+	job = job.clone()
+	job.Revision = 1
+	c.put(job)
 	return nil
 }
 
@@ -54,7 +75,7 @@ func (c *MemoryCatalog) All() []Job {
 
 	jobs := make([]Job, 0)
 	for _, job := range c.jobs {
-		jobs = append(jobs, job)
+		jobs = append(jobs, job.clone())
 	}
 	return jobs
 }
@@ -63,6 +84,17 @@ func (c *MemoryCatalog) AvailableJobs() []Job {
 	return c.GetJobsByStatus(StatusAvailable)
 }
 
+func (c *MemoryCatalog) Claim(jobId uuid.UUID, instance string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := c.checkOwner(jobId, instance); err != nil {
+		return err
+	}
+	c.owners[jobId] = instance
+	return nil
+}
+
 func (c *MemoryCatalog) Count() int {
 	c.mux.Lock()
 	defer c.mux.Unlock()
@@ -75,9 +107,21 @@ func (c *MemoryCatalog) Delete(jobId uuid.UUID) error {
 	defer c.mux.Unlock()
 
 	if _, found := c.jobs[jobId]; !found {
-		return ErrNotFound
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	c.remove(jobId)
+	return nil
+}
+
+func (c *MemoryCatalog) DeleteByName(namespace string, name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	id, err := c.lookupName(namespace, name)
+	if err != nil {
+		return err
 	}
-	delete(c.jobs, jobId)
+	c.remove(id)
 	return nil
 }
 
@@ -86,11 +130,12 @@ func (c *MemoryCatalog) Disable(jobId uuid.UUID) error {
 	defer c.mux.Unlock()
 
 	if _, found := c.jobs[jobId]; !found {
-		return ErrNotFound
+		return ErrNotFound.WithJob(jobId, "")
 	}
 	job := c.jobs[jobId]
 	job.Disable()
-	c.jobs[jobId] = job
+	job.Revision++
+	c.put(job)
 
 	return nil
 }
@@ -100,11 +145,12 @@ func (c *MemoryCatalog) Enable(jobId uuid.UUID) error {
 	defer c.mux.Unlock()
 
 	if _, found := c.jobs[jobId]; !found {
-		return ErrNotFound
+		return ErrNotFound.WithJob(jobId, "")
 	}
 	job := c.jobs[jobId]
 	job.Enable()
-	c.jobs[jobId] = job
+	job.Revision++
+	c.put(job)
 
 	return nil
 }
@@ -119,50 +165,179 @@ func (c *MemoryCatalog) Exists(id uuid.UUID) bool {
 	return false
 }
 
+func (c *MemoryCatalog) Find(query Query) (JobPage, error) {
+	if err := query.validate(); err != nil {
+		return JobPage{}, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	page := findJobs(c.candidates(query), query)
+	for i := range page.Jobs {
+		page.Jobs[i] = page.Jobs[i].clone()
+	}
+	return page, nil
+}
+
 func (c *MemoryCatalog) Get(id uuid.UUID) (Job, error) {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	if _, found := c.jobs[id]; !found {
-		return Job{}, ErrNotFound
+	job, found := c.jobs[id]
+	if !found {
+		return Job{}, ErrNotFound.WithJob(id, "")
 	}
-	return c.jobs[id], nil
+	return job.clone(), nil
+}
+
+func (c *MemoryCatalog) GetByName(namespace string, name string) (Job, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	id, err := c.lookupName(namespace, name)
+	if err != nil {
+		return Job{}, err
+	}
+	job := c.jobs[id]
+	return job.clone(), nil
 }
 
 func (c *MemoryCatalog) GetJobsByStatus(status Status) []Job {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	var jobs = make([]Job, 0)
-	for _, job := range c.jobs {
-		if job.Status == status {
-			jobs = append(jobs, job)
-		}
+	var jobs = make([]Job, 0, len(c.byStatus[status]))
+	for id := range c.byStatus[status] {
+		job := c.jobs[id]
+		jobs = append(jobs, job.clone())
 	}
 	return jobs
-
 }
 
 func (c *MemoryCatalog) HasEnabledJobs() bool {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	for _, job := range c.jobs {
-		if job.IsEnabled() {
-			return true
-		}
-	}
-	return false
+	return len(c.byEnabled[true]) > 0
 }
 
 func (c *MemoryCatalog) InactiveJobs() []Job {
 	return c.GetJobsByStatus(StatusInactive)
 }
 
+func (c *MemoryCatalog) Join(instance string, ttl time.Duration) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.members[instance] = time.Now().Add(ttl)
+	return nil
+}
+
+func (c *MemoryCatalog) Leave(instance string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	delete(c.members, instance)
+	for jobId, owner := range c.owners {
+		if owner == instance {
+			delete(c.owners, jobId)
+		}
+	}
+	return nil
+}
+
+func (c *MemoryCatalog) Members() ([]string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := time.Now()
+	members := make([]string, 0, len(c.members))
+	for instance := range c.members {
+		if c.isMember(instance, now) {
+			members = append(members, instance)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+func (c *MemoryCatalog) Overview() []Job {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	jobs := make([]Job, 0, len(c.jobs))
+	for _, job := range c.jobs {
+		jobs = append(jobs, job.cloneLast())
+	}
+	return jobs
+}
+
+func (c *MemoryCatalog) Owners() (map[uuid.UUID]string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := time.Now()
+	owners := make(map[uuid.UUID]string, len(c.owners))
+	for jobId, owner := range c.owners {
+		if c.isMember(owner, now) {
+			owners[jobId] = owner
+		}
+	}
+	return owners, nil
+}
+
 func (c *MemoryCatalog) PendingJobs() []Job {
 	return c.GetJobsByStatus(StatusPending)
 }
 
+func (c *MemoryCatalog) PruneHistory(jobId uuid.UUID, policy RetentionPolicy, now time.Time) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, found := c.jobs[jobId]
+	if !found {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	if policy.IsZero() {
+		return nil
+	}
+	job.PruneHistory(policy, now)
+	job.Revision++
+	c.put(job)
+
+	return nil
+}
+
+func (c *MemoryCatalog) Release(jobId uuid.UUID, instance string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := c.checkOwner(jobId, instance); err != nil {
+		return err
+	}
+	delete(c.owners, jobId)
+	return nil
+}
+
+func (c *MemoryCatalog) Rename(jobId uuid.UUID, namespace string, name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, found := c.jobs[jobId]
+	if !found {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	if err := c.checkName(jobId, namespace, name); err != nil {
+		return err
+	}
+	job.Namespace = namespace
+	job.Name = name
+	job.Revision++
+	c.put(job)
+	return nil
+}
+
 func (c *MemoryCatalog) RunnableJobs() []Job {
 	return c.GetJobsByStatus(StatusRunnable)
 }
@@ -171,13 +346,257 @@ func (c *MemoryCatalog) SchedulableJobs() []Job {
 	return c.GetJobsByStatus(StatusSchedulable)
 }
 
+func (c *MemoryCatalog) Transition(jobId uuid.UUID, from Status, to Status) error {
+	if err := checkTransition(jobId, from, to); err != nil {
+		return err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	job, found := c.jobs[jobId]
+	if !found {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	if job.Status != from {
+		return ErrConflict.WithJob(jobId, job.Name)
+	}
+	job.SetStatus(to)
+	job.Revision++
+	c.put(job)
+	return nil
+}
+
 func (c *MemoryCatalog) Update(job Job) error {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 
-	if _, found := c.jobs[job.Uuid]; !found {
-		return ErrNotFound
+	stored, found := c.jobs[job.Uuid]
+	if !found {
+		return ErrNotFound.WithJob(job.Uuid, job.Name)
+	}
+	if err := c.checkName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
+	job = job.clone()
+	job.Revision = stored.Revision + 1
+	c.put(job)
+	return nil
+}
+
+func (c *MemoryCatalog) UpdateIf(job Job, expectedRevision uint64) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	stored, found := c.jobs[job.Uuid]
+	if !found {
+		return ErrNotFound.WithJob(job.Uuid, job.Name)
+	}
+	if stored.Revision != expectedRevision {
+		return ErrConflict.WithJob(job.Uuid, job.Name)
+	}
+	if err := c.checkName(job.Uuid, job.Namespace, job.Name); err != nil {
+		return err
+	}
+	job = job.clone()
+	job.Revision = expectedRevision + 1
+	c.put(job)
+	return nil
+}
+
+// store saves job as is, without checks or changing its revision
+func (c *MemoryCatalog) store(job Job) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.put(job.clone())
+}
+
+// candidates returns the jobs which can match query according to the secondary indexes
+func (c *MemoryCatalog) candidates(query Query) []Job {
+	sets := make([]idSet, 0, len(query.Labels)+2)
+	if len(query.Status) == 1 {
+		sets = append(sets, c.byStatus[query.Status[0]])
+	} else if len(query.Status) > 1 {
+		union := make(idSet)
+		for _, status := range query.Status {
+			for id := range c.byStatus[status] {
+				union[id] = struct{}{}
+			}
+		}
+		sets = append(sets, union)
+	}
+	if query.Enabled != nil {
+		sets = append(sets, c.byEnabled[*query.Enabled])
+	}
+	for k, v := range query.Labels {
+		sets = append(sets, c.byLabel[k][v])
+	}
+	for _, r := range query.Selector.requirements {
+		if set, indexed := c.selectorCandidates(r); indexed {
+			sets = append(sets, set)
+		}
+	}
+
+	if len(sets) == 0 {
+		jobs := make([]Job, 0, len(c.jobs))
+		for _, job := range c.jobs {
+			jobs = append(jobs, job)
+		}
+		return jobs
+	}
+
+	// Intersect the sets starting from the smallest set
+	smallest := sets[0]
+	for _, set := range sets[1:] {
+		if len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+	jobs := make([]Job, 0, len(smallest))
+	for id := range smallest {
+		found := true
+		for _, set := range sets {
+			if _, found = set[id]; !found {
+				break
+			}
+		}
+		if found {
+			jobs = append(jobs, c.jobs[id])
+		}
+	}
+	return jobs
+}
+
+// selectorCandidates returns the jobs which can meet requirement r, requirements which exclude labels or values
+// cannot use the index
+func (c *MemoryCatalog) selectorCandidates(r selectorRequirement) (idSet, bool) {
+	switch r.operator {
+	case selectorEquals:
+		return c.byLabel[r.key][r.values[0]], true
+	case selectorIn, selectorExists:
+		union := make(idSet)
+		for value, set := range c.byLabel[r.key] {
+			if r.operator == selectorExists || r.hasValue(value) {
+				for id := range set {
+					union[id] = struct{}{}
+				}
+			}
+		}
+		return union, true
+	default:
+		return nil, false
+	}
+}
+
+// checkOwner returns ErrOwned if the job with jobId is owned by a member other than instance, c.mux must be held
+func (c *MemoryCatalog) checkOwner(jobId uuid.UUID, instance string) error {
+	job, found := c.jobs[jobId]
+	if !found {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	if owner := c.owners[jobId]; owner != instance && c.isMember(owner, time.Now()) {
+		return ErrOwned.WithJob(jobId, job.Name).WithCause(fmt.Errorf("owned by %s", owner))
+	}
+	return nil
+}
+
+// checkName returns an error if the name is invalid or used by another job than jobId, c.mux must be held
+func (c *MemoryCatalog) checkName(jobId uuid.UUID, namespace string, name string) error {
+	if err := validateName(namespace, name); err != nil {
+		return err
+	}
+	if namespace == "" {
+		return nil
+	}
+	if id, found := c.byName[QualifiedName(namespace, name)]; found && id != jobId {
+		return ErrNameExist.WithJob(jobId, QualifiedName(namespace, name))
 	}
-	c.jobs[job.Uuid] = job
 	return nil
 }
+
+// lockedCheckName is checkName for callers which do not hold the lock of the catalog
+func (c *MemoryCatalog) lockedCheckName(jobId uuid.UUID, namespace string, name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.checkName(jobId, namespace, name)
+}
+
+// isMember reports whether the membership of instance has not expired at now, c.mux must be held
+func (c *MemoryCatalog) isMember(instance string, now time.Time) bool {
+	expires, found := c.members[instance]
+	return found && expires.After(now)
+}
+
+// lookupName returns the id of the job with the name in namespace, c.mux must be held
+func (c *MemoryCatalog) lookupName(namespace string, name string) (uuid.UUID, error) {
+	if namespace == "" {
+		return uuid.Nil, errNoNamespace
+	}
+	id, found := c.byName[QualifiedName(namespace, name)]
+	if !found {
+		return uuid.Nil, ErrNotFound.WithCause(fmt.Errorf("no job named %q", QualifiedName(namespace, name)))
+	}
+	return id, nil
+}
+
+// put stores job and updates the secondary indexes
+func (c *MemoryCatalog) put(job Job) {
+	if stored, found := c.jobs[job.Uuid]; found {
+		c.unindex(stored)
+	}
+	c.jobs[job.Uuid] = job
+
+	c.byStatus[job.Status] = c.byStatus[job.Status].add(job.Uuid)
+	c.byEnabled[job.Enabled] = c.byEnabled[job.Enabled].add(job.Uuid)
+	if job.Namespace != "" {
+		c.byName[job.QualifiedName()] = job.Uuid
+	}
+	for k, v := range job.Labels {
+		if c.byLabel[k] == nil {
+			c.byLabel[k] = make(map[string]idSet)
+		}
+		c.byLabel[k][v] = c.byLabel[k][v].add(job.Uuid)
+	}
+}
+
+// remove deletes the job and its entries in the secondary indexes
+func (c *MemoryCatalog) remove(jobId uuid.UUID) {
+	if stored, found := c.jobs[jobId]; found {
+		c.unindex(stored)
+		delete(c.jobs, jobId)
+		delete(c.owners, jobId)
+	}
+}
+
+func (c *MemoryCatalog) unindex(job Job) {
+	c.byStatus[job.Status].remove(job.Uuid)
+	c.byEnabled[job.Enabled].remove(job.Uuid)
+	if job.Namespace != "" {
+		delete(c.byName, job.QualifiedName())
+	}
+	for k, v := range job.Labels {
+		c.byLabel[k][v].remove(job.Uuid)
+		if len(c.byLabel[k][v]) == 0 {
+			delete(c.byLabel[k], v)
+		}
+		if len(c.byLabel[k]) == 0 {
+			delete(c.byLabel, k)
+		}
+	}
+}
+
+type idSet map[uuid.UUID]struct{}
+
+func (s idSet) add(id uuid.UUID) idSet {
+	if s == nil {
+		s = make(idSet)
+	}
+	s[id] = struct{}{}
+	return s
+}
+
+func (s idSet) remove(id uuid.UUID) {
+	delete(s, id)
+}
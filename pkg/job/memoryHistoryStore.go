@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{
+		records: make(map[uuid.UUID][]HistoryRecord),
+		mux:     sync.Mutex{},
+	}
+}
+
+type MemoryHistoryStore struct {
+	records map[uuid.UUID][]HistoryRecord // records per job, ordered by run
+	mux     sync.Mutex
+}
+
+func (s *MemoryHistoryStore) Append(record HistoryRecord) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	records := s.records[record.JobId]
+	i := sort.Search(len(records), func(i int) bool {
+		return records[i].Run >= record.Run
+	})
+	if i < len(records) && records[i].Run == record.Run {
+		records[i] = record
+		return nil
+	}
+
+	records = append(records, HistoryRecord{})
+	copy(records[i+1:], records[i:])
+	records[i] = record
+	s.records[record.JobId] = records
+	return nil
+}
+
+func (s *MemoryHistoryStore) Count() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	count := 0
+	for _, records := range s.records {
+		count += len(records)
+	}
+	return count
+}
+
+func (s *MemoryHistoryStore) Prune(policy RetentionPolicy, now time.Time) (int, error) {
+	if policy.IsZero() {
+		return 0, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pruned := 0
+	for id, records := range s.records {
+		kept := records[:0]
+		for i, r := range records {
+			if policy.keep(r.Result, len(records)-i, now) {
+				kept = append(kept, r)
+			}
+		}
+		pruned += len(records) - len(kept)
+
+		if len(kept) == 0 {
+			delete(s.records, id)
+			continue
+		}
+		clear(records[len(kept):])
+		s.records[id] = kept
+	}
+	return pruned, nil
+}
+
+func (s *MemoryHistoryStore) Query(query HistoryQuery) (HistoryPage, error) {
+	s.mux.Lock()
+	matches := make([]HistoryRecord, 0)
+	for id, records := range s.records {
+		if query.JobId != uuid.Nil && id != query.JobId {
+			continue
+		}
+		for _, r := range records {
+			if query.matches(r) {
+				matches = append(matches, r)
+			}
+		}
+	}
+	s.mux.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Result.Start.Equal(matches[j].Result.Start) {
+			return matches[i].Result.Start.After(matches[j].Result.Start)
+		}
+		if matches[i].JobId != matches[j].JobId {
+			return matches[i].JobId.String() < matches[j].JobId.String()
+		}
+		return matches[i].Run > matches[j].Run
+	})
+
+	page := HistoryPage{Total: len(matches)}
+	from := min(max(query.Offset, 0), len(matches))
+	to := len(matches)
+	if query.Limit > 0 {
+		to = min(from+query.Limit, len(matches))
+	}
+	page.Records = matches[from:to]
+	return page, nil
+}
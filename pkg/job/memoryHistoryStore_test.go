@@ -0,0 +1,174 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestHistoryStore(t *testing.T, start time.Time, jobs ...uuid.UUID) *MemoryHistoryStore {
+	t.Helper()
+
+	s := NewMemoryHistoryStore()
+	appendTestHistory(t, s, start, jobs...)
+	return s
+}
+
+// appendTestHistory appends five runs for every job to s, the even runs failed
+func appendTestHistory(t *testing.T, s HistoryStore, start time.Time, jobs ...uuid.UUID) {
+	t.Helper()
+
+	for _, id := range jobs {
+		for run := 1; run <= 5; run++ {
+			status := StatusCompleted
+			if run%2 == 0 {
+				status = StatusError
+			}
+			record := HistoryRecord{
+				JobId: id,
+				Run:   run,
+				Result: Result{
+					Start:  start.Add(time.Duration(run) * time.Minute),
+					Finish: start.Add(time.Duration(run)*time.Minute + time.Second),
+					Status: status,
+				},
+			}
+			if err := s.Append(record); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestMemoryHistoryStore_Append(t *testing.T) {
+	id := uuid.New()
+	s := newTestHistoryStore(t, time.Now(), id)
+
+	// Appending the same run again replaces the record
+	if err := s.Append(HistoryRecord{JobId: id, Run: 3, Result: Result{Status: StatusActive}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 5 {
+		t.Errorf("got %d records, expected %d", s.Count(), 5)
+	}
+
+	page, _ := s.Query(HistoryQuery{JobId: id, Status: StatusActive})
+	if page.Total != 1 || page.Records[0].Run != 3 {
+		t.Errorf("got %d active records, expected run 3 to be replaced", page.Total)
+	}
+}
+
+func TestMemoryHistoryStore_Query(t *testing.T) {
+	testHistoryStoreQuery(t, NewMemoryHistoryStore())
+}
+
+// testHistoryStoreQuery queries the records of two jobs appended to an empty store s
+func testHistoryStoreQuery(t *testing.T, s HistoryStore) {
+	start := time.Now()
+	first, second := uuid.New(), uuid.New()
+	appendTestHistory(t, s, start, first, second)
+
+	tests := []struct {
+		name   string
+		query  HistoryQuery
+		total  int
+		wanted []int
+	}{
+		{"All", HistoryQuery{}, 10, nil},
+		{"Job", HistoryQuery{JobId: first}, 5, []int{5, 4, 3, 2, 1}},
+		{"Status", HistoryQuery{JobId: first, Status: StatusError}, 2, []int{4, 2}},
+		{"TimeRange", HistoryQuery{JobId: first, From: start.Add(2 * time.Minute), To: start.Add(4 * time.Minute)}, 2, []int{3, 2}},
+		{"Page", HistoryQuery{JobId: first, Offset: 1, Limit: 2}, 5, []int{4, 3}},
+		{"LastPage", HistoryQuery{JobId: first, Offset: 4, Limit: 2}, 5, []int{1}},
+		{"PastEnd", HistoryQuery{JobId: first, Offset: 10, Limit: 2}, 5, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.Query(tt.query)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if page.Total != tt.total {
+				t.Errorf("got %d matching records, expected %d", page.Total, tt.total)
+			}
+			if tt.wanted == nil {
+				return
+			}
+			if len(page.Records) != len(tt.wanted) {
+				t.Fatalf("got %d records, expected %d", len(page.Records), len(tt.wanted))
+			}
+			for i, run := range tt.wanted {
+				if page.Records[i].Run != run {
+					t.Errorf("got run %d at %d, expected %d", page.Records[i].Run, i, run)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryPage_HasMore(t *testing.T) {
+	s := newTestHistoryStore(t, time.Now(), uuid.New())
+
+	query := HistoryQuery{Limit: 2}
+	pages := 0
+	for {
+		page, _ := s.Query(query)
+		pages++
+		if !page.HasMore(query) {
+			break
+		}
+		query.Offset += len(page.Records)
+	}
+	if pages != 3 {
+		t.Errorf("got %d pages, expected %d", pages, 3)
+	}
+}
+
+func TestMemoryHistoryStore_Prune(t *testing.T) {
+	testHistoryStorePrune(t, NewMemoryHistoryStore())
+}
+
+// testHistoryStorePrune prunes the records of two jobs appended to an empty store s
+func testHistoryStorePrune(t *testing.T, s HistoryStore) {
+	start := time.Now()
+	first, second := uuid.New(), uuid.New()
+	appendTestHistory(t, s, start, first, second)
+
+	// Keep the last two runs of every job and failures within four minutes of the last run
+	pruned, err := s.Prune(RetentionPolicy{KeepLast: 2, KeepFailuresFor: 4 * time.Minute}, start.Add(6*time.Minute))
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if pruned != 4 {
+		t.Errorf("got %d pruned records, expected %d", pruned, 4)
+	}
+
+	page, _ := s.Query(HistoryQuery{JobId: first})
+	wanted := []int{5, 4, 2}
+	if len(page.Records) != len(wanted) {
+		t.Fatalf("got %d records, expected %d", len(page.Records), len(wanted))
+	}
+	for i, run := range wanted {
+		if page.Records[i].Run != run {
+			t.Errorf("got run %d at %d, expected %d", page.Records[i].Run, i, run)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errNoNamespace is returned when a job is looked up by name without a namespace
+var errNoNamespace = ErrInvalidName.WithCause(errors.New("names are only unique within a namespace"))
+
+// ParseQualifiedName splits a name in the form namespace/name, a name without a namespace is returned as is
+func ParseQualifiedName(qualifiedName string) (string, string, error) {
+	namespace, name, found := strings.Cut(qualifiedName, "/")
+	if !found {
+		return "", qualifiedName, nil
+	}
+	if namespace == "" {
+		return "", "", ErrInvalidName.WithCause(fmt.Errorf("empty namespace in %q", qualifiedName))
+	}
+	if err := validateName(namespace, name); err != nil {
+		return "", "", err
+	}
+	return namespace, name, nil
+}
+
+// QualifiedName returns the name of a job in the form namespace/name, or only the name if there is no namespace
+func QualifiedName(namespace string, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// validateName checks the name of a job with a namespace, jobs without a namespace can have any name
+func validateName(namespace string, name string) error {
+	if namespace == "" {
+		return nil
+	}
+	if name == "" || strings.Contains(namespace, "/") || strings.Contains(name, "/") {
+		return ErrInvalidName.WithCause(fmt.Errorf("%q", QualifiedName(namespace, name)))
+	}
+	return nil
+}
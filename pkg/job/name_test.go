@@ -0,0 +1,52 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseQualifiedName(t *testing.T) {
+	var tests = []struct {
+		qualifiedName string
+		namespace     string
+		name          string
+		err           error
+	}{
+		{"nightly-export", "", "nightly-export", nil},
+		{"exports/nightly-export", "exports", "nightly-export", nil},
+		{"exports/", "", "", ErrInvalidName},
+		{"/nightly-export", "", "", ErrInvalidName},
+		{"exports/nightly/export", "", "", ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.qualifiedName, func(t *testing.T) {
+			namespace, name, err := ParseQualifiedName(tt.qualifiedName)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, expected %v", err, tt.err)
+			}
+			if namespace != tt.namespace || name != tt.name {
+				t.Errorf("got namespace %q and name %q, expected %q and %q", namespace, name, tt.namespace, tt.name)
+			}
+			if err == nil && QualifiedName(namespace, name) != tt.qualifiedName {
+				t.Errorf("got qualified name %s, expected %s", QualifiedName(namespace, name), tt.qualifiedName)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import "time"
+
+// Observer is notified of the runs and errors of an orchestrator, for instance to export metrics. The methods are
+// called from the goroutines of the orchestrator and must return quickly.
+type Observer interface {
+	// ObserveRun is called when a run has finished
+	ObserveRun(run RunEvent)
+	// ObserveError is called for every error which is passed to the error handler of the orchestrator
+	ObserveError(err error)
+}
+
+type RunEvent struct {
+	Job    Job       // the job as it was run
+	Due    time.Time // the time at which the schedule fired or the job was triggered, zero if the run was scheduled by another orchestrator
+	Result Result
+}
+
+// Lag returns the time between the moment the run was due and its start, 0 if the due time is unknown
+func (e RunEvent) Lag() time.Duration {
+	if e.Due.IsZero() || e.Result.Start.Before(e.Due) {
+		return 0
+	}
+	return e.Result.Start.Sub(e.Due)
+}
@@ -18,21 +18,35 @@ package job
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
 	"github.com/corelayer/go-scheduler/pkg/task"
+	"github.com/corelayer/go-scheduler/pkg/trace"
 )
 
 func NewOrchestrator(catalog Catalog, taskHandlers *task.HandlerRepository, config OrchestratorConfig) *Orchestrator {
+	if config.Sharding != nil && config.Sharding.Instance == "" {
+		sharding := *config.Sharding
+		sharding.Instance = uuid.NewString()
+		config.Sharding = &sharding
+	}
+	if config.MessageHandler == nil && config.Logger != nil {
+		config.MessageHandler = task.NewSlogMessageHandler(config.Logger)
+	}
 	return &Orchestrator{
 		config:       config,
 		catalog:      catalog,
 		taskHandlers: taskHandlers,
-		chRunnerIn:   make(chan Job, config.MaxJobs),
-		chMessages:   make(chan task.IntercomMessage),
-		chErrors:     make(chan error),
 		runningJobs:  0,
+		rateLimits:   make(map[string]*ratelimit.Limiter),
 		mux:          sync.Mutex{},
 	}
 }
@@ -44,11 +58,93 @@ type Orchestrator struct {
 	chRunnerIn   chan Job
 	chMessages   chan task.IntercomMessage
 	chErrors     chan error
+	chDone       chan struct{}
+	chReleased   chan struct{} // signaled when a runner is released
+	chOwned      chan struct{} // signaled when the orchestrator claimed jobs
+	chRebalance  chan struct{} // signaled when a pending job is assigned to the orchestrator but not claimed
+	cancel       context.CancelFunc
+	queuedJobs   int
 	runningJobs  int
+	dispatched   map[uuid.UUID]dispatchedJob   // the class and concurrency keys of every queued or running job
+	classJobs    map[PriorityClass]int         // the number of queued or running jobs per class
+	heldKeys     map[string]int                // the number of queued or running jobs holding each concurrency key
+	waitingKeys  map[string]int                // the number of pending jobs waiting for each concurrency key
+	reserved     map[PriorityClass]int         // the number of runners reserved per class
+	waiting      map[uuid.UUID]time.Time       // the time at which every pending job was first seen
+	limited      map[uuid.UUID]time.Time       // the time at which every pending job was first held back by a rate limit
+	due          map[uuid.UUID]time.Time       // the time at which every job made runnable by the orchestrator was due
+	attempts     map[uuid.UUID]int             // the number of times the pending run of every job was dispatched
+	rateLimits   map[string]*ratelimit.Limiter // the limiters of label rate limits by label and value
+	owned        map[uuid.UUID]struct{}        // the jobs owned by the orchestrator, nil if the catalog is not sharded
+	shards       []ShardStats                  // the ownership of the members as of the last rebalance
+	members      []string                      // the members for which the ownership of every job was settled
+	ring         hashRing                      // the hash ring as of the last rebalance
 	isStarted    bool
+	isPaused     bool
+	isLeader     bool
 	mux          sync.Mutex
 }
 
+// dispatchedJob holds the runner class and the concurrency keys of a queued or running job
+type dispatchedJob struct {
+	class       PriorityClass
+	concurrency map[string]int
+}
+
+// minPollInterval is the shortest wait of a loop which polls the catalog and found no jobs
+const minPollInterval = time.Millisecond
+
+// reservation is the outcome of reserving a runner for a pending job
+type reservation int
+
+const (
+	reserved reservation = iota
+	runnerUnavailable
+	keysUnavailable
+	notOwned
+	notClaimed // the job is assigned to the orchestrator, but it has not claimed the job yet
+)
+
+// Add validates job and adds it to the catalog, a job with a task type for which no handler is registered is rejected
+// with ErrHandlerMissing
+func (o *Orchestrator) Add(job Job) error {
+	if err := o.Validate(job); err != nil {
+		return err
+	}
+	return o.catalog.Add(job)
+}
+
+// Drain pauses the orchestrator, waits for all queued and active runs to finish and then stops it.
+// The orchestrator is resumed after stopping, so a subsequent Start schedules jobs again.
+// If ctx expires before the orchestrator is idle, Drain returns ErrTimeout or ErrCanceled and the orchestrator remains paused.
+func (o *Orchestrator) Drain(ctx context.Context) error {
+	if !o.IsStarted() {
+		return nil
+	}
+
+	o.Pause()
+	for !o.isIdle() {
+		select {
+		case <-ctx.Done():
+			return contextError(ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := o.Stop(ctx); err != nil {
+		return err
+	}
+	o.Resume()
+	return nil
+}
+
+func (o *Orchestrator) IsPaused() bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return o.isPaused
+}
+
 func (o *Orchestrator) IsStarted() bool {
 	o.mux.Lock()
 	defer o.mux.Unlock()
@@ -56,35 +152,140 @@ func (o *Orchestrator) IsStarted() bool {
 	return o.isStarted
 }
 
-func (o *Orchestrator) Start(ctx context.Context) {
+// Pause stops the orchestrator from scheduling new runs, runs that are already active are allowed to finish.
+func (o *Orchestrator) Pause() {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	o.isPaused = true
+}
+
+func (o *Orchestrator) Resume() {
 	o.mux.Lock()
-	time.Sleep(o.config.StartDelay)
+	defer o.mux.Unlock()
+
+	o.isPaused = false
+}
+
+// Start launches the orchestrator, it keeps running until ctx is canceled or Stop is called.
+// A stopped orchestrator can be started again. With OrchestratorConfig.Strict set, Start fails with ErrHandlerMissing
+// if a job in the catalog has a task type without a registered handler, otherwise such a task fails when the job runs.
+// Start waits for OrchestratorConfig.StartDelay before launching, it fails with ErrCanceled or ErrTimeout if ctx is
+// done in the meantime.
+func (o *Orchestrator) Start(ctx context.Context) error {
+	if o.config.StartDelay > 0 {
+		select {
+		case <-ctx.Done():
+			return contextError(ctx.Err())
+		case <-time.After(o.config.StartDelay):
+		}
+	}
+
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if o.isStarted {
+		return ErrStarted
+	}
+	if o.config.Strict {
+		if err := o.validateAll(); err != nil {
+			return err
+		}
+	}
+
+	ctx, o.cancel = context.WithCancel(ctx)
+	o.chRunnerIn = make(chan Job, o.config.MaxJobs)
+	o.chReleased = make(chan struct{}, 1)
+	o.chOwned = make(chan struct{}, 1)
+	o.chRebalance = make(chan struct{}, 1)
+	o.dispatched = make(map[uuid.UUID]dispatchedJob)
+	o.classJobs = make(map[PriorityClass]int)
+	o.heldKeys = make(map[string]int)
+	o.waitingKeys = make(map[string]int)
+	o.reserved = reservedSlots(o.config.Reservations, o.config.MaxJobs)
+	o.waiting = make(map[uuid.UUID]time.Time)
+	o.limited = make(map[uuid.UUID]time.Time)
+	o.due = make(map[uuid.UUID]time.Time)
+	o.attempts = make(map[uuid.UUID]int)
+	o.owned = nil
+	o.shards = nil
+	o.members = nil
+	o.ring = hashRing{}
+	if o.config.Sharding != nil {
+		o.owned = make(map[uuid.UUID]struct{})
+	}
+	o.chMessages = make(chan task.IntercomMessage)
+	o.chErrors = make(chan error)
+	o.chDone = make(chan struct{})
+
+	var (
+		listeners  sync.WaitGroup
+		schedulers sync.WaitGroup
+		runners    sync.WaitGroup
+	)
 
 	// Make sure the orchestrator is ready to handle errors and messages before launching any other goroutine
-	go o.handleErrors()
-	go o.handleMessages()
+	listeners.Add(2)
+	go o.handleErrors(&listeners)
+	go o.handleMessages(&listeners)
 
 	// Launch goroutines in the order of the "normal" job flow
-	go o.handleInactiveJobs(ctx)
-	go o.handleAvailableJobs(ctx)
-	go o.handleSchedulableJobs(ctx)
-	go o.handleRunnableJobs(ctx)
-	go o.handlePendingJobs(ctx)
+	schedulers.Add(7)
+	go o.handleInactiveJobs(ctx, &schedulers)
+	go o.handleAvailableJobs(ctx, &schedulers)
+	go o.handleSchedulableJobs(ctx, &schedulers)
+	go o.handleRunnableJobs(ctx, &schedulers)
+	go o.handlePendingJobs(ctx, &schedulers)
+	go o.handleHistory(ctx, &schedulers)
+	go o.handleShards(ctx, &schedulers)
 
+	runners.Add(o.config.MaxJobs)
 	for i := 0; i < o.config.MaxJobs; i++ {
-		go o.handleActiveJob()
+		go o.handleActiveJob(ctx, &runners)
 	}
 
-	go o.handleShutdown(ctx)
+	go o.handleShutdown(ctx, &listeners, &schedulers, &runners)
 
 	o.isStarted = true
+	return nil
+}
+
+// Stop cancels the orchestrator and waits for active runs to finish.
+// If ctx expires before shutdown has completed, Stop returns ErrTimeout or ErrCanceled while shutdown continues in the background.
+func (o *Orchestrator) Stop(ctx context.Context) error {
+	o.mux.Lock()
+	if !o.isStarted {
+		o.mux.Unlock()
+		return nil
+	}
+	cancel := o.cancel
+	chDone := o.chDone
 	o.mux.Unlock()
+
+	cancel()
+	select {
+	case <-chDone:
+		return nil
+	case <-ctx.Done():
+		return contextError(ctx.Err())
+	}
 }
 
 func (o *Orchestrator) Statistics() OrchestratorStats {
+	// The statistics only use the last result of every job, so the catalog does not need to load their history
+	jobs := o.catalog.Overview()
+
 	o.mux.Lock()
-	jobs := o.catalog.All()
 	runningJobs := o.runningJobs
+	priorities := make([]PriorityStats, PriorityHigh+1)
+	for c := range priorities {
+		class := PriorityClass(c)
+		priorities[c] = PriorityStats{Class: class, RunningJobs: float64(o.classJobs[class]), Reserved: float64(o.reserved[class])}
+	}
+	concurrency := concurrencyStats(o.heldKeys, o.waitingKeys)
+	rateLimits := o.rateLimitStats()
+	isLeader := o.isLeader
+	shards := append([]ShardStats(nil), o.shards...)
 	o.mux.Unlock()
 
 	configuredJobs := len(jobs)
@@ -96,6 +297,8 @@ func (o *Orchestrator) Statistics() OrchestratorStats {
 	pendingJobs := 0
 	runnableJobs := 0
 	schedulableJobs := 0
+	completedJobs := 0
+	errorJobs := 0
 	finishedJobs := 0
 	taskStats := make([]TaskStats, 0)
 
@@ -120,10 +323,15 @@ func (o *Orchestrator) Statistics() OrchestratorStats {
 			finishedJobs += job.CountRuns()
 		case StatusPending:
 			pendingJobs++
+			priorities[ClassOf(job.Priority)].PendingJobs++
 		case StatusRunnable:
 			runnableJobs++
 		case StatusSchedulable:
 			schedulableJobs++
+		case StatusCompleted:
+			completedJobs++
+		case StatusError:
+			errorJobs++
 		default:
 		}
 
@@ -152,76 +360,178 @@ func (o *Orchestrator) Statistics() OrchestratorStats {
 			RunnableJobs:    float64(runnableJobs),
 			PendingJobs:     float64(pendingJobs),
 			ActiveJobs:      float64(activeJobs),
+			CompletedJobs:   float64(completedJobs),
+			ErrorJobs:       float64(errorJobs),
 			RunningJobs:     float64(runningJobs),
 			CompletedTasks:  float64(completedTasks),
 			TotalTasks:      float64(totalTasks),
 		},
-		Tasks: taskStats,
+		Leader:      isLeader,
+		Priorities:  priorities,
+		Concurrency: concurrency,
+		RateLimits:  rateLimits,
+		Shards:      shards,
+		Tasks:       taskStats,
 	}
 }
 
-func (o *Orchestrator) handleAvailableJobs(ctx context.Context) {
+// Validate returns ErrHandlerMissing if no handler is registered for the type of a task of job,
+// the error wraps a task.ErrHandlerMissing for every missing type. ErrInvalidConcurrency is returned if a concurrency
+// key of job is invalid.
+func (o *Orchestrator) Validate(job Job) error {
+	if err := validateConcurrency(job.Concurrency); err != nil {
+		return ErrInvalidConcurrency.WithJob(job.Uuid, job.Name).WithCause(err)
+	}
+	if err := o.taskHandlers.Validate(job.Tasks.All()); err != nil {
+		return ErrHandlerMissing.WithJob(job.Uuid, job.Name).WithCause(err)
+	}
+	return nil
+}
+
+func (o *Orchestrator) handleAvailableJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var idle time.Duration
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			for _, job := range o.catalog.AvailableJobs() {
-				job.SetStatus(StatusSchedulable)
-				if err := o.catalog.Update(job); err != nil {
+			jobs := o.catalog.AvailableJobs()
+			for _, job := range jobs {
+				if err := o.transition(job, StatusSchedulable); err != nil {
 					o.chErrors <- err
 				}
 			}
-
+			idle = o.waitIdle(ctx, idle, len(jobs) > 0)
 		}
 	}
 }
 
-func (o *Orchestrator) handleErrors() {
+func (o *Orchestrator) handleErrors(wg *sync.WaitGroup) {
+	defer wg.Done()
 	for {
 		err, ok := <-o.chErrors
 		if !ok {
 			return
 		}
+		if o.config.Observer != nil {
+			o.config.Observer.ObserveError(err)
+		}
+		o.logger().LogAttrs(context.Background(), slog.LevelError, "orchestrator error", slog.Any("error", err))
 		if o.config.ErrorHandler != nil {
 			o.config.ErrorHandler(err)
 		}
 	}
 }
 
-func (o *Orchestrator) handleInactiveJobs(ctx context.Context) {
+// handleHistory prunes the history store according to the history retention policy
+func (o *Orchestrator) handleHistory(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if o.config.History == nil || o.config.HistoryRetention.IsZero() {
+		return
+	}
+
+	interval := o.config.PruneInterval
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := o.config.History.Prune(o.config.HistoryRetention, time.Now()); err != nil {
+				o.chErrors <- err
+			}
+		}
+	}
+}
+
+func (o *Orchestrator) handleInactiveJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var idle time.Duration
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
+			found := false
 			for _, job := range o.catalog.InactiveJobs() {
-				job.SetStatus(StatusAvailable)
-				if err := o.catalog.Update(job); err != nil {
+				if !job.IsEligible() {
+					continue
+				}
+				found = true
+				if err := o.transition(job, StatusAvailable); err != nil {
 					o.chErrors <- err
 				}
 			}
+			idle = o.waitIdle(ctx, idle, found)
 		}
 	}
 }
 
-func (o *Orchestrator) handleActiveJob() {
+func (o *Orchestrator) handleActiveJob(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for {
 		job, ok := <-o.chRunnerIn
 		if !ok {
 			return
 		}
+
+		attempt := o.attempt(job.Uuid)
+
+		// Jobs which are still queued when the orchestrator stops are handed back to the catalog,
+		// so they are picked up again when the orchestrator is restarted
+		if ctx.Err() != nil {
+			o.requeue(job, "stopped")
+			continue
+		}
+
+		// The lists by status of a catalog may hold only the last result of a job, the run is added to the complete
+		// history of the job. A job which cannot be read is handed back to the catalog instead.
+		latest, err := o.catalog.Get(job.Uuid)
+		if err != nil {
+			o.chErrors <- err
+			o.requeue(job, "unavailable")
+			continue
+		}
+		job.History = latest.AllResults()
+
+		// The run lock guards against another orchestrator running the same job, a job which is locked is handed
+		// back to the catalog and tried again later
+		lock, err := o.lockRun(ctx, job)
+		if err != nil {
+			if !errors.Is(err, ErrLocked) {
+				o.chErrors <- err
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(o.config.ScheduleInterval):
+			}
+			o.requeue(job, "locked")
+			continue
+		}
 		o.runningJobsIncrease()
 
+		// The run executes the tasks the job was dispatched with, a definition stored during the run applies to the
+		// next run
+		tasks := job.Tasks.All()
+
 		// Update job data
 		result := Result{
 			Start:  time.Now(),
 			Status: StatusActive,
 		}
 		job.AddResult(result)
+		job.Triggered = false
+		run := LogRunAttrs(job, job.CountRuns(), attempt)
+		o.logger().LogAttrs(ctx, slog.LevelInfo, "job run started", run...)
 
 		// Send job update to catalog, so we can track active jobs
-		if err := o.catalog.Update(job); err != nil {
+		if err := o.update(&job, nil); err != nil {
 			o.chErrors <- err
 		}
 
@@ -230,9 +540,18 @@ func (o *Orchestrator) handleActiveJob() {
 		// Run all task for job
 		intercom := task.NewIntercom(job.Name, o.chMessages)
 		pipeline := make(chan *task.Pipeline, 1)
-		pipeline <- &task.Pipeline{Intercom: intercom, Data: make(map[string]interface{})}
+		runCtx, stopRun := o.holdLock(ctx, lock)
+		runCtx, span := o.tracer().Start(runCtx, "job.run",
+			trace.String(trace.AttrJobId, job.Uuid.String()),
+			trace.String(trace.AttrJobName, job.Name),
+			trace.Int(trace.AttrJobRun, job.CountRuns()))
+		data := make(map[string]interface{})
+		if lock != nil {
+			data[FencingTokenKey] = lock.Token()
+		}
+		pipeline <- &task.Pipeline{Context: runCtx, Intercom: intercom, Logger: runLogger(intercom, run), Data: data}
 
-		for i, t := range job.Tasks.All() {
+		for i, t := range tasks {
 			job.Tasks.activeIdx = i
 			job.Tasks.executed = append(job.Tasks.executed, t)
 
@@ -240,15 +559,17 @@ func (o *Orchestrator) handleActiveJob() {
 			taskResult := o.taskHandlers.Execute(t, pipeline)
 
 			job.Tasks.executed[job.Tasks.activeIdx] = taskResult
+			o.logTask(run, taskResult)
 
 			result.Tasks = job.Tasks.Executed()
 			job.UpdateResult(result)
 
-			if err := o.catalog.Update(job); err != nil {
+			if err := o.update(&job, nil); err != nil {
 				o.chErrors <- err
 			}
 		}
 		close(pipeline)
+		stopRun()
 
 		job.Tasks.active = false
 
@@ -257,31 +578,53 @@ func (o *Orchestrator) handleActiveJob() {
 		result.Messages = intercom.GetAll()
 		if intercom.HasErrors() {
 			result.Status = StatusError
+			span.SetStatus(trace.StatusError, "run failed")
 		} else {
 			result.Status = StatusCompleted
+			span.SetStatus(trace.StatusOK, "")
 		}
+		span.SetAttributes(trace.String(trace.AttrJobStatus, result.Status.String()))
+		span.End()
 		job.UpdateResult(result)
 		job.Tasks.ResetHistory()
-		job.SetStatus(result.Status)
-
-		if !job.IsActive() {
-			// Disable job if it does not need to be run again
-			if !job.IsEligible() {
-				job.Disable()
-			} else {
-				job.SetStatus(StatusInactive)
+		o.logRun(run, result)
+
+		if o.config.History != nil {
+			record := HistoryRecord{JobId: job.Uuid, JobName: job.Name, Run: job.CountRuns(), Result: result}
+			if err := o.config.History.Append(record); err != nil {
+				o.chErrors <- err
 			}
 		}
 
-		if err := o.catalog.Update(job); err != nil {
+		// The decision to run the job again is made against the latest version in the catalog,
+		// so a job which was disabled during the run stays disabled
+		err = o.update(&job, func(job *Job) {
+			job.SetStatus(result.Status)
+			if !job.IsActive() {
+				// Disable job if it does not need to be run again
+				if !job.IsEligible() {
+					job.Disable()
+				} else {
+					job.SetStatus(StatusInactive)
+				}
+			}
+		})
+		if err != nil {
 			o.chErrors <- err
+		} else {
+			o.logTransition(job, StatusActive, job.Status, "")
+			o.pruneHistory(&job)
 		}
 
-		o.runningJobsDecrease()
+		if o.config.Observer != nil {
+			o.config.Observer.ObserveRun(RunEvent{Job: job, Due: o.takeDue(job.Uuid), Result: result})
+		}
+		o.runningJobsDecrease(job.Uuid)
 	}
 }
 
-func (o *Orchestrator) handleMessages() {
+func (o *Orchestrator) handleMessages(wg *sync.WaitGroup) {
+	defer wg.Done()
 	for {
 		message, ok := <-o.chMessages
 		if !ok {
@@ -294,51 +637,158 @@ func (o *Orchestrator) handleMessages() {
 	}
 }
 
-func (o *Orchestrator) handlePendingJobs(ctx context.Context) {
+func (o *Orchestrator) handlePendingJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for {
 		select {
 		case <-ctx.Done():
-			close(o.chRunnerIn)
 			return
 		default:
-			for _, job := range o.catalog.PendingJobs() {
+			// Only the leader dispatches jobs, the other orchestrators stand by to take over
+			if !o.leader(ctx) {
+				select {
+				case <-ctx.Done():
+				case <-time.After(o.config.ScheduleInterval):
+				}
+				continue
+			}
+
+			blocked := false
+			// Once full is set, no runner is available for the class unavailable and the classes below it
+			var (
+				full        bool
+				unavailable PriorityClass
+			)
+			retry := o.config.ScheduleInterval
+			waitingKeys := make(map[string]int)
+			now := time.Now()
+			pending := prioritize(o.catalog.PendingJobs(), o.waiting, o.config.AgingInterval, now)
+			for id := range o.limited {
+				if _, found := o.waiting[id]; !found {
+					delete(o.limited, id)
+				}
+			}
+		dispatch:
+			for _, p := range pending {
+				job := p.job
+				class := ClassOf(job.Priority)
+				if full && class <= unavailable {
+					continue
+				}
+
+				// A job held back by a rate limit does not reserve a runner. The global rate limit holds up all jobs,
+				// the rate limit of a label group only holds up the jobs of the group.
+				limiters, delay, global := o.rateLimiters(job)
+				if delay > 0 {
+					if _, found := o.limited[job.Uuid]; !found {
+						o.limited[job.Uuid] = now
+					}
+					blocked = true
+					retry = min(retry, delay)
+					if global {
+						break dispatch
+					}
+					continue
+				}
+
+				// Reserve a runner before activating the job, a paused orchestrator does not start new runs.
+				// Jobs are ordered by their aged priority, but reserve a runner of the class of their configured priority.
+				// If no runner is available for a class there is none for the classes below it, a job of a higher class
+				// may still use the runners reserved for its class.
+				// A job waiting for a concurrency key or owned by another orchestrator does not hold up the jobs after it.
+				switch o.queuedJobsIncrease(job, class, waitingKeys) {
+				case runnerUnavailable:
+					blocked = true
+					if full, unavailable = true, class; class == PriorityHigh || o.IsPaused() {
+						break dispatch
+					}
+					continue
+				case keysUnavailable, notOwned:
+					blocked = true
+					continue
+				case notClaimed:
+					// Claim the job right away instead of waiting for the next rebalance
+					blocked = true
+					notify(o.chRebalance)
+					continue
+				default:
+				}
+				delete(o.waiting, job.Uuid)
+				// Only the orchestrator which activates the job is allowed to run it
+				if err := o.catalog.Transition(job.Uuid, job.Status, StatusActive); err != nil {
+					o.queuedJobsDecrease(job.Uuid)
+					if !errors.Is(err, ErrConflict) {
+						o.chErrors <- err
+					}
+					continue
+				}
+				o.takeRateLimits(job.Uuid, limiters, now)
+				o.logTransition(job, job.Status, StatusActive, "")
 				job.SetStatus(StatusActive)
-				if err := o.catalog.Update(job); err != nil {
-					o.chErrors <- err
-				} else {
-					o.chRunnerIn <- job
+				job.Revision++
+				o.chRunnerIn <- job
+			}
+
+			o.mux.Lock()
+			o.waitingKeys = waitingKeys
+			o.mux.Unlock()
+
+			// Wait for a runner or a concurrency key to be released, or for a rate limit to allow a run, before trying again
+			if blocked {
+				select {
+				case <-ctx.Done():
+				case <-o.chReleased:
+				case <-o.chOwned:
+				case <-time.After(retry):
 				}
 			}
 		}
 	}
 }
 
-func (o *Orchestrator) handleRunnableJobs(ctx context.Context) {
+func (o *Orchestrator) handleRunnableJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	var idle time.Duration
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			for _, job := range o.catalog.RunnableJobs() {
-				job.SetStatus(StatusPending)
-				if err := o.catalog.Update(job); err != nil {
+			jobs := o.catalog.RunnableJobs()
+			for _, job := range jobs {
+				if err := o.transition(job, StatusPending); err != nil {
 					o.chErrors <- err
 				}
 			}
+			idle = o.waitIdle(ctx, idle, len(jobs) > 0)
 		}
 	}
 }
 
-func (o *Orchestrator) handleSchedulableJobs(ctx context.Context) {
+func (o *Orchestrator) handleSchedulableJobs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			for _, job := range o.catalog.SchedulableJobs() {
-				job.SetStatus(StatusRunnable)
-				if err := o.catalog.Update(job); err != nil {
-					o.chErrors <- err
+			if !o.IsPaused() {
+				now := time.Now()
+				for _, job := range o.catalog.SchedulableJobs() {
+					if !job.IsDue(now) {
+						continue
+					}
+					due := now
+					if !job.IsTriggered() {
+						due = job.NextRun(now)
+					}
+					if err := o.transition(job, StatusRunnable); err != nil {
+						o.chErrors <- err
+						continue
+					}
+					o.mux.Lock()
+					o.due[job.Uuid] = due
+					o.mux.Unlock()
 				}
 			}
 
@@ -347,34 +797,525 @@ func (o *Orchestrator) handleSchedulableJobs(ctx context.Context) {
 	}
 }
 
-func (o *Orchestrator) handleShutdown(ctx context.Context) {
-	<-ctx.Done()
+// handleShards renews the membership of the orchestrator and claims the jobs assigned to it, jobs which are assigned to
+// another member are released once they are no longer queued or running. A pending job which is assigned to the
+// orchestrator but not claimed yet is claimed right away, without waiting for the membership to be renewed.
+func (o *Orchestrator) handleShards(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	if o.config.Sharding == nil {
+		return
+	}
+
+	ticker := time.NewTicker(o.config.Sharding.memberTTL() / 3)
+	defer ticker.Stop()
+
 	for {
-		o.mux.Lock()
-		if o.runningJobs != 0 {
-			o.mux.Unlock()
-			time.Sleep(100 * time.Millisecond)
-			continue
-		} else {
-			close(o.chMessages)
-			close(o.chErrors)
-			o.mux.Unlock()
-			break
+		if err := o.rebalance(); err != nil {
+			o.chErrors <- err
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.chRebalance:
 		}
 	}
+}
+
+func (o *Orchestrator) handleShutdown(ctx context.Context, listeners *sync.WaitGroup, schedulers *sync.WaitGroup, runners *sync.WaitGroup) {
+	<-ctx.Done()
+
+	// Stop feeding the runners before closing their input, then wait for active jobs to finish
+	schedulers.Wait()
+	close(o.chRunnerIn)
+	runners.Wait()
+
+	// Only close the listeners when nothing can send errors or messages anymore
+	if o.config.LeaderElector != nil {
+		o.resign()
+	}
+	if o.config.Sharding != nil {
+		o.leave()
+	}
+
+	close(o.chMessages)
+	close(o.chErrors)
+	listeners.Wait()
+
 	o.mux.Lock()
 	o.isStarted = false
+	close(o.chDone)
 	o.mux.Unlock()
 }
 
-func (o *Orchestrator) runningJobsIncrease() {
+// holdLock renews lock while the run is active. The returned context is canceled when the lock is lost, so the tasks
+// of the run can stop before another orchestrator takes over the job. stop must be called when the run has finished.
+func (o *Orchestrator) holdLock(ctx context.Context, lock Lock) (context.Context, func()) {
+	if lock == nil {
+		return ctx, func() {}
+	}
+
+	interval := o.config.LockRenewInterval
+	if interval <= 0 {
+		interval = DefaultLockRenewInterval
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Renew(runCtx); err != nil {
+					if runCtx.Err() == nil {
+						o.chErrors <- err
+					}
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return runCtx, func() {
+		cancel()
+		<-done
+		// The lock is released even if the orchestrator is stopping
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil && !errors.Is(err, ErrLockLost) {
+			o.chErrors <- err
+		}
+	}
+}
+
+// tracer returns the configured tracer, or a tracer which does not record spans
+func (o *Orchestrator) tracer() trace.Tracer {
+	if o.config.Tracer == nil {
+		return trace.NoopTracer{}
+	}
+	return o.config.Tracer
+}
+
+// takeDue returns and forgets the time at which the job with jobId was due, the zero time if it is not known
+func (o *Orchestrator) takeDue(jobId uuid.UUID) time.Time {
 	o.mux.Lock()
-	o.runningJobs++
+	defer o.mux.Unlock()
+
+	due := o.due[jobId]
+	delete(o.due, jobId)
+	return due
+}
+
+func (o *Orchestrator) isIdle() bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	return o.queuedJobs == 0 && o.runningJobs == 0
+}
+
+// leader reports whether this orchestrator dispatches pending jobs, an orchestrator without a leader elector is
+// always the leader
+func (o *Orchestrator) leader(ctx context.Context) bool {
+	isLeader := true
+	if o.config.LeaderElector != nil {
+		var err error
+		if isLeader, _, err = o.config.LeaderElector.Leader(ctx); err != nil {
+			if ctx.Err() == nil {
+				o.chErrors <- err
+			}
+			isLeader = false
+		}
+	}
+
+	o.mux.Lock()
+	o.isLeader = isLeader
+	o.mux.Unlock()
+	return isLeader
+}
+
+// leave removes the orchestrator from the members of the catalog, which releases the jobs it owns
+func (o *Orchestrator) leave() {
+	o.mux.Lock()
+	clear(o.owned)
+	o.shards = nil
+	o.members = nil
+	o.ring = hashRing{}
 	o.mux.Unlock()
+
+	if err := o.catalog.Leave(o.config.Sharding.Instance); err != nil {
+		o.chErrors <- err
+	}
 }
 
-func (o *Orchestrator) runningJobsDecrease() {
+// lockRun acquires the run lock of job, it returns a nil lock if no locker is configured
+func (o *Orchestrator) lockRun(ctx context.Context, job Job) (Lock, error) {
+	if o.config.Locker == nil {
+		return nil, nil
+	}
+	return o.config.Locker.TryLock(ctx, "job/"+job.Uuid.String())
+}
+
+// rebalance renews the membership of the orchestrator, claims the jobs which the hash ring assigns to it and releases
+// the jobs which are assigned to another member. A job which is claimed by another member is claimed during a later
+// rebalance, after that member has released it. The ownership of every job is only checked when the members change or
+// when it was not settled by the previous rebalance, otherwise only pending jobs are claimed, which are the only jobs
+// the orchestrator dispatches.
+func (o *Orchestrator) rebalance() error {
+	instance := o.config.Sharding.Instance
+	if err := o.catalog.Join(instance, o.config.Sharding.memberTTL()); err != nil {
+		return err
+	}
+	members, err := o.catalog.Members()
+	if err != nil {
+		return err
+	}
+	owners, err := o.catalog.Owners()
+	if err != nil {
+		return err
+	}
+
+	o.mux.Lock()
+	jobs := o.catalog.PendingJobs
+	if !slices.Equal(o.members, members) {
+		jobs = o.catalog.All
+	}
+	o.mux.Unlock()
+
+	ring := newHashRing(members, o.config.Sharding.virtualNodes())
+	var errs []error
+	settled := true // the ownership of every job visited is settled, so later rebalances only need to claim pending jobs
+	claimed := false
+	for _, job := range jobs() {
+		owner, owned := owners[job.Uuid]
+		switch {
+		case ring.owner(job.Uuid) == instance:
+			if owner != instance {
+				if err = o.catalog.Claim(job.Uuid, instance); err != nil {
+					if !errors.Is(err, ErrOwned) && !errors.Is(err, ErrNotFound) {
+						errs = append(errs, err)
+					}
+					o.disown(job.Uuid)
+					settled = false
+					continue
+				}
+				owners[job.Uuid] = instance
+			}
+			if o.own(job.Uuid) {
+				claimed = true
+			}
+		case owned && owner == instance:
+			// Stop dispatching the job, it is released when it is no longer queued or running
+			settled = false
+			if !o.disown(job.Uuid) {
+				continue
+			}
+			if err = o.catalog.Release(job.Uuid, instance); err != nil {
+				if !errors.Is(err, ErrOwned) && !errors.Is(err, ErrNotFound) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			delete(owners, job.Uuid)
+		default:
+			o.disown(job.Uuid)
+		}
+	}
+
+	o.mux.Lock()
+	o.shards = shardStats(members, owners)
+	o.ring = ring
+	if settled && len(errs) == 0 {
+		o.members = members
+	} else {
+		o.members = nil
+	}
+	o.mux.Unlock()
+
+	// Dispatch the claimed jobs right away
+	if claimed {
+		notify(o.chOwned)
+	}
+	return errors.Join(errs...)
+}
+
+// own allows the orchestrator to dispatch the job with jobId, it reports whether the job was not owned before
+func (o *Orchestrator) own(jobId uuid.UUID) bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if _, found := o.owned[jobId]; found {
+		return false
+	}
+	o.owned[jobId] = struct{}{}
+	return true
+}
+
+// disown stops the orchestrator from dispatching the job with jobId, it reports whether the job can be released
+// because it is not queued or running
+func (o *Orchestrator) disown(jobId uuid.UUID) bool {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	delete(o.owned, jobId)
+	_, dispatched := o.dispatched[jobId]
+	return !dispatched
+}
+
+// resign gives up the leadership of the orchestrator if the leader elector supports it
+func (o *Orchestrator) resign() {
+	o.mux.Lock()
+	o.isLeader = false
+	o.mux.Unlock()
+
+	if r, ok := o.config.LeaderElector.(interface {
+		Resign(ctx context.Context) error
+	}); ok {
+		if err := r.Resign(context.Background()); err != nil {
+			o.chErrors <- err
+		}
+	}
+}
+
+// rateLimiters returns the global limiter and the limiters of the label groups of job, and how long until all of them
+// allow a run of job to start. global reports whether the global limiter holds up the run.
+func (o *Orchestrator) rateLimiters(job Job) ([]*ratelimit.Limiter, time.Duration, bool) {
+	var (
+		limiters []*ratelimit.Limiter
+		delay    time.Duration
+	)
+	if o.config.RateLimit != nil {
+		if delay = o.config.RateLimit.Delay(); delay > 0 {
+			return nil, delay, true
+		}
+		limiters = append(limiters, o.config.RateLimit)
+	}
+	for _, l := range o.config.LabelRateLimits {
+		value, found := job.Labels[l.Label]
+		if !found {
+			continue
+		}
+		limiter := o.labelLimiter(l, value)
+		delay = max(delay, limiter.Delay())
+		limiters = append(limiters, limiter)
+	}
+	return limiters, delay, false
+}
+
+// takeRateLimits takes a token from every limiter for the run of the job with jobId, which starts at now
+func (o *Orchestrator) takeRateLimits(jobId uuid.UUID, limiters []*ratelimit.Limiter, now time.Time) {
+	var waited time.Duration
+	if since, found := o.limited[jobId]; found {
+		waited = now.Sub(since)
+		delete(o.limited, jobId)
+	}
+	for _, l := range limiters {
+		l.Take(waited)
+	}
+}
+
+// labelLimiter returns the limiter of the group of jobs with value for the label of rate limit l
+func (o *Orchestrator) labelLimiter(l LabelRateLimit, value string) *ratelimit.Limiter {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	key := l.Label + "=" + value
+	limiter, found := o.rateLimits[key]
+	if !found {
+		limiter = ratelimit.NewLimiter(l.Rate, l.Burst)
+		o.rateLimits[key] = limiter
+	}
+	return limiter
+}
+
+// waitIdle backs off a loop which polls the catalog for jobs with a status. The loop polls again immediately when it
+// found jobs, otherwise it waits twice as long as after the previous idle poll, from minPollInterval up to the schedule
+// interval. It returns the wait for the next idle poll.
+func (o *Orchestrator) waitIdle(ctx context.Context, idle time.Duration, found bool) time.Duration {
+	if found {
+		return 0
+	}
+	idle = max(min(2*idle, o.config.ScheduleInterval), minPollInterval)
+	select {
+	case <-ctx.Done():
+	case <-time.After(idle):
+	}
+	return idle
+}
+
+// validateAll validates every job in the catalog
+func (o *Orchestrator) validateAll() error {
+	var errs []error
+	for _, job := range o.catalog.All() {
+		if err := o.Validate(job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transition moves a job to a new status in the catalog. Losing the race against another orchestrator sharing the
+// same catalog is not an error, the job is handled by the orchestrator which won.
+func (o *Orchestrator) transition(job Job, to Status) error {
+	err := o.catalog.Transition(job.Uuid, job.Status, to)
+	if errors.Is(err, ErrConflict) {
+		return nil
+	}
+	if err == nil {
+		o.logTransition(job, job.Status, to, "")
+	}
+	return err
+}
+
+// update stores the run state of job in the catalog. When the job was changed in the catalog since it was read,
+// the run state is applied to the latest version and the update is retried. apply is called before every attempt,
+// so decisions which depend on the stored job are based on the latest version.
+func (o *Orchestrator) update(job *Job, apply func(job *Job)) error {
+	for {
+		if apply != nil {
+			apply(job)
+		}
+
+		err := o.catalog.UpdateIf(*job, job.Revision)
+		if err == nil {
+			job.Revision++
+			return nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+
+		var latest Job
+		if latest, err = o.catalog.Get(job.Uuid); err != nil {
+			return err
+		}
+		*job = job.withRunState(latest)
+	}
+}
+
+// pruneHistory applies the retention policy to the history of a job which finished a run, in the catalog and in job
+func (o *Orchestrator) pruneHistory(job *Job) {
+	if o.config.Retention.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	if err := o.catalog.PruneHistory(job.Uuid, o.config.Retention, now); err != nil {
+		o.chErrors <- err
+		return
+	}
+	job.PruneHistory(o.config.Retention, now)
+	job.Revision++
+}
+
+func (o *Orchestrator) queuedJobsDecrease(jobId uuid.UUID) {
+	o.mux.Lock()
+	o.queuedJobs--
+	o.releaseJob(jobId)
+	o.mux.Unlock()
+}
+
+// queuedJobsIncrease reserves a runner of class and all concurrency keys for job. It fails if the job is owned by
+// another orchestrator, if the orchestrator is paused, if no runner is available for the class or if a concurrency key
+// is held by the maximum number of jobs, in which case the keys which are not available are counted in waitingKeys.
+func (o *Orchestrator) queuedJobsIncrease(job Job, class PriorityClass, waitingKeys map[string]int) reservation {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if o.owned != nil {
+		if _, found := o.owned[job.Uuid]; !found {
+			if o.ring.owner(job.Uuid) == o.config.Sharding.Instance {
+				return notClaimed
+			}
+			return notOwned
+		}
+	}
+	if o.isPaused || !hasCapacity(class, o.classJobs, o.reserved, o.config.MaxJobs) {
+		return runnerUnavailable
+	}
+	if !keysAvailable(job.Concurrency, o.heldKeys) {
+		for key, limit := range job.Concurrency {
+			if o.heldKeys[key] >= limit {
+				waitingKeys[key]++
+			}
+		}
+		return keysUnavailable
+	}
+
+	o.queuedJobs++
+	o.dispatched[job.Uuid] = dispatchedJob{class: class, concurrency: job.Concurrency}
+	o.classJobs[class]++
+	for key := range job.Concurrency {
+		o.heldKeys[key]++
+	}
+	return reserved
+}
+
+// rateLimitStats returns the statistics of the global rate limit and of every label group, o.mux must be held
+func (o *Orchestrator) rateLimitStats() []RateLimitStats {
+	stats := make([]RateLimitStats, 0, len(o.rateLimits)+1)
+	if o.config.RateLimit != nil {
+		stats = append(stats, RateLimitStats{Name: "global", Stats: o.config.RateLimit.Stats()})
+	}
+
+	groups := make([]string, 0, len(o.rateLimits))
+	for group := range o.rateLimits {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		stats = append(stats, RateLimitStats{Name: group, Stats: o.rateLimits[group].Stats()})
+	}
+	return stats
+}
+
+// releaseJob frees the runner and the concurrency keys used by the job with jobId, o.mux must be held
+func (o *Orchestrator) releaseJob(jobId uuid.UUID) {
+	if d, found := o.dispatched[jobId]; found {
+		o.classJobs[d.class]--
+		for key := range d.concurrency {
+			if o.heldKeys[key]--; o.heldKeys[key] == 0 {
+				delete(o.heldKeys, key)
+			}
+		}
+		delete(o.dispatched, jobId)
+	}
+	notify(o.chReleased)
+}
+
+// requeue hands a job which was dispatched but not run back to the catalog, so it is dispatched again
+func (o *Orchestrator) requeue(job Job, reason string) {
+	o.queuedJobsDecrease(job.Uuid)
+	if err := o.catalog.Transition(job.Uuid, StatusActive, StatusPending); err != nil {
+		o.chErrors <- err
+	} else {
+		o.logTransition(job, StatusActive, StatusPending, reason)
+	}
+}
+
+func (o *Orchestrator) runningJobsDecrease(jobId uuid.UUID) {
 	o.mux.Lock()
 	o.runningJobs--
+	delete(o.attempts, jobId)
+	o.releaseJob(jobId)
 	o.mux.Unlock()
 }
+
+func (o *Orchestrator) runningJobsIncrease() {
+	o.mux.Lock()
+	o.queuedJobs--
+	o.runningJobs++
+	o.mux.Unlock()
+}
+
+// notify signals ch without blocking, a signal which is still pending is not repeated
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -18,11 +18,17 @@ package job
 
 import (
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
 	"github.com/corelayer/go-scheduler/pkg/task"
+	"github.com/corelayer/go-scheduler/pkg/trace"
 )
 
+// DefaultPruneInterval is used when OrchestratorConfig.PruneInterval is not set
+const DefaultPruneInterval = time.Minute
+
 func NewOrchestratorConfig(maxJobs int, delay int, interval int, errF func(err error), msgF func(msg task.IntercomMessage)) (OrchestratorConfig, error) {
 	var (
 		err  error
@@ -50,4 +56,36 @@ type OrchestratorConfig struct {
 	StartDelay       time.Duration
 	ErrorHandler     func(err error)
 	MessageHandler   func(msg task.IntercomMessage)
+	Retention        RetentionPolicy // applied to the history of a job in the catalog after every run
+	History          HistoryStore    // receives the result of every finished run when set
+	HistoryRetention RetentionPolicy // applied to History every PruneInterval
+	PruneInterval    time.Duration
+	AgingInterval    time.Duration             // the priority of a pending job increases by one every interval, 0 disables aging
+	Reservations     map[PriorityClass]float64 // the fraction of MaxJobs reserved for jobs of a class or a higher class
+	RateLimit        *ratelimit.Limiter        // limits the rate at which runs start, a held back run does not occupy a runner
+	LabelRateLimits  []LabelRateLimit
+	Strict           bool // refuse to start if a job in the catalog has a task type without a registered handler
+	// LeaderElector decides which orchestrator dispatches pending jobs when several orchestrators share a catalog,
+	// all orchestrators dispatch jobs when it is not set
+	LeaderElector LeaderElector
+	// Locker provides the run lock of a job, which is held for the duration of every run. The fencing token of the
+	// lock is passed to the tasks in the pipeline data under FencingTokenKey.
+	Locker            Locker
+	LockRenewInterval time.Duration // the interval at which the run lock is renewed, defaults to DefaultLockRenewInterval
+	// Sharding divides the jobs of a shared catalog between the orchestrators, an orchestrator only dispatches the
+	// jobs it owns. All orchestrators dispatch all jobs when it is not set.
+	Sharding *ShardingConfig
+	Observer Observer     // receives every finished run and every error
+	Tracer   trace.Tracer // traces runs and their tasks, spans are not recorded when it is not set
+	// Logger receives structured logs of every status transition, run and task outcome, nothing is logged when it is
+	// not set. When MessageHandler is not set, intercom messages are written to Logger as well.
+	Logger *slog.Logger
+}
+
+// LabelRateLimit limits the rate at which jobs with a label start runs. Jobs with the same value of the label form a
+// group with its own token bucket, which allows Rate runs per second and bursts of Burst runs.
+type LabelRateLimit struct {
+	Label string
+	Rate  float64
+	Burst int
 }
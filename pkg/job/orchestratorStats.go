@@ -18,6 +18,8 @@ package job
 
 import (
 	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
 )
 
 type GlobalStats struct {
@@ -30,6 +32,8 @@ type GlobalStats struct {
 	RunnableJobs    float64
 	PendingJobs     float64
 	ActiveJobs      float64
+	CompletedJobs   float64 // disabled jobs of which the last run completed
+	ErrorJobs       float64 // disabled jobs of which the last run failed
 	RunningJobs     float64
 	CompletedTasks  float64
 	TotalTasks      float64
@@ -43,9 +47,38 @@ type TaskStats struct {
 	HasErrors bool
 }
 
+type PriorityStats struct {
+	Class       PriorityClass
+	PendingJobs float64 // pending jobs of the class, without aging
+	RunningJobs float64 // queued or running jobs dispatched in the class
+	Reserved    float64 // runners reserved for the class
+}
+
+type ConcurrencyStats struct {
+	Key         string
+	HeldBy      float64 // queued or running jobs holding the key
+	WaitingJobs float64 // pending jobs waiting for a slot of the key
+}
+
+type RateLimitStats struct {
+	Name  string // global, or label=value for the group of a label rate limit
+	Stats ratelimit.Stats
+}
+
+// ShardStats holds the number of jobs owned by a member of the catalog
+type ShardStats struct {
+	Instance  string
+	OwnedJobs float64
+}
+
 type OrchestratorStats struct {
-	Job   GlobalStats
-	Tasks []TaskStats
+	Job         GlobalStats
+	Leader      bool               // whether the orchestrator dispatches jobs, see OrchestratorConfig.LeaderElector
+	Priorities  []PriorityStats    // ordered from the lowest to the highest class
+	Concurrency []ConcurrencyStats // keys which are held or waited for, ordered by key
+	RateLimits  []RateLimitStats   // the global rate limit followed by the label groups, ordered by name
+	Shards      []ShardStats       // the members of the catalog ordered by instance, see OrchestratorConfig.Sharding
+	Tasks       []TaskStats
 }
 
 func (o OrchestratorStats) HasTaskErrors() bool {
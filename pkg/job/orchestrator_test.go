@@ -16,32 +16,1223 @@
 
 package job
 
-// func TestNewOrchestrator(t *testing.T) {
-// 	oc := OrchestratorConfig{
-// 		MaxJobs:         10,
-// 		SchedulerConfig: NewSchedulerConfig(),
-// 		RunnerConfig:    NewRunnerConfig(&TaskHandlerRepository{}),
-// 	}
-// 	ctx, cancel := context.WithCancel(context.Background())
-//
-// 	c := NewMemoryCatalog()
-// 	_, err := NewOrchestrator(ctx, oc, c)
-// 	if err != nil {
-// 		t.Errorf("got error: %s", err.Error())
-// 	}
-//
-// 	schedule, _ := cron.NewSchedule("@everysecond")
-// 	for i := 0; i < 100; i++ {
-// 		c.Register(Job{
-// 			Uuid:     uuid.New(),
-// 			Enabled:  true,
-// 			Status:   StatusNone,
-// 			Schedule: schedule,
-// 			Name:     strconv.Itoa(i),
-// 			Tasks:    TaskSequence{},
-// 		})
-// 	}
-//
-// 	time.Sleep(15 * time.Second)
-// 	cancel()
-// }
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
+	"github.com/corelayer/go-scheduler/pkg/task"
+	"github.com/corelayer/go-scheduler/pkg/trace"
+)
+
+func newTestOrchestrator(t *testing.T, tasks []task.Task) (*Orchestrator, *MemoryCatalog, Job) {
+	t.Helper()
+
+	r := task.NewHandlerRepository()
+	err := r.RegisterHandlerPools([]*task.HandlerPool{
+		task.NewHandlerPool(task.NewDefaultEmptyTaskHandler()),
+		task.NewHandlerPool(task.NewDefaultSleepTaskHandler()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := cron.NewSchedule("@everysecond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewMemoryCatalog()
+	j := NewJob("test", s, 0, NewSequence(tasks))
+	if err = c.Add(j); err != nil {
+		t.Fatal(err)
+	}
+
+	config := OrchestratorConfig{
+		MaxJobs:          2,
+		ScheduleInterval: 10 * time.Millisecond,
+	}
+	return NewOrchestrator(c, r, config), c, j
+}
+
+func waitForRuns(t *testing.T, c *MemoryCatalog, j Job, runs int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		current, err := c.Get(j.Uuid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.CountRuns() >= runs {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job did not reach %d runs", runs)
+}
+
+func TestOrchestrator_Start(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err := o.Start(context.Background()); !errors.Is(err, ErrStarted) {
+		t.Errorf("got %v, expected %v", err, ErrStarted)
+	}
+	waitForRuns(t, c, j, 1)
+
+	if err := o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if o.IsStarted() {
+		t.Errorf("orchestrator is started, expected stopped")
+	}
+}
+
+func TestOrchestrator_StartAfterStop(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	for i := 1; i <= 2; i++ {
+		if err := o.Start(context.Background()); err != nil {
+			t.Fatalf("got error on start %d: %s", i, err.Error())
+		}
+		waitForRuns(t, c, j, i)
+		if err := o.Stop(context.Background()); err != nil {
+			t.Fatalf("got error on stop %d: %s", i, err.Error())
+		}
+	}
+}
+
+func TestOrchestrator_StartCanceled(t *testing.T) {
+	o, _, _ := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := o.Start(ctx); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for o.IsStarted() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if o.IsStarted() {
+		t.Errorf("orchestrator is started, expected stopped after cancel")
+	}
+}
+
+func TestOrchestrator_StartDelay(t *testing.T) {
+	o, _, _ := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.StartDelay = time.Second
+
+	// The orchestrator can be used while it waits to start
+	ctx, cancel := context.WithCancel(context.Background())
+	chErr := make(chan error, 1)
+	go func() {
+		chErr <- o.Start(ctx)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		o.Pause()
+		_ = o.IsStarted()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Errorf("orchestrator is locked during the start delay")
+	}
+
+	cancel()
+	select {
+	case err := <-chErr:
+		if !errors.Is(err, ErrCanceled) {
+			t.Errorf("got %v, expected %v", err, ErrCanceled)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatalf("start did not return after cancel")
+	}
+	if o.IsStarted() {
+		t.Errorf("orchestrator is started, expected a canceled start")
+	}
+}
+
+func TestOrchestrator_PauseResume(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer o.Stop(context.Background())
+
+	waitForRuns(t, c, j, 1)
+	o.Pause()
+	if !o.IsPaused() {
+		t.Fatalf("orchestrator is not paused")
+	}
+
+	// Allow a run which was already dispatched before pausing to finish
+	time.Sleep(100 * time.Millisecond)
+	current, _ := c.Get(j.Uuid)
+	runs := current.CountRuns()
+
+	time.Sleep(1500 * time.Millisecond)
+	current, _ = c.Get(j.Uuid)
+	if current.CountRuns() != runs {
+		t.Errorf("got %d runs while paused, expected %d", current.CountRuns(), runs)
+	}
+
+	o.Resume()
+	waitForRuns(t, c, j, runs+1)
+}
+
+func TestOrchestrator_Drain(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.SleepTask{Milliseconds: 200}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if o.IsStarted() || o.IsPaused() {
+		t.Errorf("orchestrator is started %t and paused %t, expected stopped and resumed", o.IsStarted(), o.IsPaused())
+	}
+
+	current, _ := c.Get(j.Uuid)
+	if current.IsActive() {
+		t.Errorf("job is %s after drain, expected run to be finished", current.Status)
+	}
+}
+
+func TestOrchestrator_StopDeadline(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.SleepTask{Milliseconds: 1000}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := o.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	if err := o.Stop(context.Background()); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+}
+
+func TestOrchestrator_DisableWhileRunning(t *testing.T) {
+	o, c, _ := newTestOrchestrator(t, []task.Task{task.SleepTask{Milliseconds: 5}})
+	o.config.MaxJobs = 8
+
+	s, err := cron.NewSchedule("@everysecond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err = c.Add(NewJob("test", s, 0, NewSequence([]task.Task{task.SleepTask{Milliseconds: 5}, task.EmptyTask{}}))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// Disable the jobs while their runs store results in the catalog
+	jobs := c.All()
+	done := make(chan struct{})
+	for _, j := range jobs {
+		go func(j Job) {
+			if err := c.Disable(j.Uuid); err != nil {
+				t.Errorf("got error: %s", err.Error())
+			}
+			done <- struct{}{}
+		}(j)
+	}
+	for range jobs {
+		<-done
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if err = o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	for _, j := range c.All() {
+		if j.IsEnabled() {
+			t.Errorf("job %s is enabled with status %s, expected disabled", j.Uuid, j.Status)
+		}
+	}
+}
+
+func TestOrchestrator_EnableAfterDisableWhileRunning(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.SleepTask{Milliseconds: 200}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer func() {
+		if err := o.Stop(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}()
+	waitForRuns(t, c, j, 1)
+
+	// The job is disabled while it runs, so it finishes its run as a disabled job
+	if err := c.Disable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current, _ := c.Get(j.Uuid)
+		if current.Status == StatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got status %s, expected job to finish its run", current.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := c.Enable(j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 2)
+}
+
+func TestOrchestrator_Retention(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	history := NewMemoryHistoryStore()
+	o.config.Retention = RetentionPolicy{KeepLast: 1}
+	o.config.History = history
+	o.config.HistoryRetention = RetentionPolicy{KeepLast: 2}
+	o.config.PruneInterval = 10 * time.Millisecond
+
+	j.MaxRuns = 3
+	if err := c.Update(j); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 3)
+
+	// Wait for the last run to finish and the history store to be pruned
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if current, _ := c.Get(j.Uuid); !current.IsEnabled() && history.Count() == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	current, _ := c.Get(j.Uuid)
+	if current.IsEnabled() || current.CountRuns() != 3 || len(current.AllResults()) != 1 {
+		t.Errorf("got enabled %t job with %d runs and %d results, expected disabled job with 3 runs and 1 result", current.IsEnabled(), current.CountRuns(), len(current.AllResults()))
+	}
+
+	page, _ := history.Query(HistoryQuery{JobId: j.Uuid})
+	if page.Total != 2 || page.Records[0].Run != 3 || page.Records[0].Result.Status != StatusCompleted {
+		t.Errorf("got %d records in history store, expected the last 2 completed runs", page.Total)
+	}
+}
+
+func TestOrchestrator_Schedule(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	s, err := cron.NewSchedule("0 0 1 1 * 2099")
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := NewJob("later", s, 0, NewSequence([]task.Task{task.EmptyTask{}}))
+	if err = c.Add(later); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 2)
+	if err = o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// A job runs at most once per second
+	current, _ := c.Get(j.Uuid)
+	history := current.AllResults()
+	if history[1].Start.Truncate(time.Second).Equal(history[0].Start.Truncate(time.Second)) {
+		t.Errorf("got runs started at %s and %s, expected runs in different seconds", history[0].Start, history[1].Start)
+	}
+
+	current, _ = c.Get(later.Uuid)
+	if current.CountRuns() != 0 || current.Status != StatusSchedulable {
+		t.Errorf("got %d runs with status %s, expected job to wait for its schedule", current.CountRuns(), current.Status)
+	}
+}
+
+func TestOrchestrator_Trigger(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	// The job is not due until 2099, unless it is triggered
+	s, err := cron.NewSchedule("0 0 1 1 * 2099")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Schedule = s
+	if err = c.Update(j); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer o.Stop(context.Background())
+
+	if err = TriggerJob(c, j.Uuid); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+
+	time.Sleep(100 * time.Millisecond)
+	current, _ := c.Get(j.Uuid)
+	if current.CountRuns() != 1 || current.IsTriggered() {
+		t.Errorf("got %d runs and triggered %t, expected a single run which clears the trigger", current.CountRuns(), current.IsTriggered())
+	}
+}
+
+func TestOrchestrator_Add(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+
+	unresolved := NewJob("print", j.Schedule, 0, NewSequence([]task.Task{task.EmptyTask{}, task.PrintTask{}}))
+	err := o.Add(unresolved)
+	if !errors.Is(err, ErrHandlerMissing) || !errors.Is(err, task.ErrHandlerMissing) {
+		t.Errorf("got %v, expected %v", err, ErrHandlerMissing)
+	}
+	if _, err = c.Get(unresolved.Uuid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, expected job with missing handler not to be added", err)
+	}
+
+	if err = o.Add(NewJob("empty", j.Schedule, 0, NewSequence([]task.Task{task.EmptyTask{}}))); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+}
+
+func TestOrchestrator_StartStrict(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.PrintTask{}})
+	o.config.Strict = true
+
+	err := o.Start(context.Background())
+	if !errors.Is(err, ErrHandlerMissing) {
+		t.Fatalf("got %v, expected %v", err, ErrHandlerMissing)
+	}
+	if o.IsStarted() {
+		t.Errorf("orchestrator is started, expected strict mode to refuse unresolved task types")
+	}
+
+	if err = c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if err = o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+}
+
+func TestOrchestrator_MissingHandler(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.PrintTask{}, task.EmptyTask{}})
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	current, _ := c.Get(j.Uuid)
+	result := current.AllResults()[0]
+	if result.Status != StatusError || len(result.Tasks) != 2 {
+		t.Fatalf("got status %s with %d tasks, expected %s with 2 tasks", result.Status, len(result.Tasks), StatusError)
+	}
+	if result.Tasks[0].Status() != task.StatusError || result.Tasks[1].Status() != task.StatusCompleted {
+		t.Errorf("got task statuses %s and %s, expected %s and %s", result.Tasks[0].Status(), result.Tasks[1].Status(), task.StatusError, task.StatusCompleted)
+	}
+}
+
+func TestOrchestrator_Priority(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.SleepTask{Milliseconds: 20}})
+	o.config.MaxJobs = 1
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// All jobs are pending when the orchestrator starts, so only their priority determines the order of the runs
+	priorities := []int{-1, 0, -1, 1, 0}
+	jobs := make([]Job, len(priorities))
+	for i, priority := range priorities {
+		jobs[i] = NewJob("test", j.Schedule, 1, NewSequence([]task.Task{task.SleepTask{Milliseconds: 20}}))
+		jobs[i].Priority = priority
+		jobs[i].Status = StatusPending
+		if err := c.Add(jobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	for _, job := range jobs {
+		waitForRuns(t, c, job, 1)
+	}
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Jobs with the same priority are dispatched in any order
+	for _, a := range jobs {
+		for _, b := range jobs {
+			first, _ := c.Get(a.Uuid)
+			second, _ := c.Get(b.Uuid)
+			if first.Priority > second.Priority && second.AllResults()[0].Start.Before(first.AllResults()[0].Start) {
+				t.Errorf("job with priority %d started before job with priority %d", second.Priority, first.Priority)
+			}
+		}
+	}
+
+	stats := o.Statistics()
+	if len(stats.Priorities) != 3 || stats.Priorities[PriorityHigh].RunningJobs != 0 {
+		t.Errorf("got priority stats %v, expected 3 classes without running jobs", stats.Priorities)
+	}
+}
+
+func TestOrchestrator_PriorityReservation(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.MaxJobs = 2
+	o.config.Reservations = map[PriorityClass]float64{PriorityHigh: 0.5}
+	o.config.AgingInterval = time.Millisecond
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// The normal job takes the only runner which is not reserved, the low job is ordered before any high job once it
+	// has aged, but it still waits for a runner which is not reserved for the high class
+	normal := NewJob("normal", j.Schedule, 1, NewSequence([]task.Task{task.SleepTask{Milliseconds: 200}}))
+	low := NewJob("low", j.Schedule, 1, NewSequence([]task.Task{task.EmptyTask{}}))
+	low.Priority = -1
+	for _, job := range []Job{normal, low} {
+		job.Status = StatusPending
+		if err := c.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, low, 1)
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	first, _ := c.Get(normal.Uuid)
+	second, _ := c.Get(low.Uuid)
+	if finish, start := first.AllResults()[0].Finish, second.AllResults()[0].Start; start.Before(finish) {
+		t.Errorf("low job started %s before the normal job finished, expected it to wait for a runner", finish.Sub(start))
+	}
+}
+
+func TestOrchestrator_Concurrency(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.MaxJobs = 4
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs sharing a key must not overlap, the job holding both keys excludes both other jobs
+	keys := []map[string]int{{"a": 1}, {"a": 1, "b": 1}, {"b": 1}, {"a": 1}, nil}
+	jobs := make([]Job, len(keys))
+	for i, concurrency := range keys {
+		jobs[i] = NewJob("test", j.Schedule, 1, NewSequence([]task.Task{task.SleepTask{Milliseconds: 50}}))
+		jobs[i].Concurrency = concurrency
+		jobs[i].Status = StatusPending
+		if err := c.Add(jobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	waited := false
+	deadline := time.Now().Add(5 * time.Second)
+	for o.Statistics().Job.EnabledJobs > 0 && time.Now().Before(deadline) {
+		for _, s := range o.Statistics().Concurrency {
+			if s.HeldBy > 1 {
+				t.Errorf("key %s held by %.0f jobs, expected at most 1", s.Key, s.HeldBy)
+			}
+			waited = waited || s.WaitingJobs > 0
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for _, job := range jobs {
+		waitForRuns(t, c, job, 1)
+	}
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if !waited {
+		t.Errorf("no job waited for a concurrency key")
+	}
+
+	for i := range jobs {
+		for k := i + 1; k < len(jobs); k++ {
+			if !sharesKey(keys[i], keys[k]) {
+				continue
+			}
+			first, _ := c.Get(jobs[i].Uuid)
+			second, _ := c.Get(jobs[k].Uuid)
+			a, b := first.AllResults()[0], second.AllResults()[0]
+			if a.Start.Before(b.Finish) && b.Start.Before(a.Finish) {
+				t.Errorf("jobs %d and %d sharing a concurrency key ran at the same time", i, k)
+			}
+		}
+	}
+	if stats := o.Statistics(); len(stats.Concurrency) != 0 {
+		t.Errorf("got concurrency stats %v after all jobs finished, expected none", stats.Concurrency)
+	}
+}
+
+func sharesKey(a map[string]int, b map[string]int) bool {
+	for key := range a {
+		if _, found := b[key]; found {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOrchestrator_RateLimit(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.MaxJobs = 5
+	o.config.LabelRateLimits = []LabelRateLimit{{Label: "api", Rate: ratelimit.Every(100 * time.Millisecond), Burst: 1}}
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Jobs calling the same api start at the rate of the label group, other jobs are not limited
+	labels := []map[string]string{{"api": "github"}, {"api": "github"}, {"api": "github"}, {"api": "gitlab"}, nil}
+	jobs := make([]Job, len(labels))
+	for i := range labels {
+		jobs[i] = NewJob("test", j.Schedule, 1, NewSequence([]task.Task{task.EmptyTask{}}))
+		jobs[i].Labels = labels[i]
+		jobs[i].Status = StatusPending
+		if err := c.Add(jobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	for _, job := range jobs {
+		waitForRuns(t, c, job, 1)
+	}
+	if err := o.Drain(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	starts := make([]time.Time, len(jobs))
+	for i := range jobs {
+		current, _ := c.Get(jobs[i].Uuid)
+		starts[i] = current.AllResults()[0].Start
+	}
+	first := starts[0]
+	for _, start := range starts {
+		if start.Before(first) {
+			first = start
+		}
+	}
+	for i := 3; i < len(jobs); i++ {
+		if waited := starts[i].Sub(first); waited > 50*time.Millisecond {
+			t.Errorf("job %d without a busy label group waited %s", i, waited)
+		}
+	}
+	last := starts[0]
+	for _, start := range starts[:3] {
+		if start.After(last) {
+			last = start
+		}
+	}
+	if spread := last.Sub(first); spread < 190*time.Millisecond {
+		t.Errorf("3 runs in a label group with burst 1 started within %s, expected at least 200ms", spread)
+	}
+
+	stats := o.Statistics().RateLimits
+	if len(stats) != 2 || stats[0].Name != "api=github" || stats[0].Stats.Delayed != 2 || stats[1].Stats.Delayed != 0 {
+		t.Errorf("got rate limit stats %+v, expected 2 delayed runs for api=github", stats)
+	}
+}
+
+func TestOrchestrator_RateLimitRunner(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.MaxJobs = 1
+	o.config.LabelRateLimits = []LabelRateLimit{{Label: "api", Rate: ratelimit.Every(time.Hour), Burst: 1}}
+	if err := c.Delete(j.Uuid); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second job of the label group is held back for an hour, the only runner is left to the other job
+	labels := []map[string]string{{"api": "github"}, {"api": "github"}, nil}
+	jobs := make([]Job, len(labels))
+	for i := range labels {
+		jobs[i] = NewJob("test", j.Schedule, 1, NewSequence([]task.Task{task.EmptyTask{}}))
+		jobs[i].Labels = labels[i]
+		jobs[i].Priority = len(labels) - i
+		jobs[i].Status = StatusPending
+		if err := c.Add(jobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, jobs[0], 1)
+	waitForRuns(t, c, jobs[2], 1)
+	if err := o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	if current, _ := c.Get(jobs[1].Uuid); current.CountRuns() != 0 || current.Status != StatusPending {
+		t.Errorf("got %d runs with status %s, expected the job to be held back", current.CountRuns(), current.Status)
+	}
+}
+
+// newTestOrchestrators returns count orchestrators which each have their own catalog holding the same job
+func newTestOrchestrators(t *testing.T, count int, tasks []task.Task, configure func(i int, config *OrchestratorConfig)) ([]*Orchestrator, []*MemoryCatalog) {
+	t.Helper()
+
+	orchestrators := make([]*Orchestrator, count)
+	catalogs := make([]*MemoryCatalog, count)
+	jobId := uuid.New()
+	for i := range orchestrators {
+		o, c, j := newTestOrchestrator(t, tasks)
+		if err := c.Delete(j.Uuid); err != nil {
+			t.Fatal(err)
+		}
+		j.Uuid = jobId
+		if err := c.Add(j); err != nil {
+			t.Fatal(err)
+		}
+		configure(i, &o.config)
+		orchestrators[i], catalogs[i] = o, c
+	}
+	return orchestrators, catalogs
+}
+
+func countRuns(c *MemoryCatalog) int {
+	runs := 0
+	for _, j := range c.All() {
+		runs += j.CountRuns()
+	}
+	return runs
+}
+
+func TestOrchestrator_LeaderElection(t *testing.T) {
+	lockers := newTestSQLLockers(t, 3, time.Second)
+	orchestrators, catalogs := newTestOrchestrators(t, 3, []task.Task{task.EmptyTask{}}, func(i int, config *OrchestratorConfig) {
+		config.LeaderElector = NewLeaderElector(lockers[i], "leader", 100*time.Millisecond)
+	})
+	for _, o := range orchestrators {
+		if err := o.Start(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		defer o.Stop(context.Background())
+	}
+
+	// waitForLeader returns the orchestrator other than skip which runs jobs first
+	waitForLeader := func(skip int) int {
+		t.Helper()
+
+		runs := make([]int, len(catalogs))
+		for i, c := range catalogs {
+			runs[i] = countRuns(c)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			for i, c := range catalogs {
+				if i != skip && countRuns(c) > runs[i] {
+					return i
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("no orchestrator ran the job")
+		return -1
+	}
+
+	// Only the leader runs jobs, the others stand by
+	leader := waitForLeader(-1)
+	for i, o := range orchestrators {
+		if isLeader := o.Statistics().Leader; isLeader != (i == leader) {
+			t.Errorf("orchestrator %d reports leader %t, expected %t", i, isLeader, i == leader)
+		}
+	}
+
+	// Another orchestrator takes over when the leader stops
+	if err := orchestrators[leader].Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	stopped := countRuns(catalogs[leader])
+	next := waitForLeader(leader)
+	for i, c := range catalogs {
+		if i != leader && i != next && countRuns(c) > 0 {
+			t.Errorf("orchestrator %d ran the job without being the leader", i)
+		}
+	}
+	if runs := countRuns(catalogs[leader]); runs != stopped {
+		t.Errorf("stopped orchestrator ran the job %d times, expected %d", runs, stopped)
+	}
+}
+
+func TestOrchestrator_RunLock(t *testing.T) {
+	lockers := newTestSQLLockers(t, 3, time.Second)
+	orchestrators, catalogs := newTestOrchestrators(t, 3, []task.Task{task.SleepTask{Milliseconds: 100}}, func(i int, config *OrchestratorConfig) {
+		config.Locker = lockers[i]
+		config.LockRenewInterval = 20 * time.Millisecond
+	})
+	for _, o := range orchestrators {
+		if err := o.Start(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		defer o.Stop(context.Background())
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for total := 0; total < 4 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		total = 0
+		for _, c := range catalogs {
+			total += countRuns(c)
+		}
+	}
+	for _, o := range orchestrators {
+		if err := o.Drain(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+
+	// Every orchestrator schedules the job in its own catalog, but the run lock keeps the runs from overlapping
+	var results []Result
+	for _, c := range catalogs {
+		for _, j := range c.All() {
+			results = append(results, j.AllResults()...)
+		}
+	}
+	if len(results) < 4 {
+		t.Fatalf("got %d runs, expected at least 4", len(results))
+	}
+	for i := range results {
+		for k := i + 1; k < len(results); k++ {
+			a, b := results[i], results[k]
+			if a.Start.Before(b.Finish) && b.Start.Before(a.Finish) {
+				t.Errorf("runs started at %s and %s overlap", a.Start.Format(time.StampMilli), b.Start.Format(time.StampMilli))
+			}
+		}
+	}
+}
+
+func TestOrchestrator_Sharding(t *testing.T) {
+	r := task.NewHandlerRepository()
+	if err := r.RegisterHandlerPool(task.NewHandlerPool(task.NewDefaultIntercomMessageTaskHandler())); err != nil {
+		t.Fatal(err)
+	}
+	s, err := cron.NewSchedule("@everysecond")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewMemoryCatalog()
+	jobs := make([]Job, 30)
+	for i := range jobs {
+		jobs[i] = NewJob("job-"+strconv.Itoa(i), s, 1, NewSequence([]task.Task{task.IntercomMessageTask{Message: "run"}}))
+		if err = c.Add(jobs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// All members join before the orchestrators start, so the jobs are divided between them from the first run
+	instances := []string{"a", "b", "c"}
+	for _, instance := range instances {
+		if err = c.Join(instance, 3*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mux sync.Mutex
+	ran := make(map[string]string) // the instance which ran every job by job name
+	orchestrators := make([]*Orchestrator, len(instances))
+	for i, instance := range instances {
+		orchestrators[i] = NewOrchestrator(c, r, OrchestratorConfig{
+			MaxJobs:          2,
+			ScheduleInterval: 10 * time.Millisecond,
+			Sharding:         &ShardingConfig{Instance: instance, MemberTTL: 3 * time.Second},
+			MessageHandler: func(msg task.IntercomMessage) {
+				mux.Lock()
+				defer mux.Unlock()
+				if previous, found := ran[msg.Name]; found {
+					t.Errorf("%s ran by %s and %s, expected a single run", msg.Name, previous, instance)
+				}
+				ran[msg.Name] = instance
+			},
+		})
+		if err = orchestrators[i].Start(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		defer orchestrators[i].Stop(context.Background())
+	}
+
+	// ranJobs returns the number of jobs for which a run was reported
+	ranJobs := func() int {
+		mux.Lock()
+		defer mux.Unlock()
+		return len(ran)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for ranJobs() < len(jobs) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ranJobs() < len(jobs) {
+		t.Fatalf("%d of %d jobs ran", ranJobs(), len(jobs))
+	}
+
+	// Every job ran on its owner
+	owners, err := c.Owners()
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	mux.Lock()
+	for _, j := range jobs {
+		if ran[j.Name] != owners[j.Uuid] {
+			t.Errorf("%s ran by %q, expected its owner %q", j.Name, ran[j.Name], owners[j.Uuid])
+		}
+	}
+	mux.Unlock()
+
+	// waitForShards waits until the jobs are divided between members according to the statistics of o
+	waitForShards := func(o *Orchestrator, members int) {
+		t.Helper()
+
+		var shards []ShardStats
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			shards = o.Statistics().Shards
+			owned := 0.0
+			for _, shard := range shards {
+				owned += shard.OwnedJobs
+			}
+			if len(shards) == members && owned == float64(len(jobs)) {
+				for _, shard := range shards {
+					if shard.OwnedJobs == 0 {
+						t.Errorf("member %s owns no jobs", shard.Instance)
+					}
+				}
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("got shard stats %+v, expected %d jobs divided between %d members", shards, len(jobs), members)
+	}
+	waitForShards(orchestrators[0], len(instances))
+
+	// The jobs of a member which leaves are taken over by the remaining members
+	if err = orchestrators[0].Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForShards(orchestrators[1], len(instances)-1)
+}
+
+// countingCatalog counts the calls of All
+type countingCatalog struct {
+	*MemoryCatalog
+	all atomic.Int32
+}
+
+func (c *countingCatalog) All() []Job {
+	c.all.Add(1)
+	return c.MemoryCatalog.All()
+}
+
+// unreadableCatalog fails to read the first job which is requested with Get
+type unreadableCatalog struct {
+	*MemoryCatalog
+	failed atomic.Bool
+}
+
+func (c *unreadableCatalog) Get(jobId uuid.UUID) (Job, error) {
+	if c.failed.CompareAndSwap(false, true) {
+		return Job{}, errors.New("unavailable")
+	}
+	return c.MemoryCatalog.Get(jobId)
+}
+
+func TestOrchestrator_RunUnreadableJob(t *testing.T) {
+	o, m, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.catalog = &unreadableCatalog{MemoryCatalog: m}
+	chErrors := make(chan error, 10)
+	o.config.ErrorHandler = func(err error) {
+		chErrors <- err
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 2; i++ {
+		j.AddResult(Result{Start: start, Finish: start, Status: StatusCompleted})
+	}
+	if err := m.Update(j); err != nil {
+		t.Fatal(err)
+	}
+
+	// A job which cannot be read before its run is handed back and run with its complete history later
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer o.Stop(context.Background())
+	waitForRuns(t, m, j, 3)
+
+	select {
+	case err := <-chErrors:
+		if err.Error() != "unavailable" {
+			t.Errorf("got error %v, expected the read to fail", err)
+		}
+	default:
+		t.Errorf("got no error, expected the read to fail")
+	}
+	if current, _ := m.Get(j.Uuid); len(current.AllResults()) != 3 {
+		t.Errorf("got %d results, expected the run to be added to the complete history", len(current.AllResults()))
+	}
+}
+
+func TestOrchestrator_ShardingSettled(t *testing.T) {
+	o, m, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	c := &countingCatalog{MemoryCatalog: m}
+	o.catalog = c
+	o.config.Sharding = &ShardingConfig{Instance: "a", MemberTTL: 30 * time.Millisecond}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer o.Stop(context.Background())
+	waitForRuns(t, m, j, 1)
+
+	// The ownership of every job is settled by the first rebalance, later rebalances only claim pending jobs
+	time.Sleep(200 * time.Millisecond)
+	if calls := c.all.Load(); calls > 1 {
+		t.Errorf("got %d calls of All while the members did not change, expected 1", calls)
+	}
+
+	added := NewJob("added", j.Schedule, 1, NewSequence([]task.Task{task.EmptyTask{}}))
+	if err := m.Add(added); err != nil {
+		t.Fatal(err)
+	}
+	waitForRuns(t, m, added, 1)
+	if owners, _ := m.Owners(); owners[added.Uuid] != "a" {
+		t.Errorf("got owner %q of added job, expected %q", owners[added.Uuid], "a")
+	}
+}
+
+func TestOrchestrator_ShardingClaim(t *testing.T) {
+	o, m, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	o.config.Sharding = &ShardingConfig{Instance: "a", MemberTTL: time.Hour}
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	defer o.Stop(context.Background())
+	waitForRuns(t, m, j, 1)
+
+	// A job added after the first rebalance is claimed and run long before the membership is renewed
+	added := NewJob("added", j.Schedule, 1, NewSequence([]task.Task{task.EmptyTask{}}))
+	if err := m.Add(added); err != nil {
+		t.Fatal(err)
+	}
+	waitForRuns(t, m, added, 1)
+	if owners, _ := m.Owners(); owners[added.Uuid] != "a" {
+		t.Errorf("got owner %q of added job, expected %q", owners[added.Uuid], "a")
+	}
+}
+
+func TestOrchestrator_Statistics(t *testing.T) {
+	o, m, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	c := &countingCatalog{MemoryCatalog: m}
+	o.catalog = c
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		j.AddResult(Result{Start: start, Finish: start, Status: StatusCompleted, Tasks: []task.Task{task.EmptyTask{}}})
+	}
+	if err := m.Update(j); err != nil {
+		t.Fatal(err)
+	}
+
+	// The statistics are collected without loading the complete history of every job
+	stats := o.Statistics()
+	if calls := c.all.Load(); calls != 0 {
+		t.Errorf("got %d calls of All, expected 0", calls)
+	}
+	if stats.Job.ConfiguredJobs != 1 || stats.Job.InactiveJobs != 1 || len(stats.Tasks) != 1 || stats.Tasks[0].Completed != 1 {
+		t.Errorf("got stats %+v with tasks %+v, expected 1 inactive job with 1 completed task", stats.Job, stats.Tasks)
+	}
+}
+
+func TestOrchestrator_Trace(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}, task.SleepTask{Milliseconds: 10}})
+	recorder := trace.NewRecorder()
+	o.config.Tracer = recorder
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+	if err := o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	var run trace.SpanData
+	children := make(map[trace.SpanId][]trace.SpanData)
+	for _, span := range recorder.Spans() {
+		if span.Name == "job.run" && span.Attributes[trace.AttrJobRun] == 1 {
+			run = span
+		}
+		children[span.Parent.SpanId] = append(children[span.Parent.SpanId], span)
+	}
+	if run.Name == "" {
+		t.Fatalf("no span for the first run")
+	}
+	if run.Attributes[trace.AttrJobId] != j.Uuid.String() || run.Attributes[trace.AttrJobName] != j.Name ||
+		run.Attributes[trace.AttrJobStatus] != StatusCompleted.String() || run.Status != trace.StatusOK {
+		t.Errorf("got run span with status %s and attributes %v", run.Status, run.Attributes)
+	}
+
+	tasks := children[run.SpanContext.SpanId]
+	if len(tasks) != 2 {
+		t.Fatalf("got %d child spans of the run, expected a span for every task", len(tasks))
+	}
+	for i, wanted := range []string{task.EmptyTask{}.Type(), task.SleepTask{}.Type()} {
+		if tasks[i].Name != "task.execute" || tasks[i].Attributes[trace.AttrTaskType] != wanted {
+			t.Errorf("got span %s for task type %v, expected a task span for %s", tasks[i].Name, tasks[i].Attributes[trace.AttrTaskType], wanted)
+		}
+		if waits := children[tasks[i].SpanContext.SpanId]; len(waits) != 1 || waits[0].Name != "handler_pool.wait" {
+			t.Errorf("got child spans %v of task %s, expected the wait for a handler", waits, wanted)
+		}
+	}
+}
+
+// logBuffer collects the lines written by a slog.JSONHandler
+type logBuffer struct {
+	lines []map[string]any
+	mux   sync.Mutex
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	var line map[string]any
+	if err := json.Unmarshal(p, &line); err != nil {
+		return 0, err
+	}
+	b.mux.Lock()
+	b.lines = append(b.lines, line)
+	b.mux.Unlock()
+	return len(p), nil
+}
+
+// find returns the lines with message msg
+func (b *logBuffer) find(msg string) []map[string]any {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	var lines []map[string]any
+	for _, line := range b.lines {
+		if line[slog.MessageKey] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestOrchestrator_Logger(t *testing.T) {
+	o, c, j := newTestOrchestrator(t, []task.Task{task.EmptyTask{}, task.SleepTask{Milliseconds: 10}})
+	logs := &logBuffer{}
+	o.config.Logger = slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	waitForRuns(t, c, j, 1)
+	if err := o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Every line of a run carries the job, the run and the attempt
+	isFirstRun := func(line map[string]any) bool {
+		job, _ := line["job"].(map[string]any)
+		return job["id"] == j.Uuid.String() && job["name"] == j.Name && line["run"] == float64(1) && line["attempt"] == float64(1)
+	}
+	for _, msg := range []string{"job run started", "job run finished"} {
+		if lines := logs.find(msg); len(lines) == 0 || !isFirstRun(lines[0]) {
+			t.Errorf("got %v for %q, expected the first run of the job", lines, msg)
+		}
+	}
+	if lines := logs.find("job run finished"); len(lines) > 0 && lines[0]["status"] != StatusCompleted.String() {
+		t.Errorf("got status %v, expected %s", lines[0]["status"], StatusCompleted)
+	}
+
+	var tasks []string
+	for _, line := range logs.find("task finished") {
+		if !isFirstRun(line) {
+			continue
+		}
+		attrs, _ := line["task"].(map[string]any)
+		if attrs["status"] != task.StatusCompleted.String() {
+			t.Errorf("got task %v, expected status %s", attrs, task.StatusCompleted)
+		}
+		tasks = append(tasks, attrs["type"].(string))
+	}
+	if len(tasks) != 2 || tasks[0] != (task.EmptyTask{}).Type() || tasks[1] != (task.SleepTask{}).Type() {
+		t.Errorf("got finished tasks %v, expected a line for every task", tasks)
+	}
+
+	// The job went through the whole flow
+	seen := make(map[string]bool)
+	for _, line := range logs.find("job transition") {
+		seen[line["from"].(string)+">"+line["to"].(string)] = true
+	}
+	for _, wanted := range []string{"inactive>available", "available>schedulable", "schedulable>runnable", "runnable>pending", "pending>active"} {
+		if !seen[wanted] {
+			t.Errorf("no transition %s in %v", wanted, seen)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PriorityClass int
+
+func (c PriorityClass) String() string {
+	switch c {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "class(" + strconv.Itoa(int(c)) + ")"
+	}
+}
+
+const (
+	PriorityLow PriorityClass = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ClassOf returns the class of a priority, negative priorities are low, zero is normal and positive priorities are high
+func ClassOf(priority int) PriorityClass {
+	switch {
+	case priority < 0:
+		return PriorityLow
+	case priority > 0:
+		return PriorityHigh
+	default:
+		return PriorityNormal
+	}
+}
+
+// pendingJob is a job waiting to be dispatched to a runner
+type pendingJob struct {
+	job      Job
+	priority int       // the priority of the job including aging
+	since    time.Time // the time at which the orchestrator first saw the job pending
+}
+
+// prioritize orders pending jobs for dispatch, from the highest to the lowest priority. The priority of a job is
+// increased by one for every aging interval it has been waiting, so low priority jobs are not starved by a steady
+// stream of higher priority jobs. Jobs with the same priority are dispatched in the order in which they became pending.
+// waiting holds the time at which every job was first seen pending, it is updated to contain only jobs.
+func prioritize(jobs []Job, waiting map[uuid.UUID]time.Time, aging time.Duration, now time.Time) []pendingJob {
+	seen := make(map[uuid.UUID]bool, len(jobs))
+	pending := make([]pendingJob, len(jobs))
+	for i, job := range jobs {
+		since, found := waiting[job.Uuid]
+		if !found {
+			since = now
+			waiting[job.Uuid] = since
+		}
+		seen[job.Uuid] = true
+
+		priority := job.Priority
+		if aging > 0 {
+			priority += int(now.Sub(since) / aging)
+		}
+		pending[i] = pendingJob{job: job, priority: priority, since: since}
+	}
+
+	// Forget jobs which are no longer pending
+	for id := range waiting {
+		if !seen[id] {
+			delete(waiting, id)
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool {
+		switch {
+		case pending[i].priority != pending[j].priority:
+			return pending[i].priority > pending[j].priority
+		case !pending[i].since.Equal(pending[j].since):
+			return pending[i].since.Before(pending[j].since)
+		default:
+			return pending[i].job.Uuid.String() < pending[j].job.Uuid.String()
+		}
+	})
+	return pending
+}
+
+// reservedSlots returns the number of runners reserved for every priority class, reservations is the fraction of
+// maxJobs reserved per class. The total number of reserved runners never exceeds maxJobs.
+func reservedSlots(reservations map[PriorityClass]float64, maxJobs int) map[PriorityClass]int {
+	slots := make(map[PriorityClass]int, len(reservations))
+	available := maxJobs
+	for c := PriorityHigh; c >= PriorityLow; c-- {
+		n := min(int(reservations[c]*float64(maxJobs)), available)
+		if n > 0 {
+			slots[c] = n
+			available -= n
+		}
+	}
+	return slots
+}
+
+// hasCapacity reports whether a runner is available for a job of class, given the number of dispatched jobs per class.
+// Runners reserved for a class can only be used by jobs of that class or a higher class.
+func hasCapacity(class PriorityClass, dispatched map[PriorityClass]int, reserved map[PriorityClass]int, maxJobs int) bool {
+	for level := class; level <= PriorityHigh; level++ {
+		used := 0
+		for c := PriorityLow; c <= level; c++ {
+			used += dispatched[c]
+		}
+		limit := maxJobs
+		for c := level + 1; c <= PriorityHigh; c++ {
+			limit -= reserved[c]
+		}
+		if used >= limit {
+			return false
+		}
+	}
+	return true
+}
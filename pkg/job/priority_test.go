@@ -0,0 +1,97 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestClassOf(t *testing.T) {
+	tests := map[int]PriorityClass{-10: PriorityLow, -1: PriorityLow, 0: PriorityNormal, 1: PriorityHigh, 10: PriorityHigh}
+	for priority, wanted := range tests {
+		if class := ClassOf(priority); class != wanted {
+			t.Errorf("got class %s for priority %d, expected %s", class, priority, wanted)
+		}
+	}
+}
+
+func TestPriorityClass_String(t *testing.T) {
+	tests := map[PriorityClass]string{PriorityLow: "low", PriorityNormal: "normal", PriorityHigh: "high", PriorityClass(5): "class(5)"}
+	for class, wanted := range tests {
+		if got := class.String(); got != wanted {
+			t.Errorf("got %q, expected %q", got, wanted)
+		}
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	now := time.Now()
+	low := Job{Uuid: uuid.New(), Priority: -1}
+	old := Job{Uuid: uuid.New(), Priority: -1}
+	normal := Job{Uuid: uuid.New()}
+	high := Job{Uuid: uuid.New(), Priority: 1}
+	gone := uuid.New()
+
+	// The old low priority job has been waiting for three aging intervals, which makes it more urgent than the high
+	// priority job
+	waiting := map[uuid.UUID]time.Time{old.Uuid: now.Add(-3 * time.Minute), gone: now}
+	pending := prioritize([]Job{low, normal, high, old}, waiting, time.Minute, now)
+
+	wanted := []uuid.UUID{old.Uuid, high.Uuid, normal.Uuid, low.Uuid}
+	for i, p := range pending {
+		if p.job.Uuid != wanted[i] {
+			t.Errorf("got job %s with priority %d at position %d, expected %s", p.job.Uuid, p.priority, i, wanted[i])
+		}
+	}
+	if pending[0].priority != 2 {
+		t.Errorf("got priority %d for aged job, expected 2", pending[0].priority)
+	}
+	if _, found := waiting[gone]; found || len(waiting) != 4 {
+		t.Errorf("got %d waiting jobs, expected the 4 pending jobs", len(waiting))
+	}
+}
+
+func TestHasCapacity(t *testing.T) {
+	reserved := reservedSlots(map[PriorityClass]float64{PriorityHigh: 0.2, PriorityNormal: 0.3}, 10)
+	if reserved[PriorityHigh] != 2 || reserved[PriorityNormal] != 3 || reserved[PriorityLow] != 0 {
+		t.Fatalf("got reserved slots %v, expected 2 high and 3 normal", reserved)
+	}
+
+	tests := []struct {
+		name       string
+		dispatched map[PriorityClass]int
+		class      PriorityClass
+		wanted     bool
+	}{
+		{"LowUnreserved", map[PriorityClass]int{PriorityLow: 4}, PriorityLow, true},
+		{"LowReserved", map[PriorityClass]int{PriorityLow: 5}, PriorityLow, false},
+		{"NormalReservedForNormal", map[PriorityClass]int{PriorityLow: 5, PriorityNormal: 2}, PriorityNormal, true},
+		{"NormalReservedForHigh", map[PriorityClass]int{PriorityLow: 5, PriorityNormal: 3}, PriorityNormal, false},
+		{"HighReserved", map[PriorityClass]int{PriorityLow: 5, PriorityNormal: 3, PriorityHigh: 1}, PriorityHigh, true},
+		{"Full", map[PriorityClass]int{PriorityLow: 5, PriorityNormal: 3, PriorityHigh: 2}, PriorityHigh, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := hasCapacity(test.class, test.dispatched, reserved, 10); result != test.wanted {
+				t.Errorf("got %t, expected %t", result, test.wanted)
+			}
+		})
+	}
+}
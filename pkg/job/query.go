@@ -0,0 +1,261 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SortField int
+
+func (f SortField) String() string {
+	return [...]string{"name", "nextRun", "lastRun"}[f]
+}
+
+const (
+	SortByName SortField = iota
+	SortByNextRun
+	SortByLastRun
+)
+
+// Query selects jobs from a catalog, zero values do not filter.
+// The last run of a job is the last run which has finished, LastResult is the status of that run.
+// Next runs are calculated at Now, which defaults to the current time.
+// Results are returned in pages of at most Limit jobs, the cursor of a page continues the query on the next page.
+type Query struct {
+	Namespace   string
+	Name        string            // pattern as accepted by path.Match
+	Labels      map[string]string // jobs must have all labels with the same value
+	Selector    Selector
+	Enabled     *bool
+	Status      []Status // jobs must have one of the statuses
+	LastResult  Status
+	LastRunFrom time.Time // inclusive
+	LastRunTo   time.Time // exclusive
+	NextRunFrom time.Time // inclusive
+	NextRunTo   time.Time // exclusive
+	Now         time.Time
+	Sort        SortField
+	Descending  bool
+	Cursor      string
+	Limit       int
+}
+
+type JobPage struct {
+	Jobs   []Job
+	Cursor string // empty if there are no more jobs
+}
+
+// validate checks the query and sets the defaults
+func (q *Query) validate() error {
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	if _, err := path.Match(q.Name, ""); err != nil {
+		return fmt.Errorf("invalid name pattern %q: %w", q.Name, err)
+	}
+	if q.Sort < SortByName || q.Sort > SortByLastRun {
+		return fmt.Errorf("invalid sort field %d", q.Sort)
+	}
+	if _, _, err := decodeCursor(q.Cursor); err != nil {
+		return err
+	}
+	return nil
+}
+
+// matches reports whether job matches all filters of the query
+func (q *Query) matches(job Job) bool {
+	if q.Namespace != "" && job.Namespace != q.Namespace {
+		return false
+	}
+	if q.Name != "" {
+		if matched, _ := path.Match(q.Name, job.Name); !matched {
+			return false
+		}
+	}
+	for k, v := range q.Labels {
+		if value, found := job.Labels[k]; !found || value != v {
+			return false
+		}
+	}
+	if !q.Selector.Matches(job.Labels) {
+		return false
+	}
+	if q.Enabled != nil && job.IsEnabled() != *q.Enabled {
+		return false
+	}
+	if len(q.Status) > 0 && !hasStatus(q.Status, job.Status) {
+		return false
+	}
+
+	if q.LastResult != StatusNone || !q.LastRunFrom.IsZero() || !q.LastRunTo.IsZero() {
+		last, found := lastRun(job)
+		if !found || !inRange(last.Start, q.LastRunFrom, q.LastRunTo) {
+			return false
+		}
+		if q.LastResult != StatusNone && last.Status != q.LastResult {
+			return false
+		}
+	}
+
+	if !q.NextRunFrom.IsZero() || !q.NextRunTo.IsZero() {
+		next := job.NextRun(q.Now)
+		if next.IsZero() || !inRange(next, q.NextRunFrom, q.NextRunTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKey returns a key for job which sorts in the order of the sort field of the query
+func (q *Query) sortKey(job Job) string {
+	switch q.Sort {
+	case SortByNextRun:
+		// Jobs which do not run again are sorted last
+		next := job.NextRun(q.Now)
+		if next.IsZero() {
+			return strings.Repeat("9", 20)
+		}
+		return timeKey(next)
+	case SortByLastRun:
+		// Jobs which have not run yet are sorted first
+		last, found := lastRun(job)
+		if !found {
+			return timeKey(time.Time{})
+		}
+		return timeKey(last.Start)
+	default:
+		return job.Name
+	}
+}
+
+// findJobs returns the page of jobs selected by the query, the query must be validated
+func findJobs(jobs []Job, q Query) JobPage {
+	type entry struct {
+		key string
+		job Job
+	}
+
+	entries := make([]entry, 0, len(jobs))
+	for _, job := range jobs {
+		if q.matches(job) {
+			entries = append(entries, entry{key: q.sortKey(job), job: job})
+		}
+	}
+
+	less := func(a, b entry) bool {
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.job.Uuid.String() < b.job.Uuid.String()
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if q.Descending {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+
+	// Skip all jobs up to and including the job of the cursor
+	if q.Cursor != "" {
+		key, id, _ := decodeCursor(q.Cursor)
+		from := entry{key: key, job: Job{Uuid: id}}
+		entries = entries[sort.Search(len(entries), func(i int) bool {
+			if q.Descending {
+				return less(entries[i], from)
+			}
+			return less(from, entries[i])
+		}):]
+	}
+
+	page := JobPage{Jobs: make([]Job, 0, len(entries))}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+		last := entries[len(entries)-1]
+		page.Cursor = encodeCursor(last.key, last.job.Uuid)
+	}
+	for _, e := range entries {
+		page.Jobs = append(page.Jobs, e.job)
+	}
+	return page
+}
+
+func encodeCursor(key string, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + "\x00" + id.String()))
+}
+
+func decodeCursor(cursor string) (string, uuid.UUID, error) {
+	if cursor == "" {
+		return "", uuid.Nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	key, id, found := strings.Cut(string(data), "\x00")
+	if !found {
+		return "", uuid.Nil, fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	var u uuid.UUID
+	if u, err = uuid.Parse(id); err != nil {
+		return "", uuid.Nil, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return key, u, nil
+}
+
+func hasStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func inRange(t time.Time, from time.Time, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// lastRun returns the last finished result of job
+func lastRun(job Job) (Result, bool) {
+	history := job.AllResults()
+	for i := len(history) - 1; i >= 0; i-- {
+		if !history[i].Finish.IsZero() {
+			return history[i], true
+		}
+	}
+	return Result{}, false
+}
+
+// timeKey returns a key which sorts in chronological order for times after 1970
+func timeKey(t time.Time) string {
+	if t.IsZero() {
+		return strings.Repeat("0", 20)
+	}
+	s := strconv.FormatInt(t.UnixNano(), 10)
+	return strings.Repeat("0", max(20-len(s), 0)) + s
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AnnotationSource is set on the jobs managed by a Reconciler to the source of their definitions
+	AnnotationSource = "go-scheduler/source"
+	// AnnotationDigest is set on the jobs managed by a Reconciler to the digest of their definition, a job is only
+	// updated when its definition changes
+	AnnotationDigest = "go-scheduler/digest"
+)
+
+func NewReconciler(o *Orchestrator, config ReconcilerConfig) *Reconciler {
+	if config.Source == "" {
+		config.Source = DefaultSource
+	}
+	return &Reconciler{
+		config:       config,
+		orchestrator: o,
+	}
+}
+
+// Reconciler makes the jobs in the catalog of an orchestrator match a desired set of jobs, such as the jobs defined
+// by files. Jobs are matched by their Uuid, so desired jobs need stable identifiers to keep their history.
+// A job is only updated when its definition changes, changes made to a job through other means, such as disabling
+// it, are kept until then. The run state of an updated job is preserved, a run in flight finishes with the
+// definition it started with.
+type Reconciler struct {
+	config       ReconcilerConfig
+	orchestrator *Orchestrator
+	mux          sync.Mutex
+}
+
+// ReconcileResult counts the changes made by a reconciliation
+type ReconcileResult struct {
+	Added     int
+	Updated   int
+	Unchanged int
+	Removed   int // deleted or disabled, as decided by the RemovalPolicy
+	Deferred  int // jobs which are deleted once their run in flight has finished
+}
+
+// Changed reports whether the reconciliation changed the catalog
+func (r ReconcileResult) Changed() bool {
+	return r.Added > 0 || r.Updated > 0 || r.Removed > 0 || r.Deferred > 0
+}
+
+// Reconcile adds the desired jobs which do not exist, updates the desired jobs of which the definition changed and
+// removes the managed jobs which are no longer desired. The changes which can be made are made, the errors of the
+// others are joined.
+func (r *Reconciler) Reconcile(desired []Job) (ReconcileResult, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var (
+		result  ReconcileResult
+		errs    []error
+		catalog = r.orchestrator.catalog
+		wanted  = make(map[uuid.UUID]struct{}, len(desired))
+	)
+	for _, j := range desired {
+		if _, found := wanted[j.Uuid]; found {
+			errs = append(errs, ErrExist.WithJob(j.Uuid, j.QualifiedName()).WithCause(errors.New("desired more than once")))
+			continue
+		}
+		wanted[j.Uuid] = struct{}{}
+
+		added, updated, err := r.apply(j)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case added:
+			result.Added++
+		case updated:
+			result.Updated++
+		default:
+			result.Unchanged++
+		}
+	}
+
+	for _, j := range catalog.All() {
+		if _, found := wanted[j.Uuid]; found || j.Annotations[AnnotationSource] != r.config.Source {
+			continue
+		}
+		removed, deferred, err := r.remove(j)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case removed:
+			result.Removed++
+		case deferred:
+			result.Deferred++
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// apply adds or updates the desired job j
+func (r *Reconciler) apply(j Job) (bool, bool, error) {
+	digest, err := definitionDigest(j)
+	if err != nil {
+		return false, false, err
+	}
+	annotations := cloneMap(j.Annotations)
+	if annotations == nil {
+		annotations = make(map[string]string, 2)
+	}
+	annotations[AnnotationSource] = r.config.Source
+	annotations[AnnotationDigest] = digest
+
+	catalog := r.orchestrator.catalog
+	for {
+		current, err := catalog.Get(j.Uuid)
+		if errors.Is(err, ErrNotFound) {
+			added := j.clone()
+			added.Annotations = annotations
+			added.Status = StatusInactive
+			added.History, added.Runs, added.Revision = nil, 0, 0
+			return true, false, r.orchestrator.Add(added)
+		}
+		if err != nil {
+			return false, false, err
+		}
+		if current.Annotations[AnnotationSource] != r.config.Source {
+			return false, false, ErrExist.WithJob(j.Uuid, current.QualifiedName()).WithCause(errors.New("the job is not managed by source " + r.config.Source))
+		}
+		if current.Annotations[AnnotationDigest] == digest {
+			return false, false, nil
+		}
+
+		// Replace the definition, keep the run state of the current job
+		updated := j.clone()
+		updated.Annotations = annotations
+		updated.Status = current.Status
+		updated.Triggered = current.Triggered
+		updated.History = current.History
+		updated.Runs = current.Runs
+		updated.Revision = current.Revision
+		if err = r.orchestrator.Validate(updated); err != nil {
+			return false, false, err
+		}
+
+		err = catalog.UpdateIf(updated, current.Revision)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		return false, err == nil, err
+	}
+}
+
+// remove deletes or disables a managed job which is no longer desired
+func (r *Reconciler) remove(j Job) (bool, bool, error) {
+	catalog := r.orchestrator.catalog
+	if r.config.Removal == RemoveDisable {
+		for {
+			if !j.Enabled && j.Annotations[AnnotationDigest] == "" {
+				return false, false, nil
+			}
+			// Clear the digest, so the job is updated and enabled as defined when it is desired again
+			disabled := j
+			disabled.Enabled = false
+			disabled.Annotations = cloneMap(j.Annotations)
+			delete(disabled.Annotations, AnnotationDigest)
+
+			err := catalog.UpdateIf(disabled, j.Revision)
+			if errors.Is(err, ErrConflict) {
+				// Disable the latest version of the job, unless it is no longer managed by the source
+				if j, err = catalog.Get(j.Uuid); errors.Is(err, ErrNotFound) {
+					return false, false, nil
+				}
+				if err != nil {
+					return false, false, err
+				}
+				if j.Annotations[AnnotationSource] != r.config.Source {
+					return false, false, nil
+				}
+				continue
+			}
+			if err != nil {
+				return false, false, err
+			}
+			return true, false, nil
+		}
+	}
+
+	switch j.Status {
+	case StatusRunnable, StatusPending, StatusActive:
+		if j.Enabled {
+			if err := catalog.Disable(j.Uuid); err != nil {
+				return false, false, err
+			}
+		}
+		return false, true, nil
+	default:
+		return true, false, catalog.Delete(j.Uuid)
+	}
+}
+
+// definitionDigest returns a digest of the definition of j, which excludes its identity and its run state
+func definitionDigest(j Job) (string, error) {
+	definition := Job{
+		Namespace:   j.Namespace,
+		Name:        j.Name,
+		Labels:      j.Labels,
+		Annotations: cloneMap(j.Annotations),
+		Enabled:     j.Enabled,
+		Schedule:    j.Schedule,
+		MaxRuns:     j.MaxRuns,
+		Priority:    j.Priority,
+		Concurrency: j.Concurrency,
+		Tasks:       j.Tasks,
+		mux:         &sync.Mutex{},
+	}
+	delete(definition.Annotations, AnnotationSource)
+	delete(definition.Annotations, AnnotationDigest)
+
+	record, err := newJobRecord(definition)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("job %s: %w", j.QualifiedName(), err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+// DefaultSource is used when ReconcilerConfig.Source is not set
+const DefaultSource = "default"
+
+// RemovalPolicy decides what happens to a managed job which is no longer desired
+type RemovalPolicy int
+
+func (p RemovalPolicy) String() string {
+	return [...]string{"delete", "disable"}[p]
+}
+
+const (
+	// RemoveDelete deletes jobs which are no longer desired, jobs with a run in flight are disabled and deleted by
+	// the first reconciliation after their run has finished
+	RemoveDelete RemovalPolicy = iota
+	// RemoveDisable disables jobs which are no longer desired, their history is kept until they are desired again
+	RemoveDisable
+)
+
+type ReconcilerConfig struct {
+	// Source identifies the jobs managed by a reconciler, jobs of other sources and jobs created through other means
+	// are never changed. Defaults to DefaultSource.
+	Source  string
+	Removal RemovalPolicy
+}
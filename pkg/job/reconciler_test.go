@@ -0,0 +1,241 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func newDesiredJob(t *testing.T, jobId uuid.UUID, name string, expression string, tasks ...task.Task) Job {
+	t.Helper()
+
+	s, err := cron.NewSchedule(expression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := NewJob(name, s, 0, NewSequence(tasks))
+	j.Uuid = jobId
+	j.Namespace = "files"
+	j.Enabled = true
+	return j
+}
+
+func TestReconciler_Reconcile(t *testing.T) {
+	o, c, unmanaged := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	r := NewReconciler(o, ReconcilerConfig{Source: "files"})
+
+	a := newDesiredJob(t, uuid.New(), "a", "@hourly", task.EmptyTask{})
+	b := newDesiredJob(t, uuid.New(), "b", "@daily", task.SleepTask{Milliseconds: 1})
+	result, err := r.Reconcile([]Job{a, b})
+	if err != nil || result != (ReconcileResult{Added: 2}) {
+		t.Fatalf("got result %+v and error %v, expected 2 added jobs", result, err)
+	}
+	if stored, _ := c.Get(a.Uuid); stored.Annotations[AnnotationSource] != "files" || !stored.IsEnabled() {
+		t.Errorf("got annotations %v, expected an enabled job managed by files", stored.Annotations)
+	}
+
+	// Changes made through the catalog are kept until the definition changes
+	if err = c.Disable(a.Uuid); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := c.Get(b.Uuid)
+	stored.AddResult(Result{Status: StatusCompleted})
+	if err = c.Update(stored); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = r.Reconcile([]Job{a, b}); err != nil || result != (ReconcileResult{Unchanged: 2}) {
+		t.Fatalf("got result %+v and error %v, expected 2 unchanged jobs", result, err)
+	}
+	if stored, _ = c.Get(a.Uuid); stored.IsEnabled() {
+		t.Error("got an enabled job, expected the job to remain disabled")
+	}
+
+	b = newDesiredJob(t, b.Uuid, "b", "@hourly", task.SleepTask{Milliseconds: 2})
+	if result, err = r.Reconcile([]Job{b}); err != nil || result != (ReconcileResult{Updated: 1, Removed: 1}) {
+		t.Fatalf("got result %+v and error %v, expected an updated and a removed job", result, err)
+	}
+	if _, err = c.Get(a.Uuid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, expected the removed job to be deleted", err)
+	}
+	stored, _ = c.Get(b.Uuid)
+	if stored.Schedule.String() != "0 * * * *" || stored.CountRuns() != 1 || stored.Tasks.Tasks[0] != (task.SleepTask{Milliseconds: 2}) {
+		t.Errorf("got schedule %s, %d runs and tasks %v, expected the new definition with the history", stored.Schedule.String(), stored.CountRuns(), stored.Tasks.Tasks)
+	}
+	if _, err = c.Get(unmanaged.Uuid); err != nil {
+		t.Errorf("got error %v, expected the unmanaged job to be kept", err)
+	}
+
+	// Unmanaged jobs are never changed
+	taken := newDesiredJob(t, unmanaged.Uuid, "test", "@hourly", task.EmptyTask{})
+	if _, err = r.Reconcile([]Job{b, taken}); !errors.Is(err, ErrExist) {
+		t.Errorf("got error %v, expected ErrExist", err)
+	}
+	missing := newDesiredJob(t, uuid.New(), "c", "@hourly", task.PrintTask{})
+	if _, err = r.Reconcile([]Job{b, missing}); !errors.Is(err, ErrHandlerMissing) {
+		t.Errorf("got error %v, expected ErrHandlerMissing", err)
+	}
+}
+
+func TestReconciler_RemoveDisable(t *testing.T) {
+	o, c, _ := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	r := NewReconciler(o, ReconcilerConfig{Removal: RemoveDisable})
+
+	a := newDesiredJob(t, uuid.New(), "a", "@hourly", task.EmptyTask{})
+	if _, err := r.Reconcile([]Job{a}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := r.Reconcile(nil)
+	if err != nil || result != (ReconcileResult{Removed: 1}) {
+		t.Fatalf("got result %+v and error %v, expected a removed job", result, err)
+	}
+	if stored, err := c.Get(a.Uuid); err != nil || stored.IsEnabled() || stored.Annotations[AnnotationSource] != DefaultSource {
+		t.Errorf("got error %v, expected the job to be kept disabled", err)
+	}
+	if result, err = r.Reconcile(nil); err != nil || result.Changed() {
+		t.Errorf("got result %+v and error %v, expected no changes", result, err)
+	}
+
+	if result, err = r.Reconcile([]Job{a}); err != nil || result != (ReconcileResult{Updated: 1}) {
+		t.Fatalf("got result %+v and error %v, expected an updated job", result, err)
+	}
+	if stored, _ := c.Get(a.Uuid); !stored.IsEnabled() {
+		t.Error("got a disabled job, expected the job to be enabled as defined")
+	}
+}
+
+// racingCatalog stores a change to a job right before the first conditional update of the job
+type racingCatalog struct {
+	*MemoryCatalog
+	raced bool
+}
+
+func (c *racingCatalog) UpdateIf(job Job, expectedRevision uint64) error {
+	if !c.raced {
+		c.raced = true
+		stored, err := c.Get(job.Uuid)
+		if err != nil {
+			return err
+		}
+		stored.AddResult(Result{Status: StatusCompleted})
+		if err = c.MemoryCatalog.Update(stored); err != nil {
+			return err
+		}
+	}
+	return c.MemoryCatalog.UpdateIf(job, expectedRevision)
+}
+
+func TestReconciler_RemoveDisableConflict(t *testing.T) {
+	o, c, _ := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	r := NewReconciler(o, ReconcilerConfig{Removal: RemoveDisable})
+
+	a := newDesiredJob(t, uuid.New(), "a", "@hourly", task.EmptyTask{})
+	if _, err := r.Reconcile([]Job{a}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The job is changed between reading and disabling it, the change is kept and the job is disabled
+	o.catalog = &racingCatalog{MemoryCatalog: c}
+	result, err := r.Reconcile(nil)
+	if err != nil || result != (ReconcileResult{Removed: 1}) {
+		t.Fatalf("got result %+v and error %v, expected a removed job", result, err)
+	}
+	if stored, _ := c.Get(a.Uuid); stored.IsEnabled() || stored.CountRuns() != 1 {
+		t.Errorf("got enabled %t and %d runs, expected the changed job to be disabled", stored.IsEnabled(), stored.CountRuns())
+	}
+}
+
+func TestReconciler_InFlight(t *testing.T) {
+	o, c, unmanaged := newTestOrchestrator(t, []task.Task{task.EmptyTask{}})
+	if err := c.Delete(unmanaged.Uuid); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReconciler(o, ReconcilerConfig{})
+
+	a := newDesiredJob(t, uuid.New(), "a", "@everysecond", task.SleepTask{Milliseconds: 300})
+	if _, err := r.Reconcile([]Job{a}); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop(context.Background())
+
+	waitForStatus := func(status Status) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if stored, _ := c.Get(a.Uuid); stored.Status == status {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("job did not reach status %s", status)
+	}
+	waitForStatus(StatusActive)
+
+	// The active run finishes with the definition it started with
+	changed := newDesiredJob(t, a.Uuid, "a", "@everysecond", task.EmptyTask{})
+	if result, err := r.Reconcile([]Job{changed}); err != nil || result != (ReconcileResult{Updated: 1}) {
+		t.Fatalf("got result %+v and error %v, expected an updated job", result, err)
+	}
+	var stored Job
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if stored, _ = c.Get(a.Uuid); len(stored.History) > 0 && !stored.History[0].Finish.IsZero() {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if tasks := stored.AllResults()[0].Tasks; len(tasks) != 1 || tasks[0].Type() != (task.SleepTask{}).Type() {
+		t.Errorf("got tasks %v in the first run, expected the sleep task", tasks)
+	}
+	if stored.Tasks.Tasks[0] != (task.EmptyTask{}) {
+		t.Errorf("got tasks %v, expected the new definition", stored.Tasks.Tasks)
+	}
+
+	// A job with a run in flight is disabled and deleted once the run has finished
+	if _, err := r.Reconcile([]Job{a}); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(StatusActive)
+	result, err := r.Reconcile(nil)
+	if err != nil || result != (ReconcileResult{Deferred: 1}) {
+		t.Fatalf("got result %+v and error %v, expected a deferred removal", result, err)
+	}
+	if stored, _ = c.Get(a.Uuid); stored.IsEnabled() {
+		t.Error("got an enabled job, expected the job to be disabled until its run has finished")
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if result, err = r.Reconcile(nil); err != nil || result.Removed == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err = c.Get(a.Uuid); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v, expected the job to be deleted after its run", err)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import "time"
+
+// RetentionPolicy determines which results of a job are kept.
+// A result is kept if it is one of the last KeepLast results, if it finished within KeepFor,
+// or if it failed and finished within KeepFailuresFor. Results of runs which have not finished are always kept.
+// The zero value keeps all results.
+type RetentionPolicy struct {
+	KeepLast        int
+	KeepFor         time.Duration
+	KeepFailuresFor time.Duration
+}
+
+// IsZero reports whether the policy keeps all results
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast == 0 && p.KeepFor == 0 && p.KeepFailuresFor == 0
+}
+
+// Apply returns the results which are kept at time now, results must be ordered from oldest to newest
+func (p RetentionPolicy) Apply(results []Result, now time.Time) []Result {
+	if p.IsZero() {
+		return results
+	}
+
+	kept := make([]Result, 0, min(len(results), max(p.KeepLast, 1)))
+	for i, r := range results {
+		if p.keep(r, len(results)-i, now) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// keep reports whether result r is kept, age is the position of the result counting back from the newest result
+func (p RetentionPolicy) keep(r Result, age int, now time.Time) bool {
+	switch {
+	case r.Finish.IsZero():
+		return true
+	case age <= p.KeepLast:
+		return true
+	case p.KeepFor > 0 && now.Sub(r.Finish) <= p.KeepFor:
+		return true
+	case p.KeepFailuresFor > 0 && r.Status == StatusError && now.Sub(r.Finish) <= p.KeepFailuresFor:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicy_Apply(t *testing.T) {
+	now := time.Now()
+	results := []Result{
+		{Status: StatusError, Finish: now.Add(-3 * time.Hour)},
+		{Status: StatusCompleted, Finish: now.Add(-2 * time.Hour)},
+		{Status: StatusError, Finish: now.Add(-30 * time.Minute)},
+		{Status: StatusCompleted, Finish: now.Add(-time.Minute)},
+		{Status: StatusActive},
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		wanted []int
+	}{
+		{"Zero", RetentionPolicy{}, []int{0, 1, 2, 3, 4}},
+		{"KeepLast", RetentionPolicy{KeepLast: 2}, []int{3, 4}},
+		{"KeepFor", RetentionPolicy{KeepFor: time.Hour}, []int{2, 3, 4}},
+		{"KeepFailuresFor", RetentionPolicy{KeepLast: 1, KeepFailuresFor: 4 * time.Hour}, []int{0, 2, 4}},
+		{"Combined", RetentionPolicy{KeepLast: 2, KeepFor: 90 * time.Minute, KeepFailuresFor: 4 * time.Hour}, []int{0, 2, 3, 4}},
+		{"Unfinished", RetentionPolicy{KeepFor: time.Second}, []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := tt.policy.Apply(results, now)
+			if len(kept) != len(tt.wanted) {
+				t.Fatalf("got %d results, expected %d", len(kept), len(tt.wanted))
+			}
+			for i, w := range tt.wanted {
+				if kept[i].Status != results[w].Status || !kept[i].Finish.Equal(results[w].Finish) {
+					t.Errorf("got %s result finished at %s, expected result %d", kept[i].Status, kept[i].Finish, w)
+				}
+			}
+		})
+	}
+}
+
+func TestJob_PruneHistory(t *testing.T) {
+	j := newTestJob(t, "test")
+	j.MaxRuns = 3
+	for i := 0; i < 3; i++ {
+		j.AddResult(Result{Status: StatusCompleted, Finish: time.Now()})
+	}
+
+	j.PruneHistory(RetentionPolicy{KeepLast: 1}, time.Now())
+	if len(j.AllResults()) != 1 || j.CountRuns() != 3 {
+		t.Errorf("got %d results and %d runs, expected 1 result and 3 runs", len(j.AllResults()), j.CountRuns())
+	}
+	if j.IsEligible() {
+		t.Errorf("job is eligible after %d runs, expected maximum of %d runs to be reached", j.CountRuns(), j.MaxRuns)
+	}
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type selectorOperator int
+
+func (o selectorOperator) String() string {
+	return [...]string{"=", "!=", "in", "notin", "exists", "!"}[o]
+}
+
+const (
+	selectorEquals selectorOperator = iota
+	selectorNotEquals
+	selectorIn
+	selectorNotIn
+	selectorExists
+	selectorNotExists
+)
+
+// ParseSelector parses a label selector, which consists of comma separated requirements that must all be met:
+//
+//	key=value, key==value  the label has the value
+//	key!=value             the label does not exist or has another value
+//	key in (a,b)           the label has one of the values
+//	key notin (a,b)        the label does not exist or has none of the values
+//	key                    the label exists
+//	!key                   the label does not exist
+//
+// An empty selector matches all jobs.
+func ParseSelector(selector string) (Selector, error) {
+	p := selectorParser{input: selector}
+	requirements, err := p.parse()
+	if err != nil {
+		return Selector{}, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	return Selector{requirements: requirements}, nil
+}
+
+type Selector struct {
+	requirements []selectorRequirement
+}
+
+// IsEmpty reports whether the selector matches all jobs
+func (s Selector) IsEmpty() bool {
+	return len(s.requirements) == 0
+}
+
+// Matches reports whether labels meet all requirements of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s.requirements {
+		if !r.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	requirements := make([]string, len(s.requirements))
+	for i, r := range s.requirements {
+		requirements[i] = r.String()
+	}
+	return strings.Join(requirements, ",")
+}
+
+type selectorRequirement struct {
+	key      string
+	operator selectorOperator
+	values   []string
+}
+
+func (r selectorRequirement) matches(labels map[string]string) bool {
+	value, found := labels[r.key]
+	switch r.operator {
+	case selectorEquals, selectorIn:
+		return found && r.hasValue(value)
+	case selectorNotEquals, selectorNotIn:
+		return !found || !r.hasValue(value)
+	case selectorExists:
+		return found
+	case selectorNotExists:
+		return !found
+	default:
+		return false
+	}
+}
+
+func (r selectorRequirement) hasValue(value string) bool {
+	for _, v := range r.values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r selectorRequirement) String() string {
+	switch r.operator {
+	case selectorEquals, selectorNotEquals:
+		return r.key + r.operator.String() + r.values[0]
+	case selectorIn, selectorNotIn:
+		return r.key + " " + r.operator.String() + " (" + strings.Join(r.values, ",") + ")"
+	case selectorNotExists:
+		return "!" + r.key
+	default:
+		return r.key
+	}
+}
+
+// selectorParser is a recursive descent parser for label selectors
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectorParser) parse() ([]selectorRequirement, error) {
+	requirements := make([]selectorRequirement, 0)
+	if p.skipSpace(); p.done() {
+		return requirements, nil
+	}
+
+	for {
+		r, err := p.requirement()
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, r)
+
+		if p.skipSpace(); p.done() {
+			return requirements, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected \",\" at position %d", p.pos)
+		}
+	}
+}
+
+func (p *selectorParser) requirement() (selectorRequirement, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		key, err := p.identifier("key")
+		return selectorRequirement{key: key, operator: selectorNotExists}, err
+	}
+
+	key, err := p.identifier("key")
+	if err != nil {
+		return selectorRequirement{}, err
+	}
+	r := selectorRequirement{key: key, operator: selectorExists}
+
+	p.skipSpace()
+	switch {
+	case p.done() || p.peek(","):
+		return r, nil
+	case p.consume("!="):
+		r.operator = selectorNotEquals
+	case p.consume("=="), p.consume("="):
+		r.operator = selectorEquals
+	case p.consumeWord("notin"):
+		r.operator = selectorNotIn
+		r.values, err = p.values()
+		return r, err
+	case p.consumeWord("in"):
+		r.operator = selectorIn
+		r.values, err = p.values()
+		return r, err
+	default:
+		return selectorRequirement{}, fmt.Errorf("expected operator at position %d", p.pos)
+	}
+
+	p.skipSpace()
+	var value string
+	if value, err = p.value(); err != nil {
+		return selectorRequirement{}, err
+	}
+	r.values = []string{value}
+	return r, nil
+}
+
+// values parses a set of values between parentheses
+func (p *selectorParser) values() ([]string, error) {
+	if p.skipSpace(); !p.consume("(") {
+		return nil, fmt.Errorf("expected \"(\" at position %d", p.pos)
+	}
+
+	values := make([]string, 0)
+	for {
+		p.skipSpace()
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			return nil, fmt.Errorf("expected value at position %d", p.pos)
+		}
+		values = append(values, value)
+
+		p.skipSpace()
+		if p.consume(")") {
+			break
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("expected \",\" or \")\" at position %d", p.pos)
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
+
+func (p *selectorParser) identifier(name string) (string, error) {
+	start := p.pos
+	for !p.done() && isSelectorChar(p.input[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected %s at position %d", name, start)
+	}
+	return p.input[start:p.pos], nil
+}
+
+// value parses a label value, which may be empty
+func (p *selectorParser) value() (string, error) {
+	start := p.pos
+	for !p.done() && isSelectorChar(p.input[p.pos]) {
+		p.pos++
+	}
+	if !p.done() && !strings.ContainsRune(" \t,)", rune(p.input[p.pos])) {
+		return "", fmt.Errorf("invalid character %q at position %d", p.input[p.pos], p.pos)
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *selectorParser) consume(s string) bool {
+	if p.peek(s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// consumeWord consumes s if it is not followed by another character of an identifier
+func (p *selectorParser) consumeWord(s string) bool {
+	end := p.pos + len(s)
+	if !p.peek(s) || (end < len(p.input) && isSelectorChar(p.input[end])) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *selectorParser) peek(s string) bool {
+	return strings.HasPrefix(p.input[p.pos:], s)
+}
+
+func (p *selectorParser) skipSpace() {
+	for !p.done() && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func isSelectorChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-_./", c) >= 0
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import "testing"
+
+func TestParseSelector(t *testing.T) {
+	var tests = []struct {
+		selector string
+		wanted   string
+		success  bool
+	}{
+		{"", "", true},
+		{"team=payments", "team=payments", true},
+		{"team==payments", "team=payments", true},
+		{"team!=payments", "team!=payments", true},
+		{" team = payments , env in ( prod, stage ) ", "team=payments,env in (prod,stage)", true},
+		{"env in (stage,prod)", "env in (prod,stage)", true},
+		{"env notin (dev)", "env notin (dev)", true},
+		{"deprecated", "deprecated", true},
+		{"!deprecated", "!deprecated", true},
+		{"team=payments,env in (prod,stage),!deprecated", "team=payments,env in (prod,stage),!deprecated", true},
+		{"app.kubernetes.io/name=scheduler", "app.kubernetes.io/name=scheduler", true},
+		{"team=", "team=", true},
+		{"index=1", "index=1", true},
+
+		{"=payments", "", false},
+		{"team payments", "", false},
+		{"team=payments,", "", false},
+		{",team=payments", "", false},
+		{"env in prod", "", false},
+		{"env in (prod", "", false},
+		{"env in ()", "", false},
+		{"team=pay ments", "", false},
+		{"team=pay$ments", "", false},
+		{"!", "", false},
+		{"!team=payments", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseSelector(tt.selector)
+			if (err == nil) != tt.success {
+				t.Fatalf("got error %v, expected success to be %t", err, tt.success)
+			}
+			if s.String() != tt.wanted {
+				t.Errorf("got %s, expected %s", s.String(), tt.wanted)
+			}
+		})
+	}
+}
+
+func TestSelector_Matches(t *testing.T) {
+	labels := map[string]string{"team": "payments", "env": "prod"}
+
+	var tests = []struct {
+		selector string
+		wanted   bool
+	}{
+		{"", true},
+		{"team=payments", true},
+		{"team=billing", false},
+		{"team!=billing", true},
+		{"team!=payments", false},
+		{"owner!=payments", true},
+		{"env in (prod,stage)", true},
+		{"env in (dev,stage)", false},
+		{"env notin (dev,stage)", true},
+		{"env notin (prod)", false},
+		{"owner notin (prod)", true},
+		{"team", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!team", false},
+		{"team=payments,env in (prod,stage),!deprecated", true},
+		{"team=payments,env in (prod,stage),!env", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			s, err := ParseSelector(tt.selector)
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if s.Matches(labels) != tt.wanted {
+				t.Errorf("got %t for %v, expected %t", s.Matches(labels), labels, tt.wanted)
+			}
+		})
+	}
+}
@@ -106,3 +106,23 @@ func (s *Sequence) ResetHistory() {
 
 	s.executed = nil
 }
+
+func (s *Sequence) clone() Sequence {
+	if s.mux != nil {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+	}
+
+	c := Sequence{
+		Tasks:     make([]task.Task, len(s.Tasks)),
+		active:    s.active,
+		activeIdx: s.activeIdx,
+		mux:       &sync.Mutex{},
+	}
+	copy(c.Tasks, s.Tasks)
+	if s.executed != nil {
+		c.executed = make([]task.Task, len(s.executed))
+		copy(c.executed, s.executed)
+	}
+	return c
+}
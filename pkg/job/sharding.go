@@ -0,0 +1,120 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultMemberTTL is used when ShardingConfig.MemberTTL is not set
+	DefaultMemberTTL = 30 * time.Second
+	// DefaultVirtualNodes is used when ShardingConfig.VirtualNodes is not set
+	DefaultVirtualNodes = 64
+)
+
+// ShardingConfig divides the jobs of a shared catalog between the orchestrators which use it. Every orchestrator joins
+// the catalog as a member and owns the jobs which are assigned to it by consistent hashing of their Uuid, so only a
+// part of the jobs moves to another member when members join or leave.
+type ShardingConfig struct {
+	Instance     string        // identifies the orchestrator, a random identifier is used when empty
+	MemberTTL    time.Duration // the membership expires when it is not renewed in time, it is renewed every third of the ttl
+	VirtualNodes int           // the number of points of every member on the hash ring
+}
+
+func (c ShardingConfig) memberTTL() time.Duration {
+	if c.MemberTTL <= 0 {
+		return DefaultMemberTTL
+	}
+	return c.MemberTTL
+}
+
+func (c ShardingConfig) virtualNodes() int {
+	if c.VirtualNodes <= 0 {
+		return DefaultVirtualNodes
+	}
+	return c.VirtualNodes
+}
+
+// hashRing assigns jobs to members by consistent hashing
+type hashRing struct {
+	points []ringPoint // sorted by hash
+}
+
+type ringPoint struct {
+	hash     uint64
+	instance string
+}
+
+func newHashRing(members []string, virtualNodes int) hashRing {
+	points := make([]ringPoint, 0, len(members)*virtualNodes)
+	for _, instance := range members {
+		for i := 0; i < virtualNodes; i++ {
+			points = append(points, ringPoint{hash: ringHash([]byte(instance + "#" + strconv.Itoa(i))), instance: instance})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash == points[j].hash {
+			return points[i].instance < points[j].instance
+		}
+		return points[i].hash < points[j].hash
+	})
+	return hashRing{points: points}
+}
+
+// owner returns the member which owns the job with jobId, the ring is empty if there are no members
+func (r hashRing) owner(jobId uuid.UUID) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+
+	hash := ringHash(jobId[:])
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].instance
+}
+
+func ringHash(data []byte) uint64 {
+	sum := sha256.Sum256(data)
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// shardStats counts the jobs owned by every member
+func shardStats(members []string, owners map[uuid.UUID]string) []ShardStats {
+	stats := make([]ShardStats, 0, len(members))
+	index := make(map[string]int, len(members))
+	for _, instance := range members {
+		index[instance] = len(stats)
+		stats = append(stats, ShardStats{Instance: instance})
+	}
+	for _, owner := range owners {
+		if i, found := index[owner]; found {
+			stats[i].OwnedJobs++
+		}
+	}
+	return stats
+}
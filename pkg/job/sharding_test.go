@@ -0,0 +1,62 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestHashRing_Owner(t *testing.T) {
+	jobs := make([]uuid.UUID, 3000)
+	for i := range jobs {
+		jobs[i] = uuid.New()
+	}
+
+	ring := newHashRing([]string{"a", "b", "c"}, DefaultVirtualNodes)
+	counts := make(map[string]int)
+	for _, jobId := range jobs {
+		counts[ring.owner(jobId)]++
+	}
+	for _, instance := range []string{"a", "b", "c"} {
+		if share := float64(counts[instance]) / float64(len(jobs)); share < 0.2 || share > 0.5 {
+			t.Errorf("member %s owns %.0f%% of the jobs, expected about a third", instance, share*100)
+		}
+	}
+
+	// Only jobs which move to the new member change owner when a member joins
+	grown := newHashRing([]string{"a", "b", "c", "d"}, DefaultVirtualNodes)
+	moved := 0
+	for _, jobId := range jobs {
+		before, after := ring.owner(jobId), grown.owner(jobId)
+		if before == after {
+			continue
+		}
+		moved++
+		if after != "d" {
+			t.Fatalf("job moved from %s to %s, expected it to move to the new member", before, after)
+		}
+	}
+	if share := float64(moved) / float64(len(jobs)); share < 0.1 || share > 0.4 {
+		t.Errorf("%.0f%% of the jobs moved, expected about a quarter", share*100)
+	}
+
+	if owner := newHashRing(nil, DefaultVirtualNodes).owner(jobs[0]); owner != "" {
+		t.Errorf("got owner %q without members, expected none", owner)
+	}
+}
@@ -0,0 +1,738 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLCatalogConfig struct {
+	Dialect SQLDialect
+	// ErrorHandler receives the errors of methods which cannot return an error, such as AvailableJobs
+	ErrorHandler func(err error)
+}
+
+// NewSQLCatalog returns a catalog backed by db and applies the schema migrations of the configured dialect.
+// Multiple orchestrators can share the same database, status changes are performed as conditional updates.
+func NewSQLCatalog(db *sql.DB, config SQLCatalogConfig) (*SQLCatalog, error) {
+	c := &SQLCatalog{
+		db:     db,
+		config: config,
+	}
+
+	if err := c.Migrate(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+type SQLCatalog struct {
+	db     *sql.DB
+	config SQLCatalogConfig
+}
+
+func (c *SQLCatalog) Add(job Job) error {
+	definition, err := c.definition(job)
+	if err != nil {
+		return err
+	}
+
+	return c.inTx(func(tx *sql.Tx) error {
+		var exists bool
+		if exists, err = c.exists(tx, job.Uuid); err != nil {
+			return err
+		}
+		if exists {
+			return ErrExist.WithJob(job.Uuid, job.Name)
+		}
+		if err = c.checkName(tx, job.Uuid, job.Namespace, job.Name); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(c.query("INSERT INTO scheduler_jobs (uuid, namespace, name, enabled, status, definition, revision) VALUES (?, ?, ?, ?, ?, ?, 1)"),
+			job.Uuid, job.Namespace, job.Name, job.IsEnabled(), job.Status, definition)
+		if err != nil {
+			return err
+		}
+		return c.writeResults(tx, job, c.firstRun(job))
+	})
+}
+
+func (c *SQLCatalog) All() []Job {
+	return c.selectJobs(false, "1 = 1")
+}
+
+func (c *SQLCatalog) AvailableJobs() []Job {
+	return c.GetJobsByStatus(StatusAvailable)
+}
+
+func (c *SQLCatalog) Claim(jobId uuid.UUID, instance string) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		if err := c.checkOwner(tx, jobId, instance); err != nil {
+			return err
+		}
+		_, err := tx.Exec(c.query("INSERT INTO scheduler_owners (job_uuid, instance) VALUES (?, ?) ON CONFLICT (job_uuid) DO UPDATE SET instance = excluded.instance"), jobId, instance)
+		return err
+	})
+}
+
+func (c *SQLCatalog) Count() int {
+	var count int
+	if err := c.db.QueryRow("SELECT COUNT(*) FROM scheduler_jobs").Scan(&count); err != nil {
+		c.handleError(err)
+	}
+	return count
+}
+
+func (c *SQLCatalog) Delete(jobId uuid.UUID) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(c.query("DELETE FROM scheduler_results WHERE job_uuid = ?"), jobId); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(c.query("DELETE FROM scheduler_owners WHERE job_uuid = ?"), jobId); err != nil {
+			return err
+		}
+		return c.affected(tx.Exec(c.query("DELETE FROM scheduler_jobs WHERE uuid = ?"), jobId))
+	})
+}
+
+func (c *SQLCatalog) DeleteByName(namespace string, name string) error {
+	if namespace == "" {
+		return errNoNamespace
+	}
+
+	return c.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(c.query("DELETE FROM scheduler_results WHERE job_uuid IN (SELECT uuid FROM scheduler_jobs WHERE namespace = ? AND name = ?)"), namespace, name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(c.query("DELETE FROM scheduler_owners WHERE job_uuid IN (SELECT uuid FROM scheduler_jobs WHERE namespace = ? AND name = ?)"), namespace, name); err != nil {
+			return err
+		}
+		return c.affected(tx.Exec(c.query("DELETE FROM scheduler_jobs WHERE namespace = ? AND name = ?"), namespace, name))
+	})
+}
+
+func (c *SQLCatalog) Disable(jobId uuid.UUID) error {
+	return c.affected(c.db.Exec(c.query("UPDATE scheduler_jobs SET enabled = ?, revision = revision + 1 WHERE uuid = ?"), false, jobId))
+}
+
+func (c *SQLCatalog) Enable(jobId uuid.UUID) error {
+	statement := "UPDATE scheduler_jobs SET enabled = ?, status = CASE WHEN status IN (?, ?) THEN ? ELSE status END, revision = revision + 1 WHERE uuid = ?"
+	return c.affected(c.db.Exec(c.query(statement), true, StatusCompleted, StatusError, StatusInactive, jobId))
+}
+
+func (c *SQLCatalog) Exists(id uuid.UUID) bool {
+	exists, err := c.exists(c.db, id)
+	if err != nil {
+		c.handleError(err)
+	}
+	return exists
+}
+
+// Find filters jobs by namespace, name, labels, status and enabled in the database, the other filters, sorting and
+// pagination are applied to the selected jobs. Labels are stored in the definition of a job, the database selects the
+// jobs whose definition contains the labels, which are then matched exactly.
+func (c *SQLCatalog) Find(query Query) (JobPage, error) {
+	if err := query.validate(); err != nil {
+		return JobPage{}, err
+	}
+
+	var (
+		where = []string{"1 = 1"}
+		args  []interface{}
+	)
+	if query.Namespace != "" {
+		where = append(where, "j.namespace = ?")
+		args = append(args, query.Namespace)
+	}
+	if query.Name != "" && !hasMeta(query.Name) {
+		where = append(where, "j.name = ?")
+		args = append(args, query.Name)
+	}
+	for k, v := range query.Labels {
+		label, err := json.Marshal(map[string]string{k: v})
+		if err != nil {
+			return JobPage{}, err
+		}
+		// The label is matched as a JSON member, without the braces of the encoded map
+		where = append(where, "j.definition LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+escapeLike(string(label[1:len(label)-1]))+"%")
+	}
+	if len(query.Status) > 0 {
+		placeholders := make([]string, len(query.Status))
+		for i, status := range query.Status {
+			placeholders[i] = "?"
+			args = append(args, status)
+		}
+		where = append(where, "j.status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if query.Enabled != nil {
+		where = append(where, "j.enabled = ?")
+		args = append(args, *query.Enabled)
+	}
+
+	jobs, err := c.queryJobs(false, strings.Join(where, " AND "), args...)
+	if err != nil {
+		return JobPage{}, err
+	}
+	return findJobs(jobs, query), nil
+}
+
+func (c *SQLCatalog) Get(id uuid.UUID) (Job, error) {
+	jobs, err := c.queryJobs(false, "j.uuid = ?", id)
+	if err != nil {
+		return Job{}, err
+	}
+	if len(jobs) == 0 {
+		return Job{}, ErrNotFound.WithJob(id, "")
+	}
+	return jobs[0], nil
+}
+
+func (c *SQLCatalog) GetByName(namespace string, name string) (Job, error) {
+	if namespace == "" {
+		return Job{}, errNoNamespace
+	}
+
+	jobs, err := c.queryJobs(false, "j.namespace = ? AND j.name = ?", namespace, name)
+	if err != nil {
+		return Job{}, err
+	}
+	if len(jobs) == 0 {
+		return Job{}, ErrNotFound.WithCause(fmt.Errorf("no job named %q", QualifiedName(namespace, name)))
+	}
+	return jobs[0], nil
+}
+
+// GetJobsByStatus returns the jobs with status, the history of the jobs holds only their last result
+func (c *SQLCatalog) GetJobsByStatus(status Status) []Job {
+	return c.selectJobs(true, "j.status = ?", status)
+}
+
+func (c *SQLCatalog) HasEnabledJobs() bool {
+	var count int
+	if err := c.db.QueryRow(c.query("SELECT COUNT(*) FROM scheduler_jobs WHERE enabled = ?"), true).Scan(&count); err != nil {
+		c.handleError(err)
+	}
+	return count > 0
+}
+
+func (c *SQLCatalog) InactiveJobs() []Job {
+	return c.GetJobsByStatus(StatusInactive)
+}
+
+func (c *SQLCatalog) Join(instance string, ttl time.Duration) error {
+	_, err := c.db.Exec(c.query("INSERT INTO scheduler_members (instance, expires) VALUES (?, ?) ON CONFLICT (instance) DO UPDATE SET expires = excluded.expires"),
+		instance, time.Now().Add(ttl).UnixNano())
+	return err
+}
+
+func (c *SQLCatalog) Leave(instance string) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(c.query("DELETE FROM scheduler_owners WHERE instance = ?"), instance); err != nil {
+			return err
+		}
+		_, err := tx.Exec(c.query("DELETE FROM scheduler_members WHERE instance = ?"), instance)
+		return err
+	})
+}
+
+func (c *SQLCatalog) Members() ([]string, error) {
+	rows, err := c.db.Query(c.query("SELECT instance FROM scheduler_members WHERE expires > ? ORDER BY instance"), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]string, 0)
+	for rows.Next() {
+		var instance string
+		if err = rows.Scan(&instance); err != nil {
+			return nil, err
+		}
+		members = append(members, instance)
+	}
+	return members, rows.Err()
+}
+
+// Migrate applies all migrations of the dialect which have not been applied to the database yet.
+// Catalogs migrating the same database concurrently apply every migration once, see migrateSQL.
+func (c *SQLCatalog) Migrate(ctx context.Context) error {
+	return migrateSQL(ctx, c.db, c.config.Dialect)
+}
+
+// Overview loads the jobs with their last result only, like the lists by status
+func (c *SQLCatalog) Overview() []Job {
+	return c.selectJobs(true, "1 = 1")
+}
+
+func (c *SQLCatalog) Owners() (map[uuid.UUID]string, error) {
+	rows, err := c.db.Query(c.query("SELECT o.job_uuid, o.instance FROM scheduler_owners o JOIN scheduler_members m ON m.instance = o.instance WHERE m.expires > ?"), time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make(map[uuid.UUID]string)
+	for rows.Next() {
+		var (
+			jobId    uuid.UUID
+			instance string
+		)
+		if err = rows.Scan(&jobId, &instance); err != nil {
+			return nil, err
+		}
+		owners[jobId] = instance
+	}
+	return owners, rows.Err()
+}
+
+func (c *SQLCatalog) PendingJobs() []Job {
+	return c.GetJobsByStatus(StatusPending)
+}
+
+// PruneHistory removes the stored results of a job which are not kept by policy, the results are read in order of their
+// runs to determine their age
+func (c *SQLCatalog) PruneHistory(jobId uuid.UUID, policy RetentionPolicy, now time.Time) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		exists, err := c.exists(tx, jobId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound.WithJob(jobId, "")
+		}
+		if policy.IsZero() {
+			return nil
+		}
+
+		var pruned []int
+		if pruned, err = c.prunedRuns(tx, jobId, policy, now); err != nil {
+			return err
+		}
+		for _, run := range pruned {
+			if _, err = tx.Exec(c.query("DELETE FROM scheduler_results WHERE job_uuid = ? AND run = ?"), jobId, run); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(c.query("UPDATE scheduler_jobs SET revision = revision + 1 WHERE uuid = ?"), jobId)
+		return err
+	})
+}
+
+func (c *SQLCatalog) Release(jobId uuid.UUID, instance string) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		if err := c.checkOwner(tx, jobId, instance); err != nil {
+			return err
+		}
+		_, err := tx.Exec(c.query("DELETE FROM scheduler_owners WHERE job_uuid = ? AND instance = ?"), jobId, instance)
+		return err
+	})
+}
+
+func (c *SQLCatalog) Rename(jobId uuid.UUID, namespace string, name string) error {
+	return c.inTx(func(tx *sql.Tx) error {
+		if err := c.checkName(tx, jobId, namespace, name); err != nil {
+			return err
+		}
+		return c.affected(tx.Exec(c.query("UPDATE scheduler_jobs SET namespace = ?, name = ?, revision = revision + 1 WHERE uuid = ?"), namespace, name, jobId))
+	})
+}
+
+func (c *SQLCatalog) RunnableJobs() []Job {
+	return c.GetJobsByStatus(StatusRunnable)
+}
+
+func (c *SQLCatalog) SchedulableJobs() []Job {
+	return c.GetJobsByStatus(StatusSchedulable)
+}
+
+func (c *SQLCatalog) Transition(jobId uuid.UUID, from Status, to Status) error {
+	if err := checkTransition(jobId, from, to); err != nil {
+		return err
+	}
+
+	result, err := c.db.Exec(c.query("UPDATE scheduler_jobs SET status = ?, revision = revision + 1 WHERE uuid = ? AND status = ?"), to, jobId, from)
+	if err != nil {
+		return err
+	}
+
+	var rows int64
+	if rows, err = result.RowsAffected(); err != nil {
+		return err
+	}
+	if rows == 1 {
+		return nil
+	}
+
+	var exists bool
+	if exists, err = c.exists(c.db, jobId); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+	return ErrConflict.WithJob(jobId, "")
+}
+
+func (c *SQLCatalog) Update(job Job) error {
+	return c.update(job, "uuid = ?", job.Uuid)
+}
+
+func (c *SQLCatalog) UpdateIf(job Job, expectedRevision uint64) error {
+	err := c.update(job, "uuid = ? AND revision = ?", job.Uuid, expectedRevision)
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	var exists bool
+	if exists, err = c.exists(c.db, job.Uuid); err != nil {
+		return err
+	}
+	if exists {
+		return ErrConflict.WithJob(job.Uuid, job.Name)
+	}
+	return ErrNotFound.WithJob(job.Uuid, job.Name)
+}
+
+// affected converts the result of a statement on a single job into ErrNotFound if no rows were changed
+func (c *SQLCatalog) affected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	var rows int64
+	if rows, err = result.RowsAffected(); err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// definition returns the serialized job without its name, status and history, which are stored in separate columns
+// and rows
+func (c *SQLCatalog) definition(job Job) (string, error) {
+	r, err := newJobRecord(Job{
+		Uuid:        job.Uuid,
+		Labels:      job.Labels,
+		Annotations: job.Annotations,
+		Triggered:   job.IsTriggered(),
+		Schedule:    job.Schedule,
+		MaxRuns:     job.MaxRuns,
+		Priority:    job.Priority,
+		Concurrency: job.Concurrency,
+		Tasks:       job.Tasks,
+		Runs:        job.CountRuns(),
+		mux:         job.mux,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var data []byte
+	if data, err = json.Marshal(r); err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// checkOwner returns ErrOwned if the job with jobId is owned by a member other than instance
+func (c *SQLCatalog) checkOwner(q querier, jobId uuid.UUID, instance string) error {
+	exists, err := c.exists(q, jobId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound.WithJob(jobId, "")
+	}
+
+	var owner string
+	err = q.QueryRow(c.query("SELECT o.instance FROM scheduler_owners o JOIN scheduler_members m ON m.instance = o.instance WHERE o.job_uuid = ? AND m.expires > ?"),
+		jobId, time.Now().UnixNano()).Scan(&owner)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	case owner != instance:
+		return ErrOwned.WithJob(jobId, "").WithCause(fmt.Errorf("owned by %s", owner))
+	default:
+		return nil
+	}
+}
+
+// checkName returns an error if the name is invalid or used by another job than jobId
+func (c *SQLCatalog) checkName(q querier, jobId uuid.UUID, namespace string, name string) error {
+	if err := validateName(namespace, name); err != nil {
+		return err
+	}
+	if namespace == "" {
+		return nil
+	}
+
+	var count int
+	err := q.QueryRow(c.query("SELECT COUNT(*) FROM scheduler_jobs WHERE namespace = ? AND name = ? AND uuid <> ?"), namespace, name, jobId).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrNameExist.WithJob(jobId, QualifiedName(namespace, name))
+	}
+	return nil
+}
+
+func (c *SQLCatalog) exists(q querier, id uuid.UUID) (bool, error) {
+	var count int
+	if err := q.QueryRow(c.query("SELECT COUNT(*) FROM scheduler_jobs WHERE uuid = ?"), id).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// firstRun returns the run of the oldest result in the history of job, runs are numbered from 0
+func (c *SQLCatalog) firstRun(job Job) int {
+	return max(job.CountRuns()-len(job.AllResults()), 0)
+}
+
+func (c *SQLCatalog) handleError(err error) {
+	if c.config.ErrorHandler != nil {
+		c.config.ErrorHandler(err)
+	}
+}
+
+func (c *SQLCatalog) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = f(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// prunedRuns returns the runs of the stored results of a job which are not kept by policy
+func (c *SQLCatalog) prunedRuns(tx *sql.Tx, jobId uuid.UUID, policy RetentionPolicy, now time.Time) ([]int, error) {
+	rows, err := tx.Query(c.query("SELECT run, data FROM scheduler_results WHERE job_uuid = ? ORDER BY run DESC"), jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pruned []int
+	for age := 1; rows.Next(); age++ {
+		var (
+			run  int
+			data string
+			rr   resultRecord
+		)
+		if err = rows.Scan(&run, &data); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(data), &rr); err != nil {
+			return nil, err
+		}
+		if !policy.keep(Result{Finish: rr.Finish, Status: rr.Status}, age, now) {
+			pruned = append(pruned, run)
+		}
+	}
+	return pruned, rows.Err()
+}
+
+func (c *SQLCatalog) query(query string) string {
+	return rebind(c.config.Dialect, query)
+}
+
+// queryJobs returns all jobs matching the where clause including their history, or only the last result of their
+// history if last is set. Columns are qualified with alias j.
+func (c *SQLCatalog) queryJobs(last bool, where string, args ...interface{}) ([]Job, error) {
+	var (
+		err  error
+		jobs = make([]Job, 0)
+	)
+
+	err = c.inTx(func(tx *sql.Tx) error {
+		var rows *sql.Rows
+		if rows, err = tx.Query(c.query("SELECT j.uuid, j.namespace, j.name, j.enabled, j.status, j.revision, j.definition FROM scheduler_jobs j WHERE "+where), args...); err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		index := make(map[uuid.UUID]int)
+		for rows.Next() {
+			var (
+				r          jobRecord
+				definition string
+				job        Job
+			)
+			if err = rows.Scan(&r.Uuid, &r.Namespace, &r.Name, &r.Enabled, &r.Status, &r.Revision, &definition); err != nil {
+				return err
+			}
+			if err = c.decodeDefinition(definition, &r); err != nil {
+				return err
+			}
+			if job, err = r.job(); err != nil {
+				return err
+			}
+			index[job.Uuid] = len(jobs)
+			jobs = append(jobs, job)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		var results *sql.Rows
+		query := "SELECT r.job_uuid, r.data FROM scheduler_results r JOIN scheduler_jobs j ON j.uuid = r.job_uuid WHERE " + where
+		if last {
+			query += " AND r.run = (SELECT MAX(l.run) FROM scheduler_results l WHERE l.job_uuid = r.job_uuid)"
+		}
+		query += " ORDER BY r.job_uuid, r.run"
+		if results, err = tx.Query(c.query(query), args...); err != nil {
+			return err
+		}
+		defer results.Close()
+
+		for results.Next() {
+			var (
+				id     uuid.UUID
+				data   string
+				rr     resultRecord
+				result Result
+			)
+			if err = results.Scan(&id, &data); err != nil {
+				return err
+			}
+			if err = json.Unmarshal([]byte(data), &rr); err != nil {
+				return err
+			}
+			if result, err = rr.result(); err != nil {
+				return err
+			}
+			if i, found := index[id]; found {
+				jobs[i].History = append(jobs[i].History, result)
+			}
+		}
+		return results.Err()
+	})
+	return jobs, err
+}
+
+func (c *SQLCatalog) decodeDefinition(definition string, r *jobRecord) error {
+	var d jobRecord
+	if err := json.Unmarshal([]byte(definition), &d); err != nil {
+		return err
+	}
+	r.Labels = d.Labels
+	r.Annotations = d.Annotations
+	r.Triggered = d.Triggered
+	r.Schedule = d.Schedule
+	r.MaxRuns = d.MaxRuns
+	r.Priority = d.Priority
+	r.Concurrency = d.Concurrency
+	r.Runs = d.Runs
+	r.Tasks = d.Tasks
+	return nil
+}
+
+func (c *SQLCatalog) selectJobs(last bool, where string, args ...interface{}) []Job {
+	jobs, err := c.queryJobs(last, where, args...)
+	if err != nil {
+		c.handleError(err)
+	}
+	return jobs
+}
+
+// update stores job if the job matches the where clause, it returns ErrNotFound if no job matches
+func (c *SQLCatalog) update(job Job, where string, args ...interface{}) error {
+	definition, err := c.definition(job)
+	if err != nil {
+		return err
+	}
+
+	return c.inTx(func(tx *sql.Tx) error {
+		if err = c.checkName(tx, job.Uuid, job.Namespace, job.Name); err != nil {
+			return err
+		}
+
+		args = append([]interface{}{job.Namespace, job.Name, job.IsEnabled(), job.Status, definition}, args...)
+		err = c.affected(tx.Exec(c.query("UPDATE scheduler_jobs SET namespace = ?, name = ?, enabled = ?, status = ?, definition = ?, revision = revision + 1 WHERE "+where), args...))
+		if err != nil {
+			return err
+		}
+
+		// Results are append-only except for the last one, which is updated while a job is active. Results which are
+		// missing from the history of the job are kept, the history may hold only the last result, see PruneHistory.
+		first := c.firstRun(job)
+		var last sql.NullInt64
+		if err = tx.QueryRow(c.query("SELECT MAX(run) FROM scheduler_results WHERE job_uuid = ?"), job.Uuid).Scan(&last); err != nil {
+			return err
+		}
+		from := max(int(last.Int64), first)
+		if _, err = tx.Exec(c.query("DELETE FROM scheduler_results WHERE job_uuid = ? AND run >= ?"), job.Uuid, from); err != nil {
+			return err
+		}
+		return c.writeResults(tx, job, from)
+	})
+}
+
+// writeResults inserts the results of job starting at run from
+func (c *SQLCatalog) writeResults(tx *sql.Tx, job Job, from int) error {
+	results := job.AllResults()
+	first := c.firstRun(job)
+	for run := from; run < first+len(results); run++ {
+		result := results[run-first]
+		rr, err := newResultRecord(result)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if data, err = json.Marshal(rr); err != nil {
+			return err
+		}
+		_, err = tx.Exec(c.query("INSERT INTO scheduler_results (job_uuid, run, start, status, data) VALUES (?, ?, ?, ?, ?)"),
+			job.Uuid, run, result.Start.UnixNano(), result.Status, string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with a backslash
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// hasMeta reports whether pattern contains any of the special characters of path.Match
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
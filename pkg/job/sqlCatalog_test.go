@@ -0,0 +1,302 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDatabase(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", "file:"+name+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func newTestSQLCatalog(t *testing.T, db *sql.DB) *SQLCatalog {
+	t.Helper()
+
+	c, err := NewSQLCatalog(db, SQLCatalogConfig{
+		Dialect: SQLiteDialect{},
+		ErrorHandler: func(err error) {
+			t.Errorf("got error: %s", err.Error())
+		},
+	})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	return c
+}
+
+func TestSQLCatalog_Migrate(t *testing.T) {
+	db := openTestDatabase(t, filepath.Join(t.TempDir(), "catalog.db"))
+	c := newTestSQLCatalog(t, db)
+
+	// Applying the migrations again must be a no-op
+	if err := c.Migrate(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	var version int
+	if err := db.QueryRow("SELECT MAX(version) FROM scheduler_migrations").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	wanted := len(SQLiteDialect{}.Migrations())
+	if version != wanted {
+		t.Errorf("got schema version %d, expected %d", version, wanted)
+	}
+}
+
+func TestSQLCatalog_MigrateConcurrent(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "catalog.db")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		db := openTestDatabase(t, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NewSQLCatalog(db, SQLCatalogConfig{Dialect: SQLiteDialect{}}); err != nil {
+				t.Errorf("got error: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	var count int
+	if err := openTestDatabase(t, name).QueryRow("SELECT COUNT(*) FROM scheduler_migrations WHERE version > 0").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if wanted := len(SQLiteDialect{}.Migrations()); count != wanted {
+		t.Errorf("got %d applied migrations, expected %d", count, wanted)
+	}
+}
+
+func TestSQLCatalog_FindLabels(t *testing.T) {
+	c := newTestSQLCatalog(t, openTestDatabase(t, filepath.Join(t.TempDir(), "catalog.db")))
+
+	labels := []map[string]string{
+		{"team": "a_b"},
+		{"team": "axb"},
+		{"team": `a"b`},
+		{"team": "a%"},
+		{"owner": "a_b"},
+	}
+	for i, l := range labels {
+		j := newTestJob(t, "test")
+		j.Namespace = "default"
+		j.Name = "test-" + strconv.Itoa(i)
+		j.Labels = l
+		if err := c.Add(j); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+
+	for _, l := range labels {
+		page, err := c.Find(Query{Namespace: "default", Labels: l})
+		if err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		if len(page.Jobs) != 1 || !maps.Equal(page.Jobs[0].Labels, l) {
+			t.Errorf("got %d jobs for labels %v, expected 1", len(page.Jobs), l)
+		}
+	}
+
+	page, err := c.Find(Query{Namespace: "default", Name: "test-1"})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(page.Jobs) != 1 || page.Jobs[0].Labels["team"] != "axb" {
+		t.Errorf("got jobs %v, expected job test-1", page.Jobs)
+	}
+}
+
+func TestSQLCatalog_StatusLastResult(t *testing.T) {
+	c := newTestSQLCatalog(t, openTestDatabase(t, filepath.Join(t.TempDir(), "catalog.db")))
+
+	j := newTestJob(t, "test")
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		j.AddResult(Result{Start: start.Add(time.Duration(i) * time.Second), Status: StatusCompleted})
+	}
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	jobs := c.InactiveJobs()
+	if len(jobs) != 1 {
+		t.Fatalf("got %d inactive jobs, expected 1", len(jobs))
+	}
+	history := jobs[0].AllResults()
+	if len(history) != 1 || !history[0].Start.Equal(start.Add(2*time.Second)) {
+		t.Errorf("got history %v, expected only the last result", history)
+	}
+
+	full, err := c.Get(j.Uuid)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if len(full.AllResults()) != 3 {
+		t.Errorf("got %d results, expected the complete history", len(full.AllResults()))
+	}
+}
+
+func TestSQLCatalog_History(t *testing.T) {
+	c := newTestSQLCatalog(t, openTestDatabase(t, filepath.Join(t.TempDir(), "catalog.db")))
+
+	j := newTestJob(t, "test")
+	if err := c.Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	j.AddResult(Result{Start: time.Now(), Status: StatusActive})
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	j.UpdateResult(Result{
+		Start:    time.Now(),
+		Status:   StatusCompleted,
+		Messages: []task.Message{task.NewErrorMessage("failed", task.EmptyTask{}, errors.New("boom"))},
+		Tasks:    []task.Task{task.EmptyTask{}.SetStatus(task.StatusCompleted)},
+	})
+	j.AddResult(Result{Start: time.Now(), Status: StatusActive})
+	if err := c.Update(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	result, err := c.Get(j.Uuid)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	history := result.AllResults()
+	if len(history) != 2 || history[0].Status != StatusCompleted || history[1].Status != StatusActive {
+		t.Fatalf("got history %v, expected a completed and an active result", history)
+	}
+	if history[0].Messages[0].Data.(error).Error() != "boom" || history[0].Tasks[0].Status() != task.StatusCompleted {
+		t.Errorf("got result %v, expected messages and tasks to be restored", history[0])
+	}
+}
+
+func TestSQLCatalog_TransitionShared(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "catalog.db")
+
+	// Every catalog has its own connection to the database, as if they were running in separate processes
+	catalogs := make([]*SQLCatalog, 5)
+	for i := range catalogs {
+		catalogs[i] = newTestSQLCatalog(t, openTestDatabase(t, name))
+	}
+
+	j := newTestJob(t, "test")
+	j.SetStatus(StatusPending)
+	if err := catalogs[0].Add(j); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mux       sync.Mutex
+		won       int
+		conflicts int
+	)
+	for _, c := range catalogs {
+		wg.Add(1)
+		go func(c *SQLCatalog) {
+			defer wg.Done()
+			err := c.Transition(j.Uuid, StatusPending, StatusActive)
+
+			mux.Lock()
+			defer mux.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.Is(err, ErrConflict):
+				conflicts++
+			default:
+				t.Errorf("got error: %s", err.Error())
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if won != 1 || conflicts != len(catalogs)-1 {
+		t.Errorf("got %d successful transitions and %d conflicts, expected 1 and %d", won, conflicts, len(catalogs)-1)
+	}
+}
+
+func TestSQLCatalog_SharedOrchestrators(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "catalog.db")
+
+	r := task.NewHandlerRepository()
+	if err := r.RegisterHandlerPool(task.NewHandlerPool(task.NewDefaultEmptyTaskHandler())); err != nil {
+		t.Fatal(err)
+	}
+
+	j := newTestJob(t, "test")
+	j.Tasks = NewSequence([]task.Task{task.EmptyTask{}})
+	j.MaxRuns = 3
+
+	var c *SQLCatalog
+	for i := 0; i < 3; i++ {
+		c = newTestSQLCatalog(t, openTestDatabase(t, name))
+		if i == 0 {
+			if err := c.Add(j); err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+		}
+
+		o := NewOrchestrator(c, r, OrchestratorConfig{MaxJobs: 2, ScheduleInterval: 10 * time.Millisecond})
+		if err := o.Start(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+		defer o.Stop(context.Background())
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for c.HasEnabledJobs() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	result, err := c.Get(j.Uuid)
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if result.IsEnabled() || result.CountRuns() != j.MaxRuns {
+		t.Errorf("got %d runs, expected exactly %d", result.CountRuns(), j.MaxRuns)
+	}
+	if len(result.AllResults()) != j.MaxRuns {
+		t.Errorf("got %d results, expected the history of all %d runs", len(result.AllResults()), j.MaxRuns)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect describes the differences between the databases supported by SQLCatalog
+type SQLDialect interface {
+	Name() string
+	// Placeholder returns the bind parameter for the argument at index, starting at 1
+	Placeholder(index int) string
+	// Migrations returns the schema migrations in ascending version order
+	Migrations() []SQLMigration
+}
+
+type SQLMigration struct {
+	Version    int
+	Statements []string
+}
+
+type SQLiteDialect struct{}
+
+func (d SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+func (d SQLiteDialect) Placeholder(index int) string {
+	return "?"
+}
+
+func (d SQLiteDialect) Migrations() []SQLMigration {
+	return []SQLMigration{
+		{
+			Version: 1,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_jobs (
+					uuid       TEXT    NOT NULL PRIMARY KEY,
+					name       TEXT    NOT NULL,
+					enabled    INTEGER NOT NULL,
+					status     INTEGER NOT NULL,
+					definition TEXT    NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS scheduler_jobs_status ON scheduler_jobs (status)`,
+				`CREATE TABLE IF NOT EXISTS scheduler_results (
+					job_uuid TEXT    NOT NULL REFERENCES scheduler_jobs (uuid) ON DELETE CASCADE,
+					run      INTEGER NOT NULL,
+					start    INTEGER NOT NULL,
+					status   INTEGER NOT NULL,
+					data     TEXT    NOT NULL,
+					PRIMARY KEY (job_uuid, run)
+				)`,
+			},
+		},
+		{
+			Version: 2,
+			Statements: []string{
+				`ALTER TABLE scheduler_jobs ADD COLUMN revision INTEGER NOT NULL DEFAULT 1`,
+			},
+		},
+		{
+			Version: 3,
+			Statements: []string{
+				`ALTER TABLE scheduler_jobs ADD COLUMN namespace TEXT NOT NULL DEFAULT ''`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS scheduler_jobs_name ON scheduler_jobs (namespace, name) WHERE namespace <> ''`,
+			},
+		},
+		{
+			Version: 4,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_locks (
+					name    TEXT    NOT NULL PRIMARY KEY,
+					owner   TEXT    NOT NULL,
+					token   INTEGER NOT NULL,
+					expires INTEGER NOT NULL
+				)`,
+			},
+		},
+		{
+			Version: 5,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_members (
+					instance TEXT    NOT NULL PRIMARY KEY,
+					expires  INTEGER NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS scheduler_owners (
+					job_uuid TEXT NOT NULL PRIMARY KEY REFERENCES scheduler_jobs (uuid) ON DELETE CASCADE,
+					instance TEXT NOT NULL
+				)`,
+			},
+		},
+		{
+			Version: 6,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_history (
+					job_uuid TEXT    NOT NULL,
+					run      INTEGER NOT NULL,
+					job_name TEXT    NOT NULL,
+					start    INTEGER NOT NULL,
+					finish   INTEGER NOT NULL,
+					status   INTEGER NOT NULL,
+					data     TEXT    NOT NULL,
+					PRIMARY KEY (job_uuid, run)
+				)`,
+				`CREATE INDEX IF NOT EXISTS scheduler_history_start ON scheduler_history (start)`,
+			},
+		},
+	}
+}
+
+type PostgreSQLDialect struct{}
+
+func (d PostgreSQLDialect) Name() string {
+	return "postgres"
+}
+
+func (d PostgreSQLDialect) Placeholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+func (d PostgreSQLDialect) Migrations() []SQLMigration {
+	return []SQLMigration{
+		{
+			Version: 1,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_jobs (
+					uuid       UUID    NOT NULL PRIMARY KEY,
+					name       TEXT    NOT NULL,
+					enabled    BOOLEAN NOT NULL,
+					status     INTEGER NOT NULL,
+					definition TEXT    NOT NULL
+				)`,
+				`CREATE INDEX IF NOT EXISTS scheduler_jobs_status ON scheduler_jobs (status)`,
+				`CREATE TABLE IF NOT EXISTS scheduler_results (
+					job_uuid UUID    NOT NULL REFERENCES scheduler_jobs (uuid) ON DELETE CASCADE,
+					run      INTEGER NOT NULL,
+					start    BIGINT  NOT NULL,
+					status   INTEGER NOT NULL,
+					data     TEXT    NOT NULL,
+					PRIMARY KEY (job_uuid, run)
+				)`,
+			},
+		},
+		{
+			Version: 2,
+			Statements: []string{
+				`ALTER TABLE scheduler_jobs ADD COLUMN revision BIGINT  NOT NULL DEFAULT 1`,
+			},
+		},
+		{
+			Version: 3,
+			Statements: []string{
+				`ALTER TABLE scheduler_jobs ADD COLUMN namespace TEXT NOT NULL DEFAULT ''`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS scheduler_jobs_name ON scheduler_jobs (namespace, name) WHERE namespace <> ''`,
+			},
+		},
+		{
+			Version: 4,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_locks (
+					name    TEXT   NOT NULL PRIMARY KEY,
+					owner   TEXT   NOT NULL,
+					token   BIGINT NOT NULL,
+					expires BIGINT NOT NULL
+				)`,
+			},
+		},
+		{
+			Version: 5,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_members (
+					instance TEXT   NOT NULL PRIMARY KEY,
+					expires  BIGINT NOT NULL
+				)`,
+				`CREATE TABLE IF NOT EXISTS scheduler_owners (
+					job_uuid UUID NOT NULL PRIMARY KEY REFERENCES scheduler_jobs (uuid) ON DELETE CASCADE,
+					instance TEXT NOT NULL
+				)`,
+			},
+		},
+		{
+			Version: 6,
+			Statements: []string{
+				`CREATE TABLE IF NOT EXISTS scheduler_history (
+					job_uuid UUID    NOT NULL,
+					run      INTEGER NOT NULL,
+					job_name TEXT    NOT NULL,
+					start    BIGINT  NOT NULL,
+					finish   BIGINT  NOT NULL,
+					status   INTEGER NOT NULL,
+					data     TEXT    NOT NULL,
+					PRIMARY KEY (job_uuid, run)
+				)`,
+				`CREATE INDEX IF NOT EXISTS scheduler_history_start ON scheduler_history (start)`,
+			},
+		},
+	}
+}
+
+// migrateSQL applies all migrations of dialect d which have not been applied to db yet. The migrations are applied in a
+// single transaction, which locks the version row, so concurrent migrations of the same database apply every
+// migration once.
+func migrateSQL(ctx context.Context, db *sql.DB, d SQLDialect) error {
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS scheduler_migrations (version INTEGER NOT NULL PRIMARY KEY)"); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO scheduler_migrations (version) VALUES (0) ON CONFLICT DO NOTHING"); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = migrateSQLTx(ctx, tx, d); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// migrateSQLTx applies the migrations in tx, the version row 0 is locked by updating it before the version is read
+func migrateSQLTx(ctx context.Context, tx *sql.Tx, d SQLDialect) error {
+	var version int
+
+	if _, err := tx.ExecContext(ctx, "UPDATE scheduler_migrations SET version = version WHERE version = 0"); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT MAX(version) FROM scheduler_migrations").Scan(&version); err != nil {
+		return err
+	}
+
+	for _, m := range d.Migrations() {
+		if m.Version <= version {
+			continue
+		}
+		for _, statement := range m.Statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, rebind(d, "INSERT INTO scheduler_migrations (version) VALUES (?)"), m.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebind replaces the ? placeholders in query with the placeholders of the dialect
+func rebind(d SQLDialect, query string) string {
+	var (
+		b     strings.Builder
+		index int
+	)
+	for _, r := range query {
+		if r == '?' {
+			index++
+			b.WriteString(d.Placeholder(index))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
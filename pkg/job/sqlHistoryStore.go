@@ -0,0 +1,230 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SQLHistoryStoreConfig struct {
+	Dialect SQLDialect
+}
+
+// NewSQLHistoryStore returns a history store backed by db and applies the schema migrations of the configured dialect.
+// The store can share a database with a SQLCatalog, records are kept when their job is deleted from the catalog.
+func NewSQLHistoryStore(db *sql.DB, config SQLHistoryStoreConfig) (*SQLHistoryStore, error) {
+	if err := migrateSQL(context.Background(), db, config.Dialect); err != nil {
+		return nil, err
+	}
+	return &SQLHistoryStore{
+		db:     db,
+		config: config,
+	}, nil
+}
+
+type SQLHistoryStore struct {
+	db     *sql.DB
+	config SQLHistoryStoreConfig
+}
+
+func (s *SQLHistoryStore) Append(record HistoryRecord) error {
+	rr, err := newResultRecord(record.Result)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if data, err = json.Marshal(rr); err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.query("INSERT INTO scheduler_history (job_uuid, run, job_name, start, finish, status, data) VALUES (?, ?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT (job_uuid, run) DO UPDATE SET job_name = excluded.job_name, start = excluded.start, finish = excluded.finish, status = excluded.status, data = excluded.data"),
+		record.JobId, record.Run, record.JobName, record.Result.Start.UnixNano(), unixNano(record.Result.Finish), record.Result.Status, string(data))
+	return err
+}
+
+// Prune removes the records which are not kept by policy, the records of every job are read in order of their runs
+// to determine their age
+func (s *SQLHistoryStore) Prune(policy RetentionPolicy, now time.Time) (int, error) {
+	if policy.IsZero() {
+		return 0, nil
+	}
+
+	type run struct {
+		jobId uuid.UUID
+		run   int
+	}
+
+	var (
+		err    error
+		pruned []run
+	)
+	err = s.inTx(func(tx *sql.Tx) error {
+		var rows *sql.Rows
+		if rows, err = tx.Query("SELECT job_uuid, run, finish, status FROM scheduler_history ORDER BY job_uuid, run DESC"); err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var (
+			current uuid.UUID
+			age     int
+		)
+		for rows.Next() {
+			var (
+				r      run
+				finish int64
+				result Result
+			)
+			if err = rows.Scan(&r.jobId, &r.run, &finish, &result.Status); err != nil {
+				return err
+			}
+			if r.jobId != current {
+				current, age = r.jobId, 0
+			}
+			age++
+			if finish != 0 {
+				result.Finish = time.Unix(0, finish)
+			}
+			if !policy.keep(result, age, now) {
+				pruned = append(pruned, r)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, r := range pruned {
+			if _, err = tx.Exec(s.query("DELETE FROM scheduler_history WHERE job_uuid = ? AND run = ?"), r.jobId, r.run); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pruned), nil
+}
+
+func (s *SQLHistoryStore) Query(query HistoryQuery) (HistoryPage, error) {
+	var (
+		where = []string{"1 = 1"}
+		args  []interface{}
+	)
+	if query.JobId != uuid.Nil {
+		where = append(where, "job_uuid = ?")
+		args = append(args, query.JobId)
+	}
+	if query.Status != StatusNone {
+		where = append(where, "status = ?")
+		args = append(args, query.Status)
+	}
+	if !query.From.IsZero() {
+		where = append(where, "start >= ?")
+		args = append(args, query.From.UnixNano())
+	}
+	if !query.To.IsZero() {
+		where = append(where, "start < ?")
+		args = append(args, query.To.UnixNano())
+	}
+	filter := strings.Join(where, " AND ")
+
+	var (
+		err  error
+		page = HistoryPage{Records: make([]HistoryRecord, 0)}
+	)
+	err = s.inTx(func(tx *sql.Tx) error {
+		if err = tx.QueryRow(s.query("SELECT COUNT(*) FROM scheduler_history WHERE "+filter), args...).Scan(&page.Total); err != nil {
+			return err
+		}
+
+		statement := "SELECT job_uuid, run, job_name, data FROM scheduler_history WHERE " + filter + " ORDER BY start DESC, job_uuid, run DESC"
+		if query.Limit > 0 {
+			statement += " LIMIT ? OFFSET ?"
+			args = append(args, query.Limit, max(query.Offset, 0))
+		}
+
+		var rows *sql.Rows
+		if rows, err = tx.Query(s.query(statement), args...); err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		// Without a limit the offset is applied to the selected records
+		skip := 0
+		if query.Limit <= 0 {
+			skip = max(query.Offset, 0)
+		}
+		for rows.Next() {
+			var (
+				r    HistoryRecord
+				data string
+				rr   resultRecord
+			)
+			if err = rows.Scan(&r.JobId, &r.Run, &r.JobName, &data); err != nil {
+				return err
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if err = json.Unmarshal([]byte(data), &rr); err != nil {
+				return err
+			}
+			if r.Result, err = rr.result(); err != nil {
+				return err
+			}
+			page.Records = append(page.Records, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	return page, nil
+}
+
+func (s *SQLHistoryStore) inTx(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err = f(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+func (s *SQLHistoryStore) query(query string) string {
+	return rebind(s.config.Dialect, query)
+}
+
+// unixNano returns t in nanoseconds since the Unix epoch, or 0 for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestSQLHistoryStore(t *testing.T) *SQLHistoryStore {
+	t.Helper()
+
+	s, err := NewSQLHistoryStore(openTestDatabase(t, filepath.Join(t.TempDir(), "history.db")), SQLHistoryStoreConfig{Dialect: SQLiteDialect{}})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	return s
+}
+
+func TestSQLHistoryStore_Append(t *testing.T) {
+	s := newTestSQLHistoryStore(t)
+	id := uuid.New()
+	appendTestHistory(t, s, time.Now(), id)
+
+	// Appending the same run again replaces the record
+	if err := s.Append(HistoryRecord{JobId: id, JobName: "test", Run: 3, Result: Result{Start: time.Now(), Status: StatusActive}}); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := s.Query(HistoryQuery{JobId: id})
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if page.Total != 5 {
+		t.Errorf("got %d records, expected %d", page.Total, 5)
+	}
+
+	if page, err = s.Query(HistoryQuery{JobId: id, Status: StatusActive}); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if page.Total != 1 || page.Records[0].Run != 3 || page.Records[0].JobName != "test" {
+		t.Errorf("got %d active records, expected run 3 to be replaced", page.Total)
+	}
+}
+
+func TestSQLHistoryStore_Query(t *testing.T) {
+	testHistoryStoreQuery(t, newTestSQLHistoryStore(t))
+}
+
+func TestSQLHistoryStore_Prune(t *testing.T) {
+	testHistoryStorePrune(t, newTestSQLHistoryStore(t))
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultLockTTL is used by NewSQLLocker when ttl is not set
+const DefaultLockTTL = 30 * time.Second
+
+// NewSQLLocker returns a locker which stores leases in the database of catalog. A lease expires after ttl unless it is
+// renewed, after which another owner can take over the lock. Owner identifies this instance, a random identifier is
+// used when owner is empty.
+func NewSQLLocker(catalog *SQLCatalog, owner string, ttl time.Duration) *SQLLocker {
+	if owner == "" {
+		owner = uuid.NewString()
+	}
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	return &SQLLocker{
+		catalog: catalog,
+		owner:   owner,
+		ttl:     ttl,
+	}
+}
+
+type SQLLocker struct {
+	catalog *SQLCatalog
+	owner   string
+	ttl     time.Duration
+}
+
+func (l *SQLLocker) Owner() string {
+	return l.owner
+}
+
+func (l *SQLLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	var token uint64
+	err := l.catalog.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, l.catalog.query("INSERT INTO scheduler_locks (name, owner, token, expires) VALUES (?, '', 0, 0) ON CONFLICT (name) DO NOTHING"), name); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result, err := tx.ExecContext(ctx, l.catalog.query("UPDATE scheduler_locks SET owner = ?, token = token + 1, expires = ? WHERE name = ? AND expires <= ?"),
+			l.owner, now.Add(l.ttl).UnixNano(), name, now.UnixNano())
+		if err != nil {
+			return err
+		}
+		if err = expectRows(result, ErrLocked); err != nil {
+			return err
+		}
+		return tx.QueryRowContext(ctx, l.catalog.query("SELECT token FROM scheduler_locks WHERE name = ?"), name).Scan(&token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sqlLock{
+		locker: l,
+		name:   name,
+		token:  token,
+	}, nil
+}
+
+type sqlLock struct {
+	locker *SQLLocker
+	name   string
+	token  uint64
+}
+
+func (l *sqlLock) Token() uint64 {
+	return l.token
+}
+
+func (l *sqlLock) Renew(ctx context.Context) error {
+	return l.update(ctx, "UPDATE scheduler_locks SET expires = ? WHERE name = ? AND owner = ? AND token = ?",
+		time.Now().Add(l.locker.ttl).UnixNano())
+}
+
+func (l *sqlLock) Release(ctx context.Context) error {
+	return l.update(ctx, "UPDATE scheduler_locks SET owner = '', expires = ? WHERE name = ? AND owner = ? AND token = ?", 0)
+}
+
+// update changes the lease of the lock, as long as it is still held with the same token
+func (l *sqlLock) update(ctx context.Context, query string, expires int64) error {
+	result, err := l.locker.catalog.db.ExecContext(ctx, l.locker.catalog.query(query), expires, l.name, l.locker.owner, l.token)
+	if err != nil {
+		return err
+	}
+	return expectRows(result, ErrLockLost)
+}
+
+// expectRows returns kind if result did not affect any rows
+func expectRows(result sql.Result, kind Error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return kind
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package job
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLLockers(t *testing.T, count int, ttl time.Duration) []*SQLLocker {
+	t.Helper()
+
+	name := filepath.Join(t.TempDir(), "catalog.db")
+	lockers := make([]*SQLLocker, count)
+	for i := range lockers {
+		lockers[i] = NewSQLLocker(newTestSQLCatalog(t, openTestDatabase(t, name)), "", ttl)
+	}
+	return lockers
+}
+
+func TestSQLLocker_TryLock(t *testing.T) {
+	lockers := newTestSQLLockers(t, 2, time.Minute)
+
+	lock, err := lockers[0].TryLock(context.Background(), "job/test")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if _, err = lockers[1].TryLock(context.Background(), "job/test"); !errors.Is(err, ErrLocked) {
+		t.Errorf("got error %v, expected ErrLocked", err)
+	}
+	if err = lock.Renew(context.Background()); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+
+	if err = lock.Release(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	next, err := lockers[1].TryLock(context.Background(), "job/test")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if next.Token() <= lock.Token() {
+		t.Errorf("got fencing token %d after %d, expected it to increase", next.Token(), lock.Token())
+	}
+	if err = lock.Release(context.Background()); !errors.Is(err, ErrLockLost) {
+		t.Errorf("got error %v releasing a lock held by another owner, expected ErrLockLost", err)
+	}
+}
+
+func TestSQLLocker_Expired(t *testing.T) {
+	lockers := newTestSQLLockers(t, 2, 50*time.Millisecond)
+
+	lock, err := lockers[0].TryLock(context.Background(), "leader")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	// Another owner takes over the lock once the lease has expired, after which the old lease cannot be renewed
+	time.Sleep(100 * time.Millisecond)
+	next, err := lockers[1].TryLock(context.Background(), "leader")
+	if err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if next.Token() <= lock.Token() {
+		t.Errorf("got fencing token %d after %d, expected it to increase", next.Token(), lock.Token())
+	}
+	if err = lock.Renew(context.Background()); !errors.Is(err, ErrLockLost) {
+		t.Errorf("got error %v, expected ErrLockLost", err)
+	}
+}
+
+func TestLeaderElector_Leader(t *testing.T) {
+	lockers := newTestSQLLockers(t, 3, 100*time.Millisecond)
+	electors := make([]*LockLeaderElector, len(lockers))
+	for i, l := range lockers {
+		electors[i] = NewLeaderElector(l, "leader", 20*time.Millisecond)
+	}
+
+	leaders := func() (int, uint64) {
+		count, token := 0, uint64(0)
+		for _, e := range electors {
+			isLeader, term, err := e.Leader(context.Background())
+			if err != nil {
+				t.Fatalf("got error: %s", err.Error())
+			}
+			if isLeader {
+				count++
+				token = term
+			}
+		}
+		return count, token
+	}
+
+	// Renewing the lease keeps the leader in place
+	count, first := leaders()
+	for i := 0; i < 10; i++ {
+		time.Sleep(20 * time.Millisecond)
+		var token uint64
+		if count, token = leaders(); count != 1 || token != first {
+			t.Fatalf("got %d leaders with token %d, expected the leader with token %d", count, token, first)
+		}
+	}
+
+	// A new leader is elected after the leader resigns
+	if err := electors[0].Resign(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	count, next := leaders()
+	if count != 1 || next <= first {
+		t.Errorf("got %d leaders with token %d, expected one leader with a token above %d", count, next, first)
+	}
+}
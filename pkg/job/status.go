@@ -16,6 +16,12 @@
 
 package job
 
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
 type Status int
 
 func (s Status) String() string {
@@ -33,3 +39,33 @@ const (
 	StatusCompleted
 	StatusError
 )
+
+// transitions lists the statuses a job can move to from each status
+var transitions = map[Status][]Status{
+	StatusInactive:    {StatusAvailable},
+	StatusAvailable:   {StatusInactive, StatusSchedulable},
+	StatusSchedulable: {StatusInactive, StatusRunnable},
+	StatusRunnable:    {StatusInactive, StatusPending},
+	StatusPending:     {StatusInactive, StatusActive},
+	StatusActive:      {StatusInactive, StatusPending, StatusCompleted, StatusError},
+	StatusCompleted:   {StatusInactive},
+	StatusError:       {StatusInactive},
+}
+
+// CanTransition reports whether a job can move from status s to status to
+func (s Status) CanTransition(to Status) bool {
+	for _, t := range transitions[s] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns ErrInvalidTransition if the job with jobId cannot move from status from to status to
+func checkTransition(jobId uuid.UUID, from Status, to Status) error {
+	if from.CanTransition(to) {
+		return nil
+	}
+	return ErrInvalidTransition.WithJob(jobId, "").WithCause(fmt.Errorf("from %s to %s", from, to))
+}
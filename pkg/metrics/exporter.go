@@ -0,0 +1,298 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package metrics exports the statistics of an orchestrator and its task handlers in the Prometheus text format.
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// NewExporter returns an exporter which observes the runs and errors of an orchestrator when it is set as
+// job.OrchestratorConfig.Observer, Handler serves the metrics.
+func NewExporter(config Config) *Exporter {
+	return &Exporter{
+		config:      config,
+		runs:        make(map[string]*histogramSeries),
+		lags:        make(map[string]*histogramSeries),
+		errors:      make(map[string]uint64),
+		labelValues: make(map[string]map[string]struct{}),
+	}
+}
+
+type Exporter struct {
+	config      Config
+	runs        map[string]*histogramSeries    // run durations by status and job labels
+	lags        map[string]*histogramSeries    // schedule lag by job labels
+	errors      map[string]uint64              // errors by kind
+	labelValues map[string]map[string]struct{} // the exported values of every job label
+	mux         sync.Mutex
+}
+
+type histogramSeries struct {
+	labels    []label
+	histogram task.Histogram
+}
+
+// ObserveRun records the duration and schedule lag of a finished run
+func (e *Exporter) ObserveRun(run job.RunEvent) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	labels := e.jobLabels(run.Job)
+	e.observe(e.runs, append([]label{{"status", run.Result.Status.String()}}, labels...), e.config.durationBuckets()).
+		histogram.Observe(run.Result.Finish.Sub(run.Result.Start))
+	if !run.Due.IsZero() {
+		e.observe(e.lags, labels, e.config.lagBuckets()).histogram.Observe(run.Lag())
+	}
+}
+
+// ObserveError counts err by its kind
+func (e *Exporter) ObserveError(err error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.errors[errorKind(err)]++
+}
+
+// Handler returns a handler which serves the metrics of orchestrator and the handler pools of handlers, either can be
+// nil to leave out their metrics.
+func (e *Exporter) Handler(orchestrator *job.Orchestrator, handlers *task.HandlerRepository) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := e.newWriter()
+		if orchestrator != nil {
+			e.writeOrchestrator(w, orchestrator.Statistics())
+		}
+		e.writeRuns(w)
+		if handlers != nil {
+			e.writeHandlers(w, handlers.Stats())
+		}
+
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = rw.Write(w.buf.Bytes())
+	})
+}
+
+// jobLabels returns the configured labels of j, e.mux must be held
+func (e *Exporter) jobLabels(j job.Job) []label {
+	labels := make([]label, 0, len(e.config.JobLabels))
+	for _, name := range e.config.JobLabels {
+		value := j.Labels[name]
+		values, found := e.labelValues[name]
+		if !found {
+			values = make(map[string]struct{})
+			e.labelValues[name] = values
+		}
+		if _, seen := values[value]; !seen {
+			if len(values) >= e.config.maxLabelValues() {
+				value = OtherLabelValue
+			} else {
+				values[value] = struct{}{}
+			}
+		}
+		labels = append(labels, label{"label_" + labelName(name), value})
+	}
+	return labels
+}
+
+// observe returns the series of histograms with labels, e.mux must be held
+func (e *Exporter) observe(histograms map[string]*histogramSeries, labels []label, buckets []time.Duration) *histogramSeries {
+	key := seriesKey(labels)
+	s, found := histograms[key]
+	if !found {
+		s = &histogramSeries{labels: labels, histogram: task.NewHistogram(buckets)}
+		histograms[key] = s
+	}
+	return s
+}
+
+func (e *Exporter) newWriter() *writer {
+	w := &writer{}
+	names := make([]string, 0, len(e.config.ConstLabels))
+	for name := range e.config.ConstLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.constLabels = append(w.constLabels, label{labelName(name), e.config.ConstLabels[name]})
+	}
+	return w
+}
+
+func (e *Exporter) writeHandlers(w *writer, stats []task.HandlerPoolStats) {
+	ns := e.config.namespace()
+
+	gauges := []struct {
+		name  string
+		help  string
+		value func(s task.HandlerPoolStats) float64
+	}{
+		{"handler_pool_capacity", "Maximum number of tasks a handler pool executes at the same time.", func(s task.HandlerPoolStats) float64 { return float64(s.MaxConcurrent) }},
+		{"handler_pool_active", "Number of tasks a handler pool is executing.", func(s task.HandlerPoolStats) float64 { return float64(s.Active) }},
+		{"handler_pool_utilization", "Fraction of the capacity of a handler pool in use.", func(s task.HandlerPoolStats) float64 {
+			if s.MaxConcurrent == 0 {
+				return 0
+			}
+			return float64(s.Active) / float64(s.MaxConcurrent)
+		}},
+		{"handler_pool_queued", "Number of tasks waiting for a free handler.", func(s task.HandlerPoolStats) float64 { return float64(s.Queued) }},
+	}
+	for _, g := range gauges {
+		w.family(ns+"_"+g.name, g.help, "gauge")
+		for _, s := range stats {
+			w.sample(ns+"_"+g.name, []label{{"type", s.Type}}, g.value(s))
+		}
+	}
+
+	w.family(ns+"_task_executions_total", "Number of executed tasks by type and resulting status.", "counter")
+	for _, s := range stats {
+		statuses := make([]task.Status, 0, len(s.Executions))
+		for status := range s.Executions {
+			statuses = append(statuses, status)
+		}
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i] < statuses[j]
+		})
+		for _, status := range statuses {
+			w.sample(ns+"_task_executions_total", []label{{"type", s.Type}, {"status", status.String()}}, float64(s.Executions[status]))
+		}
+	}
+
+	w.family(ns+"_handler_pool_rejected_total", "Number of tasks which failed because the queue of the handler pool was full.", "counter")
+	for _, s := range stats {
+		w.sample(ns+"_handler_pool_rejected_total", []label{{"type", s.Type}}, float64(s.Rejected))
+	}
+	w.family(ns+"_handler_pool_timed_out_total", "Number of tasks which failed because they waited too long for a free handler.", "counter")
+	for _, s := range stats {
+		w.sample(ns+"_handler_pool_timed_out_total", []label{{"type", s.Type}}, float64(s.TimedOut))
+	}
+	w.family(ns+"_handler_pool_wait_seconds", "Time tasks waited for a free handler.", "histogram")
+	for _, s := range stats {
+		w.histogram(ns+"_handler_pool_wait_seconds", []label{{"type", s.Type}}, s.WaitTime)
+	}
+}
+
+func (e *Exporter) writeOrchestrator(w *writer, stats job.OrchestratorStats) {
+	ns := e.config.namespace()
+
+	w.family(ns+"_jobs", "Number of jobs in the catalog by status.", "gauge")
+	for _, s := range []struct {
+		status job.Status
+		value  float64
+	}{
+		{job.StatusInactive, stats.Job.InactiveJobs},
+		{job.StatusAvailable, stats.Job.AvailableJobs},
+		{job.StatusSchedulable, stats.Job.SchedulableJobs},
+		{job.StatusRunnable, stats.Job.RunnableJobs},
+		{job.StatusPending, stats.Job.PendingJobs},
+		{job.StatusActive, stats.Job.ActiveJobs},
+		{job.StatusCompleted, stats.Job.CompletedJobs},
+		{job.StatusError, stats.Job.ErrorJobs},
+	} {
+		w.sample(ns+"_jobs", []label{{"status", s.status.String()}}, s.value)
+	}
+
+	w.family(ns+"_jobs_enabled", "Number of enabled jobs in the catalog.", "gauge")
+	w.sample(ns+"_jobs_enabled", nil, stats.Job.EnabledJobs)
+	w.family(ns+"_jobs_disabled", "Number of disabled jobs in the catalog.", "gauge")
+	w.sample(ns+"_jobs_disabled", nil, stats.Job.DisabledJobs)
+	w.family(ns+"_running_jobs", "Number of runs executed by the orchestrator.", "gauge")
+	w.sample(ns+"_running_jobs", nil, stats.Job.RunningJobs)
+
+	leader := 0.0
+	if stats.Leader {
+		leader = 1
+	}
+	w.family(ns+"_leader", "Whether the orchestrator dispatches jobs.", "gauge")
+	w.sample(ns+"_leader", nil, leader)
+
+	w.family(ns+"_owned_jobs", "Number of jobs owned by every member of a sharded catalog.", "gauge")
+	for _, s := range stats.Shards {
+		w.sample(ns+"_owned_jobs", []label{{"instance", s.Instance}}, s.OwnedJobs)
+	}
+}
+
+func (e *Exporter) writeRuns(w *writer) {
+	ns := e.config.namespace()
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	w.family(ns+"_run_duration_seconds", "Duration of finished runs by status.", "histogram")
+	for _, s := range sortedSeries(e.runs) {
+		w.histogram(ns+"_run_duration_seconds", s.labels, s.histogram)
+	}
+	w.family(ns+"_schedule_lag_seconds", "Time between the moment a run was due and its start.", "histogram")
+	for _, s := range sortedSeries(e.lags) {
+		w.histogram(ns+"_schedule_lag_seconds", s.labels, s.histogram)
+	}
+
+	w.family(ns+"_errors_total", "Number of errors reported by the orchestrator by kind.", "counter")
+	kinds := make([]string, 0, len(e.errors))
+	for kind := range e.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		w.sample(ns+"_errors_total", []label{{"kind", kind}}, float64(e.errors[kind]))
+	}
+}
+
+// errorKind returns the kind of a job or task error with underscores instead of spaces, other errors are of kind other
+func errorKind(err error) string {
+	var (
+		jobErr  job.Error
+		taskErr task.Error
+		kind    = "other"
+	)
+	switch {
+	case errors.As(err, &jobErr):
+		kind = jobErr.Kind().String()
+	case errors.As(err, &taskErr):
+		kind = taskErr.Kind().String()
+	}
+	return strings.ReplaceAll(kind, " ", "_")
+}
+
+func seriesKey(labels []label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.name + "=" + l.value + "\x00")
+	}
+	return b.String()
+}
+
+func sortedSeries(series map[string]*histogramSeries) []*histogramSeries {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*histogramSeries, len(keys))
+	for i, key := range keys {
+		sorted[i] = series[key]
+	}
+	return sorted
+}
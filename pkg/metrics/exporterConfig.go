@@ -0,0 +1,98 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package metrics
+
+import "time"
+
+const (
+	// DefaultNamespace is used when Config.Namespace is not set
+	DefaultNamespace = "scheduler"
+	// DefaultMaxLabelValues is used when Config.MaxLabelValues is not set
+	DefaultMaxLabelValues = 50
+	// OtherLabelValue replaces the values of a job label once the label has MaxLabelValues distinct values
+	OtherLabelValue = "other"
+)
+
+// DefaultDurationBuckets are the upper bounds of the buckets of the run duration histogram
+var DefaultDurationBuckets = []time.Duration{
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+}
+
+// DefaultLagBuckets are the upper bounds of the buckets of the schedule lag histogram
+var DefaultLagBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+type Config struct {
+	Namespace   string            // the prefix of the metric names, defaults to DefaultNamespace
+	ConstLabels map[string]string // labels which are added to every metric, such as the instance or environment
+	// JobLabels are the labels of jobs which are added to the run metrics, as label_<name>. Only the first
+	// MaxLabelValues distinct values of every label are exported, later values are exported as OtherLabelValue.
+	JobLabels       []string
+	MaxLabelValues  int
+	DurationBuckets []time.Duration // defaults to DefaultDurationBuckets
+	LagBuckets      []time.Duration // defaults to DefaultLagBuckets
+}
+
+func (c Config) namespace() string {
+	if c.Namespace == "" {
+		return DefaultNamespace
+	}
+	return c.Namespace
+}
+
+func (c Config) maxLabelValues() int {
+	if c.MaxLabelValues <= 0 {
+		return DefaultMaxLabelValues
+	}
+	return c.MaxLabelValues
+}
+
+func (c Config) durationBuckets() []time.Duration {
+	if len(c.DurationBuckets) == 0 {
+		return DefaultDurationBuckets
+	}
+	return c.DurationBuckets
+}
+
+func (c Config) lagBuckets() []time.Duration {
+	if len(c.LagBuckets) == 0 {
+		return DefaultLagBuckets
+	}
+	return c.LagBuckets
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/cron"
+	"github.com/corelayer/go-scheduler/pkg/job"
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+// sampleLine matches a sample in the text exposition format
+var sampleLine = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*(\{([a-zA-Z_][a-zA-Z0-9_]*="([^"\\]|\\.)*",?)*\})? \S+$`)
+
+func scrape(t *testing.T, e *Exporter, o *job.Orchestrator, r *task.HandlerRepository) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	e.Handler(o, r).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q, expected the text exposition format", ct)
+	}
+
+	body := rec.Body.String()
+	for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") && !sampleLine.MatchString(line) {
+			t.Errorf("invalid sample %q", line)
+		}
+	}
+	return body
+}
+
+func expectSamples(t *testing.T, body string, samples ...string) {
+	t.Helper()
+
+	for _, sample := range samples {
+		if !strings.Contains(body, "\n"+sample+"\n") {
+			t.Errorf("sample %q not found in\n%s", sample, body)
+		}
+	}
+}
+
+func TestExporter_Handler(t *testing.T) {
+	r := task.NewHandlerRepository()
+	if err := r.RegisterHandlerPool(task.NewHandlerPool(task.NewDefaultEmptyTaskHandler())); err != nil {
+		t.Fatal(err)
+	}
+	s, err := cron.NewSchedule("@everysecond")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := job.NewMemoryCatalog()
+	j := job.NewJob("test", s, 1, job.NewSequence([]task.Task{task.EmptyTask{}}))
+	j.Labels = map[string]string{"team": "a"}
+	if err = c.Add(j); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewExporter(Config{ConstLabels: map[string]string{"instance": "test"}, JobLabels: []string{"team"}})
+	o := job.NewOrchestrator(c, r, job.OrchestratorConfig{MaxJobs: 1, ScheduleInterval: 10 * time.Millisecond, Observer: e})
+	if err = o.Start(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.HasEnabledJobs() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err = o.Stop(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	expectSamples(t, scrape(t, e, o, r),
+		`scheduler_jobs{instance="test",status="completed"} 1`,
+		`scheduler_jobs_disabled{instance="test"} 1`,
+		`scheduler_run_duration_seconds_count{instance="test",status="completed",label_team="a"} 1`,
+		`scheduler_run_duration_seconds_bucket{instance="test",status="completed",label_team="a",le="+Inf"} 1`,
+		`scheduler_schedule_lag_seconds_count{instance="test",label_team="a"} 1`,
+		`scheduler_task_executions_total{instance="test",type="empty",status="completed"} 1`,
+		`scheduler_handler_pool_utilization{instance="test",type="empty"} 0`,
+		`scheduler_handler_pool_wait_seconds_count{instance="test",type="empty"} 1`,
+	)
+}
+
+func TestExporter_MaxLabelValues(t *testing.T) {
+	e := NewExporter(Config{Namespace: "test", JobLabels: []string{"app.kubernetes.io/name"}, MaxLabelValues: 2})
+
+	start := time.Now()
+	for _, value := range []string{"a", "b", "c", "d", "a"} {
+		j := job.NewJob("test", cron.Schedule{}, 1, job.NewSequence(nil))
+		j.Labels = map[string]string{"app.kubernetes.io/name": value}
+		e.ObserveRun(job.RunEvent{Job: j, Result: job.Result{Start: start, Finish: start.Add(time.Second), Status: job.StatusCompleted}})
+	}
+
+	body := scrape(t, e, nil, nil)
+	expectSamples(t, body,
+		`test_run_duration_seconds_count{status="completed",label_app_kubernetes_io_name="a"} 2`,
+		`test_run_duration_seconds_count{status="completed",label_app_kubernetes_io_name="b"} 1`,
+		`test_run_duration_seconds_count{status="completed",label_app_kubernetes_io_name="other"} 2`,
+		`test_run_duration_seconds_sum{status="completed",label_app_kubernetes_io_name="other"} 2`,
+	)
+	if strings.Contains(body, "test_schedule_lag_seconds_count") {
+		t.Errorf("got schedule lag for runs without a due time")
+	}
+}
+
+func TestExporter_ObserveError(t *testing.T) {
+	e := NewExporter(Config{})
+	e.ObserveError(job.ErrNotFound)
+	e.ObserveError(job.ErrConflict.WithCause(errors.New("revision changed")))
+	e.ObserveError(task.ErrHandlerMissing.WithTask("test"))
+	e.ObserveError(errors.New("unknown"))
+	e.ObserveError(job.ErrNotFound)
+
+	expectSamples(t, scrape(t, e, nil, nil),
+		`scheduler_errors_total{kind="conflict"} 1`,
+		`scheduler_errors_total{kind="handler_missing"} 1`,
+		`scheduler_errors_total{kind="not_found"} 2`,
+		`scheduler_errors_total{kind="other"} 1`,
+	)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+type label struct {
+	name  string
+	value string
+}
+
+// writer writes metrics in the Prometheus text exposition format, the samples of a family must be written directly
+// after the family
+type writer struct {
+	buf         bytes.Buffer
+	constLabels []label
+}
+
+// family writes the help and type of the metric family name
+func (w *writer) family(name string, help string, typ string) {
+	w.buf.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	w.buf.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample writes a single sample of metric name
+func (w *writer) sample(name string, labels []label, value float64) {
+	w.buf.WriteString(name)
+	w.labels(labels)
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatValue(value))
+	w.buf.WriteByte('\n')
+}
+
+// histogram writes the cumulative buckets, sum and count of h in seconds
+func (w *writer) histogram(name string, labels []label, h task.Histogram) {
+	var cumulative uint64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		w.sample(name+"_bucket", append(labels[:len(labels):len(labels)], label{"le", formatValue(bound.Seconds())}), float64(cumulative))
+	}
+	w.sample(name+"_bucket", append(labels[:len(labels):len(labels)], label{"le", "+Inf"}), float64(h.Count))
+	w.sample(name+"_sum", labels, h.Sum.Seconds())
+	w.sample(name+"_count", labels, float64(h.Count))
+}
+
+func (w *writer) labels(labels []label) {
+	if len(w.constLabels)+len(labels) == 0 {
+		return
+	}
+
+	w.buf.WriteByte('{')
+	for i, l := range append(w.constLabels[:len(w.constLabels):len(w.constLabels)], labels...) {
+		if i > 0 {
+			w.buf.WriteByte(',')
+		}
+		w.buf.WriteString(l.name + `="` + escapeLabelValue(l.value) + `"`)
+	}
+	w.buf.WriteByte('}')
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// labelName turns s into a valid label name by replacing all characters other than letters, digits and underscores
+func labelName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
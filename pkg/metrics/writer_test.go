@@ -0,0 +1,67 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/task"
+)
+
+func TestWriter_Sample(t *testing.T) {
+	w := &writer{constLabels: []label{{"env", "test"}}}
+	w.family("test_total", "Help with a \\ and\na newline.", "counter")
+	w.sample("test_total", []label{{"path", "C:\\tmp \"quoted\"\n"}}, 1.5)
+	w.sample("test_total", nil, math.Inf(1))
+
+	wanted := "# HELP test_total Help with a \\\\ and\\na newline.\n" +
+		"# TYPE test_total counter\n" +
+		"test_total{env=\"test\",path=\"C:\\\\tmp \\\"quoted\\\"\\n\"} 1.5\n" +
+		"test_total{env=\"test\"} +Inf\n"
+	if got := w.buf.String(); got != wanted {
+		t.Errorf("got\n%s\nexpected\n%s", got, wanted)
+	}
+}
+
+func TestWriter_Histogram(t *testing.T) {
+	h := task.NewHistogram([]time.Duration{100 * time.Millisecond, time.Second})
+	for _, d := range []time.Duration{50 * time.Millisecond, 500 * time.Millisecond, 700 * time.Millisecond, 2 * time.Second} {
+		h.Observe(d)
+	}
+
+	w := &writer{}
+	w.histogram("test_seconds", []label{{"type", "a"}}, h)
+
+	wanted := "test_seconds_bucket{type=\"a\",le=\"0.1\"} 1\n" +
+		"test_seconds_bucket{type=\"a\",le=\"1\"} 3\n" +
+		"test_seconds_bucket{type=\"a\",le=\"+Inf\"} 4\n" +
+		"test_seconds_sum{type=\"a\"} 3.25\n" +
+		"test_seconds_count{type=\"a\"} 4\n"
+	if got := w.buf.String(); got != wanted {
+		t.Errorf("got\n%s\nexpected\n%s", got, wanted)
+	}
+}
+
+func TestLabelName(t *testing.T) {
+	for input, wanted := range map[string]string{"team": "team", "app.kubernetes.io/name": "app_kubernetes_io_name", "1st": "_st"} {
+		if got := labelName(input); got != wanted {
+			t.Errorf("got %q for %q, expected %q", got, input, wanted)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Every converts the interval between events into a rate in events per second
+func Every(interval time.Duration) float64 {
+	if interval <= 0 {
+		return math.Inf(1)
+	}
+	return float64(time.Second) / float64(interval)
+}
+
+// NewLimiter returns a token bucket which allows rate events per second on average and bursts of up to burst events.
+// The bucket starts full. A burst below 1 is raised to 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	burst = max(burst, 1)
+	return &Limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		now:    time.Now,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// Limiter is a token bucket rate limiter, it is safe for concurrent use
+type Limiter struct {
+	rate   float64
+	burst  int
+	tokens float64 // negative when events are waiting for tokens which have been reserved
+	last   time.Time
+	now    func() time.Time
+	stats  Stats
+	mux    *sync.Mutex
+}
+
+// Allow reports whether an event can happen now, a token is taken if it can
+func (l *Limiter) Allow() bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.advance(l.now())
+	if l.tokens < 1 {
+		l.stats.Rejected++
+		return false
+	}
+	l.tokens--
+	l.stats.Allowed++
+	return true
+}
+
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// Delay returns how long until an event can happen, it does not take a token
+func (l *Limiter) Delay() time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.advance(l.now())
+	switch {
+	case l.tokens >= 1 || math.IsInf(l.rate, 1):
+		return 0
+	case l.rate <= 0:
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) Rate() float64 {
+	return l.rate
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return l.stats
+}
+
+// Take takes a token for an event which happens now, after it was held back for waited. It is meant for callers which
+// use Delay to decide when an event can happen, the token is taken even if the bucket is empty.
+func (l *Limiter) Take(waited time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.advance(l.now())
+	l.tokens--
+	if waited > 0 {
+		l.stats.Delayed++
+		l.stats.WaitTime += waited
+	} else {
+		l.stats.Allowed++
+	}
+}
+
+// Wait blocks until an event can happen. If ctx is done before, the reserved token is returned to the bucket and
+// the context error is returned.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	l.mux.Lock()
+	delay := l.reserve(l.now())
+	if delay == 0 {
+		l.stats.Allowed++
+		l.mux.Unlock()
+		return nil
+	}
+	l.stats.Delayed++
+	l.stats.Waiting++
+	l.mux.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		l.mux.Lock()
+		l.stats.Waiting--
+		l.stats.WaitTime += time.Since(start)
+		l.mux.Unlock()
+		return nil
+	case <-ctx.Done():
+		l.mux.Lock()
+		l.tokens++
+		l.stats.Waiting--
+		l.stats.Canceled++
+		l.stats.WaitTime += time.Since(start)
+		l.mux.Unlock()
+		return ctx.Err()
+	}
+}
+
+// advance adds the tokens generated since the last update, l.mux must be held
+func (l *Limiter) advance(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.burst))
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
+
+// reserve takes a token and returns how long to wait before it is available, l.mux must be held
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.advance(now)
+	l.tokens--
+	switch {
+	case l.tokens >= 0 || math.IsInf(l.rate, 1):
+		return 0
+	case l.rate <= 0:
+		// The bucket is never refilled, the event waits until its context is done
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
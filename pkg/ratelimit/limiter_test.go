@@ -0,0 +1,154 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(rate, burst)
+	l.now = c.Now
+	return l, c
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	l, c := newTestLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("event %d of burst not allowed", i)
+		}
+	}
+	if l.Allow() {
+		t.Errorf("event allowed after burst, expected empty bucket")
+	}
+
+	// Two tokens are added every second, but the bucket never holds more than the burst
+	c.now = c.now.Add(500 * time.Millisecond)
+	if !l.Allow() || l.Allow() {
+		t.Errorf("expected exactly one event to be allowed after 500ms")
+	}
+	c.now = c.now.Add(time.Hour)
+	allowed := 0
+	for l.Allow() {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Errorf("got %d events allowed after a long pause, expected burst of 3", allowed)
+	}
+
+	stats := l.Stats()
+	if stats.Allowed != 7 || stats.Rejected != 3 {
+		t.Errorf("got %d allowed and %d rejected, expected 7 and 3", stats.Allowed, stats.Rejected)
+	}
+}
+
+func TestLimiter_DelayTake(t *testing.T) {
+	l, c := newTestLimiter(2, 1)
+
+	if delay := l.Delay(); delay != 0 {
+		t.Fatalf("got delay %s for a full bucket, expected none", delay)
+	}
+	l.Take(0)
+	if delay := l.Delay(); delay != 500*time.Millisecond {
+		t.Errorf("got delay %s for an empty bucket, expected 500ms", delay)
+	}
+
+	// Delay does not take a token
+	c.now = c.now.Add(500 * time.Millisecond)
+	if l.Delay() != 0 || l.Delay() != 0 {
+		t.Errorf("expected an event to be allowed after 500ms")
+	}
+	l.Take(500 * time.Millisecond)
+
+	stats := l.Stats()
+	if stats.Allowed != 1 || stats.Delayed != 1 || stats.WaitTime != 500*time.Millisecond {
+		t.Errorf("got stats %+v, expected 1 allowed and 1 delayed event", stats)
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	l := NewLimiter(Every(20*time.Millisecond), 1)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Errorf("4 events with burst 1 took %s, expected at least 60ms", elapsed)
+	}
+
+	stats := l.Stats()
+	if stats.Allowed != 1 || stats.Delayed != 3 || stats.Waiting != 0 || stats.WaitTime == 0 {
+		t.Errorf("got stats %+v, expected 1 allowed and 3 delayed events", stats)
+	}
+}
+
+func TestLimiter_WaitCanceled(t *testing.T) {
+	l := NewLimiter(Every(time.Hour), 1)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	// The token reserved by the canceled event is returned, so the bucket is not further in debt
+	if stats := l.Stats(); stats.Canceled != 1 || stats.Waiting != 0 {
+		t.Errorf("got stats %+v, expected 1 canceled event", stats)
+	}
+	if l.tokens < -0.01 {
+		t.Errorf("got %f tokens, expected the reserved token to be returned", l.tokens)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := NewLimiter(Every(0), 1)
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("got error: %s", err.Error())
+		}
+	}
+	if stats := l.Stats(); stats.Delayed != 0 {
+		t.Errorf("got %d delayed events, expected none", stats.Delayed)
+	}
+}
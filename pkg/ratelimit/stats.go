@@ -0,0 +1,28 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package ratelimit
+
+import "time"
+
+type Stats struct {
+	Allowed  uint64        // events which did not have to wait
+	Delayed  uint64        // events which waited for a token
+	Rejected uint64        // events which were not allowed by Allow
+	Canceled uint64        // events which stopped waiting because their context was done
+	Waiting  int           // events which are currently waiting
+	WaitTime time.Duration // total time spent waiting
+}
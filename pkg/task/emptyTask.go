@@ -16,10 +16,6 @@
 
 package task
 
-import (
-	"reflect"
-)
-
 type EmptyTask struct {
 	status Status
 }
@@ -33,7 +29,7 @@ func (t EmptyTask) Status() Status {
 }
 
 func (t EmptyTask) Type() string {
-	return reflect.TypeOf(t).String()
+	return "empty"
 }
 
 func (t EmptyTask) SetStatus(s Status) Task {
@@ -17,12 +17,15 @@
 package task
 
 const (
+	// MaxConcurrentTaskHandlerEmpty is the number of tasks executed concurrently by NewDefaultEmptyTaskHandler.
+	//
+	// Deprecated: NewDefaultEmptyTaskHandler uses DefaultMaxConcurrent, use NewEmptyTaskHandler for another limit.
 	MaxConcurrentTaskHandlerEmpty = 10000
 )
 
 func NewDefaultEmptyTaskHandler() EmptyTaskHandler {
 	return EmptyTaskHandler{
-		maxConcurrent: MaxConcurrentTaskHandlerEmpty,
+		maxConcurrent: DefaultMaxConcurrent,
 	}
 }
 
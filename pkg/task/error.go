@@ -0,0 +1,142 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+
+	"github.com/corelayer/go-scheduler/internal/errs"
+)
+
+// NewError returns an error of kind which wraps err, err may be nil
+func NewError(kind ErrorKind, err error) Error {
+	return Error{
+		base: errs.Error[ErrorKind]{Kind: kind, Cause: err},
+	}
+}
+
+// Error is returned by handlers and handler repositories. Errors are matched by kind using errors.Is, so
+// errors.Is(err, ErrHandlerMissing) reports whether err is a missing handler error, regardless of the task type.
+type Error struct {
+	Task string // the type of the task the error applies to, if any
+	base errs.Error[ErrorKind]
+}
+
+func (e Error) Error() string {
+	msg := e.base.Message()
+	if e.Task == "" {
+		return msg
+	}
+	return "task " + e.Task + ": " + msg
+}
+
+// Is reports whether target is an Error of the same kind
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.base.Kind == e.base.Kind
+}
+
+// Kind returns the kind of the error
+func (e Error) Kind() ErrorKind {
+	return e.base.Kind
+}
+
+// LogValue implements slog.LogValuer, the error is logged as a group with its kind, task and cause
+func (e Error) LogValue() slog.Value {
+	var subject []slog.Attr
+	if e.Task != "" {
+		subject = append(subject, slog.String("task", e.Task))
+	}
+	return e.base.LogValue(subject...)
+}
+
+func (e Error) Unwrap() error {
+	return e.base.Cause
+}
+
+// WithCause returns a copy of the error which wraps err
+func (e Error) WithCause(err error) Error {
+	e.base.Cause = err
+	return e
+}
+
+// WithTask returns a copy of the error which applies to tasks of type taskType
+func (e Error) WithTask(taskType string) Error {
+	e.Task = taskType
+	return e
+}
+
+// contextError converts an error of a done context into ErrTimeout or ErrCanceled, which wrap the context error
+func contextError(err error) Error {
+	if e, ok := errs.FromContext(err, ErrTimeout, ErrCanceled); ok {
+		return e
+	}
+	return ErrCanceled
+}
+
+type ErrorKind int
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrKindHandlerMissing:
+		return "handler missing"
+	case ErrKindHandlerExist:
+		return "handler exists"
+	case ErrKindTimeout:
+		return "timeout"
+	case ErrKindCanceled:
+		return "canceled"
+	case ErrKindQueueFull:
+		return "queue full"
+	case ErrKindQueueTimeout:
+		return "queue timeout"
+	case ErrKindTypeUnknown:
+		return "unknown type"
+	case ErrKindTypeExist:
+		return "type exists"
+	case ErrKindInvalidParams:
+		return "invalid params"
+	default:
+		return "kind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+const (
+	ErrKindHandlerMissing ErrorKind = iota
+	ErrKindHandlerExist
+	ErrKindTimeout
+	ErrKindCanceled
+	ErrKindQueueFull
+	ErrKindQueueTimeout
+	ErrKindTypeUnknown
+	ErrKindTypeExist
+	ErrKindInvalidParams
+)
+
+var (
+	ErrHandlerMissing = NewError(ErrKindHandlerMissing, nil)
+	ErrHandlerExist   = NewError(ErrKindHandlerExist, nil)
+	ErrTimeout        = NewError(ErrKindTimeout, context.DeadlineExceeded)
+	ErrCanceled       = NewError(ErrKindCanceled, context.Canceled)
+	ErrQueueFull      = NewError(ErrKindQueueFull, nil)
+	ErrQueueTimeout   = NewError(ErrKindQueueTimeout, nil)
+	ErrTypeUnknown    = NewError(ErrKindTypeUnknown, nil)
+	ErrTypeExist      = NewError(ErrKindTypeExist, nil)
+	ErrInvalidParams  = NewError(ErrKindInvalidParams, nil)
+)
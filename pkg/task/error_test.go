@@ -0,0 +1,58 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError_Error(t *testing.T) {
+	tests := []struct {
+		err    Error
+		wanted string
+	}{
+		{ErrHandlerMissing, "handler missing"},
+		{ErrHandlerMissing.WithTask("print"), "task print: handler missing"},
+		{ErrCanceled.WithTask("sleep"), "task sleep: canceled: context canceled"},
+		{NewError(ErrorKind(-1), nil), "kind(-1)"},
+	}
+
+	for _, test := range tests {
+		if msg := test.err.Error(); msg != test.wanted {
+			t.Errorf("got %q, expected %q", msg, test.wanted)
+		}
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("execute: %w", ErrTimeout.WithTask("sleep"))
+
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v is not %v", err, ErrTimeout)
+	}
+	if errors.Is(err, ErrCanceled) {
+		t.Errorf("%v is %v", err, ErrCanceled)
+	}
+
+	var e Error
+	if !errors.As(err, &e) || e.Task != "sleep" || e.Kind() != ErrKindTimeout {
+		t.Errorf("got %v, expected timeout for task sleep", e)
+	}
+}
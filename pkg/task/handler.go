@@ -16,6 +16,10 @@
 
 package task
 
+// DefaultMaxConcurrent is the number of tasks which the built-in handlers created by their NewDefault constructor
+// execute concurrently, their New constructor takes another limit
+const DefaultMaxConcurrent = 10000
+
 type Handler interface {
 	Execute(t Task, p chan *Pipeline) Task
 	MaxConcurrent() int
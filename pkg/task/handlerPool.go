@@ -17,22 +17,49 @@
 package task
 
 import (
+	"container/list"
+	"context"
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
+	"github.com/corelayer/go-scheduler/pkg/trace"
 )
 
 func NewHandlerPool(h Handler) *HandlerPool {
+	return NewHandlerPoolWithConfig(h, HandlerPoolConfig{})
+}
+
+func NewHandlerPoolWithConfig(h Handler, config HandlerPoolConfig) *HandlerPool {
+	if config.WaitBuckets == nil {
+		config.WaitBuckets = DefaultWaitBuckets
+	}
+
 	return &HandlerPool{
 		handler:         h,
+		config:          config,
 		concurrentMax:   h.MaxConcurrent(),
 		concurrentCount: 0,
+		queue:           list.New(),
+		executions:      make(map[Status]uint64),
+		waitTime:        NewHistogram(config.WaitBuckets),
 		mux:             &sync.Mutex{},
 	}
 }
 
+// HandlerPool limits the number of tasks which are executed concurrently by a handler.
+// Tasks which cannot be executed immediately wait in a queue and are executed in the order in which they arrived.
 type HandlerPool struct {
 	handler         Handler
+	config          HandlerPoolConfig
 	concurrentMax   int
 	concurrentCount int
+	queue           *list.List // waiting tasks, a free handler is passed on by closing the channel of the first element
+	executions      map[Status]uint64
+	rejected        uint64
+	timedOut        uint64
+	waitTime        Histogram
 	mux             *sync.Mutex
 }
 
@@ -50,23 +77,154 @@ func (p *HandlerPool) AvailableHandlers() int {
 	return p.concurrentMax - p.concurrentCount
 }
 
+// Execute runs t as soon as the rate limit allows it and a handler is available. If the queue is full or the task
+// waits longer than the queue timeout, the task fails with StatusError and an error wrapping ErrQueueFull or
+// ErrQueueTimeout is sent to the intercom of the pipeline. If the context of the pipeline is done while the task waits
+// for the rate limit or for a handler, the task fails with an error wrapping ErrCanceled or ErrTimeout.
+// The time spent waiting is traced as a child span of the span in the context of the pipeline.
 func (p *HandlerPool) Execute(t Task, pipeline chan *Pipeline) Task {
-	for {
-		p.mux.Lock()
-		if p.concurrentCount < p.concurrentMax {
-			p.concurrentCount++
-			p.mux.Unlock()
-			t = p.handler.Execute(t, pipeline)
-			break
-		}
-		p.mux.Unlock()
+	span, end := startSpan(pipeline, t, "handler_pool.wait", trace.String(trace.AttrTaskType, p.handler.Type()))
+	err := p.wait(pipeline)
+	if err == nil {
+		err = p.acquire(pipelineContext(pipeline))
 	}
-	p.mux.Lock()
-	p.concurrentCount--
-	p.mux.Unlock()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(trace.StatusError, err.Error())
+		end()
+		return fail(t, pipeline, err)
+	}
+	end()
+
+	t = p.handler.Execute(t, pipeline)
+	p.release(t.Status())
 	return t
 }
 
+func (p *HandlerPool) QueuedTasks() int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	return p.queue.Len()
+}
+
+func (p *HandlerPool) Stats() HandlerPoolStats {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	executions := make(map[Status]uint64, len(p.executions))
+	for s, n := range p.executions {
+		executions[s] = n
+	}
+	return HandlerPoolStats{
+		Type:          p.handler.Type(),
+		MaxConcurrent: p.concurrentMax,
+		Active:        p.concurrentCount,
+		Queued:        p.queue.Len(),
+		Executions:    executions,
+		Rejected:      p.rejected,
+		TimedOut:      p.timedOut,
+		WaitTime:      p.waitTime.clone(),
+		RateLimit:     p.rateLimitStats(),
+	}
+}
+
 func (p *HandlerPool) Type() string {
 	return p.handler.Type()
 }
+
+// acquire reserves a handler, it waits in the queue if all handlers are active. The task leaves the queue when it
+// times out or when ctx is done.
+func (p *HandlerPool) acquire(ctx context.Context) error {
+	p.mux.Lock()
+	if p.concurrentCount < p.concurrentMax && p.queue.Len() == 0 {
+		p.concurrentCount++
+		p.waitTime.Observe(0)
+		p.mux.Unlock()
+		return nil
+	}
+	if p.config.QueueLimit > 0 && p.queue.Len() >= p.config.QueueLimit {
+		p.rejected++
+		p.mux.Unlock()
+		return ErrQueueFull.WithTask(p.handler.Type()).WithCause(fmt.Errorf("%d tasks waiting", p.config.QueueLimit))
+	}
+
+	start := time.Now()
+	ready := make(chan struct{})
+	element := p.queue.PushBack(ready)
+	p.mux.Unlock()
+
+	var timeout <-chan time.Time
+	if p.config.QueueTimeout > 0 {
+		timer := time.NewTimer(p.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-ready:
+		p.observeWait(start)
+		return nil
+	case <-timeout:
+	case <-ctx.Done():
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.waitTime.Observe(time.Since(start))
+	select {
+	case <-ready:
+		// The handler was passed on while the timeout expired
+		return nil
+	default:
+	}
+	p.queue.Remove(element)
+	if err := ctx.Err(); err != nil {
+		return contextError(err).WithTask(p.handler.Type())
+	}
+	p.timedOut++
+	return ErrQueueTimeout.WithTask(p.handler.Type()).WithCause(fmt.Errorf("waited %s", p.config.QueueTimeout))
+}
+
+func (p *HandlerPool) observeWait(start time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.waitTime.Observe(time.Since(start))
+}
+
+// rateLimitStats returns the statistics of the rate limiter of the pool
+func (p *HandlerPool) rateLimitStats() ratelimit.Stats {
+	if p.config.RateLimit == nil {
+		return ratelimit.Stats{}
+	}
+	return p.config.RateLimit.Stats()
+}
+
+// wait blocks until the rate limit allows the next task, it stops waiting when the context of the pipeline is done
+func (p *HandlerPool) wait(pipeline chan *Pipeline) error {
+	if p.config.RateLimit == nil {
+		return nil
+	}
+
+	ctx := pipelineContext(pipeline)
+	if err := p.config.RateLimit.Wait(ctx); err != nil {
+		return contextError(err).WithTask(p.handler.Type())
+	}
+	return nil
+}
+
+// release frees a handler after a task finished with status s, the handler is passed on to the first waiting task
+func (p *HandlerPool) release(s Status) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.executions[s]++
+	if first := p.queue.Front(); first != nil {
+		p.queue.Remove(first)
+		close(first.Value.(chan struct{}))
+		return
+	}
+	p.concurrentCount--
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
+)
+
+type HandlerPoolConfig struct {
+	RateLimit    *ratelimit.Limiter // limits the rate at which tasks are executed, nil means unlimited
+	QueueLimit   int                // maximum number of tasks waiting for a free handler, 0 means unlimited
+	QueueTimeout time.Duration      // maximum time a task waits for a free handler, 0 means no timeout
+	WaitBuckets  []time.Duration
+}
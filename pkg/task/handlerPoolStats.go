@@ -0,0 +1,31 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import "github.com/corelayer/go-scheduler/pkg/ratelimit"
+
+type HandlerPoolStats struct {
+	Type          string
+	MaxConcurrent int
+	Active        int
+	Queued        int
+	Executions    map[Status]uint64 // executed tasks by resulting status
+	Rejected      uint64            // tasks which failed because the queue was full
+	TimedOut      uint64            // tasks which failed because they waited longer than the queue timeout
+	WaitTime      Histogram         // time spent waiting for a free handler, including tasks which timed out
+	RateLimit     ratelimit.Stats   // waiting for the rate limit of the pool, if any
+}
@@ -0,0 +1,269 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corelayer/go-scheduler/pkg/ratelimit"
+)
+
+// blockingHandler executes print tasks one at a time when they are released, it records the order of execution
+type blockingHandler struct {
+	release  chan struct{}
+	executed chan string
+}
+
+func (h blockingHandler) Execute(t Task, p chan *Pipeline) Task {
+	<-h.release
+	h.executed <- t.(PrintTask).Message
+	return t.SetStatus(StatusCompleted)
+}
+
+func (h blockingHandler) MaxConcurrent() int {
+	return 1
+}
+
+func (h blockingHandler) Type() string {
+	return PrintTask{}.Type()
+}
+
+func newBlockingPool(config HandlerPoolConfig) (*HandlerPool, blockingHandler) {
+	h := blockingHandler{release: make(chan struct{}), executed: make(chan string, 10)}
+	return NewHandlerPoolWithConfig(h, config), h
+}
+
+func newTestPipeline() (chan *Pipeline, *Intercom) {
+	intercom := NewIntercom("test", make(chan IntercomMessage, 10))
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Intercom: intercom, Data: make(map[string]interface{})}
+	return pipeline, intercom
+}
+
+func waitForQueued(t *testing.T, p *HandlerPool, queued int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.QueuedTasks() != queued {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d queued tasks, expected %d", p.QueuedTasks(), queued)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHandlerPool_ExecuteFIFO(t *testing.T) {
+	p, h := newBlockingPool(HandlerPoolConfig{})
+
+	var wg sync.WaitGroup
+	for i, message := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(message string) {
+			defer wg.Done()
+			pipeline, _ := newTestPipeline()
+			p.Execute(PrintTask{Message: message}, pipeline)
+		}(message)
+
+		// The first task is active, the others wait in the order in which they arrived
+		if i == 0 {
+			for p.ActiveHandlers() != 1 {
+				time.Sleep(time.Millisecond)
+			}
+		} else {
+			waitForQueued(t, p, i)
+		}
+	}
+
+	for _, wanted := range []string{"a", "b", "c", "d"} {
+		h.release <- struct{}{}
+		if message := <-h.executed; message != wanted {
+			t.Errorf("got task %s, expected %s", message, wanted)
+		}
+	}
+	wg.Wait()
+
+	if p.ActiveHandlers() != 0 || p.QueuedTasks() != 0 {
+		t.Errorf("got %d active and %d queued, expected none", p.ActiveHandlers(), p.QueuedTasks())
+	}
+}
+
+func TestHandlerPool_QueueLimit(t *testing.T) {
+	p, h := newBlockingPool(HandlerPoolConfig{QueueLimit: 1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pipeline, _ := newTestPipeline()
+			p.Execute(PrintTask{}, pipeline)
+		}()
+	}
+	waitForQueued(t, p, 1)
+
+	pipeline, intercom := newTestPipeline()
+	if result := p.Execute(PrintTask{}, pipeline); result.Status() != StatusError {
+		t.Errorf("got status %s, expected %s", result.Status(), StatusError)
+	}
+	if errs := intercom.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrQueueFull) {
+		t.Errorf("got errors %v, expected %v", errs, ErrQueueFull)
+	}
+
+	for i := 0; i < 2; i++ {
+		h.release <- struct{}{}
+	}
+	wg.Wait()
+
+	stats := p.Stats()
+	if stats.Rejected != 1 || stats.Executions[StatusCompleted] != 2 {
+		t.Errorf("got %d rejected and %d completed, expected 1 and 2", stats.Rejected, stats.Executions[StatusCompleted])
+	}
+}
+
+func TestHandlerPool_QueueTimeout(t *testing.T) {
+	p, h := newBlockingPool(HandlerPoolConfig{QueueTimeout: 20 * time.Millisecond})
+
+	done := make(chan struct{})
+	go func() {
+		pipeline, _ := newTestPipeline()
+		p.Execute(PrintTask{}, pipeline)
+		close(done)
+	}()
+	for p.ActiveHandlers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	pipeline, intercom := newTestPipeline()
+	if result := p.Execute(PrintTask{}, pipeline); result.Status() != StatusError {
+		t.Errorf("got status %s, expected %s", result.Status(), StatusError)
+	}
+	if errs := intercom.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrQueueTimeout) {
+		t.Errorf("got errors %v, expected %v", errs, ErrQueueTimeout)
+	}
+
+	h.release <- struct{}{}
+	<-done
+
+	stats := p.Stats()
+	if stats.TimedOut != 1 || stats.Queued != 0 || stats.Active != 0 {
+		t.Errorf("got %d timed out, %d queued and %d active, expected 1, 0 and 0", stats.TimedOut, stats.Queued, stats.Active)
+	}
+	if stats.WaitTime.Count != 2 || stats.WaitTime.Sum < 20*time.Millisecond {
+		t.Errorf("got %d wait times with sum %s, expected 2 with sum of at least 20ms", stats.WaitTime.Count, stats.WaitTime.Sum)
+	}
+}
+
+func TestHandlerPool_QueueCanceled(t *testing.T) {
+	p, h := newBlockingPool(HandlerPoolConfig{})
+
+	done := make(chan struct{})
+	go func() {
+		pipeline, _ := newTestPipeline()
+		p.Execute(PrintTask{}, pipeline)
+		close(done)
+	}()
+	for p.ActiveHandlers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queued task leaves the queue when the context of its pipeline is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	pipeline, intercom := newTestPipeline()
+	data := <-pipeline
+	data.Context = ctx
+	pipeline <- data
+	go func() {
+		waitForQueued(t, p, 1)
+		cancel()
+	}()
+	if result := p.Execute(PrintTask{}, pipeline); result.Status() != StatusError {
+		t.Errorf("got status %s, expected %s", result.Status(), StatusError)
+	}
+	if errs := intercom.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrCanceled) {
+		t.Errorf("got errors %v, expected %v", errs, ErrCanceled)
+	}
+	if queued := p.QueuedTasks(); queued != 0 {
+		t.Errorf("got %d queued tasks, expected the canceled task to leave the queue", queued)
+	}
+
+	h.release <- struct{}{}
+	<-done
+	if stats := p.Stats(); stats.Active != 0 || stats.TimedOut != 0 {
+		t.Errorf("got %d active and %d timed out, expected 0 and 0", stats.Active, stats.TimedOut)
+	}
+}
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]time.Duration{time.Millisecond, time.Second})
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Millisecond, time.Minute} {
+		h.Observe(d)
+	}
+
+	wanted := []uint64{2, 1, 1}
+	for i := range wanted {
+		if h.Counts[i] != wanted[i] {
+			t.Errorf("got count %d for bucket %d, expected %d", h.Counts[i], i, wanted[i])
+		}
+	}
+	if h.Count != 4 || h.Sum != time.Minute+3*time.Millisecond {
+		t.Errorf("got count %d and sum %s, expected 4 and %s", h.Count, h.Sum, time.Minute+3*time.Millisecond)
+	}
+}
+
+func TestDefaultMaxConcurrent(t *testing.T) {
+	if n := NewDefaultSleepTaskHandler().MaxConcurrent(); n != DefaultMaxConcurrent {
+		t.Errorf("got %d, expected %d", n, DefaultMaxConcurrent)
+	}
+	if n := NewSleepTaskHandler(3).MaxConcurrent(); n != 3 {
+		t.Errorf("got %d, expected 3", n)
+	}
+}
+
+func TestHandlerPool_RateLimit(t *testing.T) {
+	p := NewHandlerPoolWithConfig(NewDefaultEmptyTaskHandler(), HandlerPoolConfig{RateLimit: ratelimit.NewLimiter(ratelimit.Every(20*time.Millisecond), 1)})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		pipeline, _ := newTestPipeline()
+		if result := p.Execute(EmptyTask{}, pipeline); result.Status() != StatusCompleted {
+			t.Fatalf("got status %s, expected %s", result.Status(), StatusCompleted)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("3 tasks with burst 1 took %s, expected at least 40ms", elapsed)
+	}
+	if stats := p.Stats(); stats.RateLimit.Delayed != 2 {
+		t.Errorf("got %d delayed tasks, expected 2", stats.RateLimit.Delayed)
+	}
+
+	// A task waiting for the rate limit fails when the context of its pipeline is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	intercom := NewIntercom("test", make(chan IntercomMessage, 10))
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Context: ctx, Intercom: intercom, Data: make(map[string]interface{})}
+	if result := p.Execute(EmptyTask{}, pipeline); result.Status() != StatusError {
+		t.Errorf("got status %s, expected %s", result.Status(), StatusError)
+	}
+	if errs := intercom.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], ErrCanceled) {
+		t.Errorf("got errors %v, expected %v", errs, ErrCanceled)
+	}
+}
@@ -17,8 +17,11 @@
 package task
 
 import (
-	"fmt"
+	"errors"
+	"sort"
 	"sync"
+
+	"github.com/corelayer/go-scheduler/pkg/trace"
 )
 
 func NewHandlerRepository() *HandlerRepository {
@@ -33,38 +36,55 @@ type HandlerRepository struct {
 	mux         *sync.Mutex
 }
 
+// Execute runs t with the handler registered for its type. If no handler is registered, the task fails with
+// StatusError and an error message wrapping ErrHandlerMissing is sent to the intercom of the pipeline.
+// The execution is traced as a child span of the span in the context of the pipeline.
 func (r *HandlerRepository) Execute(t Task, pipeline chan *Pipeline) Task {
+	span, end := startSpan(pipeline, t, "task.execute", trace.String(trace.AttrTaskType, t.Type()))
+	defer end()
+	defer scopeLogger(pipeline, t)()
+
 	r.mux.Lock()
 	handler, found := r.handlerPool[t.Type()]
 	r.mux.Unlock()
 
 	if !found {
-		// If no handler is available, the program cannot continue
-		panic(fmt.Sprintf("could not find handlerpool for task %s", t.Type()))
+		err := ErrHandlerMissing.WithTask(t.Type())
+		span.RecordError(err)
+		t = fail(t, pipeline, err)
+	} else {
+		t = handler.Execute(t, pipeline)
+	}
+
+	span.SetAttributes(trace.String(trace.AttrTaskStatus, t.Status().String()))
+	switch t.Status() {
+	case StatusError, StatusCanceled:
+		span.SetStatus(trace.StatusError, "task "+t.Status().String())
+	default:
+		span.SetStatus(trace.StatusOK, "")
 	}
-	return handler.Execute(t, pipeline)
+	return t
 }
 
+// HandlerNames returns the sorted task types for which a handler is registered
 func (r *HandlerRepository) HandlerNames() []string {
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
-	keys := make([]string, len(r.handlerPool))
+	keys := make([]string, 0, len(r.handlerPool))
 	for k := range r.handlerPool {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
 func (r *HandlerRepository) IsRegistered(handler string) bool {
-	handlers := r.HandlerNames()
+	r.mux.Lock()
+	defer r.mux.Unlock()
 
-	for _, h := range handlers {
-		if h == handler {
-			return true
-		}
-	}
-	return false
+	_, found := r.handlerPool[handler]
+	return found
 }
 
 func (r *HandlerRepository) RegisterHandlerPool(p *HandlerPool) error {
@@ -76,7 +96,7 @@ func (r *HandlerRepository) RegisterHandlerPool(p *HandlerPool) error {
 		r.handlerPool[p.Type()] = p
 		return nil
 	}
-	return fmt.Errorf("handlerpool %s is already registered", p.Type())
+	return ErrHandlerExist.WithTask(p.Type())
 }
 
 func (r *HandlerRepository) RegisterHandlerPools(pools []*HandlerPool) error {
@@ -89,3 +109,42 @@ func (r *HandlerRepository) RegisterHandlerPools(pools []*HandlerPool) error {
 	}
 	return nil
 }
+
+// Stats returns the statistics of all handler pools, sorted by task type
+func (r *HandlerRepository) Stats() []HandlerPoolStats {
+	r.mux.Lock()
+	pools := make([]*HandlerPool, 0, len(r.handlerPool))
+	for _, p := range r.handlerPool {
+		pools = append(pools, p)
+	}
+	r.mux.Unlock()
+
+	stats := make([]HandlerPoolStats, len(pools))
+	for i, p := range pools {
+		stats[i] = p.Stats()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Type < stats[j].Type
+	})
+	return stats
+}
+
+// Validate checks whether a handler is registered for the type of every task, it returns an error wrapping
+// ErrHandlerMissing for every type without a handler
+func (r *HandlerRepository) Validate(tasks []Task) error {
+	var (
+		errs    []error
+		checked = make(map[string]bool)
+	)
+	for _, t := range tasks {
+		if checked[t.Type()] {
+			continue
+		}
+		checked[t.Type()] = true
+
+		if !r.IsRegistered(t.Type()) {
+			errs = append(errs, ErrHandlerMissing.WithTask(t.Type()))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/corelayer/go-scheduler/pkg/trace"
+)
+
+func TestHandlerRepository_RegisterHandlerPool(t *testing.T) {
+	r := NewHandlerRepository()
+	if err := r.RegisterHandlerPool(NewHandlerPool(NewDefaultEmptyTaskHandler())); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	err := r.RegisterHandlerPool(NewHandlerPool(NewDefaultEmptyTaskHandler()))
+	if !errors.Is(err, ErrHandlerExist) {
+		t.Errorf("got %v, expected %v", err, ErrHandlerExist)
+	}
+}
+
+func TestHandlerRepository_ExecuteMissingHandler(t *testing.T) {
+	r := NewHandlerRepository()
+	messages := make(chan IntercomMessage, 1)
+	intercom := NewIntercom("test", messages)
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Intercom: intercom, Data: make(map[string]interface{})}
+
+	result := r.Execute(PrintTask{Message: "test"}, pipeline)
+	if result.Status() != StatusError {
+		t.Errorf("got status %s, expected %s", result.Status(), StatusError)
+	}
+	if len(pipeline) != 1 {
+		t.Errorf("pipeline was not handed to the next task")
+	}
+
+	errs := intercom.GetErrors()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrHandlerMissing) {
+		t.Errorf("got errors %v, expected %v", errs, ErrHandlerMissing)
+	}
+}
+
+// spanHandler executes print tasks and records the span context the task finds in the pipeline
+type spanHandler struct {
+	spans chan trace.SpanContext
+}
+
+func (h spanHandler) Execute(t Task, p chan *Pipeline) Task {
+	pipeline := <-p
+	h.spans <- trace.SpanFromContext(pipeline.Context).SpanContext()
+	p <- pipeline
+	return t.SetStatus(StatusCompleted)
+}
+
+func (h spanHandler) MaxConcurrent() int {
+	return 1
+}
+
+func (h spanHandler) Type() string {
+	return PrintTask{}.Type()
+}
+
+func TestHandlerRepository_ExecuteTrace(t *testing.T) {
+	h := spanHandler{spans: make(chan trace.SpanContext, 1)}
+	r := NewHandlerRepository()
+	if err := r.RegisterHandlerPool(NewHandlerPool(h)); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := trace.NewRecorder()
+	ctx, root := recorder.Start(context.Background(), "root")
+	pipeline, _ := newTestPipeline()
+	p := <-pipeline
+	p.Context = ctx
+	pipeline <- p
+
+	// The pipeline is shared with another goroutine while the tasks execute, which only uses it through the channel
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case p := <-pipeline:
+				_ = p.Context
+				pipeline <- p
+			}
+		}
+	}()
+	r.Execute(PrintTask{Message: "test"}, pipeline)
+	r.Execute(EmptyTask{}, pipeline)
+	close(stop)
+	<-done
+	root.End()
+
+	if p.Context != ctx {
+		t.Errorf("context of the pipeline was not restored")
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("got %d spans, expected 4", len(spans))
+	}
+	wait, executed, missing := spans[0], spans[1], spans[2]
+	if wait.Name != "handler_pool.wait" || wait.Parent != executed.SpanContext {
+		t.Errorf("got span %s with parent %s, expected the wait span as a child of the task", wait.Name, wait.Parent.SpanId)
+	}
+	if executed.Name != "task.execute" || executed.Parent != root.SpanContext() || executed.Status != trace.StatusOK {
+		t.Errorf("got span %s with status %s, expected a successful task span as a child of root", executed.Name, executed.Status)
+	}
+	if executed.Attributes[trace.AttrTaskType] != (PrintTask{}).Type() || executed.Attributes[trace.AttrTaskStatus] != StatusCompleted.String() {
+		t.Errorf("got attributes %v", executed.Attributes)
+	}
+	if sc := <-h.spans; sc != executed.SpanContext {
+		t.Errorf("handler found span %s in the pipeline, expected the task span %s", sc.SpanId, executed.SpanContext.SpanId)
+	}
+	if missing.Status != trace.StatusError || len(missing.Errors) != 1 || !errors.Is(missing.Errors[0], ErrHandlerMissing) {
+		t.Errorf("got status %s with errors %v, expected a failed span for a missing handler", missing.Status, missing.Errors)
+	}
+}
+
+func TestHandlerRepository_Validate(t *testing.T) {
+	r := NewHandlerRepository()
+	if err := r.RegisterHandlerPool(NewHandlerPool(NewDefaultEmptyTaskHandler())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Validate([]Task{EmptyTask{}, EmptyTask{}}); err != nil {
+		t.Errorf("got error: %s", err.Error())
+	}
+
+	err := r.Validate([]Task{EmptyTask{}, PrintTask{}, SleepTask{}, PrintTask{}})
+	if !errors.Is(err, ErrHandlerMissing) {
+		t.Fatalf("got %v, expected %v", err, ErrHandlerMissing)
+	}
+	if wanted := "task print: handler missing\ntask sleep: handler missing"; err.Error() != wanted {
+		t.Errorf("got %q, expected %q", err.Error(), wanted)
+	}
+}
+
+func TestHandlerRepository_HandlerNames(t *testing.T) {
+	r := NewHandlerRepository()
+	err := r.RegisterHandlerPools([]*HandlerPool{
+		NewHandlerPool(NewDefaultSleepTaskHandler()),
+		NewHandlerPool(NewDefaultEmptyTaskHandler()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := r.HandlerNames()
+	if len(names) != 2 || names[0] != "empty" || names[1] != "sleep" {
+		t.Errorf("got %v, expected [empty sleep]", names)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import "time"
+
+// DefaultWaitBuckets are the upper bounds of the buckets of the wait time histogram of a handler pool
+var DefaultWaitBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+func NewHistogram(bounds []time.Duration) Histogram {
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Histogram counts durations in buckets. Counts[i] is the number of durations greater than Bounds[i-1] and less than
+// or equal to Bounds[i], the last count is the number of durations greater than the last bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+// Observe adds d to the histogram
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// clone returns a copy of the histogram which does not share its counts with the original
+func (h *Histogram) clone() Histogram {
+	c := *h
+	c.Counts = make([]uint64, len(h.Counts))
+	copy(c.Counts, h.Counts)
+	return c
+}
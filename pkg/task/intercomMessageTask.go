@@ -16,10 +16,6 @@
 
 package task
 
-import (
-	"reflect"
-)
-
 type IntercomMessageTask struct {
 	Message string
 	status  Status
@@ -34,7 +30,7 @@ func (t IntercomMessageTask) Status() Status {
 }
 
 func (t IntercomMessageTask) Type() string {
-	return reflect.TypeOf(t).String()
+	return "intercom"
 }
 
 func (t IntercomMessageTask) SetStatus(s Status) Task {
@@ -16,13 +16,18 @@
 
 package task
 
+import "log/slog"
+
 const (
+	// MaxConcurrentTaskHandlerIntercomMessage is the number of tasks executed concurrently by NewDefaultIntercomMessageTaskHandler.
+	//
+	// Deprecated: NewDefaultIntercomMessageTaskHandler uses DefaultMaxConcurrent, use NewIntercomMessageTaskHandler for another limit.
 	MaxConcurrentTaskHandlerIntercomMessage = 10000
 )
 
 func NewDefaultIntercomMessageTaskHandler() IntercomMessageTaskHandler {
 	return IntercomMessageTaskHandler{
-		maxConcurrent: MaxConcurrentTaskHandlerIntercomMessage,
+		maxConcurrent: DefaultMaxConcurrent,
 	}
 }
 
@@ -51,7 +56,7 @@ func (h IntercomMessageTaskHandler) Execute(t Task, p chan *Pipeline) Task {
 		Task:    task.Type(),
 		Type:    LogMessage,
 		Data:    nil,
-	}, nil)
+	}, &LogData{Level: slog.LevelInfo, Attrs: []slog.Attr{LogTaskAttr(task)}})
 
 	if t.WriteToPipeline() {
 		p <- pipeline
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewIntercomHandler returns a slog.Handler which sends log records as messages to intercom. Records below level are
+// discarded, a nil level defaults to slog.LevelInfo.
+func NewIntercomHandler(intercom *Intercom, level slog.Leveler) *IntercomHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &IntercomHandler{
+		intercom: intercom,
+		level:    level,
+	}
+}
+
+// IntercomHandler bridges slog to the intercom of a job. Every record is added as a LogMessage, the level and
+// attributes of the record are sent along as LogData so the message handler of the orchestrator can forward them to
+// a structured logger, see NewSlogMessageHandler.
+type IntercomHandler struct {
+	intercom *Intercom
+	level    slog.Leveler
+	task     string
+	attrs    []slog.Attr
+	groups   []string
+}
+
+func (h *IntercomHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *IntercomHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	h.intercom.Add(Message{
+		Message: r.Message,
+		Task:    h.task,
+		Type:    LogMessage,
+	}, &LogData{
+		Level: r.Level,
+		Attrs: append(append([]slog.Attr{}, h.attrs...), h.group(attrs)...),
+	})
+	return nil
+}
+
+func (h *IntercomHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), h.group(attrs)...)
+	return &c
+}
+
+func (h *IntercomHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups = append(append([]string{}, h.groups...), name)
+	return &c
+}
+
+// group nests attrs in the open groups of the handler
+func (h *IntercomHandler) group(attrs []slog.Attr) []slog.Attr {
+	if len(attrs) == 0 {
+		return nil
+	}
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		values := make([]any, len(attrs))
+		for j, a := range attrs {
+			values[j] = a
+		}
+		attrs = []slog.Attr{slog.Group(h.groups[i], values...)}
+	}
+	return attrs
+}
+
+// withTask returns a copy of the handler which marks its messages as sent by tasks of type taskType
+func (h *IntercomHandler) withTask(taskType string) *IntercomHandler {
+	c := *h
+	c.task = taskType
+	return &c
+}
+
+// NewSlogMessageHandler returns a message handler for the orchestrator which writes intercom messages to logger.
+// Messages carrying LogData are logged at their level with their attributes, other messages are logged at
+// slog.LevelError if they are errors and slog.LevelInfo otherwise.
+func NewSlogMessageHandler(logger *slog.Logger) func(IntercomMessage) {
+	return func(m IntercomMessage) {
+		level := slog.LevelInfo
+		var attrs []slog.Attr
+		switch {
+		case m.Log != nil:
+			level = m.Log.Level
+			attrs = m.Log.Attrs
+		case m.Content.Type == ErrorMessage:
+			level = slog.LevelError
+		}
+
+		if !hasAttr(attrs, "job") {
+			attrs = append([]slog.Attr{slog.Group("job", slog.String("name", m.Name))}, attrs...)
+		}
+		if m.Content.Task != "" && !hasAttr(attrs, "task") {
+			attrs = append(attrs, slog.Group("task", slog.String("type", m.Content.Task)))
+		}
+		if err, ok := m.Content.Data.(error); ok && !hasAttr(attrs, "error") {
+			attrs = append(attrs, LogErrAttr(err))
+		}
+		logger.LogAttrs(context.Background(), level, m.Content.Message, attrs...)
+	}
+}
+
+func hasAttr(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeLogger makes the logger of the pipeline a logger scoped to t while t executes. If the pipeline has no logger,
+// it logs to the intercom of the pipeline. The returned function restores the logger of the pipeline if t handed the
+// pipeline back, see restorePipeline.
+func scopeLogger(pipeline chan *Pipeline, t Task) func() {
+	p := <-pipeline
+	parent := p.Logger
+
+	logger := parent
+	if logger == nil && p.Intercom != nil {
+		logger = slog.New(NewIntercomHandler(p.Intercom, nil))
+	}
+	if logger != nil {
+		if h, ok := logger.Handler().(*IntercomHandler); ok {
+			logger = slog.New(h.withTask(t.Type()))
+		}
+		p.Logger = logger.With(slog.Group("task", slog.String("name", t.Name()), slog.String("type", t.Type())))
+	}
+	pipeline <- p
+
+	return func() {
+		restorePipeline(pipeline, t, func(p *Pipeline) {
+			p.Logger = parent
+		})
+	}
+}
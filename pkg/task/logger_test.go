@@ -0,0 +1,203 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// logHandler executes print tasks by logging their message with the logger of the pipeline
+type logHandler struct{}
+
+func (h logHandler) Execute(t Task, p chan *Pipeline) Task {
+	pipeline := <-p
+	pipeline.Logger.Info(t.(PrintTask).Message, slog.Int("count", 1))
+	p <- pipeline
+	return t.SetStatus(StatusCompleted)
+}
+
+func (h logHandler) MaxConcurrent() int {
+	return 1
+}
+
+func (h logHandler) Type() string {
+	return PrintTask{}.Type()
+}
+
+// keepTask is a task which does not hand the pipeline back to the next task
+type keepTask struct {
+	EmptyTask
+}
+
+func (t keepTask) SetStatus(s Status) Task {
+	t.status = s
+	return t
+}
+
+func (t keepTask) Type() string {
+	return "keep"
+}
+
+func (t keepTask) WriteToPipeline() bool {
+	return false
+}
+
+// keepHandler executes keep tasks by taking the pipeline from the channel
+type keepHandler struct {
+	kept chan *Pipeline
+}
+
+func (h keepHandler) Execute(t Task, p chan *Pipeline) Task {
+	h.kept <- <-p
+	return t.SetStatus(StatusCompleted)
+}
+
+func (h keepHandler) MaxConcurrent() int {
+	return 1
+}
+
+func (h keepHandler) Type() string {
+	return keepTask{}.Type()
+}
+
+func TestIntercomHandler(t *testing.T) {
+	messages := make(chan IntercomMessage, 10)
+	intercom := NewIntercom("test", messages)
+	logger := slog.New(NewIntercomHandler(intercom, slog.LevelInfo)).With("run", 1).WithGroup("g").With("a", 1)
+
+	logger.Debug("discarded")
+	logger.Warn("warning", "b", 2)
+
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, expected 1", len(messages))
+	}
+	m := <-messages
+	if m.Name != "test" || m.Content.Message != "warning" || m.Content.Type != LogMessage || m.Log == nil || m.Log.Level != slog.LevelWarn {
+		t.Fatalf("got message %+v, expected a warning", m)
+	}
+
+	// Attributes added in a group are nested in the group
+	wanted := []string{"run=1", "g=[a=1]", "g=[b=2]"}
+	if len(m.Log.Attrs) != len(wanted) {
+		t.Fatalf("got attributes %v, expected %v", m.Log.Attrs, wanted)
+	}
+	for i := range wanted {
+		if got := m.Log.Attrs[i].String(); got != wanted[i] {
+			t.Errorf("got attribute %s, expected %s", got, wanted[i])
+		}
+	}
+}
+
+func TestHandlerRepository_ExecuteLogger(t *testing.T) {
+	r := NewHandlerRepository()
+	if err := r.RegisterHandlerPool(NewHandlerPool(logHandler{})); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	messages := make(chan IntercomMessage, 10)
+	intercom := NewIntercom("job", messages)
+	logger := slog.New(NewIntercomHandler(intercom, nil)).With("run", 3)
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Intercom: intercom, Logger: logger, Data: make(map[string]interface{})}
+
+	// The pipeline is shared with another goroutine while the task executes, which only uses it through the channel
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case p := <-pipeline:
+				_ = p.Logger
+				pipeline <- p
+			}
+		}
+	}()
+	r.Execute(PrintTask{Message: "hello"}, pipeline)
+	close(stop)
+	<-done
+
+	if p := <-pipeline; p.Logger != logger {
+		t.Errorf("the logger of the pipeline was not restored")
+	}
+
+	// The message is forwarded to a structured logger with the attributes of the run and the task
+	var out bytes.Buffer
+	NewSlogMessageHandler(slog.New(slog.NewJSONHandler(&out, nil)))(<-messages)
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	attrs, _ := line["task"].(map[string]any)
+	job, _ := line["job"].(map[string]any)
+	if line["msg"] != "hello" || line["level"] != "INFO" || line["run"] != float64(3) || line["count"] != float64(1) ||
+		attrs["type"] != (PrintTask{}).Type() || job["name"] != "job" {
+		t.Errorf("got %v, expected the message with the attributes of the run and the task", line)
+	}
+}
+
+func TestHandlerRepository_ExecuteKeepPipeline(t *testing.T) {
+	h := keepHandler{kept: make(chan *Pipeline, 1)}
+	r := NewHandlerRepository()
+	if err := r.RegisterHandlerPool(NewHandlerPool(h)); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Intercom: NewIntercom("job", make(chan IntercomMessage, 10)), Data: make(map[string]interface{})}
+
+	done := make(chan Task)
+	go func() {
+		done <- r.Execute(keepTask{}, pipeline)
+	}()
+	select {
+	case task := <-done:
+		if task.Status() != StatusCompleted {
+			t.Errorf("got status %s, expected %s", task.Status(), StatusCompleted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("execute did not return for a task which keeps the pipeline")
+	}
+
+	if len(pipeline) != 0 || len(h.kept) != 1 {
+		t.Errorf("the pipeline was handed back to the channel, expected the task to keep it")
+	}
+}
+
+func TestNewSlogMessageHandler_Error(t *testing.T) {
+	messages := make(chan IntercomMessage, 10)
+	pipeline := make(chan *Pipeline, 1)
+	pipeline <- &Pipeline{Intercom: NewIntercom("job", messages), Data: make(map[string]interface{})}
+
+	NewHandlerRepository().Execute(PrintTask{}, pipeline)
+
+	var out bytes.Buffer
+	NewSlogMessageHandler(slog.New(slog.NewJSONHandler(&out, nil)))(<-messages)
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("got error: %s", err.Error())
+	}
+	if line["level"] != "ERROR" || line["error"] == nil || line["task"] == nil {
+		t.Errorf("got %v, expected an error with the task and the error", line)
+	}
+}
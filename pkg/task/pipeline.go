@@ -16,7 +16,68 @@
 
 package task
 
+import (
+	"context"
+	"log/slog"
+
+	"github.com/corelayer/go-scheduler/pkg/trace"
+)
+
 type Pipeline struct {
+	// Context is canceled when the run of the job is aborted, nil is treated as context.Background(). While a task
+	// executes, it holds the span of the task, see trace.SpanFromContext.
+	Context  context.Context
 	Intercom *Intercom
-	Data     map[string]interface{}
+	// Logger is scoped to the job run, while a task executes it is scoped to the task as well. Records are sent to
+	// the intercom unless the orchestrator is configured otherwise, see IntercomHandler. It may be nil outside a
+	// HandlerRepository.
+	Logger *slog.Logger
+	Data   map[string]interface{}
+}
+
+// pipelineContext returns the context of the pipeline without taking the pipeline from the next task
+func pipelineContext(pipeline chan *Pipeline) context.Context {
+	p := <-pipeline
+	pipeline <- p
+	if p.Context == nil {
+		return context.Background()
+	}
+	return p.Context
+}
+
+// restorePipeline calls restore with the pipeline once t handed it back. A task which does not write to the pipeline
+// keeps it, restore is only called if the pipeline is in the channel then, for instance when t failed before its
+// handler ran.
+func restorePipeline(pipeline chan *Pipeline, t Task, restore func(p *Pipeline)) {
+	if t.WriteToPipeline() {
+		p := <-pipeline
+		restore(p)
+		pipeline <- p
+		return
+	}
+
+	select {
+	case p := <-pipeline:
+		restore(p)
+		pipeline <- p
+	default:
+	}
+}
+
+// startSpan starts a span as a child of the span in the context of the pipeline and makes it the span of the pipeline.
+// The returned function ends the span and restores the context of the pipeline if t handed the pipeline back, see
+// restorePipeline.
+func startSpan(pipeline chan *Pipeline, t Task, name string, attributes ...trace.Attribute) (trace.Span, func()) {
+	p := <-pipeline
+	parent := p.Context
+	ctx, span := trace.Start(parent, name, attributes...)
+	p.Context = ctx
+	pipeline <- p
+
+	return span, func() {
+		span.End()
+		restorePipeline(pipeline, t, func(p *Pipeline) {
+			p.Context = parent
+		})
+	}
 }
@@ -16,10 +16,6 @@
 
 package task
 
-import (
-	"reflect"
-)
-
 type PrintTask struct {
 	Message string
 	status  Status
@@ -34,7 +30,7 @@ func (t PrintTask) Status() Status {
 }
 
 func (t PrintTask) Type() string {
-	return reflect.TypeOf(t).String()
+	return "print"
 }
 
 func (t PrintTask) SetStatus(s Status) Task {
@@ -21,12 +21,15 @@ import (
 )
 
 const (
+	// MaxConcurrentTaskHandlerPrint is the number of tasks executed concurrently by NewDefaultPrintTaskHandler.
+	//
+	// Deprecated: NewDefaultPrintTaskHandler uses DefaultMaxConcurrent, use NewPrintTaskHandler for another limit.
 	MaxConcurrentTaskHandlerPrint = 10000
 )
 
 func NewDefaultPrintTaskHandler() PrintTaskHandler {
 	return PrintTaskHandler{
-		maxConcurrent: MaxConcurrentTaskHandlerPrint,
+		maxConcurrent: DefaultMaxConcurrent,
 	}
 }
 
@@ -0,0 +1,244 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// DefaultRegistry holds the built-in task types, it is used by catalogs which persist jobs and by job definitions.
+// Register custom task types with DefaultRegistry to store them or to use them in job definitions.
+var DefaultRegistry = NewDefaultRegistry()
+
+// Factory returns a new task of a registered type, with its parameters set to their defaults
+type Factory func() Task
+
+// Codec converts the parameters of the tasks of a type to and from JSON. The status of a task is not part of its
+// parameters, it is encoded by the Registry.
+type Codec interface {
+	// Encode returns the parameters of t
+	Encode(t Task) (json.RawMessage, error)
+	// Decode returns t with the parameters in params applied to it
+	Decode(t Task, params json.RawMessage) (Task, error)
+}
+
+// JSONCodec encodes the exported fields of a task with encoding/json. Names of parameters match the exported fields
+// regardless of case, unknown parameters are rejected.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(t Task) (json.RawMessage, error) {
+	return json.Marshal(t)
+}
+
+func (JSONCodec) Decode(t Task, params json.RawMessage) (Task, error) {
+	v := reflect.New(reflect.TypeOf(t))
+	v.Elem().Set(reflect.ValueOf(t))
+
+	d := json.NewDecoder(bytes.NewReader(params))
+	d.DisallowUnknownFields()
+	if err := d.Decode(v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface().(Task), nil
+}
+
+// EncodedTask is the JSON representation of a task produced by a Registry
+type EncodedTask struct {
+	Type   string          `json:"type"`
+	Status Status          `json:"status,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		types:   make(map[string]registration),
+		aliases: make(map[string]string),
+		mux:     &sync.Mutex{},
+	}
+}
+
+// NewDefaultRegistry returns a registry with the built-in task types: empty, intercom, print, sleep and timelog.
+// The Go type names which identified the built-in types before, such as task.SleepTask, are registered as aliases.
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	for alias, factory := range map[string]Factory{
+		"task.EmptyTask":           func() Task { return EmptyTask{} },
+		"task.IntercomMessageTask": func() Task { return IntercomMessageTask{} },
+		"task.PrintTask":           func() Task { return PrintTask{} },
+		"task.SleepTask":           func() Task { return SleepTask{} },
+		"task.TimeLogTask":         func() Task { return TimeLogTask{} },
+	} {
+		name := factory().Type()
+		_ = r.Register(name, factory, JSONCodec{})
+		_ = r.Alias(alias, name)
+	}
+	return r
+}
+
+// Registry maps the stable names of task types to the factories and codecs of the types, so tasks can be created from
+// configuration and stored. A task type is registered under the name returned by the Type method of its tasks.
+type Registry struct {
+	types   map[string]registration
+	aliases map[string]string // the registered name of every alias
+	mux     *sync.Mutex
+}
+
+type registration struct {
+	factory Factory
+	codec   Codec
+}
+
+// Register adds a task type under name, JSONCodec is used if codec is nil. It returns ErrTypeExist if name is
+// already registered, and an error if the tasks of factory do not have name as type.
+func (r *Registry) Register(name string, factory Factory, codec Codec) error {
+	if taskType := factory().Type(); taskType != name {
+		return fmt.Errorf("tasks of type %s cannot be registered as %s", taskType, name)
+	}
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.isRegistered(name) {
+		return ErrTypeExist.WithTask(name)
+	}
+	r.types[name] = registration{factory: factory, codec: codec}
+	return nil
+}
+
+// Alias makes a registered task type known by another name, such as a name under which tasks were stored before.
+// Tasks decoded by an alias have the registered type.
+func (r *Registry) Alias(alias string, name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, found := r.types[name]; !found {
+		return ErrTypeUnknown.WithTask(name)
+	}
+	if r.isRegistered(alias) {
+		return ErrTypeExist.WithTask(alias)
+	}
+	r.aliases[alias] = name
+	return nil
+}
+
+// IsRegistered reports whether name is a registered task type or an alias
+func (r *Registry) IsRegistered(name string) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.isRegistered(name)
+}
+
+func (r *Registry) isRegistered(name string) bool {
+	_, found := r.types[name]
+	_, isAlias := r.aliases[name]
+	return found || isAlias
+}
+
+// Names returns the sorted names of the registered task types, aliases are not included
+func (r *Registry) Names() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	names := make([]string, 0, len(r.types))
+	for name := range r.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns a new task of the type registered as name
+func (r *Registry) New(name string) (Task, error) {
+	reg, err := r.registration(name)
+	if err != nil {
+		return nil, err
+	}
+	return reg.factory(), nil
+}
+
+// Encode returns the type, the status and the parameters of t
+func (r *Registry) Encode(t Task) (EncodedTask, error) {
+	reg, err := r.registration(t.Type())
+	if err != nil {
+		return EncodedTask{}, err
+	}
+	params, err := reg.codec.Encode(t)
+	if err != nil {
+		return EncodedTask{}, ErrInvalidParams.WithTask(t.Type()).WithCause(err)
+	}
+	if bytes.Equal(params, []byte("{}")) {
+		params = nil
+	}
+	return EncodedTask{Type: t.Type(), Status: t.Status(), Params: params}, nil
+}
+
+// Decode returns the task encoded by e, parameters which are not set keep the defaults of the factory of the type
+func (r *Registry) Decode(e EncodedTask) (Task, error) {
+	reg, err := r.registration(e.Type)
+	if err != nil {
+		return nil, err
+	}
+	t := reg.factory()
+	if len(e.Params) > 0 && !bytes.Equal(e.Params, []byte("null")) {
+		if t, err = reg.codec.Decode(t, e.Params); err != nil {
+			return nil, ErrInvalidParams.WithTask(e.Type).WithCause(err)
+		}
+	}
+	return t.SetStatus(e.Status), nil
+}
+
+// Marshal returns the JSON encoding of t as an EncodedTask
+func (r *Registry) Marshal(t Task) ([]byte, error) {
+	e, err := r.Encode(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(e)
+}
+
+// Unmarshal returns the task of which data is the JSON encoding as an EncodedTask
+func (r *Registry) Unmarshal(data []byte) (Task, error) {
+	var e EncodedTask
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return r.Decode(e)
+}
+
+// registration returns the registration of the type registered as name or under the alias name
+func (r *Registry) registration(name string) (registration, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if alias, found := r.aliases[name]; found {
+		name = alias
+	}
+	reg, found := r.types[name]
+	if !found {
+		return registration{}, ErrTypeUnknown.WithTask(name)
+	}
+	return reg, nil
+}
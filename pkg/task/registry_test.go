@@ -0,0 +1,142 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Marshal(t *testing.T) {
+	r := NewDefaultRegistry()
+	tasks := []Task{
+		EmptyTask{}.SetStatus(StatusCompleted),
+		IntercomMessageTask{Message: "hello"},
+		PrintTask{Message: "hello"}.SetStatus(StatusError),
+		SleepTask{Milliseconds: 250}.SetStatus(StatusPending),
+		TimeLogTask{Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}.SetStatus(StatusCanceled),
+	}
+
+	for _, task := range tasks {
+		t.Run(task.Type(), func(t *testing.T) {
+			data, err := r.Marshal(task)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := r.Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result, task) {
+				t.Errorf("got %#v from %s, expected %#v", result, data, task)
+			}
+		})
+	}
+
+	data, _ := r.Marshal(SleepTask{Milliseconds: 250}.SetStatus(StatusCompleted))
+	if wanted := `{"type":"sleep","status":"completed","params":{"Milliseconds":250}}`; string(data) != wanted {
+		t.Errorf("got %s, expected %s", data, wanted)
+	}
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	r := NewDefaultRegistry()
+
+	var tests = []struct {
+		name   string
+		data   string
+		wanted Task
+		err    error
+	}{
+		{"params by field name", `{"type":"sleep","params":{"milliseconds":5}}`, SleepTask{Milliseconds: 5}, nil},
+		{"default params", `{"type":"print","status":"pending"}`, PrintTask{}.SetStatus(StatusPending), nil},
+		{"alias", `{"type":"task.SleepTask","status":2,"params":{"Milliseconds":5}}`, SleepTask{Milliseconds: 5}.SetStatus(StatusCompleted), nil},
+		{"unknown type", `{"type":"task.FooTask"}`, nil, ErrTypeUnknown},
+		{"unknown param", `{"type":"sleep","params":{"seconds":5}}`, nil, ErrInvalidParams},
+		{"invalid param", `{"type":"print","params":{"message":5}}`, nil, ErrInvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Unmarshal([]byte(tt.data))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, expected %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(result, tt.wanted) {
+				t.Errorf("got %#v, expected %#v", result, tt.wanted)
+			}
+		})
+	}
+
+	if _, err := r.Unmarshal([]byte(`{"type":"sleep","status":"sleeping"}`)); err == nil || !strings.Contains(err.Error(), "sleeping") {
+		t.Errorf("got error %v, expected an unknown status", err)
+	}
+}
+
+// upperCodec stores the message of a PrintTask as a plain string in upper case
+type upperCodec struct{}
+
+func (upperCodec) Encode(t Task) (json.RawMessage, error) {
+	return json.Marshal(strings.ToUpper(t.(PrintTask).Message))
+}
+
+func (upperCodec) Decode(t Task, params json.RawMessage) (Task, error) {
+	p := t.(PrintTask)
+	err := json.Unmarshal(params, &p.Message)
+	return p, err
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("print", func() Task { return PrintTask{} }, upperCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("print", func() Task { return PrintTask{} }, nil); !errors.Is(err, ErrTypeExist) {
+		t.Errorf("got error %v, expected ErrTypeExist", err)
+	}
+	if err := r.Register("pause", func() Task { return SleepTask{} }, nil); err == nil {
+		t.Error("expected an error for a factory of another type")
+	}
+	if err := r.Alias("echo", "print"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Alias("echo", "print"); !errors.Is(err, ErrTypeExist) {
+		t.Errorf("got error %v, expected ErrTypeExist", err)
+	}
+	if err := r.Alias("pause", "sleep"); !errors.Is(err, ErrTypeUnknown) {
+		t.Errorf("got error %v, expected ErrTypeUnknown", err)
+	}
+	if names := r.Names(); len(names) != 1 || names[0] != "print" || !r.IsRegistered("echo") {
+		t.Errorf("got names %v, expected print with the alias echo", names)
+	}
+
+	data, err := r.Marshal(PrintTask{Message: "hello"})
+	if err != nil || string(data) != `{"type":"print","params":"HELLO"}` {
+		t.Errorf("got %s and error %v, expected the params of the codec", data, err)
+	}
+	result, err := r.Unmarshal([]byte(`{"type":"echo","params":"hi"}`))
+	if err != nil || result != (PrintTask{Message: "hi"}) {
+		t.Errorf("got %#v and error %v, expected a print task", result, err)
+	}
+	if _, err = r.New("sleep"); !errors.Is(err, ErrTypeUnknown) {
+		t.Errorf("got error %v, expected ErrTypeUnknown", err)
+	}
+}
@@ -16,10 +16,6 @@
 
 package task
 
-import (
-	"reflect"
-)
-
 type SleepTask struct {
 	Milliseconds int
 	status       Status
@@ -34,7 +30,7 @@ func (t SleepTask) Status() Status {
 }
 
 func (t SleepTask) Type() string {
-	return reflect.TypeOf(t).String()
+	return "sleep"
 }
 
 func (t SleepTask) SetStatus(s Status) Task {
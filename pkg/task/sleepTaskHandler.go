@@ -22,12 +22,15 @@ import (
 )
 
 const (
+	// MaxConcurrentTaskHandlerSleep is the number of tasks executed concurrently by NewDefaultSleepTaskHandler.
+	//
+	// Deprecated: NewDefaultSleepTaskHandler uses DefaultMaxConcurrent, use NewSleepTaskHandler for another limit.
 	MaxConcurrentTaskHandlerSleep = 10000
 )
 
 func NewDefaultSleepTaskHandler() SleepTaskHandler {
 	return SleepTaskHandler{
-		maxConcurrent: MaxConcurrentTaskHandlerSleep,
+		maxConcurrent: DefaultMaxConcurrent,
 	}
 }
 
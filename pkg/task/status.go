@@ -16,6 +16,11 @@
 
 package task
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 type Status int
 
 func (s Status) String() string {
@@ -29,3 +34,38 @@ const (
 	StatusError
 	StatusCanceled
 )
+
+// ParseStatus returns the status with name, as returned by Status.String
+func ParseStatus(name string) (Status, error) {
+	for s := StatusNone; s <= StatusCanceled; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return StatusNone, fmt.Errorf("unknown task status %q", name)
+}
+
+// MarshalJSON encodes the status by its name
+func (s Status) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a status by its name, or by its number as stored by earlier versions
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var n int
+		if json.Unmarshal(data, &n) != nil || n < int(StatusNone) || n > int(StatusCanceled) {
+			return fmt.Errorf("invalid task status %s", data)
+		}
+		*s = Status(n)
+		return nil
+	}
+
+	parsed, err := ParseStatus(name)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
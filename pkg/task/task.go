@@ -20,9 +20,12 @@ import (
 	"log/slog"
 )
 
+// Task is a unit of work of a job. Tasks are values, SetStatus returns a copy of the task with the new status.
 type Task interface {
 	Name() string
 	Status() Status
+	// Type returns the stable name of the type of the task, under which the type is registered with a Registry and
+	// its handler is registered with a HandlerRepository
 	Type() string
 	SetStatus(s Status) Task
 	WriteToPipeline() bool
@@ -43,3 +46,13 @@ func LogErrAttr(err error) slog.Attr {
 		slog.String("message", err.Error()),
 	)
 }
+
+// fail sets the status of t to StatusError without executing it, err is sent to the intercom of the pipeline
+func fail(t Task, pipeline chan *Pipeline, err error) Task {
+	p := <-pipeline
+	if p.Intercom != nil {
+		p.Intercom.Add(NewErrorMessage(err.Error(), t, err), &LogData{Level: slog.LevelError, Attrs: []slog.Attr{LogTaskAttr(t), LogErrAttr(err)}})
+	}
+	pipeline <- p
+	return t.SetStatus(StatusError)
+}
@@ -17,7 +17,6 @@
 package task
 
 import (
-	"reflect"
 	"time"
 )
 
@@ -35,7 +34,7 @@ func (t TimeLogTask) Status() Status {
 }
 
 func (t TimeLogTask) Type() string {
-	return reflect.TypeOf(t).String()
+	return "timelog"
 }
 
 func (t TimeLogTask) SetStatus(s Status) Task {
@@ -17,16 +17,20 @@
 package task
 
 import (
+	"log/slog"
 	"time"
 )
 
 const (
+	// MaxConcurrentTaskHandlerTimeLog is the number of tasks executed concurrently by NewDefaultTimeLogTaskHandler.
+	//
+	// Deprecated: NewDefaultTimeLogTaskHandler uses DefaultMaxConcurrent, use NewTimeLogTaskHandler for another limit.
 	MaxConcurrentTaskHandlerTimeLog = 10000
 )
 
 func NewDefaultTimeLogTaskHandler() TimeLogTaskHandler {
 	return TimeLogTaskHandler{
-		maxConcurrency: MaxConcurrentTaskHandlerTimeLog,
+		maxConcurrency: DefaultMaxConcurrent,
 	}
 }
 
@@ -66,7 +70,7 @@ func (h TimeLogTaskHandler) processTask(t TimeLogTask, p *Pipeline) TimeLogTask
 		Type:    LogMessage,
 		Task:    t.Name(),
 		Data:    timestamp,
-	}, nil)
+	}, &LogData{Level: slog.LevelInfo, Attrs: []slog.Attr{LogTaskAttr(t), slog.Time("timestamp", timestamp)}})
 	t.Timestamp = timestamp
 
 	return t
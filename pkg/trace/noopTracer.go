@@ -0,0 +1,51 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package trace
+
+import "context"
+
+// NoopTracer starts spans which are not recorded, it is used when no tracer is configured
+type NoopTracer struct{}
+
+func (t NoopTracer) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// The span context of the parent is kept, so spans of another tracer further down remain part of its trace
+	span := noopSpan{spanContext: SpanFromContext(ctx).SpanContext()}
+	return ContextWithSpan(ctx, span), span
+}
+
+type noopSpan struct {
+	spanContext SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext {
+	return s.spanContext
+}
+
+func (s noopSpan) SetAttributes(attributes ...Attribute) {}
+
+func (s noopSpan) SetStatus(status Status, description string) {}
+
+func (s noopSpan) RecordError(err error) {}
+
+func (s noopSpan) End() {}
+
+func (s noopSpan) Tracer() Tracer {
+	return NoopTracer{}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// NewRecorder returns a tracer which keeps all ended spans in memory, it is meant for tests
+func NewRecorder() *Recorder {
+	return &Recorder{
+		mux: &sync.Mutex{},
+	}
+}
+
+type Recorder struct {
+	spans []SpanData
+	mux   *sync.Mutex
+}
+
+// SpanData is a span which has ended
+type SpanData struct {
+	Name        string
+	SpanContext SpanContext
+	Parent      SpanContext // the span context of the parent span, it is not valid for a root span
+	Attributes  map[string]interface{}
+	Status      Status
+	Description string
+	Errors      []error
+	Start       time.Time
+	End         time.Time
+}
+
+func (r *Recorder) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	parent := SpanFromContext(ctx).SpanContext()
+	sc := SpanContext{TraceId: parent.TraceId}
+	if !parent.IsValid() {
+		_, _ = rand.Read(sc.TraceId[:])
+	}
+	_, _ = rand.Read(sc.SpanId[:])
+
+	span := &recordedSpan{
+		recorder: r,
+		data: SpanData{
+			Name:        name,
+			SpanContext: sc,
+			Parent:      parent,
+			Attributes:  make(map[string]interface{}, len(attributes)),
+			Start:       time.Now(),
+		},
+		mux: &sync.Mutex{},
+	}
+	span.SetAttributes(attributes...)
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the spans which have ended, in the order in which they ended
+func (r *Recorder) Spans() []SpanData {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	spans := make([]SpanData, len(r.spans))
+	copy(spans, r.spans)
+	return spans
+}
+
+func (r *Recorder) record(data SpanData) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.spans = append(r.spans, data)
+}
+
+type recordedSpan struct {
+	recorder *Recorder
+	data     SpanData
+	ended    bool
+	mux      *sync.Mutex
+}
+
+func (s *recordedSpan) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *recordedSpan) SetAttributes(attributes ...Attribute) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, a := range attributes {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(status Status, description string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.data.Status = status
+	if status == StatusError {
+		s.data.Description = description
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.data.Errors = append(s.data.Errors, err)
+}
+
+// End records the span, calling End again has no effect
+func (s *recordedSpan) End() {
+	s.mux.Lock()
+	if s.ended {
+		s.mux.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mux.Unlock()
+
+	s.recorder.record(data)
+}
+
+func (s *recordedSpan) Tracer() Tracer {
+	return s.recorder
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecorder_Start(t *testing.T) {
+	r := NewRecorder()
+
+	ctx, root := r.Start(context.Background(), "root", String("key", "value"))
+	_, child := Start(ctx, "child", Int("count", 1))
+	child.RecordError(errors.New("failed"))
+	child.SetStatus(StatusError, "child failed")
+	child.End()
+	root.SetStatus(StatusOK, "ignored")
+	root.End()
+	root.End()
+
+	spans := r.Spans()
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "root" {
+		t.Fatalf("got spans %+v, expected child and root", spans)
+	}
+	c, p := spans[0], spans[1]
+	if c.Parent != p.SpanContext || c.SpanContext.TraceId != p.SpanContext.TraceId || p.Parent.IsValid() {
+		t.Errorf("got child %s with parent %s, expected a child of root %s", c.SpanContext.SpanId, c.Parent.SpanId, p.SpanContext.SpanId)
+	}
+	if c.Attributes["count"] != 1 || p.Attributes["key"] != "value" {
+		t.Errorf("got attributes %v and %v", c.Attributes, p.Attributes)
+	}
+	if c.Status != StatusError || c.Description != "child failed" || len(c.Errors) != 1 {
+		t.Errorf("got status %s (%s) with errors %v, expected an error", c.Status, c.Description, c.Errors)
+	}
+	if p.Status != StatusOK || p.Description != "" {
+		t.Errorf("got status %s (%s), expected ok without a description", p.Status, p.Description)
+	}
+}
+
+func TestStart_Noop(t *testing.T) {
+	// Without a span in the context, spans are not recorded but keep the span context of the parent
+	ctx, span := Start(context.Background(), "test")
+	if span.SpanContext().IsValid() {
+		t.Errorf("got a valid span context for a span which is not recorded")
+	}
+	if _, ok := span.Tracer().(NoopTracer); !ok {
+		t.Errorf("got tracer %T, expected NoopTracer", span.Tracer())
+	}
+
+	r := NewRecorder()
+	ctx, root := r.Start(ctx, "root")
+	_, noop := NoopTracer{}.Start(ctx, "noop")
+	if noop.SpanContext() != root.SpanContext() {
+		t.Errorf("got span context %v, expected the span context of the parent", noop.SpanContext())
+	}
+}
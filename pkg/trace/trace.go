@@ -0,0 +1,131 @@
+/*
+ * Copyright 2024 CoreLayer BV
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ */
+
+// Package trace defines a small tracing interface for job runs and task executions. Implementations can adapt it to
+// a tracing library such as OpenTelemetry, NoopTracer discards all spans and Recorder keeps them in memory.
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+)
+
+// Attribute keys used by the spans of the scheduler
+const (
+	AttrJobId      = "job.id"
+	AttrJobName    = "job.name"
+	AttrJobRun     = "job.run"
+	AttrJobStatus  = "job.status"
+	AttrTaskType   = "task.type"
+	AttrTaskStatus = "task.status"
+)
+
+// Tracer starts spans
+type Tracer interface {
+	// Start starts a span named name, which is a child of the span in ctx if there is one. The returned context
+	// holds the new span.
+	Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is an operation which is traced, it must be ended by calling End
+type Span interface {
+	SpanContext() SpanContext
+	SetAttributes(attributes ...Attribute)
+	// SetStatus sets the outcome of the operation, description is only used for StatusError
+	SetStatus(status Status, description string)
+	RecordError(err error)
+	End()
+	// Tracer returns the tracer which started the span, it is used to start child spans
+	Tracer() Tracer
+}
+
+// Start starts a span as a child of the span in ctx, using the tracer of that span. Without a span in ctx the new
+// span is not recorded.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	return SpanFromContext(ctx).Tracer().Start(ctx, name, attributes...)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx which holds span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or a span which is not recorded if ctx does not hold a span
+func SpanFromContext(ctx context.Context) Span {
+	if ctx != nil {
+		if span, ok := ctx.Value(spanKey{}).(Span); ok {
+			return span
+		}
+	}
+	return noopSpan{}
+}
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type Status int
+
+func (s Status) String() string {
+	return [...]string{"unset", "ok", "error"}[s]
+}
+
+const (
+	StatusUnset Status = iota
+	StatusOK
+	StatusError
+)
+
+type TraceId [16]byte
+
+func (t TraceId) IsValid() bool {
+	return t != TraceId{}
+}
+
+func (t TraceId) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+type SpanId [8]byte
+
+func (s SpanId) IsValid() bool {
+	return s != SpanId{}
+}
+
+func (s SpanId) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span and the trace it belongs to
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+}
+
+func (c SpanContext) IsValid() bool {
+	return c.TraceId.IsValid() && c.SpanId.IsValid()
+}
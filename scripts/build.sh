@@ -15,4 +15,5 @@
 # *    limitations under the License.
 # */
 
-go build -race -o output/examples/example examples/main.go
\ No newline at end of file
+go build -race -o output/examples/example examples/main.go
+go build -o output/go-scheduler ./cmd/go-scheduler